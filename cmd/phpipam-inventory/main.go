@@ -0,0 +1,78 @@
+// Command phpipam-inventory is an Ansible dynamic inventory script backed by
+// phpIPAM. It implements the --list and --host protocol:
+//
+//	ansible-inventory -i phpipam-inventory --graph
+//	ansible all -i phpipam-inventory -m ping
+//
+// The connection is configured through the environment:
+//
+//	PHPIPAM_URL       phpIPAM base URL, e.g. https://ipam.example.com
+//	PHPIPAM_APP_ID    API application ID
+//	PHPIPAM_USERNAME  phpIPAM username
+//	PHPIPAM_PASSWORD  phpIPAM password
+//	PHPIPAM_INSECURE  set to 1 to skip TLS certificate verification
+//	PHPIPAM_SECTIONS  comma-separated section names or IDs, all when unset
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ansible"
+)
+
+func main() {
+	list := flag.Bool("list", false, "print the whole inventory")
+	host := flag.String("host", "", "print the variables of one host")
+	flag.Parse()
+
+	if !*list && *host == "" {
+		fmt.Fprintln(os.Stderr, "usage: phpipam-inventory --list | --host <hostname>")
+		os.Exit(2)
+	}
+	if err := run(os.Stdout, os.Stderr, *list, *host); err != nil {
+		fmt.Fprintf(os.Stderr, "phpipam-inventory: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run generates the inventory and writes the requested part of it to stdout,
+// with warnings on stderr
+func run(stdout, stderr io.Writer, list bool, host string) error {
+	insecure, _ := strconv.ParseBool(os.Getenv("PHPIPAM_INSECURE"))
+	client, err := phpipam.New(
+		os.Getenv("PHPIPAM_URL"),
+		os.Getenv("PHPIPAM_APP_ID"),
+		os.Getenv("PHPIPAM_USERNAME"),
+		os.Getenv("PHPIPAM_PASSWORD"),
+		insecure,
+	)
+	if err != nil {
+		return err
+	}
+
+	var opts ansible.Options
+	for _, section := range strings.Split(os.Getenv("PHPIPAM_SECTIONS"), ",") {
+		if section = strings.TrimSpace(section); section != "" {
+			opts.Sections = append(opts.Sections, section)
+		}
+	}
+
+	inv, err := ansible.Generate(client, opts)
+	if err != nil {
+		return err
+	}
+	for _, warning := range inv.Warnings {
+		fmt.Fprintf(stderr, "phpipam-inventory: warning: %s\n", warning)
+	}
+
+	if list {
+		return inv.WriteList(stdout)
+	}
+	return inv.WriteHost(stdout, host)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// responses is a phpIPAM with one section holding two hosts that share a
+// hostname, and a Lab section the tests leave out
+var responses = map[string]string{
+	"GET sections":                `{"code":200,"success":true,"data":[{"id":"1","name":"Production"},{"id":"2","name":"Lab"}]}`,
+	"GET vlan":                    `{"code":404,"success":false,"message":"No vlans configured"}`,
+	"GET tools/locations":         `{"code":200,"success":true,"data":[{"id":"4","name":"DC1"}]}`,
+	"GET tools/device_types":      `{"code":404,"success":false,"message":"No results"}`,
+	"GET devices":                 `{"code":404,"success":false,"message":"No results"}`,
+	"GET addresses/custom_fields": `{"code":404,"success":false,"message":"No results"}`,
+	"GET sections/1/subnets":      `{"code":200,"success":true,"data":[{"id":7,"subnet":"10.0.0.0","mask":"24","sectionId":1,"description":"Servers","location":"4"}]}`,
+	"GET subnets/7/addresses": `{"code":200,"success":true,"data":[
+		{"id":10,"subnetId":7,"ip":"10.0.0.10","hostname":"web1","owner":"ops"},
+		{"id":11,"subnetId":7,"ip":"10.0.0.11","hostname":"web1"}]}`,
+}
+
+// setupIPAM starts a fake phpIPAM that accepts the user "ansible" and points
+// the environment at it
+func setupIPAM(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/inventory/"), "/")
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "POST" && endpoint == "user" {
+			if user, password, _ := r.BasicAuth(); user != "ansible" || password != "secret" {
+				t.Errorf("authenticated as %s:%s", user, password)
+			}
+			w.Write([]byte(`{"code":200,"success":true,"data":{"token":"abc","expires":"2099-01-01 00:00:00"}}`))
+			return
+		}
+		body, ok := responses[r.Method+" "+endpoint]
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, endpoint)
+			body = `{"code":400,"success":false,"message":"Invalid request"}`
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("PHPIPAM_URL", srv.URL)
+	t.Setenv("PHPIPAM_APP_ID", "inventory")
+	t.Setenv("PHPIPAM_USERNAME", "ansible")
+	t.Setenv("PHPIPAM_PASSWORD", "secret")
+	t.Setenv("PHPIPAM_INSECURE", "")
+	t.Setenv("PHPIPAM_SECTIONS", " Production, ")
+}
+
+func TestRunList(t *testing.T) {
+	setupIPAM(t)
+
+	var stdout, stderr strings.Builder
+	if err := run(&stdout, &stderr, true, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var list map[string]struct {
+		Hosts    []string `json:"hosts"`
+		Children []string `json:"children"`
+	}
+	if err := json.Unmarshal([]byte(stdout.String()), &list); err != nil {
+		t.Fatalf("--list is not JSON: %v\n%s", err, stdout.String())
+	}
+	var groups []string
+	for name := range list {
+		groups = append(groups, name)
+	}
+	sort.Strings(groups)
+	want := []string{"_meta", "all", "location_dc1", "section_production", "subnet_servers"}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("--list groups %v, want %v", groups, want)
+	}
+	if got := list["subnet_servers"].Hosts; !reflect.DeepEqual(got, []string{"web1"}) {
+		t.Errorf("subnet_servers hosts %v, want [web1]", got)
+	}
+	if got := list["all"].Children; !reflect.DeepEqual(got, want[2:]) {
+		t.Errorf("all children %v, want %v", got, want[2:])
+	}
+
+	// The duplicate hostname is reported on stderr, keeping stdout valid JSON
+	wantWarning := "phpipam-inventory: warning: 10.0.0.11: hostname web1 is already used by 10.0.0.10\n"
+	if stderr.String() != wantWarning {
+		t.Errorf("stderr = %q, want %q", stderr.String(), wantWarning)
+	}
+}
+
+func TestRunHost(t *testing.T) {
+	setupIPAM(t)
+
+	tests := []struct {
+		host string
+		want map[string]interface{}
+	}{
+		{host: "web1", want: map[string]interface{}{
+			"ansible_host":       "10.0.0.10",
+			"phpipam_address_id": 10.0,
+			"phpipam_ip":         "10.0.0.10",
+			"phpipam_location":   "DC1",
+			"phpipam_owner":      "ops",
+			"phpipam_section":    "Production",
+			"phpipam_subnet":     "10.0.0.0/24",
+		}},
+		{host: "db1", want: map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		if err := run(&stdout, &stderr, false, tt.host); err != nil {
+			t.Fatal(err)
+		}
+		var vars map[string]interface{}
+		if err := json.Unmarshal([]byte(stdout.String()), &vars); err != nil {
+			t.Fatalf("--host %s is not JSON: %v\n%s", tt.host, err, stdout.String())
+		}
+		if !reflect.DeepEqual(vars, tt.want) {
+			t.Errorf("--host %s = %v, want %v", tt.host, vars, tt.want)
+		}
+	}
+}
+
+func TestRunError(t *testing.T) {
+	setupIPAM(t)
+	t.Setenv("PHPIPAM_SECTIONS", "Staging")
+
+	var stdout, stderr strings.Builder
+	err := run(&stdout, &stderr, true, "")
+	if err == nil || err.Error() != `unknown section "Staging"` {
+		t.Errorf("err = %v, want the unknown section", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("wrote %q on failure", stdout.String())
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/whogan00/phpipam-go-sdk
 
 go 1.21
+
+require gopkg.in/yaml.v3 v3.0.1
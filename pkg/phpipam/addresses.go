@@ -2,8 +2,11 @@ package phpipam
 
 import (
 	"fmt"
+	"net/netip"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Address represents a phpIPAM address object
@@ -27,20 +30,55 @@ type Address struct {
 	EditDate    string `json:"editDate,omitempty"`
 }
 
-// Tag represents an IP address tag
-type Tag struct {
-	ID          int    `json:"id,omitempty"`
-	Type        string `json:"type,omitempty"`
-	ShowTag     int    `json:"showtag,omitempty"`
-	BgColor     string `json:"bgcolor,omitempty"`
-	FgColor     string `json:"fgcolor,omitempty"`
-	DisplayName string `json:"displayname,omitempty"`
-	Description string `json:"description,omitempty"`
+// LastSeenLayout is the format of Address.LastSeen
+const LastSeenLayout = "2006-01-02 15:04:05"
+
+// ParseLastSeen parses an Address.LastSeen value in the server's time zone, see
+// Client.TimeZone. Addresses that were never seen fail to parse.
+func (c *Client) ParseLastSeen(value string) (time.Time, error) {
+	return time.ParseInLocation(LastSeenLayout, value, c.location())
+}
+
+// FormatLastSeen formats a time as an Address.LastSeen value in the server's
+// time zone
+func (c *Client) FormatLastSeen(t time.Time) string {
+	return t.In(c.location()).Format(LastSeenLayout)
+}
+
+// location returns the server's time zone
+func (c *Client) location() *time.Location {
+	if c.TimeZone == nil {
+		return time.Local
+	}
+	return c.TimeZone
+}
+
+// Addr returns the IP of the address as a netip.Addr
+func (a *Address) Addr() (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(a.IP))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid address %q: %w", a.IP, err)
+	}
+	return addr.Unmap(), nil
+}
+
+// AddressAddrs returns the IPs of the given addresses
+func AddressAddrs(addresses []Address) ([]netip.Addr, error) {
+	addrs := make([]netip.Addr, 0, len(addresses))
+	for i := range addresses {
+		addr, err := addresses[i].Addr()
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
 }
 
 // AddressesService handles communication with the addresses related methods of the API
 type AddressesService struct {
 	client *Client
+	tags   tagCache
 }
 
 // NewAddressesService creates a new addresses service with the provided client
@@ -171,6 +209,9 @@ func (a *AddressesService) Create(address *Address) (*Address, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the address data, retrieve the full address
 	if resp.ID != 0 && createdAddress.ID == 0 {
@@ -187,6 +228,9 @@ func (a *AddressesService) CreateFirstFree(subnetID int, address *Address) (*Add
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the address data, retrieve the full address
 	if resp.ID != 0 && createdAddress.ID == 0 {
@@ -207,21 +251,49 @@ func (a *AddressesService) Update(address *Address) (*Address, error) {
 	return &updatedAddress, err
 }
 
+// Patch sends a partial update for an address, including fields set to zero or empty
+func (a *AddressesService) Patch(id int, patch *AddressPatch) error {
+	if id == 0 {
+		return fmt.Errorf("address ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("address patch contains no fields")
+	}
+
+	resp, err := a.client.Request("PATCH", fmt.Sprintf("addresses/%d", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to patch address %d: %s", id, resp.Message)
+	}
+	return nil
+}
+
 // Delete deletes an address
 func (a *AddressesService) Delete(id int) error {
-	_, err := a.client.Request("DELETE", fmt.Sprintf("addresses/%d", id), nil, nil)
-	return err
+	resp, err := a.client.Request("DELETE", fmt.Sprintf("addresses/%d", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // DeleteWithRemoveDNS deletes an address and removes all related DNS records
 func (a *AddressesService) DeleteWithRemoveDNS(id int) error {
 	params := map[string]string{"remove_dns": "1"}
-	_, err := a.client.Request("DELETE", fmt.Sprintf("addresses/%d", id), params, nil)
-	return err
+	resp, err := a.client.Request("DELETE", fmt.Sprintf("addresses/%d", id), params, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // DeleteByIPAndSubnet deletes an address by IP in a specific subnet
 func (a *AddressesService) DeleteByIPAndSubnet(ip string, subnetID int) error {
-	_, err := a.client.Request("DELETE", fmt.Sprintf("addresses/%s/%d/", ip, subnetID), nil, nil)
-	return err
+	resp, err := a.client.Request("DELETE", fmt.Sprintf("addresses/%s/%d/", ip, subnetID), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
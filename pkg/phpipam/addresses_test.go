@@ -0,0 +1,36 @@
+package phpipam
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastSeenTimeZone(t *testing.T) {
+	berlin := time.FixedZone("CEST", 2*60*60)
+	seen := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		zone *time.Location
+		want string
+	}{
+		{time.UTC, "2024-05-01 10:00:00"},
+		{berlin, "2024-05-01 12:00:00"},
+	}
+	for _, tt := range tests {
+		c := &Client{TimeZone: tt.zone}
+		if got := c.FormatLastSeen(seen); got != tt.want {
+			t.Errorf("FormatLastSeen in %s = %q, want %q", tt.zone, got, tt.want)
+		}
+		parsed, err := c.ParseLastSeen(tt.want)
+		if err != nil || !parsed.Equal(seen) {
+			t.Errorf("ParseLastSeen(%q) in %s = %s, %v, want %s", tt.want, tt.zone, parsed, err, seen)
+		}
+	}
+
+	if got, want := (&Client{}).FormatLastSeen(seen), seen.In(time.Local).Format(LastSeenLayout); got != want {
+		t.Errorf("FormatLastSeen without a time zone = %q, want local time %q", got, want)
+	}
+	if _, err := (&Client{}).ParseLastSeen("0000-00-00 00:00:00"); err == nil {
+		t.Error("ParseLastSeen of a never seen address succeeded")
+	}
+}
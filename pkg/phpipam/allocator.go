@@ -0,0 +1,195 @@
+package phpipam
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// ErrInsufficientSpace is returned when a subnet has not enough free addresses for an allocation
+var ErrInsufficientSpace = errors.New("not enough free addresses")
+
+// defaultMaxRetries is how often allocators retry after a conflict by default
+const defaultMaxRetries = 3
+
+// AllocationRequest describes a set of addresses to reserve in a subnet
+type AllocationRequest struct {
+	// SubnetID is the subnet to allocate from
+	SubnetID int
+	// Count is the number of addresses to reserve
+	Count int
+	// Contiguous requires the addresses to form a single consecutive block
+	Contiguous bool
+	// Within restricts the allocation to these ranges; empty means the whole subnet
+	Within []ipcalc.Range
+	// Exclude lists ranges that must not be allocated
+	Exclude []ipcalc.Range
+	// Template holds the fields set on every created address; ID, IP and
+	// SubnetID are filled in by the allocator
+	Template Address
+}
+
+// Allocator reserves several addresses in a subnet at once. Free addresses are
+// calculated locally; when another client takes a chosen address first the
+// allocation is retried, and addresses created by a failed allocation are
+// deleted again.
+type Allocator struct {
+	subnets   *SubnetsService
+	addresses *AddressesService
+
+	// MaxRetries is how many times an allocation is retried after a conflict
+	MaxRetries int
+}
+
+// NewAllocator creates a new address allocator using the provided services
+func NewAllocator(subnets *SubnetsService, addresses *AddressesService) *Allocator {
+	return &Allocator{
+		subnets:    subnets,
+		addresses:  addresses,
+		MaxRetries: defaultMaxRetries,
+	}
+}
+
+// NewAllocator creates a new address allocator
+func (p *PHPIPAM) NewAllocator() *Allocator {
+	return NewAllocator(p.Subnets, p.Addresses)
+}
+
+// Allocate reserves the requested addresses and returns the created records.
+// Either all addresses are created or, on failure, none are left behind.
+func (a *Allocator) Allocate(req AllocationRequest) ([]Address, error) {
+	if req.SubnetID == 0 {
+		return nil, fmt.Errorf("subnet ID is required for allocation")
+	}
+	if req.Count <= 0 {
+		return nil, fmt.Errorf("allocation count must be positive")
+	}
+
+	var created []Address
+	for attempt := 0; ; attempt++ {
+		free, err := a.subnets.GetFreeSpace(req.SubnetID)
+		if err != nil {
+			return nil, a.rollback(created, err)
+		}
+
+		candidates, err := pickAddresses(restrictRanges(free.Ranges, req.Within, req.Exclude), req.Count-len(created), req.Contiguous)
+		if err != nil {
+			return nil, a.rollback(created, fmt.Errorf("subnet %d: %w", req.SubnetID, err))
+		}
+
+		conflict := false
+		for _, ip := range candidates {
+			address := req.Template
+			address.ID = 0
+			address.IP = ip.String()
+			address.SubnetID = req.SubnetID
+
+			createdAddress, err := a.addresses.Create(&address)
+			if err != nil {
+				if IsConflict(err) && attempt < a.MaxRetries {
+					conflict = true
+					break
+				}
+				return nil, a.rollback(created, fmt.Errorf("failed to create address %s: %w", ip, err))
+			}
+			if createdAddress.IP == "" {
+				createdAddress.IP = address.IP
+				createdAddress.SubnetID = address.SubnetID
+			}
+			created = append(created, *createdAddress)
+		}
+
+		if !conflict {
+			return created, nil
+		}
+
+		// A contiguous block has to be chosen again as a whole
+		if req.Contiguous && len(created) > 0 {
+			if err := a.rollback(created, nil); err != nil {
+				return nil, err
+			}
+			created = nil
+		}
+	}
+}
+
+// rollback deletes addresses created by a failed allocation and returns the
+// original error, extended with any failure to delete
+func (a *Allocator) rollback(created []Address, cause error) error {
+	var errs []error
+	if cause != nil {
+		errs = append(errs, cause)
+	}
+	for _, address := range created {
+		var err error
+		if address.ID != 0 {
+			err = a.addresses.Delete(address.ID)
+		} else {
+			err = a.addresses.DeleteByIPAndSubnet(address.IP, address.SubnetID)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rollback of address %s failed: %w", address.IP, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// restrictRanges limits free ranges to the allowed ranges and removes excluded
+// ones. The allowed ranges are merged first so that overlapping ones do not
+// yield an address twice and the result stays in ascending order.
+func restrictRanges(free, within, exclude []ipcalc.Range) []ipcalc.Range {
+	restricted := free
+	if len(within) > 0 {
+		within = ipcalc.Merge(within)
+		restricted = nil
+		for _, r := range free {
+			for _, w := range within {
+				if !r.Overlaps(w) {
+					continue
+				}
+				part := r
+				if part.First.Less(w.First) {
+					part.First = w.First
+				}
+				if w.Last.Less(part.Last) {
+					part.Last = w.Last
+				}
+				restricted = append(restricted, part)
+			}
+		}
+	}
+
+	if len(exclude) == 0 {
+		return restricted
+	}
+	var result []ipcalc.Range
+	for _, r := range restricted {
+		result = append(result, ipcalc.Subtract(r, exclude)...)
+	}
+	return result
+}
+
+// pickAddresses chooses count addresses from the free ranges, lowest first
+func pickAddresses(free []ipcalc.Range, count int, contiguous bool) ([]netip.Addr, error) {
+	var picked []netip.Addr
+	for _, r := range free {
+		if contiguous {
+			// Ranges too large for an int64 always fit
+			if size := r.Size(); size.IsInt64() && size.Int64() < int64(count) {
+				continue
+			}
+		}
+		for ip := r.First; len(picked) < count; ip = ip.Next() {
+			picked = append(picked, ip)
+			if ip == r.Last {
+				break
+			}
+		}
+		if len(picked) == count {
+			return picked, nil
+		}
+	}
+	return nil, ErrInsufficientSpace
+}
@@ -0,0 +1,217 @@
+package phpipam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAddresses is a phpIPAM server holding the addresses of subnet 7,
+// 10.0.0.0/29. taken is called for every create and reports whether another
+// client got the address first; failDeletes makes every delete fail.
+type fakeAddresses struct {
+	mu          sync.Mutex
+	t           *testing.T
+	nextID      int
+	addresses   map[int]string
+	deleted     []string
+	taken       func(ip string, creates int) bool
+	creates     int
+	failDeletes bool
+}
+
+func newFakeAddresses(t *testing.T, taken func(ip string, creates int) bool) (*fakeAddresses, *PHPIPAM) {
+	f := &fakeAddresses{t: t, nextID: 100, addresses: map[int]string{}, taken: taken}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	api, err := NewTokenClient(srv.URL+"/api/", "test", "token", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, api
+}
+
+func (f *fakeAddresses) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/test/"), "/")
+	reply := func(body string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+
+	switch {
+	case r.Method == "GET" && endpoint == "subnets/7":
+		reply(`{"code":200,"success":true,"data":{"id":7,"subnet":"10.0.0.0","mask":"29","sectionId":1}}`)
+	case r.Method == "GET" && endpoint == "subnets/7/slaves":
+		reply(`{"code":404,"success":false,"message":"No slaves"}`)
+	case r.Method == "GET" && endpoint == "subnets/7/addresses":
+		if len(f.addresses) == 0 {
+			reply(notFound)
+			return
+		}
+		var list []Address
+		for id, ip := range f.addresses {
+			list = append(list, Address{ID: id, SubnetID: 7, IP: ip})
+		}
+		data, _ := json.Marshal(list)
+		reply(`{"code":200,"success":true,"data":` + string(data) + `}`)
+	case r.Method == "GET" && strings.HasPrefix(endpoint, "addresses/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(endpoint, "addresses/"))
+		ip, ok := f.addresses[id]
+		if !ok {
+			reply(notFound)
+			return
+		}
+		reply(fmt.Sprintf(`{"code":200,"success":true,"data":{"id":%d,"subnetId":7,"ip":"%s"}}`, id, ip))
+	case r.Method == "POST" && endpoint == "addresses":
+		var address Address
+		if err := json.NewDecoder(r.Body).Decode(&address); err != nil {
+			f.t.Errorf("create: %v", err)
+		}
+		f.creates++
+		for _, ip := range f.addresses {
+			if ip == address.IP {
+				f.t.Errorf("create of %s, which is already taken", address.IP)
+			}
+		}
+		f.nextID++
+		if f.taken != nil && f.taken(address.IP, f.creates) {
+			f.addresses[f.nextID] = address.IP
+			reply(fmt.Sprintf(`{"code":409,"success":false,"message":"IP address %s already exists"}`, address.IP))
+			return
+		}
+		f.addresses[f.nextID] = address.IP
+		reply(fmt.Sprintf(`{"code":201,"success":true,"message":"Address created","id":"%d"}`, f.nextID))
+	case r.Method == "DELETE" && strings.HasPrefix(endpoint, "addresses/"):
+		if f.failDeletes {
+			reply(`{"code":500,"success":false,"message":"Failed to delete address"}`)
+			return
+		}
+		id, _ := strconv.Atoi(strings.TrimPrefix(endpoint, "addresses/"))
+		f.deleted = append(f.deleted, f.addresses[id])
+		delete(f.addresses, id)
+		reply(`{"code":200,"success":true,"message":"Address deleted"}`)
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, endpoint)
+		reply(`{"code":400,"success":false,"message":"Invalid request"}`)
+	}
+}
+
+// ips returns the addresses held by the server, sorted
+func (f *fakeAddresses) ips() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ips []string
+	for _, ip := range f.addresses {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+func addressIPs(addresses []Address) string {
+	ips := make([]string, len(addresses))
+	for i, address := range addresses {
+		ips[i] = address.IP
+	}
+	return strings.Join(ips, ",")
+}
+
+func TestAllocateRetriesConflicts(t *testing.T) {
+	tests := []struct {
+		name       string
+		contiguous bool
+		taken      string
+		want       string
+		deleted    string
+	}{
+		{"first candidate taken", false, "10.0.0.1", "10.0.0.2,10.0.0.3", ""},
+		{"second candidate taken keeps the first", false, "10.0.0.2", "10.0.0.1,10.0.0.3", ""},
+		{"contiguous block is chosen again", true, "10.0.0.2", "10.0.0.3,10.0.0.4", "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, api := newFakeAddresses(t, func(ip string, creates int) bool { return ip == tt.taken })
+
+			created, err := api.NewAllocator().Allocate(AllocationRequest{SubnetID: 7, Count: 2, Contiguous: tt.contiguous})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := addressIPs(created); got != tt.want {
+				t.Errorf("allocated %s, want %s", got, tt.want)
+			}
+			for _, address := range created {
+				if address.ID == 0 || address.SubnetID != 7 {
+					t.Errorf("created address %+v lacks ID or subnet", address)
+				}
+			}
+			if got := strings.Join(f.deleted, ","); got != tt.deleted {
+				t.Errorf("deleted %s, want %s", got, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestAllocateRetriesExhausted(t *testing.T) {
+	// Every create after the first loses the race
+	f, api := newFakeAddresses(t, func(ip string, creates int) bool { return creates > 1 })
+	allocator := api.NewAllocator()
+	allocator.MaxRetries = 2
+
+	_, err := allocator.Allocate(AllocationRequest{SubnetID: 7, Count: 2})
+	if !IsConflict(err) {
+		t.Fatalf("err = %v, want a conflict", err)
+	}
+	if f.creates != 4 {
+		t.Errorf("%d creates, want 4 (the first and one per attempt)", f.creates)
+	}
+	if got := strings.Join(f.deleted, ","); got != "10.0.0.1" {
+		t.Errorf("deleted %s, want the address created before the conflicts", got)
+	}
+	if got := strings.Join(f.ips(), ","); got != "10.0.0.2,10.0.0.3,10.0.0.4" {
+		t.Errorf("left %s, want only the addresses of the other client", got)
+	}
+}
+
+func TestAllocateRollbackFailure(t *testing.T) {
+	f, api := newFakeAddresses(t, func(ip string, creates int) bool { return creates > 2 })
+	f.failDeletes = true
+	allocator := api.NewAllocator()
+	allocator.MaxRetries = 0
+
+	_, err := allocator.Allocate(AllocationRequest{SubnetID: 7, Count: 3})
+	if err == nil {
+		t.Fatal("allocation succeeded")
+	}
+	if !IsConflict(err) {
+		t.Errorf("err = %v, want it to keep the conflict", err)
+	}
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		if !strings.Contains(err.Error(), "rollback of address "+ip+" failed") {
+			t.Errorf("err = %v, want it to report the failed rollback of %s", err, ip)
+		}
+	}
+}
+
+func TestAllocateInsufficientSpace(t *testing.T) {
+	f, api := newFakeAddresses(t, nil)
+
+	if _, err := api.NewAllocator().Allocate(AllocationRequest{SubnetID: 7, Count: 7}); !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("err = %v, want ErrInsufficientSpace", err)
+	}
+	if f.creates != 0 {
+		t.Errorf("%d creates for an allocation that cannot fit", f.creates)
+	}
+}
@@ -0,0 +1,321 @@
+package ansible
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+)
+
+// Group kinds, the prefixes of generated group names
+const (
+	KindSection    = "section"
+	KindSubnet     = "subnet"
+	KindVLAN       = "vlan"
+	KindLocation   = "location"
+	KindDeviceType = "device_type"
+)
+
+// Options controls what Generate reads
+type Options struct {
+	// Sections limits the inventory to the sections with these names or IDs;
+	// all sections when empty
+	Sections []string
+}
+
+// generator holds the lookups Generate builds once per inventory
+type generator struct {
+	api          *phpipam.PHPIPAM
+	inv          *Inventory
+	vlans        map[int]string // VLAN ID to number
+	locations    map[int]string // location ID to name
+	devices      map[int]device
+	customFields []string
+	hosts        map[string]string // hostname to the IP that claimed it
+}
+
+// device is what the inventory needs from a phpIPAM device
+type device struct {
+	hostname string
+	kind     string
+	location int
+}
+
+// Generate reads the addresses of the selected sections and returns the
+// inventory of those with a hostname. Each host joins the groups of its
+// section, subnet (by description, or prefix when there is none), VLAN
+// number, location and device type. The location is the address's own, or
+// that of its device or subnet. When two addresses share a hostname the first
+// is kept and the other reported in Inventory.Warnings.
+func Generate(api *phpipam.PHPIPAM, opts Options) (*Inventory, error) {
+	sections, err := api.Sections.List()
+	if err != nil {
+		return nil, fmt.Errorf("list sections: %w", err)
+	}
+	selected, err := selectSections(sections, opts.Sections)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{api: api, inv: NewInventory(), hosts: make(map[string]string)}
+	if err := g.lookups(); err != nil {
+		return nil, err
+	}
+	for _, section := range selected {
+		subnets, err := api.Sections.GetSubnets(section.ID)
+		if err != nil {
+			return nil, fmt.Errorf("section %s: %w", section.Name, err)
+		}
+		sort.SliceStable(subnets, func(i, j int) bool {
+			a, _ := subnets[i].Prefix()
+			b, _ := subnets[j].Prefix()
+			return a.Addr().Less(b.Addr()) || a.Addr() == b.Addr() && a.Bits() < b.Bits()
+		})
+		for i := range subnets {
+			if subnets[i].IsFolder == 1 {
+				continue
+			}
+			if err := g.subnet(section, &subnets[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return g.inv, nil
+}
+
+// selectSections returns the sections named by names, or all of them
+func selectSections(sections []phpipam.Section, names []string) ([]phpipam.Section, error) {
+	if len(names) == 0 {
+		return sections, nil
+	}
+	var selected []phpipam.Section
+	for _, name := range names {
+		found := false
+		for _, s := range sections {
+			if s.ID == name || strings.EqualFold(s.Name, name) {
+				selected = append(selected, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown section %q", name)
+		}
+	}
+	return selected, nil
+}
+
+// lookups reads the VLANs, locations, devices, device types and address custom
+// fields. VLANs, locations, devices and device types are left empty on servers
+// that do not provide them.
+func (g *generator) lookups() error {
+	vlans, err := g.api.VLANs.List()
+	if err := optional(err); err != nil {
+		return fmt.Errorf("list VLANs: %w", err)
+	}
+	g.vlans = make(map[int]string, len(vlans))
+	for _, v := range vlans {
+		if id, err := strconv.Atoi(v.ID); err == nil {
+			g.vlans[id] = v.Number
+		}
+	}
+
+	g.locations = make(map[int]string)
+	locations, err := g.api.Tools.GetLocations()
+	if err := optional(err); err != nil {
+		return fmt.Errorf("list locations: %w", err)
+	}
+	for _, l := range locations {
+		if id, err := strconv.Atoi(l.ID); err == nil {
+			g.locations[id] = l.Name
+		}
+	}
+
+	types, err := g.api.Tools.GetDeviceTypes()
+	if err := optional(err); err != nil {
+		return fmt.Errorf("list device types: %w", err)
+	}
+	typeNames := make(map[string]string, len(types))
+	for _, t := range types {
+		typeNames[t.ID] = t.Name
+	}
+	// Device does not carry its type, so read the raw objects
+	var devices []map[string]interface{}
+	_, err = g.api.Client.Request("GET", "devices", nil, &devices)
+	if err := optional(err); err != nil {
+		return fmt.Errorf("list devices: %w", err)
+	}
+	g.devices = make(map[int]device, len(devices))
+	for _, d := range devices {
+		id, err := strconv.Atoi(rawString(d, "id"))
+		if err != nil {
+			continue
+		}
+		location, _ := strconv.Atoi(rawString(d, "location"))
+		g.devices[id] = device{
+			hostname: rawString(d, "hostname"),
+			kind:     typeNames[rawString(d, "type")],
+			location: location,
+		}
+	}
+
+	defined, err := g.api.Addresses.GetCustomFields()
+	if err != nil {
+		return fmt.Errorf("list address custom fields: %w", err)
+	}
+	for name := range defined {
+		g.customFields = append(g.customFields, name)
+	}
+	sort.Strings(g.customFields)
+	return nil
+}
+
+// optional returns err unless it reports an endpoint the server does not
+// provide
+func optional(err error) error {
+	if errors.Is(err, phpipam.ErrUnsupported) {
+		return nil
+	}
+	return err
+}
+
+// rawAddress is an address along with its raw API object, which carries the
+// custom fields and location that Address lacks
+type rawAddress struct {
+	phpipam.Address
+	object map[string]interface{}
+}
+
+// UnmarshalJSON decodes both forms of the address. Fields whose type does not
+// fit Address are left at their zero value, as in lenient decoding.
+func (a *rawAddress) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.object); err != nil {
+		return err
+	}
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal(data, &a.Address); err != nil && !errors.As(err, &typeErr) {
+		return err
+	}
+	return nil
+}
+
+// subnet adds the hosts of a subnet
+func (g *generator) subnet(section phpipam.Section, subnet *phpipam.Subnet) error {
+	prefix, err := subnet.Prefix()
+	if err != nil {
+		return fmt.Errorf("subnet %d: %w", subnet.ID, err)
+	}
+	var addresses []rawAddress
+	if _, err := g.api.Client.Request("GET", fmt.Sprintf("subnets/%d/addresses", subnet.ID), nil, &addresses); err != nil {
+		return fmt.Errorf("subnet %s: %w", prefix, err)
+	}
+
+	var gateway string
+	for _, a := range addresses {
+		if a.IsGateway != 0 {
+			if addr, err := a.Addr(); err == nil {
+				gateway = addr.String()
+			}
+			break
+		}
+	}
+
+	groups := []string{GroupName(KindSection, section.Name)}
+	if subnet.Description != "" {
+		groups = append(groups, GroupName(KindSubnet, subnet.Description))
+	} else {
+		groups = append(groups, GroupName(KindSubnet, prefix.String()))
+	}
+	if id, ok := subnet.GetVlanID(); ok {
+		if number, ok := g.vlans[id]; ok && number != "" {
+			groups = append(groups, GroupName(KindVLAN, number))
+		}
+	}
+	subnetLocation, _ := subnet.GetLocationID()
+
+	for _, a := range addresses {
+		hostname := strings.TrimSuffix(strings.TrimSpace(a.Hostname), ".")
+		if hostname == "" {
+			continue
+		}
+		addr, err := a.Addr()
+		if err != nil {
+			return fmt.Errorf("subnet %s: %w", prefix, err)
+		}
+		if ip, ok := g.hosts[hostname]; ok {
+			g.inv.Warnings = append(g.inv.Warnings,
+				fmt.Sprintf("%s: hostname %s is already used by %s", addr, hostname, ip))
+			continue
+		}
+		g.hosts[hostname] = addr.String()
+
+		vars := map[string]interface{}{
+			"ansible_host":           addr.String(),
+			VarPrefix + "ip":         addr.String(),
+			VarPrefix + "subnet":     prefix.String(),
+			VarPrefix + "section":    section.Name,
+			VarPrefix + "address_id": a.ID,
+		}
+		setVar(vars, "mac", a.Mac)
+		setVar(vars, "owner", a.Owner)
+		setVar(vars, "description", a.Description)
+		setVar(vars, "gateway", gateway)
+
+		object := a.object
+		if len(g.customFields) > 0 {
+			custom := make(map[string]interface{})
+			for _, name := range g.customFields {
+				if value := rawString(object, name); value != "" {
+					custom[strings.TrimPrefix(name, "custom_")] = value
+				}
+			}
+			if len(custom) > 0 {
+				vars[VarPrefix+"custom_fields"] = custom
+			}
+		}
+
+		hostGroups := append([]string(nil), groups...)
+		location, _ := strconv.Atoi(rawString(object, "location"))
+		dev, hasDevice := g.devices[a.DeviceID]
+		if hasDevice {
+			setVar(vars, "device", dev.hostname)
+			if dev.kind != "" {
+				hostGroups = append(hostGroups, GroupName(KindDeviceType, dev.kind))
+			}
+			if location == 0 {
+				location = dev.location
+			}
+		}
+		if location == 0 {
+			location = subnetLocation
+		}
+		if name, ok := g.locations[location]; ok {
+			setVar(vars, "location", name)
+			hostGroups = append(hostGroups, GroupName(KindLocation, name))
+		}
+
+		g.inv.AddHost(hostname, vars, hostGroups...)
+	}
+	return nil
+}
+
+// setVar sets a prefixed host variable unless the value is empty
+func setVar(vars map[string]interface{}, name, value string) {
+	if value != "" {
+		vars[VarPrefix+name] = value
+	}
+}
+
+// rawString returns a field of a raw API object as a string, empty when it is
+// missing or null
+func rawString(object map[string]interface{}, name string) string {
+	value, ok := object[name]
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
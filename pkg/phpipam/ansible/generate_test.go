@@ -0,0 +1,219 @@
+package ansible
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+)
+
+const notFound = `{"code":404,"success":false,"message":"No results (filter applied)"}`
+
+// newFakeIPAM serves hand-written phpIPAM responses to GET requests by endpoint
+func newFakeIPAM(t *testing.T, responses map[string]string) *phpipam.PHPIPAM {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/test/"), "/")
+		body, ok := responses[endpoint]
+		if !ok || r.Method != "GET" {
+			t.Errorf("unexpected request %s %s", r.Method, endpoint)
+			body = `{"code":400,"success":false,"message":"Invalid request"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := phpipam.NewTokenClient(srv.URL+"/api/", "test", "token", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+// labResponses returns the responses for a phpIPAM with the Production
+// section holding the Core Servers subnet in VLAN 100 and DC1 London, and an
+// undescribed subnet, a folder, and a Lab section that is not read
+func labResponses() map[string]string {
+	return map[string]string{
+		"sections":                `{"code":200,"success":true,"data":[{"id":"1","name":"Production"},{"id":"2","name":"Lab"}]}`,
+		"vlan":                    `{"code":200,"success":true,"data":[{"id":"3","number":"100"}]}`,
+		"tools/locations":         `{"code":200,"success":true,"data":[{"id":"4","name":"DC1 London"},{"id":"5","name":"DC2"}]}`,
+		"tools/device_types":      `{"code":200,"success":true,"data":[{"id":"1","name":"Switch"}]}`,
+		"devices":                 `{"code":200,"success":true,"data":[{"id":"6","hostname":"sw1","type":"1","location":"5"}]}`,
+		"addresses/custom_fields": `{"code":200,"success":true,"data":{"custom_rack":{"name":"custom_rack","type":"varchar(32)"}}}`,
+		"sections/1/subnets": `{"code":200,"success":true,"data":[
+			{"id":8,"subnet":"10.0.1.0","mask":"24","sectionId":1},
+			{"id":7,"subnet":"10.0.0.0","mask":"24","sectionId":1,"description":"Core Servers","vlanId":"3","location":"4"},
+			{"id":9,"sectionId":1,"description":"Archive","isFolder":1}]}`,
+		"subnets/7/addresses": `{"code":200,"success":true,"data":[
+			{"id":10,"subnetId":7,"ip":"10.0.0.1","hostname":"gw.example.com.","owner":"netops","is_gateway":1},
+			{"id":11,"subnetId":7,"ip":"10.0.0.10","hostname":"db1","mac":"00:1a:2b:3c:4d:5e","deviceId":6,"custom_rack":"R1"},
+			{"id":12,"subnetId":7,"ip":"10.0.0.11","hostname":""}]}`,
+		"subnets/8/addresses": `{"code":200,"success":true,"data":[
+			{"id":13,"subnetId":8,"ip":"10.0.1.5","hostname":"db1"},
+			{"id":14,"subnetId":8,"ip":"10.0.1.6","hostname":"web1","description":"frontend","location":"4","custom_rack":null}]}`,
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	api := newFakeIPAM(t, labResponses())
+
+	inv, err := Generate(api, Options{Sections: []string{"production"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Subnets are read in prefix order, so db1 in 10.0.0.0/24 is kept
+	var b strings.Builder
+	if err := inv.WriteList(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "_meta": {
+    "hostvars": {
+      "db1": {
+        "ansible_host": "10.0.0.10",
+        "phpipam_address_id": 11,
+        "phpipam_custom_fields": {
+          "rack": "R1"
+        },
+        "phpipam_device": "sw1",
+        "phpipam_gateway": "10.0.0.1",
+        "phpipam_ip": "10.0.0.10",
+        "phpipam_location": "DC2",
+        "phpipam_mac": "00:1a:2b:3c:4d:5e",
+        "phpipam_section": "Production",
+        "phpipam_subnet": "10.0.0.0/24"
+      },
+      "gw.example.com": {
+        "ansible_host": "10.0.0.1",
+        "phpipam_address_id": 10,
+        "phpipam_gateway": "10.0.0.1",
+        "phpipam_ip": "10.0.0.1",
+        "phpipam_location": "DC1 London",
+        "phpipam_owner": "netops",
+        "phpipam_section": "Production",
+        "phpipam_subnet": "10.0.0.0/24"
+      },
+      "web1": {
+        "ansible_host": "10.0.1.6",
+        "phpipam_address_id": 14,
+        "phpipam_description": "frontend",
+        "phpipam_ip": "10.0.1.6",
+        "phpipam_location": "DC1 London",
+        "phpipam_section": "Production",
+        "phpipam_subnet": "10.0.1.0/24"
+      }
+    }
+  },
+  "all": {
+    "children": [
+      "device_type_switch",
+      "location_dc1_london",
+      "location_dc2",
+      "section_production",
+      "subnet_10_0_1_0_24",
+      "subnet_core_servers",
+      "vlan_100"
+    ]
+  },
+  "device_type_switch": {
+    "hosts": [
+      "db1"
+    ]
+  },
+  "location_dc1_london": {
+    "hosts": [
+      "gw.example.com",
+      "web1"
+    ]
+  },
+  "location_dc2": {
+    "hosts": [
+      "db1"
+    ]
+  },
+  "section_production": {
+    "hosts": [
+      "db1",
+      "gw.example.com",
+      "web1"
+    ]
+  },
+  "subnet_10_0_1_0_24": {
+    "hosts": [
+      "web1"
+    ]
+  },
+  "subnet_core_servers": {
+    "hosts": [
+      "db1",
+      "gw.example.com"
+    ]
+  },
+  "vlan_100": {
+    "hosts": [
+      "db1",
+      "gw.example.com"
+    ]
+  }
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("--list:\n%s\nwant:\n%s", got, want)
+	}
+
+	wantWarnings := []string{"10.0.1.5: hostname db1 is already used by 10.0.0.10"}
+	if strings.Join(inv.Warnings, "\n") != strings.Join(wantWarnings, "\n") {
+		t.Errorf("Warnings = %q, want %q", inv.Warnings, wantWarnings)
+	}
+}
+
+func TestGenerateUnknownSection(t *testing.T) {
+	api := newFakeIPAM(t, labResponses())
+
+	_, err := Generate(api, Options{Sections: []string{"Production", "Staging"}})
+	if err == nil || err.Error() != `unknown section "Staging"` {
+		t.Errorf("err = %v, want the unknown section", err)
+	}
+}
+
+func TestGenerateOptionalControllers(t *testing.T) {
+	// Servers without locations, device types or devices still produce hosts
+	responses := labResponses()
+	responses["tools/locations"] = `{"code":400,"success":false,"message":"Invalid controller"}`
+	responses["tools/device_types"] = notFound
+	responses["devices"] = notFound
+	responses["addresses/custom_fields"] = notFound
+	api := newFakeIPAM(t, responses)
+
+	inv, err := Generate(api, Options{Sections: []string{"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := inv.WriteHost(&b, "db1"); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "ansible_host": "10.0.0.10",
+  "phpipam_address_id": 11,
+  "phpipam_gateway": "10.0.0.1",
+  "phpipam_ip": "10.0.0.10",
+  "phpipam_mac": "00:1a:2b:3c:4d:5e",
+  "phpipam_section": "Production",
+  "phpipam_subnet": "10.0.0.0/24"
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("--host db1:\n%s\nwant:\n%s", got, want)
+	}
+	for name := range inv.Groups {
+		if strings.HasPrefix(name, KindLocation+"_") || strings.HasPrefix(name, KindDeviceType+"_") {
+			t.Errorf("unexpected group %s", name)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package ansible builds Ansible dynamic inventories from phpIPAM. Addresses
+// with a hostname become hosts, grouped by section, subnet, VLAN, location and
+// device type, with their IP, MAC, owner, subnet gateway and custom fields as
+// host variables.
+//
+//	inv, err := ansible.Generate(client, ansible.Options{})
+//	err = inv.WriteList(os.Stdout) // ansible-inventory --list
+//
+// The cmd/phpipam-inventory program wraps this as an inventory script.
+package ansible
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+// VarPrefix starts the names of the host variables taken from phpIPAM
+const VarPrefix = "phpipam_"
+
+// Group is an Ansible inventory group
+type Group struct {
+	Hosts    []string               `json:"hosts,omitempty"`
+	Vars     map[string]interface{} `json:"vars,omitempty"`
+	Children []string               `json:"children,omitempty"`
+}
+
+// Inventory is an Ansible dynamic inventory
+type Inventory struct {
+	Groups   map[string]*Group
+	HostVars map[string]map[string]interface{}
+	// Warnings lists addresses left out of the inventory, such as duplicate
+	// hostnames
+	Warnings []string
+}
+
+// NewInventory returns an empty inventory
+func NewInventory() *Inventory {
+	return &Inventory{
+		Groups:   make(map[string]*Group),
+		HostVars: make(map[string]map[string]interface{}),
+	}
+}
+
+// AddHost adds a host with its variables to the named groups, creating them
+// as needed. Adding a host again merges its variables and groups.
+func (inv *Inventory) AddHost(name string, vars map[string]interface{}, groups ...string) {
+	hostVars, ok := inv.HostVars[name]
+	if !ok {
+		hostVars = make(map[string]interface{}, len(vars))
+		inv.HostVars[name] = hostVars
+	}
+	for k, v := range vars {
+		hostVars[k] = v
+	}
+
+	for _, group := range groups {
+		g, ok := inv.Groups[group]
+		if !ok {
+			g = &Group{}
+			inv.Groups[group] = g
+		}
+		if !contains(g.Hosts, name) {
+			g.Hosts = append(g.Hosts, name)
+			sort.Strings(g.Hosts)
+		}
+	}
+}
+
+// Host returns the variables of a host, empty for unknown hosts as the
+// --host protocol expects
+func (inv *Inventory) Host(name string) map[string]interface{} {
+	if vars, ok := inv.HostVars[name]; ok {
+		return vars
+	}
+	return map[string]interface{}{}
+}
+
+// MarshalJSON encodes the inventory in the --list format: one object per
+// group, "all" with every group as a child, and the host variables under
+// "_meta" so Ansible does not call --host for each host. A group named "all"
+// is replaced.
+func (inv *Inventory) MarshalJSON() ([]byte, error) {
+	list := make(map[string]interface{}, len(inv.Groups)+2)
+	names := make([]string, 0, len(inv.Groups))
+	for name, group := range inv.Groups {
+		if name == "all" {
+			continue
+		}
+		list[name] = group
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list["all"] = &Group{Children: names}
+	list["_meta"] = map[string]interface{}{"hostvars": inv.HostVars}
+	return json.Marshal(list)
+}
+
+// WriteList writes the inventory as the output of --list
+func (inv *Inventory) WriteList(w io.Writer) error {
+	return writeJSON(w, inv)
+}
+
+// WriteHost writes the variables of a host as the output of --host
+func (inv *Inventory) WriteHost(w io.Writer, name string) error {
+	return writeJSON(w, inv.Host(name))
+}
+
+// writeJSON writes indented JSON followed by a newline
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// GroupName builds a valid Ansible group name from a kind and a value, such
+// as "vlan_10" or "location_dc1_london". The value is lowercased and each run of
+// characters other than letters and digits becomes one underscore.
+func GroupName(kind, value string) string {
+	var b strings.Builder
+	b.WriteString(kind)
+	underscore := true
+	if kind != "" {
+		b.WriteString("_")
+	}
+	for _, r := range strings.ToLower(value) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			underscore = false
+			continue
+		}
+		if !underscore {
+			b.WriteString("_")
+			underscore = true
+		}
+	}
+	return strings.TrimRight(b.String(), "_")
+}
+
+// contains reports whether a sorted list holds a string
+func contains(sorted []string, s string) bool {
+	i := sort.SearchStrings(sorted, s)
+	return i < len(sorted) && sorted[i] == s
+}
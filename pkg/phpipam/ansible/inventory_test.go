@@ -0,0 +1,97 @@
+package ansible
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupName(t *testing.T) {
+	tests := []struct {
+		kind  string
+		value string
+		want  string
+	}{
+		{kind: KindVLAN, value: "10", want: "vlan_10"},
+		{kind: KindLocation, value: "DC1 - London", want: "location_dc1_london"},
+		{kind: KindSubnet, value: "10.0.0.0/24", want: "subnet_10_0_0_0_24"},
+		{kind: KindSubnet, value: "  Core (old)  ", want: "subnet_core_old"},
+		{kind: KindSection, value: "Zürich", want: "section_z_rich"},
+		{kind: "", value: "-Web Servers-", want: "web_servers"},
+	}
+	for _, tt := range tests {
+		if got := GroupName(tt.kind, tt.value); got != tt.want {
+			t.Errorf("GroupName(%q, %q) = %q, want %q", tt.kind, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAddHost(t *testing.T) {
+	inv := NewInventory()
+	inv.AddHost("web2", map[string]interface{}{"a": 1}, "web")
+	inv.AddHost("web1", map[string]interface{}{"a": 1}, "web", "all")
+	// Adding a host again merges its variables and groups
+	inv.AddHost("web1", map[string]interface{}{"b": "x"}, "web", "db")
+
+	var b strings.Builder
+	if err := inv.WriteList(&b); err != nil {
+		t.Fatal(err)
+	}
+	// The "all" group holds every other group and no hosts of its own
+	want := `{
+  "_meta": {
+    "hostvars": {
+      "web1": {
+        "a": 1,
+        "b": "x"
+      },
+      "web2": {
+        "a": 1
+      }
+    }
+  },
+  "all": {
+    "children": [
+      "db",
+      "web"
+    ]
+  },
+  "db": {
+    "hosts": [
+      "web1"
+    ]
+  },
+  "web": {
+    "hosts": [
+      "web1",
+      "web2"
+    ]
+  }
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("--list:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteHost(t *testing.T) {
+	inv := NewInventory()
+	inv.AddHost("web1", map[string]interface{}{"ansible_host": "10.0.0.1"}, "web")
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "web1", want: "{\n  \"ansible_host\": \"10.0.0.1\"\n}\n"},
+		// Unknown hosts get an empty object rather than an error
+		{host: "web9", want: "{}\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := inv.WriteHost(&b, tt.host); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("--host %s = %q, want %q", tt.host, b.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package arp reconciles exported ARP and IPv6 neighbor tables with phpIPAM.
+// Tables are read from Linux "ip neigh" output, Cisco "show ip arp" or
+// "show ipv6 neighbors" output, or CSV. Each entry is matched to its address
+// to fill in or check the MAC, refresh the last seen time and, when the device
+// the table came from is known, record the device and port.
+//
+//	entries, err := arp.Parse(f, arp.FormatCisco)
+//	report, err := arp.Reconcile(client, entries, arp.Options{Device: "core-sw1", DryRun: true})
+//	fmt.Print(report)
+package arp
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// Entry is a neighbor table entry
+type Entry struct {
+	IP  netip.Addr
+	MAC net.HardwareAddr
+	// Interface is the interface the neighbor was learned on
+	Interface string
+	// Device is the name or ID of the device the entry was read from, empty
+	// when the table does not say
+	Device string
+}
+
+// Format is a neighbor table format
+type Format string
+
+const (
+	// FormatIPNeigh is the output of Linux "ip neigh show"
+	FormatIPNeigh Format = "ip-neigh"
+	// FormatCisco is the output of Cisco "show ip arp" or "show ipv6 neighbors"
+	FormatCisco Format = "cisco"
+	// FormatCSV is a CSV file with a header row, see ParseCSV
+	FormatCSV Format = "csv"
+)
+
+// Parse reads a neighbor table in the given format
+func Parse(r io.Reader, format Format) ([]Entry, error) {
+	switch format {
+	case FormatIPNeigh:
+		return ParseIPNeigh(r)
+	case FormatCisco:
+		return ParseCisco(r)
+	case FormatCSV:
+		return ParseCSV(r)
+	}
+	return nil, fmt.Errorf("unknown neighbor table format %q", format)
+}
+
+// ParseIPNeigh reads the output of Linux "ip neigh show" (or "ip -6 neigh").
+// Entries without a link-layer address, such as FAILED and INCOMPLETE ones,
+// are skipped.
+func ParseIPNeigh(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	err := scanLines(r, func(line int, fields []string) error {
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return fmt.Errorf("line %d: invalid address: %w", line, err)
+		}
+		entry := Entry{IP: addr.Unmap()}
+		for i := 1; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "dev":
+				i++
+				entry.Interface = fields[i]
+			case "lladdr":
+				i++
+				if entry.MAC, err = ipcalc.ParseMAC(fields[i]); err != nil {
+					return fmt.Errorf("line %d: invalid lladdr: %w", line, err)
+				}
+			}
+		}
+		if entry.MAC != nil {
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return latestEntries(entries), nil
+}
+
+// ciscoSkip lists the encapsulation and IPv6 neighbor state columns that may
+// sit between the MAC address and the interface
+var ciscoSkip = map[string]bool{
+	"ARPA": true, "SNAP": true, "SAP": true,
+	"REACH": true, "STALE": true, "DELAY": true, "PROBE": true, "INCMP": true,
+}
+
+// ParseCisco reads the output of Cisco IOS "show ip arp" and "show ipv6
+// neighbors", or the NX-OS equivalents. Header and summary lines are ignored,
+// as are incomplete entries.
+func ParseCisco(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	err := scanLines(r, func(line int, fields []string) error {
+		if fields[0] == "Internet" {
+			fields = fields[1:]
+		}
+		// address, age, MAC and optional encapsulation or state, interface
+		if len(fields) < 3 {
+			return nil
+		}
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil
+		}
+		if strings.EqualFold(fields[2], "incomplete") || fields[2] == "-" {
+			return nil
+		}
+		mac, err := ipcalc.ParseMAC(fields[2])
+		if err != nil {
+			return fmt.Errorf("line %d: invalid MAC address: %w", line, err)
+		}
+		entry := Entry{IP: addr.Unmap(), MAC: mac}
+		for _, field := range fields[3:] {
+			if !ciscoSkip[field] {
+				entry.Interface = field
+				break
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return latestEntries(entries), nil
+}
+
+// csvColumns maps accepted CSV header names to Entry fields
+var csvColumns = map[string]string{
+	"ip":              "ip",
+	"ip address":      "ip",
+	"address":         "ip",
+	"mac":             "mac",
+	"mac address":     "mac",
+	"hwaddr":          "mac",
+	"lladdr":          "mac",
+	"hardware addr":   "mac",
+	"interface":       "interface",
+	"port":            "interface",
+	"dev":             "interface",
+	"device":          "device",
+	"source device":   "device",
+	"device id":       "device",
+	"device hostname": "device",
+}
+
+// ParseCSV reads a CSV neighbor table. The header row names the columns:
+// "ip" and "mac" are required, "interface" (or "port") and "device" are
+// optional; case, underscores and dashes in names are ignored. Rows without a
+// MAC are skipped.
+func ParseCSV(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+		name = strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(strings.TrimSpace(name)))
+		if field, ok := csvColumns[name]; ok {
+			if _, dup := columns[field]; !dup {
+				columns[field] = i
+			}
+		}
+	}
+	for _, required := range []string{"ip", "mac"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	var entries []Entry
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return strings.TrimSpace(fields[i])
+			}
+			return ""
+		}
+
+		if get("ip") == "" && get("mac") == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(get("ip"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid address: %w", line, err)
+		}
+		if get("mac") == "" {
+			continue
+		}
+		mac, err := ipcalc.ParseMAC(get("mac"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid MAC address: %w", line, err)
+		}
+		entries = append(entries, Entry{
+			IP:        addr.Unmap(),
+			MAC:       mac,
+			Interface: get("interface"),
+			Device:    get("device"),
+		})
+	}
+	return latestEntries(entries), nil
+}
+
+// scanLines calls fn with the fields of each non-empty line
+func scanLines(r io.Reader, fn func(line int, fields []string) error) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := fn(line, fields); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// latestEntries keeps the last entry of each IP and device and orders them by IP
+func latestEntries(entries []Entry) []Entry {
+	type key struct {
+		ip     netip.Addr
+		device string
+	}
+	latest := make(map[key]int, len(entries))
+	var unique []Entry
+	for _, entry := range entries {
+		k := key{entry.IP, entry.Device}
+		if i, ok := latest[k]; ok {
+			unique[i] = entry
+			continue
+		}
+		latest[k] = len(unique)
+		unique = append(unique, entry)
+	}
+	sort.SliceStable(unique, func(i, j int) bool { return unique[i].IP.Less(unique[j].IP) })
+	return unique
+}
@@ -0,0 +1,82 @@
+package arp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// entries formats entries one per line as IP, MAC, interface and device
+func entries(list []Entry) string {
+	lines := make([]string, len(list))
+	for i, e := range list {
+		lines[i] = fmt.Sprintf("%s %s %s %s", e.IP, e.MAC, e.Interface, e.Device)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		file   string
+		format Format
+		want   []string
+	}{
+		{"ip-neigh.txt", FormatIPNeigh, []string{
+			"10.0.0.1 52:54:00:00:00:01 eth0 ",
+			"10.0.0.7 52:54:00:00:07:07 eth0 ",
+			"2001:db8::20 52:54:00:00:00:20 eth1 ",
+			"fe80::1 52:54:00:00:00:01 eth0 ",
+		}},
+		{"cisco-arp.txt", FormatCisco, []string{
+			"10.0.0.1 52:54:00:00:00:01 Vlan10 ",
+			"10.0.0.7 52:54:00:00:00:07 Vlan10 ",
+			"10.0.1.5 52:54:00:00:01:05 GigabitEthernet1/0/5 ",
+		}},
+		{"cisco-ipv6.txt", FormatCisco, []string{
+			"2001:db8::20 52:54:00:00:00:20 Vl10 ",
+			"fe80::1 52:54:00:00:00:01 Vl10 ",
+		}},
+		{"neighbors.csv", FormatCSV, []string{
+			"10.0.0.1 52:54:00:00:00:01 Vlan10 core-sw1",
+			"10.0.0.1 52:54:00:00:00:01 Vlan10 core-sw2",
+			"10.0.0.20 52:54:00:00:00:20  core-sw1",
+		}},
+	}
+	for _, tt := range tests {
+		f, err := os.Open("testdata/" + tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list, err := Parse(f, tt.format)
+		f.Close()
+		if err != nil {
+			t.Errorf("Parse(%s): %v", tt.file, err)
+			continue
+		}
+		if got, want := entries(list), strings.Join(tt.want, "\n"); got != want {
+			t.Errorf("Parse(%s) =\n%s\nwant\n%s", tt.file, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		format Format
+		want   string
+	}{
+		{"10.0.0.300 dev eth0 lladdr 52:54:00:00:00:01 REACHABLE\n", FormatIPNeigh, "line 1: invalid address"},
+		{"10.0.0.1 dev eth0 lladdr zz REACHABLE\n", FormatIPNeigh, "line 1: invalid lladdr"},
+		{"Internet  10.0.0.1  -  zzzz.zzzz  ARPA  Vlan10\n", FormatCisco, "line 1: invalid MAC address"},
+		{"ip,port\n10.0.0.1,Vlan10\n", FormatCSV, "missing mac column"},
+		{"ip,mac\n10.0.0.1,zz\n", FormatCSV, "line 2: invalid MAC address"},
+		{"", "junos", `unknown neighbor table format "junos"`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.input), tt.format)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q, %s) error = %v, want %q", tt.input, tt.format, err, tt.want)
+		}
+	}
+}
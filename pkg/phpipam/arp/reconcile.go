@@ -0,0 +1,383 @@
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/report"
+)
+
+// Options controls how a neighbor table is reconciled
+type Options struct {
+	// VRF is the VRF whose subnets entries are matched against,
+	// phpipam.GlobalVRF by default
+	VRF int
+	// Device is the name or ID of the device the table was read from, used
+	// for entries that do not name one. Entries with a known device record it
+	// and their interface on the address.
+	Device string
+	// OverwriteMACs replaces MACs that differ from the table; by default they
+	// are reported as conflicts and left alone
+	OverwriteMACs bool
+	// SeenAt is when the table was exported, time.Now when zero
+	SeenAt time.Time
+	// DryRun computes the report without changing anything
+	DryRun bool
+}
+
+// Action is what reconciling did, or would do, with an entry
+type Action string
+
+const (
+	// ActionUpdate updates the entry's address
+	ActionUpdate Action = "update"
+	// ActionUnchanged leaves an address that already matches the entry
+	ActionUnchanged Action = "unchanged"
+	// ActionMissing reports an IP that is seen but not in phpIPAM
+	ActionMissing Action = "missing"
+	// ActionSkip ignores the entry, see Change.Reason
+	ActionSkip Action = "skip"
+	// ActionFailed marks an update phpIPAM rejected
+	ActionFailed Action = "failed"
+)
+
+// FieldDiff is an address field that differs from the table
+type FieldDiff = report.FieldDiff
+
+// Change is the effect of reconciling one entry
+type Change struct {
+	Entry  Entry
+	Action Action
+	// Subnet is the most specific subnet containing the entry
+	Subnet string
+	// AddressID is the entry's address, 0 when it is not in phpIPAM
+	AddressID int
+	Diffs     []FieldDiff
+	// Conflicts lists table values that differ from phpIPAM and were kept
+	Conflicts []FieldDiff
+	// Elsewhere lists other addresses that record the entry's MAC
+	Elsewhere []phpipam.Address
+	// Reason explains skipped entries
+	Reason string
+	Err    error
+}
+
+// Conflicted reports whether the entry's MAC disagrees with phpIPAM, either on
+// its own address or because other addresses record it
+func (c *Change) Conflicted() bool {
+	return len(c.Conflicts) > 0 || len(c.Elsewhere) > 0
+}
+
+// Report lists the effect of reconciling every entry of a table
+type Report struct {
+	DryRun  bool
+	Changes []Change
+}
+
+// Count returns the number of changes with the given action
+func (r *Report) Count(action Action) int {
+	return report.Count(r.Changes, func(c Change) bool { return c.Action == action })
+}
+
+// Conflicts returns the changes whose MAC disagrees with phpIPAM
+func (r *Report) Conflicts() []Change {
+	var conflicts []Change
+	for _, c := range r.Changes {
+		if c.Conflicted() {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts
+}
+
+// Missing returns the changes of IPs that are seen but not in phpIPAM
+func (r *Report) Missing() []Change {
+	var missing []Change
+	for _, c := range r.Changes {
+		if c.Action == ActionMissing {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// Err returns an error describing the failed updates, or nil
+func (r *Report) Err() error {
+	var errs []error
+	for _, c := range r.Changes {
+		if c.Action == ActionFailed {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Entry.IP, c.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Render writes the report in a human-readable form. Unchanged entries without
+// conflicts are left out.
+func (r *Report) Render(w io.Writer) error {
+	return report.Render(w, func(b *strings.Builder) {
+		for _, c := range r.Changes {
+			if c.Action == ActionUnchanged && !c.Conflicted() {
+				continue
+			}
+			fmt.Fprintf(b, "%-9s %s %s", c.Action, c.Entry.IP, c.Entry.MAC)
+			if c.Subnet != "" {
+				fmt.Fprintf(b, " in %s", c.Subnet)
+			}
+			switch {
+			case c.Err != nil:
+				fmt.Fprintf(b, ": %v", c.Err)
+			case c.Reason != "":
+				fmt.Fprintf(b, ": %s", c.Reason)
+			}
+			b.WriteString("\n")
+			report.WriteDiffs(b, c.Diffs, false)
+			report.WriteConflicts(b, c.Conflicts, "table has")
+			for _, a := range c.Elsewhere {
+				fmt.Fprintf(b, "    mac also recorded on %s", a.IP)
+				if a.Hostname != "" {
+					fmt.Fprintf(b, " (%s)", a.Hostname)
+				}
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString(report.Summary("Reconcile", r.DryRun,
+			report.Tally{N: r.Count(ActionUpdate), Label: "updated"},
+			report.Tally{N: r.Count(ActionUnchanged), Label: "unchanged"},
+			report.Tally{N: r.Count(ActionMissing), Label: "not in phpIPAM"},
+			report.Tally{N: len(r.Conflicts()), Label: "MAC conflicts"},
+			report.Tally{N: r.Count(ActionSkip), Label: "skipped"},
+			report.Tally{N: r.Count(ActionFailed), Label: "failed"}))
+	})
+}
+
+// String returns the rendered report
+func (r *Report) String() string {
+	return report.String(r.Render)
+}
+
+// reconciler holds what Reconcile looks up once per table
+type reconciler struct {
+	api     *phpipam.PHPIPAM
+	opts    Options
+	index   *phpipam.SubnetIndex
+	seenAt  time.Time
+	devices map[string]int
+	macs    map[string][]phpipam.Address
+}
+
+// Reconcile matches each entry to its address in the most specific subnet
+// containing it and looks its MAC up with AddressesService.SearchByMAC. Empty
+// MACs are filled in and differing ones reported as conflicts, as are other
+// addresses that record the MAC. IPs phpIPAM does not know are reported as
+// missing; other failed lookups stop the run. Addresses whose MAC matches get
+// their last seen time refreshed and, when the entry's device is known, their
+// device and port set from the device and interface. The returned error
+// summarizes failed updates.
+func Reconcile(api *phpipam.PHPIPAM, entries []Entry, opts Options) (*Report, error) {
+	index, err := api.GetSubnetIndex()
+	if err != nil {
+		return nil, err
+	}
+	seenAt := opts.SeenAt
+	if seenAt.IsZero() {
+		seenAt = time.Now()
+	}
+	rc := &reconciler{
+		api:    api,
+		opts:   opts,
+		index:  index,
+		seenAt: seenAt.Truncate(time.Second),
+		macs:   make(map[string][]phpipam.Address),
+	}
+	if err := rc.resolveDevices(entries); err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: opts.DryRun}
+	for _, entry := range entries {
+		if entry.Device == "" {
+			entry.Device = opts.Device
+		}
+		change, err := rc.entry(entry)
+		if err != nil {
+			return nil, err
+		}
+		report.Changes = append(report.Changes, change)
+	}
+	return report, report.Err()
+}
+
+// resolveDevices maps the device names and IDs of the table to device IDs. An
+// unknown device is an error rather than a silently skipped device and port
+// update.
+func (rc *reconciler) resolveDevices(entries []Entry) error {
+	names := make(map[string]bool)
+	if rc.opts.Device != "" {
+		names[rc.opts.Device] = true
+	}
+	for _, entry := range entries {
+		if entry.Device != "" {
+			names[entry.Device] = true
+		}
+	}
+	rc.devices = make(map[string]int, len(names))
+	if len(names) == 0 {
+		return nil
+	}
+
+	devices, err := rc.api.Devices.List()
+	if err != nil {
+		return fmt.Errorf("list devices: %w", err)
+	}
+	for name := range names {
+		for _, d := range devices {
+			if d.ID == name || strings.EqualFold(d.Hostname, name) {
+				if id, err := strconv.Atoi(d.ID); err == nil {
+					rc.devices[name] = id
+				}
+				break
+			}
+		}
+		if _, ok := rc.devices[name]; !ok {
+			return fmt.Errorf("unknown device %q", name)
+		}
+	}
+	return nil
+}
+
+// elsewhere returns the addresses other than id that record a MAC
+func (rc *reconciler) elsewhere(entry Entry, id int) ([]phpipam.Address, error) {
+	mac := entry.MAC.String()
+	found, ok := rc.macs[mac]
+	if !ok {
+		list, err := rc.api.Addresses.SearchByMAC(mac)
+		if err != nil {
+			return nil, fmt.Errorf("search MAC %s: %w", mac, err)
+		}
+		// the search matches substrings, keep exact matches only
+		for _, a := range list {
+			if m, err := ipcalc.ParseMAC(a.Mac); err == nil && m.String() == mac {
+				found = append(found, a)
+			}
+		}
+		rc.macs[mac] = found
+	}
+
+	var others []phpipam.Address
+	for _, a := range found {
+		if a.ID != id {
+			others = append(others, a)
+		}
+	}
+	return others, nil
+}
+
+// entry reconciles one entry
+func (rc *reconciler) entry(entry Entry) (Change, error) {
+	change := Change{Entry: entry, Action: ActionSkip}
+
+	subnet, ok := rc.index.Lookup(rc.opts.VRF, entry.IP)
+	if !ok {
+		change.Reason = "no subnet contains the address"
+		return change, nil
+	}
+	change.Subnet = subnet.CIDR()
+
+	current, err := rc.address(entry, subnet.ID)
+	if err != nil {
+		return change, fmt.Errorf("address %s: %w", entry.IP, err)
+	}
+	change.AddressID = current.ID
+
+	if change.Elsewhere, err = rc.elsewhere(entry, current.ID); err != nil {
+		return change, err
+	}
+
+	if current.ID == 0 {
+		change.Action = ActionMissing
+		return change, nil
+	}
+	rc.update(current, &change)
+	return change, nil
+}
+
+// address returns the address of an entry in a subnet, with ID 0 when phpIPAM
+// reports that it does not exist. Other failures are errors rather than
+// missing addresses.
+func (rc *reconciler) address(entry Entry, subnetID int) (*phpipam.Address, error) {
+	var address phpipam.Address
+	resp, err := rc.api.Client.Request("GET", fmt.Sprintf("addresses/%s/%d", entry.IP, subnetID), nil, &address)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		if phpipam.IsNotFound(err) {
+			return &phpipam.Address{}, nil
+		}
+		return nil, err
+	}
+	return &address, nil
+}
+
+// update checks the MAC of an address and refreshes its last seen time,
+// device and port. An address whose MAC conflicts with the entry is left
+// alone.
+func (rc *reconciler) update(current *phpipam.Address, change *Change) {
+	entry := change.Entry
+	patch := phpipam.NewAddressPatch()
+	diff := func(field, old, value string) {
+		change.Diffs = append(change.Diffs, FieldDiff{Field: field, Old: old, New: value})
+	}
+
+	mac := entry.MAC.String()
+	old, err := ipcalc.ParseMAC(current.Mac)
+	switch {
+	case err == nil && old.String() == mac:
+	case current.Mac == "" || rc.opts.OverwriteMACs:
+		diff("mac", current.Mac, mac)
+		patch.SetMac(mac)
+	default:
+		change.Conflicts = append(change.Conflicts, FieldDiff{Field: "mac", Old: current.Mac, New: mac})
+		// another host answers on the IP, which says nothing about when the
+		// recorded one was seen or where it is connected
+		change.Action = ActionUnchanged
+		return
+	}
+
+	seen, err := rc.api.Client.ParseLastSeen(current.LastSeen)
+	if err != nil || rc.seenAt.After(seen) {
+		value := rc.api.Client.FormatLastSeen(rc.seenAt)
+		diff("lastSeen", current.LastSeen, value)
+		patch.SetLastSeen(value)
+	}
+
+	if device, ok := rc.devices[entry.Device]; ok {
+		if current.DeviceID != device {
+			diff("deviceId", strconv.Itoa(current.DeviceID), strconv.Itoa(device))
+			patch.SetDeviceID(device)
+		}
+		if entry.Interface != "" && current.Port != entry.Interface {
+			diff("port", current.Port, entry.Interface)
+			patch.SetPort(entry.Interface)
+		}
+	}
+
+	if patch.Len() == 0 {
+		change.Action = ActionUnchanged
+		return
+	}
+	change.Action = ActionUpdate
+	if rc.opts.DryRun {
+		return
+	}
+	if err := rc.api.Addresses.Patch(current.ID, patch); err != nil {
+		change.Action, change.Err = ActionFailed, err
+	}
+}
@@ -0,0 +1,100 @@
+package arp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// reconcile runs Reconcile as a dry run for 10.0.0.5 with MAC 52:54:00:00:00:05
+// against a server answering the address lookup with found
+func reconcile(t *testing.T, found string) (*Report, error) {
+	t.Helper()
+	mac := "52:54:00:00:00:05"
+	responses := map[string]string{
+		"sections":                    `{"code":200,"success":true,"data":[{"id":"1","name":"Main"}]}`,
+		"sections/1/subnets":          `{"code":200,"success":true,"data":[{"id":7,"subnet":"10.0.0.0","mask":"24","sectionId":1}]}`,
+		"addresses/10.0.0.5/7":        found,
+		"addresses/search_mac/" + mac: `{"code":200,"success":false,"message":"No addresses found"}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/test/"), "/")
+		body, ok := responses[endpoint]
+		if !ok || r.Method != "GET" {
+			t.Errorf("unexpected request %s %s", r.Method, endpoint)
+			body = `{"code":400,"success":false,"message":"Invalid request"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := phpipam.NewTokenClient(srv.URL+"/api/", "test", "token", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.Client.TimeZone = time.UTC
+	hw, err := ipcalc.ParseMAC(mac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []Entry{{IP: netip.MustParseAddr("10.0.0.5"), MAC: hw, Interface: "Vlan10"}}
+	return Reconcile(api, entries, Options{SeenAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), DryRun: true})
+}
+
+func TestReconcile(t *testing.T) {
+	address := func(mac string) string {
+		return `{"code":200,"success":true,"data":{"id":105,"subnetId":7,"ip":"10.0.0.5","mac":"` + mac +
+			`","lastSeen":"2024-04-01 08:00:00"}}`
+	}
+	tests := []struct {
+		name      string
+		found     string
+		action    Action
+		diffs     string
+		conflicts string
+	}{
+		{"not found", `{"code":404,"success":false,"message":"No addresses found"}`, ActionMissing, "", ""},
+		{"matching MAC", address("52:54:00:00:00:05"), ActionUpdate, `lastSeen:"2024-04-01 08:00:00"->"2024-05-01 10:00:00"`, ""},
+		{"empty MAC", address(""), ActionUpdate,
+			`mac:""->"52:54:00:00:00:05",lastSeen:"2024-04-01 08:00:00"->"2024-05-01 10:00:00"`, ""},
+		{"conflicting MAC", address("52:54:00:00:00:99"), ActionUnchanged, "", `mac:"52:54:00:00:00:99"->"52:54:00:00:00:05"`},
+	}
+	format := func(diffs []FieldDiff) string {
+		parts := make([]string, len(diffs))
+		for i, d := range diffs {
+			parts[i] = d.Field + ":" + `"` + d.Old + `"->"` + d.New + `"`
+		}
+		return strings.Join(parts, ",")
+	}
+	for _, tt := range tests {
+		report, err := reconcile(t, tt.found)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		c := report.Changes[0]
+		if c.Action != tt.action {
+			t.Errorf("%s: action = %s, want %s", tt.name, c.Action, tt.action)
+		}
+		if got := format(c.Diffs); got != tt.diffs {
+			t.Errorf("%s: diffs = %s, want %s", tt.name, got, tt.diffs)
+		}
+		if got := format(c.Conflicts); got != tt.conflicts {
+			t.Errorf("%s: conflicts = %s, want %s", tt.name, got, tt.conflicts)
+		}
+	}
+}
+
+func TestReconcileLookupFailure(t *testing.T) {
+	_, err := reconcile(t, `{"code":500,"success":false,"message":"Database error"}`)
+	if err == nil || !strings.Contains(err.Error(), "Database error") {
+		t.Errorf("Reconcile error = %v, want the lookup failure", err)
+	}
+}
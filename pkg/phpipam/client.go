@@ -29,6 +29,16 @@ type Client struct {
 	HTTPClient  *http.Client
 	UserAgent   string
 	InsecureTLS bool
+
+	// DecodeMode controls how responses that do not match the models are handled
+	DecodeMode DecodeMode
+	// Diagnostics collects decode issues when non-nil, see EnableDiagnostics
+	Diagnostics *Diagnostics
+	// TimeZone is the time zone of the phpIPAM server, which stores times such
+	// as Address.LastSeen without an offset; time.Local when nil
+	TimeZone *time.Location
+
+	server serverState
 }
 
 // ResponseID is a type that can unmarshal from both string and int JSON values
@@ -209,11 +219,15 @@ func (c *Client) do(req *http.Request, v interface{}) (*Response, error) {
 	apiResp := &Response{}
 	err = json.NewDecoder(resp.Body).Decode(apiResp)
 	if err != nil {
+		// A wrong base URL or a proxy answers with a page that is not JSON
+		if resp.StatusCode >= http.StatusBadRequest {
+			return nil, &statusError{StatusCode: resp.StatusCode, Endpoint: c.endpointKey(req), Err: err}
+		}
 		return nil, err
 	}
 
 	if v != nil && apiResp.Data != nil {
-		err = json.Unmarshal(apiResp.Data, v)
+		err = c.decodeData(req, apiResp.Data, v)
 		if err != nil {
 			return nil, err
 		}
@@ -278,6 +292,10 @@ func (c *Client) Request(method, endpoint string, body, result interface{}) (*Re
 		return nil, err
 	}
 
+	if err := c.checkSupported(endpoint); err != nil {
+		return nil, err
+	}
+
 	req, err := c.newRequest(method, endpoint, body)
 	if err != nil {
 		return nil, err
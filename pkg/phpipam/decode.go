@@ -0,0 +1,407 @@
+package phpipam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DecodeMode controls how response data that does not match the SDK models is handled
+type DecodeMode int
+
+const (
+	// DecodeLenient ignores unknown fields and leaves fields whose JSON type does
+	// not match the model at their zero value. This is the default. A mismatched
+	// ID field still fails the request, as it would otherwise be read as 0.
+	DecodeLenient DecodeMode = iota
+	// DecodeStrict fails a request whose response contains unknown fields or type
+	// mismatches with a *SchemaError, after the data has been decoded.
+	DecodeStrict
+)
+
+// IssueKind describes why a response field did not decode cleanly
+type IssueKind string
+
+const (
+	// IssueUnknownField is reported for a response field the model does not have
+	IssueUnknownField IssueKind = "unknown field"
+	// IssueTypeMismatch is reported for a response field whose JSON type does not fit the model
+	IssueTypeMismatch IssueKind = "type mismatch"
+)
+
+// DecodeIssue describes a single response field that did not decode cleanly
+type DecodeIssue struct {
+	// Endpoint is the request method and path with IDs and addresses replaced
+	// by placeholders, e.g. "GET subnets/{id}/addresses"
+	Endpoint string
+	// Field is the path of the field in the response data, e.g. "[].custom_rack"
+	Field string
+	Kind  IssueKind
+	// Detail describes a type mismatch, e.g. "string into int"
+	Detail string
+	// Count is the number of responses the issue was seen in
+	Count int
+}
+
+// String returns a human readable description of the issue
+func (i DecodeIssue) String() string {
+	s := fmt.Sprintf("%s: %s %s", i.Endpoint, i.Kind, i.Field)
+	if i.Detail != "" {
+		s += " (" + i.Detail + ")"
+	}
+	return s
+}
+
+// SchemaError is returned in strict decode mode when a response does not match
+// the model, and in lenient mode when an ID field does not decode
+type SchemaError struct {
+	Endpoint string
+	Issues   []DecodeIssue
+}
+
+// Error implements the error interface
+func (e *SchemaError) Error() string {
+	fields := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		fields[i] = string(issue.Kind) + " " + issue.Field
+		if issue.Detail != "" {
+			fields[i] += " (" + issue.Detail + ")"
+		}
+	}
+	return fmt.Sprintf("response of %s does not match model: %s", e.Endpoint, strings.Join(fields, ", "))
+}
+
+// Diagnostics collects decode issues across requests, grouped by endpoint.
+// It is safe for concurrent use.
+type Diagnostics struct {
+	mu     sync.Mutex
+	issues map[string]*DecodeIssue
+}
+
+// NewDiagnostics creates an empty diagnostics collector
+func NewDiagnostics() *Diagnostics {
+	return &Diagnostics{issues: map[string]*DecodeIssue{}}
+}
+
+// record adds the issues found in one response
+func (d *Diagnostics) record(issues []DecodeIssue) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.issues == nil {
+		d.issues = map[string]*DecodeIssue{}
+	}
+	for _, issue := range issues {
+		key := issue.Endpoint + "\x00" + issue.Field + "\x00" + string(issue.Kind)
+		if existing, ok := d.issues[key]; ok {
+			existing.Count++
+			existing.Detail = issue.Detail
+			continue
+		}
+		recorded := issue
+		recorded.Count = 1
+		d.issues[key] = &recorded
+	}
+}
+
+// Issues returns all recorded issues, sorted by endpoint and field
+func (d *Diagnostics) Issues() []DecodeIssue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	issues := make([]DecodeIssue, 0, len(d.issues))
+	for _, issue := range d.issues {
+		issues = append(issues, *issue)
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Endpoint != issues[j].Endpoint {
+			return issues[i].Endpoint < issues[j].Endpoint
+		}
+		if issues[i].Field != issues[j].Field {
+			return issues[i].Field < issues[j].Field
+		}
+		return issues[i].Kind < issues[j].Kind
+	})
+	return issues
+}
+
+// IgnoredFields returns the response fields that were discarded because the
+// model has no such field, keyed by endpoint
+func (d *Diagnostics) IgnoredFields() map[string][]string {
+	ignored := map[string][]string{}
+	for _, issue := range d.Issues() {
+		if issue.Kind == IssueUnknownField {
+			ignored[issue.Endpoint] = append(ignored[issue.Endpoint], issue.Field)
+		}
+	}
+	return ignored
+}
+
+// Reset discards all recorded issues
+func (d *Diagnostics) Reset() {
+	d.mu.Lock()
+	d.issues = map[string]*DecodeIssue{}
+	d.mu.Unlock()
+}
+
+// SetDecodeMode sets how responses that do not match the models are handled
+func (c *Client) SetDecodeMode(mode DecodeMode) {
+	c.DecodeMode = mode
+}
+
+// EnableDiagnostics starts collecting decode issues and returns the collector.
+// Calling it again returns the existing collector.
+func (c *Client) EnableDiagnostics() *Diagnostics {
+	if c.Diagnostics == nil {
+		c.Diagnostics = NewDiagnostics()
+	}
+	return c.Diagnostics
+}
+
+// decodeData unmarshals response data into v according to the decode mode
+func (c *Client) decodeData(req *http.Request, data json.RawMessage, v interface{}) error {
+	err := json.Unmarshal(data, v)
+
+	// Anything other than a type mismatch (e.g. malformed JSON) always fails
+	var typeErr *json.UnmarshalTypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		return err
+	}
+
+	if err == nil && c.DecodeMode != DecodeStrict && c.Diagnostics == nil {
+		return nil
+	}
+
+	endpoint := c.endpointKey(req)
+	var issues []DecodeIssue
+	inspectJSON(data, reflect.TypeOf(v), "", func(field string, kind IssueKind, detail string) {
+		issues = append(issues, DecodeIssue{Endpoint: endpoint, Field: field, Kind: kind, Detail: detail})
+	})
+
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Field < issues[j].Field })
+
+	if c.Diagnostics != nil {
+		c.Diagnostics.record(issues)
+	}
+	if c.DecodeMode == DecodeStrict && len(issues) > 0 {
+		return &SchemaError{Endpoint: endpoint, Issues: issues}
+	}
+
+	// IDs are used to address objects in later requests, so one that did not
+	// decode fails the request even in lenient mode
+	var idIssues []DecodeIssue
+	for _, issue := range issues {
+		if issue.Kind == IssueTypeMismatch && isIDField(issue.Field) {
+			idIssues = append(idIssues, issue)
+		}
+	}
+	if len(idIssues) > 0 {
+		return &SchemaError{Endpoint: endpoint, Issues: idIssues}
+	}
+	return nil
+}
+
+// isIDField reports whether a field path names an object ID, e.g. "id" or "[].subnetId"
+func isIDField(path string) bool {
+	name := path[strings.LastIndexAny(path, ".]")+1:]
+	return name == "id" || strings.HasSuffix(name, "Id") || strings.HasSuffix(name, "ID")
+}
+
+// endpointKey returns the request method and path relative to the application,
+// with IDs, addresses and search terms replaced by placeholders
+func (c *Client) endpointKey(req *http.Request) string {
+	path := strings.TrimPrefix(req.URL.Path, c.BaseURL.Path)
+	path = strings.TrimPrefix(path, c.AppID+"/")
+	path = strings.Trim(path, "/")
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case i > 0 && strings.HasPrefix(segments[i-1], "search"):
+			segments[i] = "{query}"
+		case isNumeric(segment):
+			segments[i] = "{id}"
+		case net.ParseIP(segment) != nil:
+			segments[i] = "{ip}"
+		}
+	}
+
+	return req.Method + " " + strings.Join(segments, "/")
+}
+
+// isNumeric reports whether s consists of decimal digits only
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	_, err := strconv.ParseUint(s, 10, 64)
+	return err == nil
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// inspectJSON walks JSON data alongside the Go type it is decoded into and
+// reports unknown object keys and values that cannot be decoded into their field
+func inspectJSON(data []byte, t reflect.Type, path string, report func(field string, kind IssueKind, detail string)) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" || trimmed == "null" || t.Kind() == reflect.Interface {
+		return
+	}
+
+	field := path
+	if field == "" {
+		field = "(root)"
+	}
+
+	// Types with custom decoding decide for themselves which JSON they accept
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		if err := json.Unmarshal(data, reflect.New(t).Interface()); err != nil {
+			report(field, IssueTypeMismatch, fmt.Sprintf("%s into %s: %v", jsonKind(trimmed), t, err))
+			return
+		}
+		// Structs with custom decoding can still have keys they do not know about
+		if t.Kind() == reflect.Struct && trimmed[0] == '{' {
+			var object map[string]json.RawMessage
+			if json.Unmarshal(data, &object) == nil {
+				fields := jsonFields(t)
+				for key := range object {
+					if _, ok := lookupField(fields, key); !ok {
+						report(joinPath(path, key), IssueUnknownField, "")
+					}
+				}
+			}
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			report(field, IssueTypeMismatch, fmt.Sprintf("%s into %s", jsonKind(trimmed), t))
+			return
+		}
+		fields := jsonFields(t)
+		for key, value := range object {
+			fieldType, ok := lookupField(fields, key)
+			if !ok {
+				report(joinPath(path, key), IssueUnknownField, "")
+				continue
+			}
+			inspectJSON(value, fieldType, joinPath(path, key), report)
+		}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && trimmed[0] == '"' {
+			return
+		}
+		var elements []json.RawMessage
+		if err := json.Unmarshal(data, &elements); err != nil {
+			report(field, IssueTypeMismatch, fmt.Sprintf("%s into %s", jsonKind(trimmed), t))
+			return
+		}
+		for _, element := range elements {
+			inspectJSON(element, t.Elem(), path+"[]", report)
+		}
+
+	case reflect.Map:
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			report(field, IssueTypeMismatch, fmt.Sprintf("%s into %s", jsonKind(trimmed), t))
+			return
+		}
+		for _, value := range object {
+			inspectJSON(value, t.Elem(), joinPath(path, "*"), report)
+		}
+
+	default:
+		if err := json.Unmarshal(data, reflect.New(t).Interface()); err != nil {
+			report(field, IssueTypeMismatch, fmt.Sprintf("%s into %s", jsonKind(trimmed), t))
+		}
+	}
+}
+
+// jsonFields returns the JSON field names of a struct type, including promoted
+// fields of embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for n, ft := range jsonFields(embedded) {
+					if _, ok := fields[n]; !ok {
+						fields[n] = ft
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// lookupField finds a field by JSON name, falling back to the case-insensitive
+// match encoding/json uses
+func lookupField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if t, ok := fields[key]; ok {
+		return t, true
+	}
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// joinPath appends a key to a field path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonKind returns the JSON type name of a raw value
+func jsonKind(data string) string {
+	switch data[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "bool"
+	}
+	return "number"
+}
@@ -0,0 +1,148 @@
+package phpipam
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeModes(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		// wantLenient and wantStrict are the field of the returned schema issue,
+		// or "" if the request succeeds
+		wantLenient string
+		wantStrict  string
+		wantKind    IssueKind
+	}{
+		{
+			name: "clean",
+			data: `{"id":7,"subnet":"10.0.0.0","mask":"24","sectionId":1}`,
+		},
+		{
+			name:       "unknown field",
+			data:       `{"id":7,"subnet":"10.0.0.0","mask":"24","custom_rack":"r1"}`,
+			wantStrict: "custom_rack",
+			wantKind:   IssueUnknownField,
+		},
+		{
+			name:       "type mismatch",
+			data:       `{"id":7,"subnet":"10.0.0.0","mask":"24","allowRequests":"yes"}`,
+			wantStrict: "allowRequests",
+			wantKind:   IssueTypeMismatch,
+		},
+		{
+			name:        "id type mismatch",
+			data:        `{"id":"seven","subnet":"10.0.0.0","mask":"24"}`,
+			wantLenient: "id",
+			wantStrict:  "id",
+			wantKind:    IssueTypeMismatch,
+		},
+		{
+			name:        "reference type mismatch",
+			data:        `{"id":7,"subnet":"10.0.0.0","mask":"24","sectionId":"1"}`,
+			wantLenient: "sectionId",
+			wantStrict:  "sectionId",
+			wantKind:    IssueTypeMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, mode := range []DecodeMode{DecodeLenient, DecodeStrict} {
+			want := tt.wantLenient
+			if mode == DecodeStrict {
+				want = tt.wantStrict
+			}
+
+			api := newTestServer(t, map[string]string{
+				"subnets/7": `{"code":200,"success":true,"data":` + tt.data + `}`,
+			})
+			api.Client.SetDecodeMode(mode)
+
+			subnet, err := api.Subnets.Get(7)
+			if want == "" {
+				if err != nil {
+					t.Errorf("%s (mode %d): unexpected error %v", tt.name, mode, err)
+				} else if subnet.Subnet != "10.0.0.0" {
+					t.Errorf("%s (mode %d): subnet = %q, want 10.0.0.0", tt.name, mode, subnet.Subnet)
+				}
+				continue
+			}
+
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Errorf("%s (mode %d): err = %v, want *SchemaError", tt.name, mode, err)
+				continue
+			}
+			if len(schemaErr.Issues) != 1 || schemaErr.Issues[0].Field != want || schemaErr.Issues[0].Kind != tt.wantKind {
+				t.Errorf("%s (mode %d): issues = %v, want %s %s", tt.name, mode, schemaErr.Issues, tt.wantKind, want)
+			}
+			if schemaErr.Endpoint != "GET subnets/{id}" {
+				t.Errorf("%s (mode %d): endpoint = %q", tt.name, mode, schemaErr.Endpoint)
+			}
+		}
+	}
+}
+
+func TestDecodeLenientLeavesMismatchAtZero(t *testing.T) {
+	api := newTestServer(t, map[string]string{
+		"subnets/7": `{"code":200,"success":true,"data":{"id":7,"subnet":"10.0.0.0","allowRequests":"yes","showName":1}}`,
+	})
+
+	subnet, err := api.Subnets.Get(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subnet.ID != 7 || subnet.AllowRequests != 0 || subnet.ShowName != 1 {
+		t.Errorf("subnet = %+v, want ID 7, AllowRequests 0, ShowName 1", subnet)
+	}
+}
+
+func TestDiagnosticsRecordsIssues(t *testing.T) {
+	api := newTestServer(t, map[string]string{
+		"subnets/7": `{"code":200,"success":true,"data":{"id":7,"subnet":"10.0.0.0","custom_rack":"r1","allowRequests":"yes"}}`,
+		"subnets/8": `{"code":200,"success":true,"data":{"id":8,"subnet":"10.0.1.0","custom_rack":"r2"}}`,
+	})
+	diag := api.Client.EnableDiagnostics()
+
+	for _, id := range []int{7, 8} {
+		if _, err := api.Subnets.Get(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	issues := diag.Issues()
+	if len(issues) != 2 {
+		t.Fatalf("issues = %v, want 2", issues)
+	}
+	if issues[0].Field != "allowRequests" || issues[0].Kind != IssueTypeMismatch || issues[0].Count != 1 {
+		t.Errorf("issues[0] = %+v", issues[0])
+	}
+	if issues[1].Field != "custom_rack" || issues[1].Kind != IssueUnknownField || issues[1].Count != 2 {
+		t.Errorf("issues[1] = %+v", issues[1])
+	}
+
+	ignored := diag.IgnoredFields()["GET subnets/{id}"]
+	if len(ignored) != 1 || ignored[0] != "custom_rack" {
+		t.Errorf("IgnoredFields = %v", diag.IgnoredFields())
+	}
+}
+
+func TestIsIDField(t *testing.T) {
+	tests := map[string]bool{
+		"id":              true,
+		"[].id":           true,
+		"[].subnetId":     true,
+		"masterSubnetId":  true,
+		"tag.ID":          true,
+		"[].custom_rack":  false,
+		"[].identifier":   false,
+		"allowRequests":   false,
+		"nameserver.hide": false,
+	}
+	for path, want := range tests {
+		if got := isIDField(path); got != want {
+			t.Errorf("isIDField(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
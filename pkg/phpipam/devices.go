@@ -79,6 +79,9 @@ func (d *DevicesService) Create(device *Device) (*Device, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the device data, retrieve the full device
 	if resp.ID != 0 && createdDevice.ID == "" {
@@ -99,8 +102,27 @@ func (d *DevicesService) Update(device *Device) (*Device, error) {
 	return &updatedDevice, err
 }
 
+// Patch sends a partial update for a device, including fields set to zero or empty
+func (d *DevicesService) Patch(id string, patch *Patch) error {
+	if id == "" {
+		return fmt.Errorf("device ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("device patch contains no fields")
+	}
+
+	resp, err := d.client.Request("PATCH", fmt.Sprintf("devices/%s", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
 // Delete deletes a device
 func (d *DevicesService) Delete(id string) error {
-	_, err := d.client.Request("DELETE", fmt.Sprintf("devices/%s", id), nil, nil)
-	return err
+	resp, err := d.client.Request("DELETE", fmt.Sprintf("devices/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
@@ -0,0 +1,251 @@
+// Package dhcp connects phpIPAM address data with DHCP servers. It exports
+// static reservations for ISC dhcpd and Kea from addresses that have a MAC and
+// a DHCP or Reserved state, and feeds dhcpd and Kea lease files back into
+// phpIPAM addresses.
+//
+//	export, err := dhcp.Collect(client, []int{42}, dhcp.Options{})
+//	for _, p := range export.Problems {
+//		log.Println(p)
+//	}
+//	err = export.WriteDhcpd(os.Stdout)
+package dhcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// DefaultTags are the address states exported as reservations when
+// Options.Tags is empty
+var DefaultTags = []string{"DHCP", "Reserved"}
+
+// Options controls which addresses become reservations
+type Options struct {
+	// Tags are the names or IDs of the address states to export, DefaultTags
+	// when empty
+	Tags []string
+}
+
+// Reservation is a static lease for a single host
+type Reservation struct {
+	// AddressID is the phpIPAM address ID
+	AddressID int
+	IP        netip.Addr
+	MAC       net.HardwareAddr
+	Hostname  string
+}
+
+// Subnet is a subnet with its options and reservations
+type Subnet struct {
+	// ID is the phpIPAM subnet ID, also used as the Kea subnet ID
+	ID          int
+	Prefix      netip.Prefix
+	Description string
+	// Gateway is the address marked as gateway in phpIPAM, if any
+	Gateway netip.Addr
+	// DNS are the IP entries of the subnet's nameserver set
+	DNS          []netip.Addr
+	Reservations []Reservation
+}
+
+// Problem is an address left out of the export
+type Problem struct {
+	SubnetID int
+	IP       string
+	MAC      string
+	Message  string
+}
+
+// String returns a one-line description of the problem
+func (p Problem) String() string {
+	return fmt.Sprintf("subnet %d: %s (%s): %s", p.SubnetID, p.IP, p.MAC, p.Message)
+}
+
+// Export is the reservation data of a set of subnets
+type Export struct {
+	Subnets []Subnet
+	// Problems lists addresses that were not exported: invalid MACs, IPs outside
+	// their subnet, and duplicate MACs or IPs
+	Problems []Problem
+}
+
+// Err returns an error listing the problems, or nil
+func (e *Export) Err() error {
+	var errs []error
+	for _, p := range e.Problems {
+		errs = append(errs, errors.New(p.String()))
+	}
+	return errors.Join(errs...)
+}
+
+// Collect reads the given subnets and their reservations. Addresses with a
+// state in opts.Tags and a MAC become reservations.
+func Collect(api *phpipam.PHPIPAM, subnetIDs []int, opts Options) (*Export, error) {
+	tags, err := resolveTags(api, opts.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets := make([]phpipam.Subnet, 0, len(subnetIDs))
+	for _, id := range subnetIDs {
+		subnet, err := api.Subnets.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("subnet %d: %w", id, err)
+		}
+		subnets = append(subnets, *subnet)
+	}
+	data, err := api.GetSubnetData(subnets)
+	if err != nil {
+		return nil, err
+	}
+	return Build(data, tags)
+}
+
+// resolveTags returns the IDs of the address states to export
+func resolveTags(api *phpipam.PHPIPAM, names []string) (map[int]bool, error) {
+	if len(names) == 0 {
+		names = DefaultTags
+	}
+	tags := make(map[int]bool, len(names))
+	for _, name := range names {
+		tag, err := api.Addresses.ResolveTag(name)
+		if err != nil {
+			return nil, err
+		}
+		tags[tag.ID] = true
+	}
+	return tags, nil
+}
+
+// SubnetData is a subnet with the data its reservations are built from
+type SubnetData = phpipam.SubnetData
+
+// Build creates the export of the given subnets from the addresses whose tag is
+// in tags. MACs and IPs must be unique per address family across all subnets;
+// every address involved in a duplicate is reported and left out.
+func Build(data []SubnetData, tags map[int]bool) (*Export, error) {
+	export := &Export{}
+	type candidate struct {
+		subnet      int
+		reservation Reservation
+	}
+	var candidates []candidate
+	macs := make(map[string]int)
+	ips := make(map[netip.Addr]int)
+
+	for _, d := range data {
+		prefix, err := d.Subnet.Prefix()
+		if err != nil {
+			return nil, fmt.Errorf("subnet %d: %w", d.Subnet.ID, err)
+		}
+		subnet := Subnet{ID: d.Subnet.ID, Prefix: prefix, Description: d.Subnet.Description}
+		for _, entry := range d.Nameservers {
+			if addr, err := netip.ParseAddr(entry); err == nil && addr.Is4() == prefix.Addr().Is4() {
+				subnet.DNS = append(subnet.DNS, addr)
+			}
+		}
+
+		for i := range d.Addresses {
+			a := &d.Addresses[i]
+			addr, addrErr := a.Addr()
+			if a.IsGateway == 1 && addrErr == nil && !subnet.Gateway.IsValid() {
+				subnet.Gateway = addr
+			}
+			if !tags[a.Tag] || strings.TrimSpace(a.Mac) == "" {
+				continue
+			}
+
+			problem := func(message string) {
+				export.Problems = append(export.Problems, Problem{SubnetID: d.Subnet.ID, IP: a.IP, MAC: a.Mac, Message: message})
+			}
+			if addrErr != nil {
+				problem("invalid IP address")
+				continue
+			}
+			if !prefix.Contains(addr) {
+				problem(fmt.Sprintf("outside subnet %s", prefix))
+				continue
+			}
+			mac, err := ipcalc.ParseMAC(a.Mac)
+			if err != nil || len(mac) != 6 {
+				problem("invalid MAC address")
+				continue
+			}
+
+			macs[familyKey(addr, mac)]++
+			ips[addr]++
+			candidates = append(candidates, candidate{
+				subnet:      len(export.Subnets),
+				reservation: Reservation{AddressID: a.ID, IP: addr, MAC: mac, Hostname: strings.TrimSpace(a.Hostname)},
+			})
+		}
+		export.Subnets = append(export.Subnets, subnet)
+	}
+
+	for _, c := range candidates {
+		r := c.reservation
+		subnet := &export.Subnets[c.subnet]
+		switch {
+		case ips[r.IP] > 1:
+			export.Problems = append(export.Problems, Problem{SubnetID: subnet.ID, IP: r.IP.String(), MAC: r.MAC.String(), Message: "duplicate IP address"})
+		case macs[familyKey(r.IP, r.MAC)] > 1:
+			export.Problems = append(export.Problems, Problem{SubnetID: subnet.ID, IP: r.IP.String(), MAC: r.MAC.String(), Message: "duplicate MAC address"})
+		default:
+			subnet.Reservations = append(subnet.Reservations, r)
+		}
+	}
+
+	for i := range export.Subnets {
+		reservations := export.Subnets[i].Reservations
+		sort.Slice(reservations, func(a, b int) bool { return reservations[a].IP.Less(reservations[b].IP) })
+	}
+	return export, nil
+}
+
+// familyKey identifies a MAC within an address family, since a host can hold
+// one IPv4 and one IPv6 reservation
+func familyKey(addr netip.Addr, mac net.HardwareAddr) string {
+	if addr.Is4() {
+		return "4/" + mac.String()
+	}
+	return "6/" + mac.String()
+}
+
+// hostNames returns unique declaration names for the reservations of all
+// subnets: the hostname where there is one, otherwise derived from the IP
+func (e *Export) hostNames() map[netip.Addr]string {
+	names := make(map[netip.Addr]string)
+	used := make(map[string]int)
+	for _, s := range e.Subnets {
+		for _, r := range s.Reservations {
+			name := sanitizeName(r.Hostname)
+			if name == "" {
+				name = "host-" + strings.NewReplacer(".", "-", ":", "-").Replace(r.IP.String())
+			}
+			used[name]++
+			if n := used[name]; n > 1 {
+				name = fmt.Sprintf("%s-%d", name, n)
+			}
+			names[r.IP] = name
+		}
+	}
+	return names
+}
+
+// sanitizeName keeps the characters valid in a host declaration name
+func sanitizeName(name string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(name) {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_' {
+			b.WriteRune(c)
+		}
+	}
+	return strings.Trim(b.String(), ".-")
+}
@@ -0,0 +1,89 @@
+package dhcp
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// WriteDhcpd writes ISC dhcpd subnet declarations with routers and
+// domain-name-servers options for the IPv4 subnets, each followed by the host
+// blocks of its reservations
+func (e *Export) WriteDhcpd(w io.Writer) error {
+	return e.writeDhcpd(w, true)
+}
+
+// WriteDhcpd6 writes ISC dhcpd subnet6 declarations with dhcp6.name-servers for
+// the IPv6 subnets, each followed by the host blocks of its reservations. dhcpd
+// runs IPv6 as a separate server, so this goes into its own configuration file.
+func (e *Export) WriteDhcpd6(w io.Writer) error {
+	return e.writeDhcpd(w, false)
+}
+
+// writeDhcpd writes the subnets of one address family
+func (e *Export) writeDhcpd(w io.Writer, ipv4 bool) error {
+	names := e.hostNames()
+
+	var b strings.Builder
+	for _, s := range e.Subnets {
+		if s.Prefix.Addr().Is4() != ipv4 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		if s.Description != "" {
+			fmt.Fprintf(&b, "# %s\n", oneLine(s.Description))
+		}
+		if ipv4 {
+			netmask, _ := ipcalc.Netmask(s.Prefix)
+			fmt.Fprintf(&b, "subnet %s netmask %s {\n", s.Prefix.Addr(), netmask)
+			if s.Gateway.IsValid() {
+				fmt.Fprintf(&b, "  option routers %s;\n", s.Gateway)
+			}
+			if len(s.DNS) > 0 {
+				fmt.Fprintf(&b, "  option domain-name-servers %s;\n", joinAddrs(s.DNS))
+			}
+		} else {
+			fmt.Fprintf(&b, "subnet6 %s {\n", s.Prefix)
+			if len(s.DNS) > 0 {
+				fmt.Fprintf(&b, "  option dhcp6.name-servers %s;\n", joinAddrs(s.DNS))
+			}
+		}
+		b.WriteString("}\n")
+
+		for _, r := range s.Reservations {
+			fmt.Fprintf(&b, "\nhost %s {\n", names[r.IP])
+			fmt.Fprintf(&b, "  hardware ethernet %s;\n", r.MAC)
+			if r.IP.Is4() {
+				fmt.Fprintf(&b, "  fixed-address %s;\n", r.IP)
+			} else {
+				fmt.Fprintf(&b, "  fixed-address6 %s;\n", r.IP)
+			}
+			if r.Hostname != "" {
+				fmt.Fprintf(&b, "  option host-name %q;\n", r.Hostname)
+			}
+			b.WriteString("}\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// joinAddrs formats an option list of addresses
+func joinAddrs(addrs []netip.Addr) string {
+	parts := make([]string, len(addrs))
+	for i, a := range addrs {
+		parts[i] = a.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// oneLine flattens text for a comment
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
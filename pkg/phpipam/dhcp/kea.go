@@ -0,0 +1,102 @@
+package dhcp
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// KeaOption is an entry of a Kea option-data list
+type KeaOption struct {
+	Name string `json:"name"`
+	Data string `json:"data"`
+}
+
+// KeaReservation is a Kea host reservation
+type KeaReservation struct {
+	HWAddress string `json:"hw-address"`
+	// IPAddress is set for DHCPv4 reservations
+	IPAddress string `json:"ip-address,omitempty"`
+	// IPAddresses is set for DHCPv6 reservations
+	IPAddresses []string `json:"ip-addresses,omitempty"`
+	Hostname    string   `json:"hostname,omitempty"`
+}
+
+// KeaSubnet is a subnet4 or subnet6 entry
+type KeaSubnet struct {
+	ID           int              `json:"id"`
+	Subnet       string           `json:"subnet"`
+	OptionData   []KeaOption      `json:"option-data,omitempty"`
+	Reservations []KeaReservation `json:"reservations"`
+	// UserContext carries the phpIPAM subnet description
+	UserContext map[string]string `json:"user-context,omitempty"`
+}
+
+// KeaDHCPv4 returns the IPv4 subnets as Kea subnet4 entries
+func (e *Export) KeaDHCPv4() []KeaSubnet {
+	return e.kea(true)
+}
+
+// KeaDHCPv6 returns the IPv6 subnets as Kea subnet6 entries
+func (e *Export) KeaDHCPv6() []KeaSubnet {
+	return e.kea(false)
+}
+
+// kea converts the subnets of one address family
+func (e *Export) kea(ipv4 bool) []KeaSubnet {
+	subnets := []KeaSubnet{}
+	for _, s := range e.Subnets {
+		if s.Prefix.Addr().Is4() != ipv4 {
+			continue
+		}
+		subnet := KeaSubnet{ID: s.ID, Subnet: s.Prefix.String(), Reservations: []KeaReservation{}}
+		if s.Description != "" {
+			subnet.UserContext = map[string]string{"description": s.Description}
+		}
+		if ipv4 && s.Gateway.IsValid() {
+			subnet.OptionData = append(subnet.OptionData, KeaOption{Name: "routers", Data: s.Gateway.String()})
+		}
+		if len(s.DNS) > 0 {
+			name := "dns-servers"
+			if ipv4 {
+				name = "domain-name-servers"
+			}
+			subnet.OptionData = append(subnet.OptionData, KeaOption{Name: name, Data: joinAddrs(s.DNS)})
+		}
+
+		for _, r := range s.Reservations {
+			reservation := KeaReservation{HWAddress: r.MAC.String(), Hostname: strings.ToLower(r.Hostname)}
+			if ipv4 {
+				reservation.IPAddress = r.IP.String()
+			} else {
+				reservation.IPAddresses = []string{r.IP.String()}
+			}
+			subnet.Reservations = append(subnet.Reservations, reservation)
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets
+}
+
+// WriteKeaDHCPv4 writes {"Dhcp4": {"subnet4": [...]}} for merging into a
+// kea-dhcp4 configuration
+func (e *Export) WriteKeaDHCPv4(w io.Writer) error {
+	return writeJSON(w, map[string]interface{}{
+		"Dhcp4": map[string]interface{}{"subnet4": e.KeaDHCPv4()},
+	})
+}
+
+// WriteKeaDHCPv6 writes {"Dhcp6": {"subnet6": [...]}} for merging into a
+// kea-dhcp6 configuration
+func (e *Export) WriteKeaDHCPv6(w io.Writer) error {
+	return writeJSON(w, map[string]interface{}{
+		"Dhcp6": map[string]interface{}{"subnet6": e.KeaDHCPv6()},
+	})
+}
+
+// writeJSON writes indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,224 @@
+package dhcp
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// Lease is a DHCP lease read from a server's lease database
+type Lease struct {
+	IP       netip.Addr
+	MAC      net.HardwareAddr
+	Hostname string
+	// Starts is when the lease was last granted or renewed
+	Starts time.Time
+	// Ends is when the lease expires; zero for infinite leases
+	Ends time.Time
+	// Active reports whether the server considers the lease bound
+	Active bool
+}
+
+// Current reports whether the lease is active and not expired at the given time
+func (l *Lease) Current(now time.Time) bool {
+	return l.Active && (l.Ends.IsZero() || l.Ends.After(now))
+}
+
+// latestLeases keeps the last lease of each IP, as lease files are append-only
+// logs, and orders them by IP
+func latestLeases(leases []Lease) []Lease {
+	latest := make(map[netip.Addr]int, len(leases))
+	var unique []Lease
+	for _, lease := range leases {
+		if i, ok := latest[lease.IP]; ok {
+			unique[i] = lease
+			continue
+		}
+		latest[lease.IP] = len(unique)
+		unique = append(unique, lease)
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i].IP.Less(unique[j].IP) })
+	return unique
+}
+
+// ParseDhcpdLeases reads an ISC dhcpd.leases file. Only IPv4 lease blocks are
+// read; later entries for an IP replace earlier ones.
+func ParseDhcpdLeases(r io.Reader) ([]Lease, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var leases []Lease
+	var lease *Lease
+	var cltt time.Time
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if lease == nil {
+			if rest, ok := strings.CutPrefix(text, "lease "); ok && strings.HasSuffix(rest, "{") {
+				addr, err := netip.ParseAddr(strings.TrimSpace(strings.TrimSuffix(rest, "{")))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid lease address: %w", line, err)
+				}
+				lease, cltt = &Lease{IP: addr}, time.Time{}
+			}
+			continue
+		}
+
+		if text == "}" {
+			if !cltt.IsZero() {
+				lease.Starts = cltt
+			}
+			leases = append(leases, *lease)
+			lease = nil
+			continue
+		}
+
+		statement := strings.TrimSuffix(text, ";")
+		keyword, value, _ := strings.Cut(statement, " ")
+		var err error
+		switch keyword {
+		case "starts":
+			lease.Starts, err = parseDhcpdTime(value)
+		case "ends":
+			lease.Ends, err = parseDhcpdTime(value)
+		case "cltt":
+			cltt, err = parseDhcpdTime(value)
+		case "binding":
+			lease.Active = strings.TrimSpace(strings.TrimPrefix(value, "state")) == "active"
+		case "hardware":
+			if kind, mac, ok := strings.Cut(value, " "); ok && kind == "ethernet" {
+				lease.MAC, err = ipcalc.ParseMAC(mac)
+			}
+		case "client-hostname":
+			lease.Hostname, err = strconv.Unquote(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", line, keyword, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if lease != nil {
+		return nil, fmt.Errorf("line %d: unterminated lease %s", line, lease.IP)
+	}
+	return latestLeases(leases), nil
+}
+
+// parseDhcpdTime parses a dhcpd lease time: "4 2024/05/01 10:00:00" in UTC,
+// "epoch 1714557600; # comment", or "never"
+func parseDhcpdTime(value string) (time.Time, error) {
+	value, _, _ = strings.Cut(value, ";")
+	fields := strings.Fields(value)
+	switch {
+	case len(fields) == 1 && fields[0] == "never":
+		return time.Time{}, nil
+	case len(fields) == 2 && fields[0] == "epoch":
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	case len(fields) == 3:
+		return time.ParseInLocation("2006/01/02 15:04:05", fields[1]+" "+fields[2], time.UTC)
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// Kea lease states
+const (
+	keaStateDefault  = "0"
+	keaLeaseTypeAddr = "0"
+)
+
+// ParseKeaLeases reads a Kea memfile lease CSV file (kea-leases4.csv or
+// kea-leases6.csv). Columns are found by header name. Prefix delegations are
+// skipped; later entries for an IP replace earlier ones.
+func ParseKeaLeases(r io.Reader) ([]Lease, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"address", "expire", "valid_lifetime"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+
+	var leases []Lease
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		get := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return strings.ReplaceAll(strings.TrimSpace(fields[i]), "&#x2c", ",")
+			}
+			return ""
+		}
+
+		if leaseType := get("lease_type"); leaseType != "" && leaseType != keaLeaseTypeAddr {
+			continue
+		}
+		addr, err := netip.ParseAddr(get("address"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid address: %w", line, err)
+		}
+		lease := Lease{
+			IP:       addr,
+			Hostname: strings.TrimSuffix(get("hostname"), "."),
+			Active:   get("state") == "" || get("state") == keaStateDefault,
+		}
+		if hw := get("hwaddr"); hw != "" {
+			if lease.MAC, err = ipcalc.ParseMAC(hw); err != nil {
+				return nil, fmt.Errorf("line %d: invalid hwaddr: %w", line, err)
+			}
+		}
+
+		expire, err := strconv.ParseInt(get("expire"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expire: %w", line, err)
+		}
+		lifetime, err := strconv.ParseInt(get("valid_lifetime"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid valid_lifetime: %w", line, err)
+		}
+		// Kea stores the expiry; infinite leases have the all-ones lifetime
+		if uint32(lifetime) != 0xffffffff {
+			lease.Ends = time.Unix(expire, 0).UTC()
+		}
+		lease.Starts = time.Unix(expire-lifetime, 0).UTC()
+
+		leases = append(leases, lease)
+	}
+	return latestLeases(leases), nil
+}
@@ -0,0 +1,107 @@
+package dhcp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leases formats leases one per line as IP, MAC, hostname, start, end and
+// whether they are active
+func leases(list []Lease) string {
+	lines := make([]string, len(list))
+	for i, l := range list {
+		ends := "never"
+		if !l.Ends.IsZero() {
+			ends = l.Ends.Format(time.RFC3339)
+		}
+		lines[i] = fmt.Sprintf("%s %s %q %s %s %t", l.IP, l.MAC, l.Hostname, l.Starts.Format(time.RFC3339), ends, l.Active)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseFile runs a lease parser on a file in testdata
+func parseFile(t *testing.T, name string, parse func(f *os.File) ([]Lease, error)) []Lease {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	list, err := parse(f)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return list
+}
+
+func TestParseDhcpdLeases(t *testing.T) {
+	got := parseFile(t, "dhcpd.leases", func(f *os.File) ([]Lease, error) { return ParseDhcpdLeases(f) })
+	want := strings.Join([]string{
+		`10.0.0.5 52:54:00:00:00:05 "printer" 2024-05-01T10:00:00Z never true`,
+		`10.0.0.20 52:54:00:12:34:56 "laptop-1" 2024-05-01T12:30:00Z 2024-05-02T00:00:00Z true`,
+		`10.0.0.21 52:54:00:ab:cd:ef "" 2024-05-01T07:00:00Z 2024-05-01T09:00:00Z false`,
+	}, "\n")
+	if s := leases(got); s != want {
+		t.Errorf("ParseDhcpdLeases =\n%s\nwant\n%s", s, want)
+	}
+}
+
+func TestParseDhcpdLeasesErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"lease 10.0.0.300 {\n}\n", "line 1: invalid lease address"},
+		{"lease 10.0.0.1 {\n  starts 3 2024/13/01 08:00:00;\n}\n", "line 2: starts"},
+		{"lease 10.0.0.1 {\n  hardware ethernet zz:zz;\n}\n", "line 2: hardware"},
+		{"lease 10.0.0.1 {\n  binding state active;\n", "unterminated lease 10.0.0.1"},
+	}
+	for _, tt := range tests {
+		_, err := ParseDhcpdLeases(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseDhcpdLeases(%q) error = %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestParseKeaLeases(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"kea-leases4.csv", []string{
+			`10.0.1.10 52:54:00:aa:00:10 "host-10.example.com" 2024-05-01T11:00:00Z 2024-05-01T12:00:00Z true`,
+			`10.0.1.11 52:54:00:aa:00:11 "" 2024-05-01T10:00:00Z 2024-05-01T11:00:00Z false`,
+			`10.0.1.12  "static,one" 2024-05-01T10:00:00Z never true`,
+		}},
+		{"kea-leases6.csv", []string{
+			`2001:db8:1::10 52:54:00:bb:00:10 "v6-host.example.com" 2024-05-01T10:00:00Z 2024-05-01T12:00:00Z true`,
+		}},
+	}
+	for _, tt := range tests {
+		got := parseFile(t, tt.file, func(f *os.File) ([]Lease, error) { return ParseKeaLeases(f) })
+		if s, want := leases(got), strings.Join(tt.want, "\n"); s != want {
+			t.Errorf("ParseKeaLeases(%s) =\n%s\nwant\n%s", tt.file, s, want)
+		}
+	}
+}
+
+func TestParseKeaLeasesErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"address,hwaddr\n10.0.0.1,52:54:00:00:00:01\n", "missing expire column"},
+		{"address,expire,valid_lifetime\nnot-an-ip,1,1\n", "line 2: invalid address"},
+		{"address,expire,valid_lifetime\n10.0.0.1,soon,3600\n", "line 2: invalid expire"},
+	}
+	for _, tt := range tests {
+		_, err := ParseKeaLeases(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseKeaLeases(%q) error = %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package dhcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/report"
+)
+
+// DefaultLeaseTag is the state given to addresses created from leases when
+// SyncOptions.Tag is empty
+const DefaultLeaseTag = "DHCP"
+
+// SyncOptions controls how leases are applied to phpIPAM
+type SyncOptions struct {
+	// VRF is the VRF whose subnets leases are matched against, phpipam.GlobalVRF
+	// by default
+	VRF int
+	// Tag is the name or ID of the state of created addresses, DefaultLeaseTag
+	// when empty
+	Tag string
+	// OnlyTagged limits the sync to existing addresses whose state is Tag; no
+	// addresses are created and others are left alone
+	OnlyTagged bool
+	// Retag sets Tag on existing addresses with another state
+	Retag bool
+	// IncludeExpired also applies leases that are expired or no longer bound
+	IncludeExpired bool
+	// Now is the time leases are checked for expiry against, time.Now when zero
+	Now time.Time
+}
+
+// SyncAction is what a sync does with a lease
+type SyncAction string
+
+const (
+	// SyncCreate creates an address for the lease
+	SyncCreate SyncAction = "create"
+	// SyncUpdate updates the address of the lease
+	SyncUpdate SyncAction = "update"
+	// SyncUnchanged leaves an address that already matches the lease
+	SyncUnchanged SyncAction = "unchanged"
+	// SyncSkip ignores the lease, see LeaseChange.Reason
+	SyncSkip SyncAction = "skip"
+)
+
+// symbol returns the preview marker of an action
+func (a SyncAction) symbol() string {
+	switch a {
+	case SyncCreate:
+		return "+"
+	case SyncUpdate:
+		return "~"
+	case SyncSkip:
+		return "!"
+	}
+	return "="
+}
+
+// FieldDiff is an address field the sync changes
+type FieldDiff = report.FieldDiff
+
+// LeaseChange is the planned effect of one lease
+type LeaseChange struct {
+	Lease  Lease
+	Action SyncAction
+	// SubnetID is the most specific subnet containing the lease
+	SubnetID int
+	Subnet   string
+	// AddressID is the existing address, or 0 for creates
+	AddressID int
+	Diffs     []FieldDiff
+	// Reason explains skipped leases
+	Reason string
+}
+
+// String returns a one-line summary such as "+ 10.0.0.5 in 10.0.0.0/24"
+func (c LeaseChange) String() string {
+	if c.Action == SyncSkip {
+		return fmt.Sprintf("%s %s: %s", c.Action.symbol(), c.Lease.IP, c.Reason)
+	}
+	return fmt.Sprintf("%s %s in %s", c.Action.symbol(), c.Lease.IP, c.Subnet)
+}
+
+// SyncPlan is the diff between a set of leases and phpIPAM
+type SyncPlan struct {
+	Changes []LeaseChange
+
+	tag int
+}
+
+// Count returns the number of changes with the given action
+func (p *SyncPlan) Count(action SyncAction) int {
+	return report.Count(p.Changes, func(c LeaseChange) bool { return c.Action == action })
+}
+
+// Render writes the plan as a diff preview. Unchanged leases are left out.
+func (p *SyncPlan) Render(w io.Writer) error {
+	return report.Render(w, func(b *strings.Builder) {
+		for _, c := range p.Changes {
+			if c.Action == SyncUnchanged {
+				continue
+			}
+			fmt.Fprintln(b, c.String())
+			report.WriteDiffs(b, c.Diffs, c.Action == SyncCreate)
+		}
+		b.WriteString(report.Summary("Leases", false,
+			report.Tally{N: p.Count(SyncCreate), Label: "to create"},
+			report.Tally{N: p.Count(SyncUpdate), Label: "to update"},
+			report.Tally{N: p.Count(SyncUnchanged), Label: "unchanged"},
+			report.Tally{N: p.Count(SyncSkip), Label: "skipped"}))
+	})
+}
+
+// String returns the rendered plan
+func (p *SyncPlan) String() string {
+	return report.String(p.Render)
+}
+
+// PlanLeaseSync matches leases to the most specific subnet containing them and
+// computes the address creates and updates that bring phpIPAM in line.
+// Hostname, MAC, last seen time and state are compared. Nothing is modified.
+func PlanLeaseSync(api *phpipam.PHPIPAM, leases []Lease, opts SyncOptions) (*SyncPlan, error) {
+	name := opts.Tag
+	if name == "" {
+		name = DefaultLeaseTag
+	}
+	tag, err := api.Addresses.ResolveTag(name)
+	if err != nil {
+		return nil, err
+	}
+	index, err := api.GetSubnetIndex()
+	if err != nil {
+		return nil, err
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	tagName := func(id int) string {
+		if id == 0 {
+			return ""
+		}
+		if name, err := api.Addresses.TagName(id); err == nil {
+			return name
+		}
+		return strconv.Itoa(id)
+	}
+
+	plan := &SyncPlan{tag: tag.ID}
+	addresses := make(map[int]map[netip.Addr]*phpipam.Address)
+	for _, lease := range leases {
+		change := LeaseChange{Lease: lease, Action: SyncSkip}
+
+		subnet, ok := index.Lookup(opts.VRF, lease.IP)
+		if !ok {
+			change.Reason = "no subnet contains the address"
+			plan.Changes = append(plan.Changes, change)
+			continue
+		}
+		change.SubnetID, change.Subnet = subnet.ID, subnet.CIDR()
+
+		if !opts.IncludeExpired && !lease.Current(now) {
+			change.Reason = "lease is expired or not bound"
+			plan.Changes = append(plan.Changes, change)
+			continue
+		}
+
+		existing, ok := addresses[subnet.ID]
+		if !ok {
+			list, err := api.Subnets.GetAddresses(subnet.ID)
+			if err != nil {
+				return nil, fmt.Errorf("subnet %s: %w", subnet.CIDR(), err)
+			}
+			existing = make(map[netip.Addr]*phpipam.Address, len(list))
+			for i := range list {
+				if addr, err := list[i].Addr(); err == nil {
+					existing[addr] = &list[i]
+				}
+			}
+			addresses[subnet.ID] = existing
+		}
+
+		current, ok := existing[lease.IP]
+		switch {
+		case !ok && opts.OnlyTagged:
+			change.Reason = "not in phpIPAM"
+		case !ok:
+			change.Action = SyncCreate
+			change.Diffs = leaseDiffs(api.Client, &phpipam.Address{}, lease, tag.ID, true, tagName)
+		case opts.OnlyTagged && current.Tag != tag.ID:
+			change.AddressID = current.ID
+			change.Reason = fmt.Sprintf("address state is not %s", tag.Type)
+		default:
+			change.AddressID = current.ID
+			change.Diffs = leaseDiffs(api.Client, current, lease, tag.ID, opts.Retag, tagName)
+			change.Action = SyncUnchanged
+			if len(change.Diffs) > 0 {
+				change.Action = SyncUpdate
+			}
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan, nil
+}
+
+// leaseDiffs returns the fields of an address that differ from a lease. The
+// last seen time only moves forward.
+func leaseDiffs(client *phpipam.Client, current *phpipam.Address, lease Lease, tag int, setTag bool, tagName func(int) string) []FieldDiff {
+	var diffs []FieldDiff
+	if lease.Hostname != "" && !strings.EqualFold(lease.Hostname, current.Hostname) {
+		diffs = append(diffs, FieldDiff{Field: "hostname", Old: current.Hostname, New: lease.Hostname})
+	}
+	if lease.MAC != nil {
+		old, err := ipcalc.ParseMAC(current.Mac)
+		if err != nil || old.String() != lease.MAC.String() {
+			diffs = append(diffs, FieldDiff{Field: "mac", Old: current.Mac, New: lease.MAC.String()})
+		}
+	}
+	if !lease.Starts.IsZero() {
+		seen, err := client.ParseLastSeen(current.LastSeen)
+		if err != nil || lease.Starts.After(seen) {
+			diffs = append(diffs, FieldDiff{Field: "lastSeen", Old: current.LastSeen, New: client.FormatLastSeen(lease.Starts)})
+		}
+	}
+	if setTag && current.Tag != tag {
+		diffs = append(diffs, FieldDiff{Field: "tag", Old: tagName(current.Tag), New: tagName(tag)})
+	}
+	return diffs
+}
+
+// SyncFailure is a change phpIPAM rejected
+type SyncFailure struct {
+	Change LeaseChange
+	Err    error
+}
+
+// SyncResult reports the outcome of applying a plan
+type SyncResult struct {
+	Applied []LeaseChange
+	Failed  []SyncFailure
+}
+
+// Err returns an error describing the failed changes, or nil
+func (r *SyncResult) Err() error {
+	var errs []error
+	for _, f := range r.Failed {
+		errs = append(errs, fmt.Errorf("%s: %w", f.Change.Lease.IP, f.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyLeaseSync executes the creates and updates of a plan. A failed change
+// does not stop the run; the returned error summarizes all failures.
+func ApplyLeaseSync(api *phpipam.PHPIPAM, plan *SyncPlan) (*SyncResult, error) {
+	result := &SyncResult{}
+	for _, change := range plan.Changes {
+		var err error
+		switch change.Action {
+		case SyncCreate:
+			err = createFromLease(api, change, plan.tag)
+		case SyncUpdate:
+			err = api.Addresses.Patch(change.AddressID, leasePatch(change.Diffs, plan.tag))
+		default:
+			continue
+		}
+		if err != nil {
+			result.Failed = append(result.Failed, SyncFailure{Change: change, Err: err})
+			continue
+		}
+		result.Applied = append(result.Applied, change)
+	}
+	return result, result.Err()
+}
+
+// createFromLease creates the address of a lease
+func createFromLease(api *phpipam.PHPIPAM, change LeaseChange, tag int) error {
+	address := &phpipam.Address{
+		SubnetID: change.SubnetID,
+		IP:       change.Lease.IP.String(),
+		Hostname: change.Lease.Hostname,
+		Tag:      tag,
+	}
+	if change.Lease.MAC != nil {
+		address.Mac = change.Lease.MAC.String()
+	}
+	if !change.Lease.Starts.IsZero() {
+		address.LastSeen = api.Client.FormatLastSeen(change.Lease.Starts)
+	}
+	_, err := api.Addresses.Create(address)
+	return err
+}
+
+// leasePatch builds the address update of a change's diffs
+func leasePatch(diffs []FieldDiff, tag int) *phpipam.AddressPatch {
+	patch := phpipam.NewAddressPatch()
+	for _, diff := range diffs {
+		switch diff.Field {
+		case "hostname":
+			patch.SetHostname(diff.New)
+		case "mac":
+			patch.SetMac(diff.New)
+		case "lastSeen":
+			patch.SetLastSeen(diff.New)
+		case "tag":
+			patch.SetTag(tag)
+		}
+	}
+	return patch
+}
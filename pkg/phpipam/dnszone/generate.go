@@ -0,0 +1,493 @@
+package dnszone
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// Source selects the subnets to publish
+type Source struct {
+	// Subnets are subnet IDs
+	Subnets []int
+	// Sections are section IDs whose subnets are all published
+	Sections []string
+}
+
+// Options controls zone generation
+type Options struct {
+	// Domain is the forward zone, e.g. "example.com". Hostnames without a dot
+	// are qualified with it. Without a domain only reverse zones are built.
+	Domain string
+	// TTL is the default TTL of the zones, DefaultTTL when zero
+	TTL int
+	// SOA sets the SOA parameters; zero timers are taken from DefaultSOA
+	SOA SOA
+	// Serial fixes the serial of all zones. When zero, each zone's serial is the
+	// Unix time of the most recent edit of its addresses, or the current time
+	// for zones without edit dates, adjusted by Previous.
+	Serial uint32
+	// Previous are the zones written by the last run, e.g. from ReadZoneFiles.
+	// A zone whose content matches its previous version keeps that serial, and
+	// a changed zone gets a serial above it even when its edit dates went back,
+	// as they do when the latest edited address is deleted.
+	Previous []*Zone
+	// Nameservers are the NS records of every zone. When empty, each zone uses
+	// the nameserver sets of its subnets.
+	Nameservers []string
+}
+
+// SubnetData is a subnet with the data its records are built from
+type SubnetData = phpipam.SubnetData
+
+// Zones are the generated forward and reverse zones
+type Zones struct {
+	// Forward is nil when Options.Domain is empty
+	Forward *Zone
+	Reverse []*Zone
+	// Warnings lists addresses and nameservers that were left out
+	Warnings []string
+}
+
+// All returns the forward zone followed by the reverse zones
+func (z *Zones) All() []*Zone {
+	var all []*Zone
+	if z.Forward != nil {
+		all = append(all, z.Forward)
+	}
+	return append(all, z.Reverse...)
+}
+
+// WriteFiles writes every zone to dir, creating it if needed
+func (z *Zones) WriteFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, zone := range z.All() {
+		if err := zone.WriteFile(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Generate reads the selected subnets from phpIPAM and builds their zones
+func Generate(api *phpipam.PHPIPAM, src Source, opts Options) (*Zones, error) {
+	data, err := Collect(api, src)
+	if err != nil {
+		return nil, err
+	}
+	return Build(data, opts)
+}
+
+// Collect reads the selected subnets with their addresses and nameserver sets.
+// Folders are skipped.
+func Collect(api *phpipam.PHPIPAM, src Source) ([]SubnetData, error) {
+	var subnets []phpipam.Subnet
+	for _, id := range src.Subnets {
+		subnet, err := api.Subnets.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("subnet %d: %w", id, err)
+		}
+		subnets = append(subnets, *subnet)
+	}
+	for _, id := range src.Sections {
+		section, err := api.Sections.GetSubnets(id)
+		if err != nil {
+			return nil, fmt.Errorf("section %s: %w", id, err)
+		}
+		subnets = append(subnets, section...)
+	}
+	return api.GetSubnetData(subnets)
+}
+
+// host is an address that gets records
+type host struct {
+	addr     netip.Addr
+	name     string // fully qualified
+	ptr      bool
+	editDate time.Time
+}
+
+// builder accumulates the records of all zones
+type builder struct {
+	opts   Options
+	domain string
+	zones  *Zones
+
+	forward  *zoneData
+	reverse  map[netip.Prefix]*zoneData
+	names    map[netip.Addr]string // fully qualified hostnames for nameserver IPs
+	previous map[string]*Zone      // Options.Previous by origin
+}
+
+// zoneData is a zone being built
+type zoneData struct {
+	zone        *Zone
+	records     map[Record]bool
+	nameservers map[string]bool
+	editDate    time.Time
+}
+
+// newZoneData starts an empty zone
+func newZoneData(origin string) *zoneData {
+	return &zoneData{zone: &Zone{Origin: origin}, records: make(map[Record]bool), nameservers: make(map[string]bool)}
+}
+
+// add records an entry and tracks the most recent edit
+func (z *zoneData) add(r Record, edited time.Time) {
+	z.records[r] = true
+	if edited.After(z.editDate) {
+		z.editDate = edited
+	}
+}
+
+// Build creates the zones of the given subnets. Addresses without a hostname are
+// skipped, as are PTR records of addresses with PTRIgnore set. Reverse zones
+// are split on octet (IPv4) or nibble (IPv6) boundaries, see
+// ipcalc.ReverseZoneBits; classless RFC 2317 delegation is not generated.
+func Build(data []SubnetData, opts Options) (*Zones, error) {
+	b := &builder{
+		opts:     opts,
+		domain:   fqdn(opts.Domain),
+		zones:    &Zones{},
+		reverse:  make(map[netip.Prefix]*zoneData),
+		names:    make(map[netip.Addr]string),
+		previous: make(map[string]*Zone, len(opts.Previous)),
+	}
+	for _, zone := range opts.Previous {
+		b.previous[zone.Origin] = zone
+	}
+	if b.domain != "" {
+		if err := checkName(b.domain); err != nil {
+			return nil, fmt.Errorf("domain: %w", err)
+		}
+		b.forward = newZoneData(b.domain)
+	}
+
+	hosts := make([][]host, len(data))
+	for i := range data {
+		hosts[i] = b.hosts(&data[i])
+	}
+	for i := range data {
+		b.addSubnet(&data[i], hosts[i])
+	}
+
+	if b.forward != nil {
+		zone, err := b.finish(b.forward)
+		if err != nil {
+			return nil, err
+		}
+		b.zones.Forward = zone
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(b.reverse))
+	for p := range b.reverse {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		a, c := prefixes[i], prefixes[j]
+		if a.Addr().Is4() != c.Addr().Is4() {
+			return a.Addr().Is4()
+		}
+		return a.Addr().Less(c.Addr())
+	})
+	for _, p := range prefixes {
+		zone, err := b.finish(b.reverse[p])
+		if err != nil {
+			return nil, err
+		}
+		b.zones.Reverse = append(b.zones.Reverse, zone)
+	}
+	return b.zones, nil
+}
+
+// warn records an address or nameserver that was left out
+func (b *builder) warn(format string, args ...interface{}) {
+	warning := fmt.Sprintf(format, args...)
+	for _, w := range b.zones.Warnings {
+		if w == warning {
+			return
+		}
+	}
+	b.zones.Warnings = append(b.zones.Warnings, warning)
+}
+
+// hosts returns the addresses of a subnet that have a valid hostname
+func (b *builder) hosts(d *SubnetData) []host {
+	var hosts []host
+	for i := range d.Addresses {
+		a := &d.Addresses[i]
+		name := strings.ToLower(strings.TrimSpace(a.Hostname))
+		if name == "" {
+			continue
+		}
+		addr, err := a.Addr()
+		if err != nil {
+			b.warn("%v", err)
+			continue
+		}
+		name = b.qualify(name)
+		if name == "" {
+			b.warn("%s: hostname %q has no domain and Options.Domain is not set", addr, a.Hostname)
+			continue
+		}
+		if err := checkName(name); err != nil {
+			b.warn("%s: hostname %q: %v", addr, a.Hostname, err)
+			continue
+		}
+
+		edited, _ := time.ParseInLocation("2006-01-02 15:04:05", a.EditDate, time.UTC)
+		hosts = append(hosts, host{addr: addr, name: name, ptr: a.PTRIgnore != 1, editDate: edited})
+		b.names[addr] = name
+	}
+	return hosts
+}
+
+// qualify returns a hostname fully qualified with the domain, or "" if it has
+// no dot and there is no domain
+func (b *builder) qualify(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	if strings.Contains(name, ".") {
+		// Dotted names are taken as fully qualified
+		return name + "."
+	}
+	if b.domain == "" {
+		return ""
+	}
+	return name + "." + b.domain
+}
+
+// addSubnet adds the records and nameservers of a subnet
+func (b *builder) addSubnet(d *SubnetData, hosts []host) {
+	prefix, err := d.Subnet.Prefix()
+	if err != nil {
+		b.warn("subnet %d: %v", d.Subnet.ID, err)
+		return
+	}
+	nameservers := b.nameservers(d)
+	zoneBits := ipcalc.ReverseZoneBits(prefix)
+	if b.forward != nil {
+		addAll(b.forward.nameservers, nameservers)
+	}
+
+	for _, h := range hosts {
+		if b.forward != nil {
+			name, ok := relative(h.name, b.domain)
+			if ok {
+				recordType := TypeA
+				if h.addr.Is6() {
+					recordType = TypeAAAA
+				}
+				b.forward.add(Record{Name: name, Type: recordType, Data: h.addr.String()}, h.editDate)
+			} else {
+				b.warn("%s: %s is outside %s, no forward record", h.addr, h.name, b.domain)
+			}
+		}
+
+		if !h.ptr {
+			continue
+		}
+		zonePrefix := netip.PrefixFrom(h.addr, zoneBits).Masked()
+		zone, ok := b.reverse[zonePrefix]
+		if !ok {
+			origin, _ := ipcalc.ReverseZone(zonePrefix)
+			zone = newZoneData(origin)
+			b.reverse[zonePrefix] = zone
+		}
+		name, _ := relative(ipcalc.ReverseName(h.addr), zone.zone.Origin)
+		zone.add(Record{Name: name, Type: TypePTR, Data: h.name}, h.editDate)
+		addAll(zone.nameservers, nameservers)
+	}
+}
+
+// nameservers returns the fully qualified NS targets of a subnet
+func (b *builder) nameservers(d *SubnetData) []string {
+	entries := b.opts.Nameservers
+	if len(entries) == 0 {
+		entries = d.Nameservers
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			name, ok := b.names[addr.Unmap()]
+			if !ok {
+				b.warn("subnet %s: nameserver %s has no hostname in the published subnets", d.Subnet.CIDR(), entry)
+				continue
+			}
+			names = append(names, name)
+			continue
+		}
+		name := b.qualify(strings.ToLower(entry))
+		if name == "" || checkName(name) != nil {
+			b.warn("subnet %s: invalid nameserver %q", d.Subnet.CIDR(), entry)
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// addAll adds names to a set
+func addAll(set map[string]bool, names []string) {
+	for _, name := range names {
+		set[name] = true
+	}
+}
+
+// finish sorts the records of a zone and fills in its SOA
+func (b *builder) finish(z *zoneData) (*Zone, error) {
+	zone := z.zone
+	for ns := range z.nameservers {
+		zone.Nameservers = append(zone.Nameservers, ns)
+	}
+	sort.Strings(zone.Nameservers)
+	if len(zone.Nameservers) == 0 {
+		return nil, fmt.Errorf("zone %s has no nameservers: set Options.Nameservers or a nameserver set on its subnets", zone.Origin)
+	}
+
+	for r := range z.records {
+		zone.Records = append(zone.Records, r)
+	}
+	sort.Slice(zone.Records, func(i, j int) bool {
+		return recordLess(zone.Records[i], zone.Records[j])
+	})
+
+	zone.TTL = b.opts.TTL
+	if zone.TTL == 0 {
+		zone.TTL = DefaultTTL
+	}
+	zone.SOA = b.soa(zone, z.editDate)
+	if b.opts.Serial == 0 {
+		zone.SOA.Serial = nextSerial(zone, b.previous[zone.Origin])
+	}
+	return zone, nil
+}
+
+// soa fills in the defaults of the configured SOA for a zone
+func (b *builder) soa(zone *Zone, edited time.Time) SOA {
+	soa := b.opts.SOA
+	if soa.PrimaryNS == "" {
+		soa.PrimaryNS = zone.Nameservers[0]
+	} else {
+		soa.PrimaryNS = fqdn(soa.PrimaryNS)
+	}
+	if soa.Hostmaster == "" {
+		domain := b.domain
+		if domain == "" {
+			domain = zone.Origin
+		}
+		soa.Hostmaster = "hostmaster." + domain
+	}
+	if soa.Refresh == 0 {
+		soa.Refresh = DefaultSOA.Refresh
+	}
+	if soa.Retry == 0 {
+		soa.Retry = DefaultSOA.Retry
+	}
+	if soa.Expire == 0 {
+		soa.Expire = DefaultSOA.Expire
+	}
+	if soa.Minimum == 0 {
+		soa.Minimum = DefaultSOA.Minimum
+	}
+
+	switch {
+	case b.opts.Serial != 0:
+		soa.Serial = b.opts.Serial
+	case !edited.IsZero():
+		soa.Serial = uint32(edited.Unix())
+	default:
+		soa.Serial = uint32(time.Now().Unix())
+	}
+	return soa
+}
+
+// nextSerial returns the serial of a zone given its previous version: the
+// previous serial when nothing changed, otherwise the zone's own serial if it
+// is newer, or one more than the previous serial. Serials compare and wrap as
+// in RFC 1982, so the serial after 4294967295 is 0.
+func nextSerial(zone, previous *Zone) uint32 {
+	if previous == nil {
+		return zone.SOA.Serial
+	}
+	if sameContent(zone, previous) {
+		return previous.SOA.Serial
+	}
+	if serialAfter(zone.SOA.Serial, previous.SOA.Serial) {
+		return zone.SOA.Serial
+	}
+	return previous.SOA.Serial + 1
+}
+
+// serialAfter reports whether serial a is greater than b in RFC 1982 serial
+// number arithmetic. Serials exactly 2^31 apart are not comparable.
+func serialAfter(a, b uint32) bool {
+	return int32(a-b) > 0
+}
+
+// recordLess orders records by name, type and address
+func recordLess(a, b Record) bool {
+	if a.Name != b.Name {
+		if a.Name == "@" || b.Name == "@" {
+			return a.Name == "@"
+		}
+		return nameLess(a.Name, b.Name)
+	}
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	x, errX := netip.ParseAddr(a.Data)
+	y, errY := netip.ParseAddr(b.Data)
+	if errX == nil && errY == nil {
+		return x.Less(y)
+	}
+	return a.Data < b.Data
+}
+
+// nameLess orders owner names label by label from the right, comparing numeric
+// labels by value so that reverse zones list addresses in order
+func nameLess(a, b string) bool {
+	la, lb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		x, y := la[len(la)-i], lb[len(lb)-i]
+		if x == y {
+			continue
+		}
+		nx, errX := strconv.Atoi(x)
+		ny, errY := strconv.Atoi(y)
+		if errX == nil && errY == nil {
+			return nx < ny
+		}
+		return x < y
+	}
+	return len(la) < len(lb)
+}
+
+// checkName validates a fully qualified hostname
+func checkName(name string) error {
+	if len(name) > 254 {
+		return fmt.Errorf("name is longer than 253 characters")
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("invalid label %q", label)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return fmt.Errorf("invalid character %q in %q", c, label)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+// Package dnszone builds BIND zone files from phpIPAM data: forward zones with
+// A and AAAA records from address hostnames, and reverse in-addr.arpa and
+// ip6.arpa zones with PTR records.
+//
+//	zones, err := dnszone.Generate(client, dnszone.Source{Sections: []string{"1"}},
+//		dnszone.Options{Domain: "example.com"})
+//	err = zones.WriteFiles("/etc/bind/zones")
+package dnszone
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Record types
+const (
+	TypeA    = "A"
+	TypeAAAA = "AAAA"
+	TypeNS   = "NS"
+	TypePTR  = "PTR"
+)
+
+// Record is a resource record of a zone
+type Record struct {
+	// Name is the owner name relative to the zone origin, or "@" for the apex
+	Name string
+	Type string
+	// Data is the record data, with names fully qualified
+	Data string
+}
+
+// SOA holds the start of authority parameters of a zone. Times are in seconds.
+type SOA struct {
+	// PrimaryNS is the primary nameserver; defaults to the first NS record
+	PrimaryNS string
+	// Hostmaster is the responsible mailbox in DNS form ("hostmaster.example.com.")
+	// or as an email address; defaults to hostmaster in the forward domain
+	Hostmaster string
+	// Serial is set by the generator unless Options.Serial is given
+	Serial  uint32
+	Refresh int
+	Retry   int
+	Expire  int
+	// Minimum is the negative caching TTL
+	Minimum int
+}
+
+// DefaultSOA holds the SOA timers used when Options.SOA leaves them zero
+var DefaultSOA = SOA{
+	Refresh: 3600,
+	Retry:   900,
+	Expire:  1209600,
+	Minimum: 3600,
+}
+
+// DefaultTTL is the zone TTL used when Options.TTL is zero
+const DefaultTTL = 3600
+
+// Zone is a forward or reverse zone
+type Zone struct {
+	// Origin is the fully qualified zone name, e.g. "example.com."
+	Origin string
+	TTL    int
+	SOA    SOA
+	// Nameservers are the fully qualified NS targets of the apex
+	Nameservers []string
+	Records     []Record
+}
+
+// Render writes the zone in RFC 1035 master file format
+func (z *Zone) Render(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ORIGIN %s\n", z.Origin)
+	fmt.Fprintf(&b, "$TTL %d\n", z.TTL)
+	fmt.Fprintf(&b, "@\tIN\tSOA\t%s %s (\n", z.SOA.PrimaryNS, mailbox(z.SOA.Hostmaster))
+	fmt.Fprintf(&b, "\t\t%d ; serial\n", z.SOA.Serial)
+	fmt.Fprintf(&b, "\t\t%d ; refresh\n", z.SOA.Refresh)
+	fmt.Fprintf(&b, "\t\t%d ; retry\n", z.SOA.Retry)
+	fmt.Fprintf(&b, "\t\t%d ; expire\n", z.SOA.Expire)
+	fmt.Fprintf(&b, "\t\t%d ) ; minimum\n", z.SOA.Minimum)
+	for _, ns := range z.Nameservers {
+		fmt.Fprintf(&b, "@\tIN\tNS\t%s\n", ns)
+	}
+	if len(z.Records) > 0 {
+		b.WriteString("\n")
+	}
+	for _, r := range z.Records {
+		fmt.Fprintf(&b, "%s\tIN\t%s\t%s\n", r.Name, r.Type, r.Data)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// String returns the rendered zone
+func (z *Zone) String() string {
+	var b strings.Builder
+	z.Render(&b)
+	return b.String()
+}
+
+// FileName returns the file name of the zone, e.g. "example.com.zone"
+func (z *Zone) FileName() string {
+	return strings.TrimSuffix(z.Origin, ".") + ".zone"
+}
+
+// WriteFile writes the zone to dir under FileName
+func (z *Zone) WriteFile(dir string) error {
+	return os.WriteFile(filepath.Join(dir, z.FileName()), []byte(z.String()), 0o644)
+}
+
+// ReadZone parses a zone in the format Render writes. Other master files may
+// not parse.
+func ReadZone(r io.Reader) (*Zone, error) {
+	zone := &Zone{}
+	soa := []*int{nil, &zone.SOA.Refresh, &zone.SOA.Retry, &zone.SOA.Expire, &zone.SOA.Minimum}
+	timer := -1 // index into soa while reading the SOA timers
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if timer >= 0 {
+			value, _, _ := strings.Cut(text, ";")
+			value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), ")"))
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid SOA value %q", line, value)
+			}
+			if timer == 0 {
+				zone.SOA.Serial = uint32(n)
+			} else {
+				*soa[timer] = int(n)
+			}
+			if timer++; timer == len(soa) {
+				timer = -1
+			}
+			continue
+		}
+
+		fields := strings.Fields(text)
+		switch {
+		case len(fields) == 0 || strings.HasPrefix(fields[0], ";"):
+		case fields[0] == "$ORIGIN" && len(fields) == 2:
+			zone.Origin = fields[1]
+		case fields[0] == "$TTL" && len(fields) == 2:
+			ttl, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid TTL %q", line, fields[1])
+			}
+			zone.TTL = ttl
+		case len(fields) >= 5 && fields[1] == "IN" && fields[2] == "SOA":
+			zone.SOA.PrimaryNS, zone.SOA.Hostmaster = fields[3], fields[4]
+			timer = 0
+		case len(fields) == 4 && fields[1] == "IN" && fields[0] == "@" && fields[2] == TypeNS:
+			zone.Nameservers = append(zone.Nameservers, fields[3])
+		case len(fields) == 4 && fields[1] == "IN":
+			zone.Records = append(zone.Records, Record{Name: fields[0], Type: fields[2], Data: fields[3]})
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", line, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if zone.Origin == "" {
+		return nil, fmt.Errorf("zone has no $ORIGIN")
+	}
+	return zone, nil
+}
+
+// ReadZoneFiles reads the zones WriteFiles wrote to dir, to pass as
+// Options.Previous. A missing directory holds no zones.
+func ReadZoneFiles(dir string) ([]*Zone, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.zone"))
+	if err != nil {
+		return nil, err
+	}
+	var zones []*Zone
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		zone, err := ReadZone(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+// sameContent reports whether two zones render the same apart from the serial
+func sameContent(a, b *Zone) bool {
+	x, y := *a, *b
+	x.SOA.Serial, y.SOA.Serial = 0, 0
+	return x.String() == y.String()
+}
+
+// mailbox converts an email address to the DNS mailbox form of the SOA record
+func mailbox(hostmaster string) string {
+	local, domain, ok := strings.Cut(hostmaster, "@")
+	if !ok {
+		return fqdn(hostmaster)
+	}
+	return strings.ReplaceAll(local, ".", "\\.") + "." + fqdn(domain)
+}
+
+// fqdn returns a lower-case name with a trailing dot
+func fqdn(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// relative returns name relative to origin, or false if it is outside it
+func relative(name, origin string) (string, bool) {
+	if name == origin {
+		return "@", true
+	}
+	if strings.HasSuffix(name, "."+origin) {
+		return strings.TrimSuffix(name, "."+origin), true
+	}
+	return "", false
+}
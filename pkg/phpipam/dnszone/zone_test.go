@@ -0,0 +1,126 @@
+package dnszone
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testZone returns a forward zone with one nameserver and two records
+func testZone(serial uint32) *Zone {
+	return &Zone{
+		Origin: "example.com.",
+		TTL:    3600,
+		SOA: SOA{
+			PrimaryNS:  "ns1.example.com.",
+			Hostmaster: "hostmaster.example.com.",
+			Serial:     serial,
+			Refresh:    3600,
+			Retry:      900,
+			Expire:     1209600,
+			Minimum:    300,
+		},
+		Nameservers: []string{"ns1.example.com."},
+		Records: []Record{
+			{Name: "db", Type: TypeAAAA, Data: "2001:db8::1"},
+			{Name: "www", Type: TypeA, Data: "10.0.0.1"},
+		},
+	}
+}
+
+func TestReadZone(t *testing.T) {
+	zone := testZone(1700000000)
+	want := `$ORIGIN example.com.
+$TTL 3600
+@	IN	SOA	ns1.example.com. hostmaster.example.com. (
+		1700000000 ; serial
+		3600 ; refresh
+		900 ; retry
+		1209600 ; expire
+		300 ) ; minimum
+@	IN	NS	ns1.example.com.
+
+db	IN	AAAA	2001:db8::1
+www	IN	A	10.0.0.1
+`
+	if got := zone.String(); got != want {
+		t.Fatalf("rendered:\n%s\nwant:\n%s", got, want)
+	}
+
+	read, err := ReadZone(strings.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, zone) {
+		t.Errorf("read %+v, want %+v", read, zone)
+	}
+
+	// An email hostmaster is written in mailbox form and reads back unchanged
+	zone.SOA.Hostmaster = "dns.admin@example.com"
+	read, err = ReadZone(strings.NewReader(zone.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.SOA.Hostmaster != `dns\.admin.example.com.` || !sameContent(read, zone) {
+		t.Errorf("hostmaster read as %q", read.SOA.Hostmaster)
+	}
+}
+
+func TestReadZoneErrors(t *testing.T) {
+	valid := testZone(1).String()
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "no origin", input: strings.Replace(valid, "$ORIGIN example.com.\n", "", 1), wantErr: "zone has no $ORIGIN"},
+		{name: "invalid TTL", input: strings.Replace(valid, "$TTL 3600", "$TTL 1h", 1), wantErr: `line 2: invalid TTL "1h"`},
+		{name: "invalid serial", input: strings.Replace(valid, "\t\t1 ; serial", "\t\tnow ; serial", 1), wantErr: `line 4: invalid SOA value "now"`},
+		{name: "serial beyond 32 bits", input: strings.Replace(valid, "\t\t1 ; serial", "\t\t4294967296 ; serial", 1), wantErr: `line 4: invalid SOA value "4294967296"`},
+		{name: "record with a TTL", input: valid + "mail\t300\tIN\tA\t10.0.0.2\n", wantErr: "line 13: unexpected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadZone(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNextSerial(t *testing.T) {
+	changed := func(serial uint32) *Zone {
+		zone := testZone(serial)
+		zone.Records = zone.Records[:1]
+		return zone
+	}
+
+	tests := []struct {
+		name     string
+		zone     *Zone
+		previous *Zone
+		want     uint32
+	}{
+		{name: "first run", zone: testZone(1700000000), want: 1700000000},
+		{name: "unchanged keeps the previous serial", zone: testZone(1700000500), previous: testZone(1700000000), want: 1700000000},
+		{name: "unchanged at the maximum", zone: testZone(5), previous: testZone(math.MaxUint32), want: math.MaxUint32},
+		{name: "changed with a newer edit", zone: changed(1700000500), previous: testZone(1700000000), want: 1700000500},
+		{name: "changed with an older edit", zone: changed(1699999000), previous: testZone(1700000000), want: 1700000001},
+		{name: "changed with the same edit", zone: changed(1700000000), previous: testZone(1700000000), want: 1700000001},
+		{name: "increment wraps at the maximum", zone: changed(3000000000), previous: testZone(math.MaxUint32), want: 0},
+		// 1700000000 is less than 2^31 ahead of 4000000000 when the serial wraps
+		{name: "newer edit past the wrap", zone: changed(1700000000), previous: testZone(4000000000), want: 1700000000},
+		// 3000000000 is more than 2^31 ahead of 5, which makes it older
+		{name: "far ahead is older", zone: changed(3000000000), previous: testZone(5), want: 6},
+		{name: "exactly 2^31 ahead is not newer", zone: changed(1 << 31), previous: testZone(0), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextSerial(tt.zone, tt.previous); got != tt.want {
+				t.Errorf("nextSerial = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package phpipam
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned when the phpIPAM API reports that a request failed
+type APIError struct {
+	Code    int
+	Message string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("phpIPAM API error %d: %s", e.Code, e.Message)
+}
+
+// Err returns an *APIError when the response reports a failure, and nil otherwise
+func (r *Response) Err() error {
+	if r == nil || r.Success {
+		return nil
+	}
+	return &APIError{Code: r.Code, Message: r.Message}
+}
+
+// statusError is returned for HTTP error responses whose body is not a phpIPAM
+// API response
+type statusError struct {
+	StatusCode int
+	Endpoint   string
+	Err        error
+}
+
+// Error implements the error interface
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP %d response from %s: %v", e.StatusCode, e.Endpoint, e.Err)
+}
+
+// Unwrap returns the decode error
+func (e *statusError) Unwrap() error {
+	return e.Err
+}
+
+// IsConflict reports whether err is an API error about an object that already
+// exists or overlaps an existing one, e.g. a duplicate address
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code == http.StatusConflict {
+		return true
+	}
+
+	message := strings.ToLower(apiErr.Message)
+	for _, hint := range []string{"already exists", "already in use", "duplicate", "overlap"} {
+		if strings.Contains(message, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotFound reports whether err is an API error about an object that does not
+// exist, e.g. an address lookup that found nothing
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code == http.StatusNotFound {
+		return true
+	}
+
+	message := strings.ToLower(apiErr.Message)
+	for _, hint := range []string{"not found", "no addresses found", "does not exist"} {
+		if strings.Contains(message, hint) {
+			return true
+		}
+	}
+	return false
+}
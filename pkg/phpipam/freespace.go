@@ -0,0 +1,191 @@
+package phpipam
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// FreeSpace describes the unused space of a subnet, calculated locally
+type FreeSpace struct {
+	// Prefix is the subnet prefix
+	Prefix netip.Prefix
+	// Usable is the range of addresses phpIPAM allows to be assigned
+	Usable ipcalc.Range
+	// MaxHosts is the number of usable addresses
+	MaxHosts *big.Int
+	// Used is the number of usable addresses taken by addresses or child subnets
+	Used *big.Int
+	// Free is the number of usable addresses still available
+	Free *big.Int
+	// Ranges are the free address ranges, in ascending order
+	Ranges []ipcalc.Range
+	// Blocks are the largest free aligned prefixes that can hold new child
+	// subnets, in ascending order
+	Blocks []netip.Prefix
+}
+
+// FreePercent returns the free share of usable addresses in percent
+func (f *FreeSpace) FreePercent() float64 {
+	if f.MaxHosts.Sign() == 0 {
+		return 0
+	}
+	ratio, _ := new(big.Rat).SetFrac(f.Free, f.MaxHosts).Float64()
+	return ratio * 100
+}
+
+// FirstFree returns the lowest free address
+func (f *FreeSpace) FirstFree() (netip.Addr, bool) {
+	if len(f.Ranges) == 0 {
+		return netip.Addr{}, false
+	}
+	return f.Ranges[0].First, true
+}
+
+// LastFree returns the highest free address
+func (f *FreeSpace) LastFree() (netip.Addr, bool) {
+	if len(f.Ranges) == 0 {
+		return netip.Addr{}, false
+	}
+	return f.Ranges[len(f.Ranges)-1].Last, true
+}
+
+// FreePrefixes returns free child prefixes of the given length, at most limit of
+// them (0 for all)
+func (f *FreeSpace) FreePrefixes(bits int, limit int) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, block := range f.Blocks {
+		remaining := 0
+		if limit > 0 {
+			remaining = limit - len(prefixes)
+			if remaining <= 0 {
+				break
+			}
+		}
+		prefixes = append(prefixes, ipcalc.FreePrefixes(block, nil, bits, remaining)...)
+	}
+	return prefixes
+}
+
+// UsableRange returns the addresses of a subnet that phpIPAM allows to be
+// assigned. It follows phpIPAM's rules:
+//
+//   - IPv4 subnets shorter than /31 exclude the network and broadcast address
+//   - IPv4 /31 subnets use both addresses and /32 subnets their single address
+//   - IPv6 subnets have no broadcast; only the subnet-router anycast (network)
+//     address is excluded, except for /127 and /128 subnets
+//   - subnets marked as pool (IsPool) use every address
+func UsableRange(subnet *Subnet) (ipcalc.Range, error) {
+	prefix, err := subnet.Prefix()
+	if err != nil {
+		return ipcalc.Range{}, err
+	}
+
+	r := ipcalc.PrefixRange(prefix)
+	if subnet.IsPool == 1 {
+		return r, nil
+	}
+
+	if prefix.Addr().Is4() {
+		if prefix.Bits() < 31 {
+			r.First = r.First.Next()
+			r.Last = r.Last.Prev()
+		}
+		return r, nil
+	}
+
+	if prefix.Bits() < 127 {
+		r.First = r.First.Next()
+	}
+	return r, nil
+}
+
+// CalculateFreeSpace computes the free address ranges and free child prefixes of a
+// subnet from its addresses (SubnetsService.GetAddresses) and immediate child
+// subnets (SubnetsService.GetSlaves), without asking the server.
+//
+// Addresses and child subnets count as used regardless of their tag. Addresses or
+// children outside the subnet are ignored. Folders have no address space and
+// return an error.
+func CalculateFreeSpace(subnet *Subnet, addresses []Address, slaves []Subnet) (*FreeSpace, error) {
+	if subnet.IsFolder == 1 {
+		return nil, fmt.Errorf("subnet %d is a folder and has no address space", subnet.ID)
+	}
+
+	prefix, err := subnet.Prefix()
+	if err != nil {
+		return nil, err
+	}
+	usable, err := UsableRange(subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Child subnets and addresses both take space away
+	var children []netip.Prefix
+	for i := range slaves {
+		if slaves[i].IsFolder == 1 {
+			continue
+		}
+		child, err := slaves[i].Prefix()
+		if err != nil {
+			return nil, fmt.Errorf("child subnet %d: %w", slaves[i].ID, err)
+		}
+		if ipcalc.Contains(prefix, child) {
+			children = append(children, child)
+		}
+	}
+
+	var taken []ipcalc.Range
+	for _, child := range children {
+		taken = append(taken, ipcalc.PrefixRange(child))
+	}
+	for i := range addresses {
+		addr, err := addresses[i].Addr()
+		if err != nil {
+			return nil, err
+		}
+		if prefix.Contains(addr) {
+			taken = append(taken, ipcalc.AddrRange(addr))
+		}
+	}
+
+	free := &FreeSpace{
+		Prefix:   prefix,
+		Usable:   usable,
+		MaxHosts: usable.Size(),
+		Free:     new(big.Int),
+		Ranges:   ipcalc.Subtract(usable, taken),
+	}
+	for _, r := range free.Ranges {
+		free.Free.Add(free.Free, r.Size())
+	}
+	free.Used = new(big.Int).Sub(free.MaxHosts, free.Free)
+
+	// New child subnets may use the whole prefix, including network and broadcast
+	for _, r := range ipcalc.Subtract(ipcalc.PrefixRange(prefix), taken) {
+		free.Blocks = append(free.Blocks, r.Prefixes()...)
+	}
+
+	return free, nil
+}
+
+// GetFreeSpace fetches a subnet with its addresses and child subnets and computes
+// its free space locally, see CalculateFreeSpace
+func (s *SubnetsService) GetFreeSpace(id int) (*FreeSpace, error) {
+	subnet, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := s.GetAddresses(id)
+	if err != nil {
+		return nil, err
+	}
+	slaves, err := s.GetSlaves(id)
+	if err != nil {
+		return nil, err
+	}
+	return CalculateFreeSpace(subnet, addresses, slaves)
+}
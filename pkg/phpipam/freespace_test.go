@@ -0,0 +1,243 @@
+package phpipam
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// notFound is phpIPAM's reply to a list or first_free request that finds nothing
+const notFound = `{"code":404,"success":false,"message":"No addresses found"}`
+
+// newTestServer serves hand-written phpIPAM responses keyed by endpoint, such as
+// "subnets/7/addresses", and returns a client for it. The responses follow the
+// shape of phpIPAM's but use numeric IDs where a real server sends strings.
+func newTestServer(t *testing.T, responses map[string]string) *PHPIPAM {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/test/"), "/")
+		body, ok := responses[r.Method+" "+endpoint]
+		if !ok {
+			body, ok = responses[endpoint]
+		}
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, endpoint)
+			body = `{"code":400,"success":false,"message":"Invalid request"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := NewTokenClient(srv.URL+"/api/", "test", "token", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+func TestFreeSpace(t *testing.T) {
+	tests := []struct {
+		name      string
+		subnet    string
+		addresses string
+		slaves    string
+		firstFree string
+		// usable, free and ranges are the expected FreeSpace fields
+		usable string
+		free   string
+		ranges string
+	}{
+		{
+			name:      "ipv4 /24",
+			subnet:    `{"id":7,"subnet":"10.10.0.0","mask":"24","sectionId":1,"isPool":0}`,
+			addresses: `[{"id":101,"subnetId":7,"ip":"10.10.0.1","is_gateway":1},{"id":102,"subnetId":7,"ip":"10.10.0.2"},{"id":105,"subnetId":7,"ip":"10.10.0.5"}]`,
+			firstFree: `{"code":200,"success":true,"data":"10.10.0.3"}`,
+			usable:    "10.10.0.1-10.10.0.254",
+			free:      "251",
+			ranges:    "10.10.0.3-10.10.0.4,10.10.0.6-10.10.0.254",
+		},
+		{
+			name:      "ipv4 /24 with child subnet",
+			subnet:    `{"id":7,"subnet":"10.10.4.0","mask":"24","sectionId":1}`,
+			addresses: `[{"id":101,"subnetId":7,"ip":"10.10.4.129"}]`,
+			slaves:    `[{"id":8,"subnet":"10.10.4.0","mask":"25","sectionId":1,"masterSubnetId":7}]`,
+			usable:    "10.10.4.1-10.10.4.254",
+			free:      "126",
+			ranges:    "10.10.4.128,10.10.4.130-10.10.4.254",
+		},
+		{
+			name:      "ipv4 /31 uses both addresses",
+			subnet:    `{"id":7,"subnet":"10.10.1.0","mask":"31","sectionId":1}`,
+			addresses: `[{"id":101,"subnetId":7,"ip":"10.10.1.0"}]`,
+			firstFree: `{"code":200,"success":true,"data":"10.10.1.1"}`,
+			usable:    "10.10.1.0-10.10.1.1",
+			free:      "1",
+			ranges:    "10.10.1.1",
+		},
+		{
+			name:      "ipv4 /32 free",
+			subnet:    `{"id":7,"subnet":"10.10.2.7","mask":"32","sectionId":1}`,
+			firstFree: `{"code":200,"success":true,"data":"10.10.2.7"}`,
+			usable:    "10.10.2.7",
+			free:      "1",
+			ranges:    "10.10.2.7",
+		},
+		{
+			name:      "ipv4 /32 taken",
+			subnet:    `{"id":7,"subnet":"10.10.2.7","mask":"32","sectionId":1}`,
+			addresses: `[{"id":101,"subnetId":7,"ip":"10.10.2.7"}]`,
+			firstFree: `{"code":404,"success":false,"message":"No free addresses found"}`,
+			usable:    "10.10.2.7",
+			free:      "0",
+		},
+		{
+			name:      "ipv4 pool uses network and broadcast",
+			subnet:    `{"id":7,"subnet":"10.10.3.0","mask":"30","sectionId":1,"isPool":1}`,
+			addresses: `[{"id":101,"subnetId":7,"ip":"10.10.3.0"}]`,
+			firstFree: `{"code":200,"success":true,"data":"10.10.3.1"}`,
+			usable:    "10.10.3.0-10.10.3.3",
+			free:      "3",
+			ranges:    "10.10.3.1-10.10.3.3",
+		},
+		{
+			name:      "ipv6 /64 skips the subnet-router anycast address",
+			subnet:    `{"id":7,"subnet":"2001:db8:1::","mask":"64","sectionId":1}`,
+			addresses: `[{"id":101,"subnetId":7,"ip":"2001:db8:1::1","is_gateway":1}]`,
+			firstFree: `{"code":200,"success":true,"data":"2001:db8:1::2"}`,
+			usable:    "2001:db8:1::1-2001:db8:1:0:ffff:ffff:ffff:ffff",
+			free:      "18446744073709551614",
+			ranges:    "2001:db8:1::2-2001:db8:1:0:ffff:ffff:ffff:ffff",
+		},
+		{
+			name:      "ipv6 /64 has no broadcast",
+			subnet:    `{"id":7,"subnet":"2001:db8:1::","mask":"64","sectionId":1}`,
+			addresses: `[{"id":101,"subnetId":7,"ip":"2001:db8:1::ffff:ffff:ffff:ffff"}]`,
+			firstFree: `{"code":200,"success":true,"data":"2001:db8:1::1"}`,
+			usable:    "2001:db8:1::1-2001:db8:1:0:ffff:ffff:ffff:ffff",
+			free:      "18446744073709551614",
+			ranges:    "2001:db8:1::1-2001:db8:1:0:ffff:ffff:ffff:fffe",
+		},
+		{
+			name:      "ipv6 /127 uses both addresses",
+			subnet:    `{"id":7,"subnet":"2001:db8:2::","mask":"127","sectionId":1}`,
+			firstFree: `{"code":200,"success":true,"data":"2001:db8:2::"}`,
+			usable:    "2001:db8:2::-2001:db8:2::1",
+			free:      "2",
+			ranges:    "2001:db8:2::-2001:db8:2::1",
+		},
+		{
+			name:      "ipv6 /128",
+			subnet:    `{"id":7,"subnet":"2001:db8:3::5","mask":"128","sectionId":1}`,
+			firstFree: `{"code":200,"success":true,"data":"2001:db8:3::5"}`,
+			usable:    "2001:db8:3::5",
+			free:      "1",
+			ranges:    "2001:db8:3::5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses := map[string]string{
+				"subnets/7":            `{"code":200,"success":true,"data":` + tt.subnet + `}`,
+				"subnets/7/addresses":  notFound,
+				"subnets/7/slaves":     `{"code":404,"success":false,"message":"No slaves"}`,
+				"subnets/7/first_free": tt.firstFree,
+			}
+			if tt.addresses != "" {
+				responses["subnets/7/addresses"] = `{"code":200,"success":true,"data":` + tt.addresses + `}`
+			}
+			if tt.slaves != "" {
+				responses["subnets/7/slaves"] = `{"code":200,"success":true,"data":` + tt.slaves + `}`
+			}
+			api := newTestServer(t, responses)
+
+			space, err := api.Subnets.GetFreeSpace(7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := space.Usable.String(); got != tt.usable {
+				t.Errorf("Usable = %s, want %s", got, tt.usable)
+			}
+			if got := space.Free.String(); got != tt.free {
+				t.Errorf("Free = %s, want %s", got, tt.free)
+			}
+			var ranges []string
+			for _, r := range space.Ranges {
+				ranges = append(ranges, r.String())
+			}
+			if got := strings.Join(ranges, ","); got != tt.ranges {
+				t.Errorf("Ranges = %s, want %s", got, tt.ranges)
+			}
+
+			// The local calculation agrees with phpIPAM's first_free
+			if tt.firstFree == "" {
+				return
+			}
+			want, err := api.Subnets.GetFirstFree(7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first, ok := space.FirstFree()
+			if want == "" {
+				if ok {
+					t.Errorf("FirstFree = %s, phpIPAM has no free address", first)
+				}
+			} else if !ok || first.String() != want {
+				t.Errorf("FirstFree = %s, %v, phpIPAM first_free = %s", first, ok, want)
+			}
+		})
+	}
+}
+
+func TestCalculateFreeSpaceBlocks(t *testing.T) {
+	subnet := &Subnet{ID: 7, Subnet: "10.20.0.0", Mask: "24"}
+	addresses := []Address{{IP: "10.20.0.1"}, {IP: "10.20.1.1"}}
+	slaves := []Subnet{
+		{ID: 8, Subnet: "10.20.0.128", Mask: "26"},
+		{ID: 9, Subnet: "10.20.0.0", Mask: "16"},
+		{ID: 10, IsFolder: 1},
+	}
+	space, err := CalculateFreeSpace(subnet, addresses, slaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []string
+	for _, p := range space.Blocks {
+		blocks = append(blocks, p.String())
+	}
+	want := "10.20.0.0/32,10.20.0.2/31,10.20.0.4/30,10.20.0.8/29,10.20.0.16/28,10.20.0.32/27,10.20.0.64/26,10.20.0.192/26"
+	if got := strings.Join(blocks, ","); got != want {
+		t.Errorf("Blocks = %s, want %s", got, want)
+	}
+	if got := space.FreePrefixes(26, 0); len(got) != 2 || got[0].String() != "10.20.0.64/26" {
+		t.Errorf("FreePrefixes(26) = %v, want 10.20.0.64/26 and 10.20.0.192/26", got)
+	}
+
+	if _, err := CalculateFreeSpace(&Subnet{ID: 11, IsFolder: 1}, nil, nil); err == nil {
+		t.Error("CalculateFreeSpace of a folder succeeded")
+	}
+}
+
+func TestUsableRangeFamilies(t *testing.T) {
+	for _, tt := range []struct {
+		subnet, mask string
+		pool         int
+		want         string
+	}{
+		{"192.0.2.0", "30", 0, "192.0.2.1-192.0.2.2"},
+		{"192.0.2.0", "30", 1, "192.0.2.0-192.0.2.3"},
+		{"2001:db8::", "126", 0, "2001:db8::1-2001:db8::3"},
+		{"2001:db8::", "126", 1, "2001:db8::-2001:db8::3"},
+	} {
+		r, err := UsableRange(&Subnet{Subnet: tt.subnet, Mask: tt.mask, IsPool: tt.pool})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.String() != tt.want {
+			t.Errorf("UsableRange(%s/%s, pool %d) = %s, want %s", tt.subnet, tt.mask, tt.pool, r, tt.want)
+		}
+	}
+}
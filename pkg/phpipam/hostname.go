@@ -0,0 +1,260 @@
+package phpipam
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hostnamePlaceholder matches the index placeholder of a hostname template:
+// {n}, {n:d} or {n:0Wd} for zero padding to W digits
+var hostnamePlaceholder = regexp.MustCompile(`\{n(?::(0?)(\d*)d)?\}`)
+
+// HostnameTemplate is a parsed hostname pattern such as "web-{n:02d}.dc1.example.com"
+type HostnameTemplate struct {
+	// Prefix is the text before the index, used as hostbase for searches
+	Prefix string
+	// Suffix is the text after the index
+	Suffix string
+	// Width is the minimum number of digits; shorter indexes are zero-padded
+	Width int
+}
+
+// ParseHostnameTemplate parses a hostname template with exactly one index
+// placeholder: {n}, or {n:02d} for zero-padded indexes
+func ParseHostnameTemplate(template string) (*HostnameTemplate, error) {
+	matches := hostnamePlaceholder.FindAllStringSubmatchIndex(template, -1)
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("hostname template %q must contain exactly one {n} placeholder", template)
+	}
+	m := matches[0]
+
+	t := &HostnameTemplate{
+		Prefix: template[:m[0]],
+		Suffix: template[m[1]:],
+	}
+	if m[4] >= 0 && m[4] < m[5] {
+		width, err := strconv.Atoi(template[m[4]:m[5]])
+		if err != nil {
+			return nil, fmt.Errorf("hostname template %q: invalid width", template)
+		}
+		if template[m[2]:m[3]] == "0" {
+			t.Width = width
+		}
+	}
+	if t.Prefix == "" {
+		return nil, fmt.Errorf("hostname template %q must start with a fixed prefix", template)
+	}
+	return t, nil
+}
+
+// Format returns the hostname for an index
+func (t *HostnameTemplate) Format(index int) string {
+	return fmt.Sprintf("%s%0*d%s", t.Prefix, t.Width, index, t.Suffix)
+}
+
+// Match returns the index of a hostname built from the template. Hostnames are
+// compared case-insensitively; unpadded indexes such as "web-7" also match.
+func (t *HostnameTemplate) Match(hostname string) (int, bool) {
+	lower := strings.ToLower(hostname)
+	prefix, suffix := strings.ToLower(t.Prefix), strings.ToLower(t.Suffix)
+	if !strings.HasPrefix(lower, prefix) || !strings.HasSuffix(lower, suffix) ||
+		len(lower) <= len(prefix)+len(suffix) {
+		return 0, false
+	}
+
+	digits := lower[len(prefix) : len(lower)-len(suffix)]
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	index, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+	return index, true
+}
+
+// NextIndex returns the next index not used by any of the hostnames, starting at
+// start. With fillGaps the lowest unused index is returned, otherwise the index
+// after the highest one in use.
+func (t *HostnameTemplate) NextIndex(hostnames []string, start int, fillGaps bool) int {
+	used := make(map[int]bool)
+	next := start
+	for _, hostname := range hostnames {
+		index, ok := t.Match(hostname)
+		if !ok || index < start {
+			continue
+		}
+		used[index] = true
+		if index >= next {
+			next = index + 1
+		}
+	}
+
+	if fillGaps {
+		for index := start; ; index++ {
+			if !used[index] {
+				return index
+			}
+		}
+	}
+	return next
+}
+
+// HostnameRequest describes an address to allocate under the next free hostname
+type HostnameRequest struct {
+	// Hostname is the hostname template, e.g. "web-{n:02d}.dc1.example.com"
+	Hostname string
+	// SubnetID is the subnet the address is taken from (first free address)
+	SubnetID int
+	// Start is the lowest index to use, 1 when zero
+	Start int
+	// FillGaps reuses the lowest unused index instead of the one after the highest
+	FillGaps bool
+	// Template holds the other fields of the created address, such as
+	// Description and Owner; ID, IP, SubnetID and Hostname are set by the allocation
+	Template Address
+	// MaxRetries is how many times the allocation is retried after a conflict
+	// with another client; defaults to 3 when zero
+	MaxRetries int
+}
+
+// AllocateHostname creates an address under the next free hostname of a
+// template, e.g. web-07.dc1.example.com after web-01 to web-06.
+//
+// Existing hostnames are found with SearchByHostbase and the address is reserved
+// with CreateFirstFree. If another address with the same hostname appears at the
+// same time, the one created last is deleted again and the next index is tried,
+// so on success the hostname is unique.
+func (a *AddressesService) AllocateHostname(req HostnameRequest) (*Address, error) {
+	if req.SubnetID == 0 {
+		return nil, fmt.Errorf("subnet ID is required for allocation")
+	}
+	template, err := ParseHostnameTemplate(req.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	start := req.Start
+	if start == 0 {
+		start = 1
+	}
+	maxRetries := req.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	// Hostnames lost to other clients stay taken even if searches lag behind
+	var taken []string
+	for attempt := 0; ; attempt++ {
+		existing, err := a.SearchByHostbase(template.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search hostnames: %w", err)
+		}
+		hostnames := append([]string(nil), taken...)
+		for i := range existing {
+			hostnames = append(hostnames, existing[i].Hostname)
+		}
+		hostname := template.Format(template.NextIndex(hostnames, start, req.FillGaps))
+
+		address := req.Template
+		address.ID = 0
+		address.IP = ""
+		address.SubnetID = req.SubnetID
+		address.Hostname = hostname
+
+		created, err := a.CreateFirstFree(req.SubnetID, &address)
+		if err != nil {
+			if IsConflict(err) && attempt < maxRetries {
+				continue
+			}
+			return nil, fmt.Errorf("failed to create address for %s: %w", hostname, err)
+		}
+
+		if created.SubnetID == 0 {
+			created.SubnetID = req.SubnetID
+		}
+		if created.Hostname == "" {
+			created.Hostname = hostname
+		}
+		if created.ID == 0 {
+			if err := a.resolveCreated(created); err != nil {
+				return nil, a.rollbackHostname(created, err)
+			}
+		}
+
+		won, err := a.ownsHostname(created, hostname)
+		if err != nil {
+			return nil, a.rollbackHostname(created, err)
+		}
+		if won {
+			return created, nil
+		}
+
+		if err := a.rollbackHostname(created, nil); err != nil {
+			return nil, err
+		}
+		if attempt >= maxRetries {
+			return nil, fmt.Errorf("hostname %s was taken by another client, giving up after %d retries", hostname, maxRetries)
+		}
+		taken = append(taken, hostname)
+	}
+}
+
+// resolveCreated looks up the ID of a created address phpIPAM did not return
+// it for. Without the ID, ownsHostname cannot tell the new address from an
+// older one with the same hostname.
+func (a *AddressesService) resolveCreated(created *Address) error {
+	if created.IP == "" {
+		return fmt.Errorf("phpIPAM returned neither the ID nor the IP of the address created for %s", created.Hostname)
+	}
+	address, err := a.GetByIPAndSubnet(created.IP, created.SubnetID)
+	if err != nil {
+		return fmt.Errorf("failed to look up created address %s: %w", created.IP, err)
+	}
+	if address.ID == 0 {
+		return fmt.Errorf("created address %s not found in subnet %d", created.IP, created.SubnetID)
+	}
+	created.ID = address.ID
+	return nil
+}
+
+// ownsHostname checks that no older address carries the same hostname as the
+// created one. The created address itself need not be in the search results
+// yet.
+func (a *AddressesService) ownsHostname(created *Address, hostname string) (bool, error) {
+	matches, err := a.SearchByHostname(hostname)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify hostname %s: %w", hostname, err)
+	}
+
+	for i := range matches {
+		if strings.EqualFold(matches[i].Hostname, hostname) && matches[i].ID != 0 && matches[i].ID < created.ID {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// rollbackHostname deletes an address created by a failed hostname allocation
+// and returns the cause, extended with any failure to delete. When phpIPAM
+// returned neither the ID nor the IP of the address there is nothing to
+// delete by, and the error says so.
+func (a *AddressesService) rollbackHostname(created *Address, cause error) error {
+	var err error
+	switch {
+	case created.ID != 0:
+		err = a.Delete(created.ID)
+	case created.IP != "" && created.SubnetID != 0:
+		err = a.DeleteByIPAndSubnet(created.IP, created.SubnetID)
+	default:
+		return errors.Join(cause, fmt.Errorf("rollback of address %s failed: created address unknown, manual cleanup needed", created.Hostname))
+	}
+	if err != nil {
+		err = fmt.Errorf("rollback of address %s failed: %w", created.Hostname, err)
+	}
+	return errors.Join(cause, err)
+}
@@ -0,0 +1,370 @@
+package phpipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseHostnameTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		want     HostnameTemplate
+		format   string
+		wantErr  bool
+	}{
+		{template: "web-{n}.dc1", want: HostnameTemplate{Prefix: "web-", Suffix: ".dc1"}, format: "web-7.dc1"},
+		{template: "web-{n:02d}.dc1", want: HostnameTemplate{Prefix: "web-", Suffix: ".dc1", Width: 2}, format: "web-07.dc1"},
+		{template: "db{n:03d}", want: HostnameTemplate{Prefix: "db", Width: 3}, format: "db007"},
+		{template: "db{n:d}", want: HostnameTemplate{Prefix: "db"}, format: "db7"},
+		// Without a leading zero the width does not pad, as in fmt
+		{template: "db{n:3d}", want: HostnameTemplate{Prefix: "db"}, format: "db7"},
+		{template: "web.example.com", wantErr: true},
+		{template: "web-{n}-{n}", wantErr: true},
+		{template: "{n}.example.com", wantErr: true},
+		{template: "web-{n:99999999999999999999d}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		template, err := ParseHostnameTemplate(tt.template)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseHostnameTemplate(%q) = %+v, want an error", tt.template, template)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseHostnameTemplate(%q): %v", tt.template, err)
+			continue
+		}
+		if *template != tt.want {
+			t.Errorf("ParseHostnameTemplate(%q) = %+v, want %+v", tt.template, *template, tt.want)
+		}
+		if got := template.Format(7); got != tt.format {
+			t.Errorf("%q.Format(7) = %q, want %q", tt.template, got, tt.format)
+		}
+	}
+}
+
+func TestHostnameTemplateMatch(t *testing.T) {
+	template := &HostnameTemplate{Prefix: "web-", Suffix: ".dc1.example.com", Width: 2}
+
+	tests := []struct {
+		hostname string
+		want     int
+		ok       bool
+	}{
+		{"web-07.dc1.example.com", 7, true},
+		{"web-7.dc1.example.com", 7, true},
+		{"web-007.dc1.example.com", 7, true},
+		{"web-123.dc1.example.com", 123, true},
+		{"WEB-09.DC1.Example.com", 9, true},
+		{"web-.dc1.example.com", 0, false},
+		{"web-0a.dc1.example.com", 0, false},
+		{"web--1.dc1.example.com", 0, false},
+		{"web-07.dc2.example.com", 0, false},
+		{"db-07.dc1.example.com", 0, false},
+		{"web-99999999999999999999.dc1.example.com", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := template.Match(tt.hostname)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Match(%q) = %d, %v, want %d, %v", tt.hostname, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHostnameTemplateNextIndex(t *testing.T) {
+	template := &HostnameTemplate{Prefix: "web-", Width: 2}
+
+	tests := []struct {
+		name      string
+		hostnames []string
+		start     int
+		fillGaps  bool
+		want      int
+	}{
+		{"none", nil, 1, false, 1},
+		{"after highest", []string{"web-01", "web-02", "web-05"}, 1, false, 6},
+		{"fill gaps", []string{"web-01", "web-02", "web-05"}, 1, true, 3},
+		{"fill gaps with none free below highest", []string{"web-02", "web-01"}, 1, true, 3},
+		{"unpadded and duplicate hostnames", []string{"web-1", "web-01", "web-3"}, 1, true, 2},
+		{"beyond the padding", []string{"web-99", "web-100"}, 1, false, 101},
+		{"indexes below start are ignored", []string{"web-01", "web-02", "web-11"}, 10, true, 10},
+		{"start after gap", []string{"web-01", "web-11"}, 10, false, 12},
+		{"other names are ignored", []string{"web-01", "web-x", "db-07", "web-03.old"}, 1, false, 2},
+	}
+	for _, tt := range tests {
+		if got := template.NextIndex(tt.hostnames, tt.start, tt.fillGaps); got != tt.want {
+			t.Errorf("%s: NextIndex = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// fakeHostnames is a phpIPAM server for AllocateHostname in subnet 7
+type fakeHostnames struct {
+	mu        sync.Mutex
+	t         *testing.T
+	addresses []Address
+	nextID    int
+	nextIP    int
+	deletes   int
+
+	// race is called before each first_free create and returns a hostname
+	// another client takes first, or ""
+	race func(hostname string) string
+	// omitID creates addresses without returning their ID
+	omitID bool
+	// lagging hides created addresses from hostname searches
+	lagging bool
+	// failDeletes makes every delete fail
+	failDeletes bool
+	// hidden holds the IDs of addresses searches do not see yet
+	hidden map[int]bool
+}
+
+func newFakeHostnames(t *testing.T, existing ...string) (*fakeHostnames, *AddressesService) {
+	f := &fakeHostnames{t: t, nextID: 10, nextIP: 10, hidden: map[int]bool{}}
+	for _, hostname := range existing {
+		f.add(hostname)
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	api, err := NewTokenClient(srv.URL+"/api/", "test", "token", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, api.Addresses
+}
+
+// add stores an address with the next ID and IP
+func (f *fakeHostnames) add(hostname string) Address {
+	f.nextID++
+	f.nextIP++
+	address := Address{ID: f.nextID, SubnetID: 7, IP: fmt.Sprintf("10.0.0.%d", f.nextIP), Hostname: hostname}
+	f.addresses = append(f.addresses, address)
+	return address
+}
+
+func (f *fakeHostnames) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/test/"), "/")
+	reply := func(body string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+	list := func(match func(a Address) bool) {
+		var found []Address
+		for _, address := range f.addresses {
+			if !f.hidden[address.ID] && match(address) {
+				found = append(found, address)
+			}
+		}
+		if len(found) == 0 {
+			reply(notFound)
+			return
+		}
+		data, _ := json.Marshal(found)
+		reply(`{"code":200,"success":true,"data":` + string(data) + `}`)
+	}
+
+	switch {
+	case r.Method == "GET" && strings.HasPrefix(endpoint, "addresses/search_hostbase/"):
+		base := strings.TrimPrefix(endpoint, "addresses/search_hostbase/")
+		list(func(a Address) bool { return strings.HasPrefix(strings.ToLower(a.Hostname), strings.ToLower(base)) })
+	case r.Method == "GET" && strings.HasPrefix(endpoint, "addresses/search_hostname/"):
+		hostname := strings.TrimPrefix(endpoint, "addresses/search_hostname/")
+		list(func(a Address) bool { return strings.EqualFold(a.Hostname, hostname) })
+	case r.Method == "POST" && endpoint == "addresses/first_free/7":
+		var request Address
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			f.t.Errorf("create: %v", err)
+		}
+		if f.race != nil {
+			if hostname := f.race(request.Hostname); hostname != "" {
+				f.add(hostname)
+			}
+		}
+		created := f.add(request.Hostname)
+		if f.lagging {
+			f.hidden[created.ID] = true
+		}
+		if f.omitID {
+			reply(fmt.Sprintf(`{"code":201,"success":true,"message":"Address created","data":{"ip":"%s"}}`, created.IP))
+			return
+		}
+		reply(fmt.Sprintf(`{"code":201,"success":true,"message":"Address created","id":"%d","data":{"id":%d,"subnetId":7,"ip":"%s","hostname":"%s"}}`,
+			created.ID, created.ID, created.IP, created.Hostname))
+	case r.Method == "GET" && strings.HasPrefix(endpoint, "addresses/") && strings.HasSuffix(endpoint, "/7"):
+		ip := strings.TrimSuffix(strings.TrimPrefix(endpoint, "addresses/"), "/7")
+		for _, address := range f.addresses {
+			if address.IP == ip {
+				data, _ := json.Marshal(address)
+				reply(`{"code":200,"success":true,"data":` + string(data) + `}`)
+				return
+			}
+		}
+		reply(notFound)
+	case r.Method == "DELETE" && strings.HasPrefix(endpoint, "addresses/"):
+		f.deletes++
+		if f.failDeletes {
+			reply(`{"code":500,"success":false,"message":"Failed to delete address"}`)
+			return
+		}
+		id, _ := strconv.Atoi(strings.TrimPrefix(endpoint, "addresses/"))
+		for i, address := range f.addresses {
+			if address.ID == id {
+				f.addresses = append(f.addresses[:i], f.addresses[i+1:]...)
+				reply(`{"code":200,"success":true,"message":"Address deleted"}`)
+				return
+			}
+		}
+		reply(notFound)
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, endpoint)
+		reply(`{"code":400,"success":false,"message":"Invalid request"}`)
+	}
+}
+
+// hostnames returns the hostnames held by the server, sorted
+func (f *fakeHostnames) hostnames() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var hostnames []string
+	for _, address := range f.addresses {
+		hostnames = append(hostnames, address.Hostname)
+	}
+	sort.Strings(hostnames)
+	return strings.Join(hostnames, " ")
+}
+
+func TestAllocateHostname(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		setup    func(f *fakeHostnames)
+		fillGaps bool
+		want     string
+		// left lists the hostnames the server holds afterwards
+		left string
+	}{
+		{
+			name:     "next after highest",
+			existing: []string{"web-01", "web-03"},
+			want:     "web-04",
+			left:     "web-01 web-03 web-04",
+		},
+		{
+			name:     "fill gaps",
+			existing: []string{"web-01", "web-03"},
+			fillGaps: true,
+			want:     "web-02",
+			left:     "web-01 web-02 web-03",
+		},
+		{
+			name:     "lost race is rolled back and retried",
+			existing: []string{"web-01"},
+			setup: func(f *fakeHostnames) {
+				raced := false
+				f.race = func(hostname string) string {
+					if raced {
+						return ""
+					}
+					raced = true
+					return hostname
+				}
+			},
+			want: "web-03",
+			left: "web-01 web-02 web-03",
+		},
+		{
+			name:     "created address missing from the search",
+			existing: []string{"web-01"},
+			setup:    func(f *fakeHostnames) { f.lagging = true },
+			want:     "web-02",
+			left:     "web-01 web-02",
+		},
+		{
+			name:     "created without ID",
+			existing: []string{"web-01"},
+			setup:    func(f *fakeHostnames) { f.omitID = true },
+			want:     "web-02",
+			left:     "web-01 web-02",
+		},
+		{
+			name:     "created without ID and missing from the search after losing a race",
+			existing: []string{"web-01"},
+			setup: func(f *fakeHostnames) {
+				f.omitID = true
+				f.lagging = true
+				raced := false
+				f.race = func(hostname string) string {
+					if raced {
+						return ""
+					}
+					raced = true
+					return hostname
+				}
+			},
+			want: "web-03",
+			left: "web-01 web-02 web-03",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, addresses := newFakeHostnames(t, tt.existing...)
+			if tt.setup != nil {
+				tt.setup(f)
+			}
+
+			created, err := addresses.AllocateHostname(HostnameRequest{Hostname: "web-{n:02d}", SubnetID: 7, FillGaps: tt.fillGaps})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created.Hostname != tt.want || created.ID == 0 {
+				t.Errorf("created %+v, want %s with an ID", created, tt.want)
+			}
+			if got := f.hostnames(); got != tt.left {
+				t.Errorf("server holds %s, want %s", got, tt.left)
+			}
+		})
+	}
+}
+
+func TestAllocateHostnameGivesUp(t *testing.T) {
+	f, addresses := newFakeHostnames(t)
+	f.race = func(hostname string) string { return hostname }
+
+	_, err := addresses.AllocateHostname(HostnameRequest{Hostname: "web-{n:02d}", SubnetID: 7, MaxRetries: 2})
+	if err == nil || !strings.Contains(err.Error(), "hostname web-03 was taken by another client, giving up after 2 retries") {
+		t.Fatalf("err = %v, want giving up after 2 retries", err)
+	}
+	if got := f.hostnames(); got != "web-01 web-02 web-03" {
+		t.Errorf("server holds %s, want only the addresses of the other client", got)
+	}
+}
+
+func TestAllocateHostnameRollbackFailure(t *testing.T) {
+	f, addresses := newFakeHostnames(t)
+	f.race = func(hostname string) string { return hostname }
+	f.failDeletes = true
+
+	_, err := addresses.AllocateHostname(HostnameRequest{Hostname: "web-{n:02d}", SubnetID: 7})
+	if err == nil || !strings.Contains(err.Error(), "rollback of address web-01 failed") {
+		t.Fatalf("err = %v, want a failed rollback of web-01", err)
+	}
+	if f.deletes != 1 {
+		t.Errorf("%d deletes, want the allocation to stop at the first failed rollback", f.deletes)
+	}
+}
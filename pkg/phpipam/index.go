@@ -0,0 +1,222 @@
+package phpipam
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"sync"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// GlobalVRF is the VRF key used by SubnetIndex for subnets without a VRF
+const GlobalVRF = 0
+
+// indexedSubnet remembers where a subnet is stored in the index
+type indexedSubnet struct {
+	vrf    int
+	prefix netip.Prefix
+}
+
+// SubnetIndex answers IP-to-subnet lookups from a snapshot of subnets without
+// calling the API. Subnets are kept in one prefix trie per VRF, so lookups in
+// one VRF never match subnets of another.
+//
+// The same prefix can exist in several sections of one VRF; queries then return
+// all of them, ordered by subnet ID. A SubnetIndex is safe for concurrent use.
+type SubnetIndex struct {
+	mu     sync.RWMutex
+	tables map[int]*ipcalc.Table[[]Subnet]
+	byID   map[int]indexedSubnet
+}
+
+// NewSubnetIndex builds an index from a list of subnets. Folders are skipped.
+func NewSubnetIndex(subnets []Subnet) (*SubnetIndex, error) {
+	idx := &SubnetIndex{
+		tables: make(map[int]*ipcalc.Table[[]Subnet]),
+		byID:   make(map[int]indexedSubnet, len(subnets)),
+	}
+	for i := range subnets {
+		if err := idx.Add(subnets[i]); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// subnetVRF returns the VRF key of a subnet
+func subnetVRF(subnet *Subnet) int {
+	if vrf, ok := subnet.GetVrfID(); ok {
+		return vrf
+	}
+	return GlobalVRF
+}
+
+// Add inserts a subnet, replacing an earlier version with the same ID. Folders
+// are ignored.
+func (idx *SubnetIndex) Add(subnet Subnet) error {
+	if subnet.IsFolder == 1 {
+		return nil
+	}
+	prefix, err := subnet.Prefix()
+	if err != nil {
+		return fmt.Errorf("subnet %d: %w", subnet.ID, err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(subnet.ID)
+
+	vrf := subnetVRF(&subnet)
+	table, ok := idx.tables[vrf]
+	if !ok {
+		table = &ipcalc.Table[[]Subnet]{}
+		idx.tables[vrf] = table
+	}
+
+	existing, _ := table.Get(prefix)
+	subnets := append(append([]Subnet(nil), existing...), subnet)
+	sort.Slice(subnets, func(i, j int) bool { return subnets[i].ID < subnets[j].ID })
+	table.Insert(prefix, subnets)
+	idx.byID[subnet.ID] = indexedSubnet{vrf: vrf, prefix: prefix}
+	return nil
+}
+
+// Remove deletes the subnet with the given ID and reports whether it was indexed
+func (idx *SubnetIndex) Remove(id int) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.remove(id)
+}
+
+// remove deletes a subnet; the caller holds the write lock
+func (idx *SubnetIndex) remove(id int) bool {
+	entry, ok := idx.byID[id]
+	if !ok {
+		return false
+	}
+	delete(idx.byID, id)
+
+	table := idx.tables[entry.vrf]
+	existing, _ := table.Get(entry.prefix)
+	var remaining []Subnet
+	for _, subnet := range existing {
+		if subnet.ID != id {
+			remaining = append(remaining, subnet)
+		}
+	}
+	if len(remaining) > 0 {
+		table.Insert(entry.prefix, remaining)
+	} else {
+		table.Delete(entry.prefix)
+	}
+	if table.Len() == 0 {
+		delete(idx.tables, entry.vrf)
+	}
+	return true
+}
+
+// Len returns the number of indexed subnets
+func (idx *SubnetIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.byID)
+}
+
+// VRFs returns the VRF IDs that have indexed subnets, GlobalVRF included
+func (idx *SubnetIndex) VRFs() []int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	vrfs := make([]int, 0, len(idx.tables))
+	for vrf := range idx.tables {
+		vrfs = append(vrfs, vrf)
+	}
+	sort.Ints(vrfs)
+	return vrfs
+}
+
+// Lookup returns the most specific subnet of a VRF containing the address. When
+// several sections hold that prefix, the subnet with the lowest ID is returned.
+func (idx *SubnetIndex) Lookup(vrfID int, addr netip.Addr) (*Subnet, bool) {
+	subnets := idx.LookupAll(vrfID, addr)
+	if len(subnets) == 0 {
+		return nil, false
+	}
+	return &subnets[0], true
+}
+
+// LookupAll returns every subnet with the most specific prefix of a VRF
+// containing the address
+func (idx *SubnetIndex) LookupAll(vrfID int, addr netip.Addr) []Subnet {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	table, ok := idx.tables[vrfID]
+	if !ok {
+		return nil
+	}
+	_, subnets, ok := table.Lookup(addr)
+	if !ok {
+		return nil
+	}
+	return append([]Subnet(nil), subnets...)
+}
+
+// LookupIP parses an address and looks it up, see Lookup
+func (idx *SubnetIndex) LookupIP(vrfID int, ip string) (*Subnet, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address %q: %w", ip, err)
+	}
+	subnet, ok := idx.Lookup(vrfID, addr)
+	if !ok {
+		return nil, fmt.Errorf("no subnet contains %s", ip)
+	}
+	return subnet, nil
+}
+
+// Covering returns the subnets of a VRF that contain the prefix, including exact
+// matches, from the largest to the most specific
+func (idx *SubnetIndex) Covering(vrfID int, prefix netip.Prefix) []Subnet {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	table, ok := idx.tables[vrfID]
+	if !ok {
+		return nil
+	}
+	return flattenEntries(table.Covering(prefix))
+}
+
+// CoveredBy returns the subnets of a VRF inside the prefix, including exact
+// matches, in address order
+func (idx *SubnetIndex) CoveredBy(vrfID int, prefix netip.Prefix) []Subnet {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	table, ok := idx.tables[vrfID]
+	if !ok {
+		return nil
+	}
+	return flattenEntries(table.CoveredBy(prefix))
+}
+
+// flattenEntries copies the subnets of table entries into one list
+func flattenEntries(entries []ipcalc.Entry[[]Subnet]) []Subnet {
+	var subnets []Subnet
+	for _, entry := range entries {
+		subnets = append(subnets, entry.Value...)
+	}
+	return subnets
+}
+
+// GetSubnetIndex fetches the subnets of all sections and indexes them
+func (p *PHPIPAM) GetSubnetIndex() (*SubnetIndex, error) {
+	_, subnets, err := p.listAllSubnets()
+	if err != nil {
+		return nil, err
+	}
+	return NewSubnetIndex(subnets)
+}
@@ -0,0 +1,152 @@
+package phpipam
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// subnetIDs formats the IDs of subnets as a space-separated list
+func subnetIDs(subnets []Subnet) string {
+	ids := make([]string, len(subnets))
+	for i, subnet := range subnets {
+		ids[i] = strconv.Itoa(subnet.ID)
+	}
+	return strings.Join(ids, " ")
+}
+
+func testIndex(t *testing.T) *SubnetIndex {
+	t.Helper()
+	idx, err := NewSubnetIndex([]Subnet{
+		{ID: 1, Subnet: "10.0.0.0", Mask: "8"},
+		{ID: 2, Subnet: "10.1.0.0", Mask: "16"},
+		{ID: 4, Subnet: "10.1.2.0", Mask: "24", SectionID: 2},
+		{ID: 3, Subnet: "10.1.2.0", Mask: "24", SectionID: 1},
+		{ID: 5, Subnet: "10.1.2.0", Mask: "24", VrfID: "7"},
+		{ID: 6, Subnet: "10.0.0.0", Mask: "8", VrfID: 7},
+		{ID: 7, Subnet: "2001:db8::", Mask: "32"},
+		{ID: 8, Subnet: "2001:db8:1::", Mask: "48", VrfID: "7"},
+		{ID: 9, IsFolder: 1, Description: "folder"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func TestSubnetIndexLookup(t *testing.T) {
+	idx := testIndex(t)
+
+	if idx.Len() != 8 {
+		t.Errorf("Len = %d, want 8 without the folder", idx.Len())
+	}
+	if got := idx.VRFs(); len(got) != 2 || got[0] != GlobalVRF || got[1] != 7 {
+		t.Errorf("VRFs = %v, want [0 7]", got)
+	}
+
+	tests := []struct {
+		vrf  int
+		addr string
+		want string
+	}{
+		{GlobalVRF, "10.1.2.3", "3 4"},
+		{7, "10.1.2.3", "5"},
+		{GlobalVRF, "10.1.3.1", "2"},
+		{7, "10.1.3.1", "6"},
+		{GlobalVRF, "2001:db8:1::1", "7"},
+		{7, "2001:db8:1::1", "8"},
+		{7, "2001:db8:2::1", ""},
+		{9, "10.1.2.3", ""},
+	}
+	for _, tt := range tests {
+		addr := netip.MustParseAddr(tt.addr)
+		if got := subnetIDs(idx.LookupAll(tt.vrf, addr)); got != tt.want {
+			t.Errorf("LookupAll(%d, %s) = %q, want %q", tt.vrf, tt.addr, got, tt.want)
+		}
+		subnet, ok := idx.Lookup(tt.vrf, addr)
+		if tt.want == "" {
+			if ok {
+				t.Errorf("Lookup(%d, %s) = %d, want no match", tt.vrf, tt.addr, subnet.ID)
+			}
+			continue
+		}
+		// Duplicate prefixes resolve to the lowest ID
+		if want, _, _ := strings.Cut(tt.want, " "); !ok || strconv.Itoa(subnet.ID) != want {
+			t.Errorf("Lookup(%d, %s) = %v, %v, want %s", tt.vrf, tt.addr, subnet, ok, want)
+		}
+	}
+
+	if _, err := idx.LookupIP(GlobalVRF, "not-an-ip"); err == nil {
+		t.Error("LookupIP accepted an invalid address")
+	}
+	if _, err := idx.LookupIP(GlobalVRF, "192.168.0.1"); err == nil {
+		t.Error("LookupIP matched an address outside all subnets")
+	}
+}
+
+func TestSubnetIndexCovering(t *testing.T) {
+	idx := testIndex(t)
+
+	tests := []struct {
+		vrf       int
+		prefix    string
+		covering  string
+		coveredBy string
+	}{
+		{GlobalVRF, "10.1.2.0/24", "1 2 3 4", "3 4"},
+		{7, "10.1.2.0/24", "6 5", "5"},
+		{GlobalVRF, "10.0.0.0/8", "1", "1 2 3 4"},
+		{7, "10.0.0.0/8", "6", "6 5"},
+		{GlobalVRF, "2001:db8::/32", "7", "7"},
+		{7, "2001:db8::/32", "", "8"},
+		{9, "10.0.0.0/8", "", ""},
+	}
+	for _, tt := range tests {
+		prefix := netip.MustParsePrefix(tt.prefix)
+		if got := subnetIDs(idx.Covering(tt.vrf, prefix)); got != tt.covering {
+			t.Errorf("Covering(%d, %s) = %q, want %q", tt.vrf, tt.prefix, got, tt.covering)
+		}
+		if got := subnetIDs(idx.CoveredBy(tt.vrf, prefix)); got != tt.coveredBy {
+			t.Errorf("CoveredBy(%d, %s) = %q, want %q", tt.vrf, tt.prefix, got, tt.coveredBy)
+		}
+	}
+}
+
+func TestSubnetIndexUpdate(t *testing.T) {
+	idx := testIndex(t)
+	addr := netip.MustParseAddr("10.1.2.3")
+
+	// Moving a subnet to another VRF removes it from the old one
+	if err := idx.Add(Subnet{ID: 3, Subnet: "10.1.2.0", Mask: "24", VrfID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if got := subnetIDs(idx.LookupAll(GlobalVRF, addr)); got != "4" {
+		t.Errorf("global LookupAll after move = %q, want 4", got)
+	}
+	if got := subnetIDs(idx.LookupAll(7, addr)); got != "3 5" {
+		t.Errorf("VRF 7 LookupAll after move = %q, want 3 5", got)
+	}
+	if idx.Len() != 8 {
+		t.Errorf("Len = %d after replacing a subnet, want 8", idx.Len())
+	}
+
+	if !idx.Remove(4) || idx.Remove(4) {
+		t.Error("Remove(4) did not report the subnet exactly once")
+	}
+	if got := subnetIDs(idx.LookupAll(GlobalVRF, addr)); got != "2" {
+		t.Errorf("global LookupAll after remove = %q, want 2", got)
+	}
+
+	// A VRF without subnets is dropped
+	for _, id := range []int{3, 5, 6, 8} {
+		idx.Remove(id)
+	}
+	if got := idx.VRFs(); len(got) != 1 || got[0] != GlobalVRF {
+		t.Errorf("VRFs = %v, want only the global VRF", got)
+	}
+
+	if err := idx.Add(Subnet{ID: 10, Subnet: "10.0.0.0", Mask: "bad"}); err == nil {
+		t.Error("Add accepted a subnet with an invalid mask")
+	}
+}
@@ -0,0 +1,99 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a document serialization format
+type Format string
+
+const (
+	// FormatYAML is YAML
+	FormatYAML Format = "yaml"
+	// FormatJSON is JSON
+	FormatJSON Format = "json"
+)
+
+// FormatForPath returns the format matching a file extension; files other than
+// .json are treated as YAML
+func FormatForPath(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// Decode reads a document, rejecting unknown fields so typos in hand-written
+// documents are caught, then normalizes and validates it
+func Decode(r io.Reader, format Format) (*Document, error) {
+	var doc Document
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to decode inventory: %w", err)
+		}
+	case FormatYAML:
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&doc); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to decode inventory: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown inventory format %q", format)
+	}
+
+	if err := doc.Normalize(); err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Encode writes a document
+func Encode(w io.Writer, doc *Document, format Format) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(doc)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+	return fmt.Errorf("unknown inventory format %q", format)
+}
+
+// Load reads a document from a file, choosing the format by extension
+func Load(path string) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f, FormatForPath(path))
+}
+
+// Save writes a document to a file, choosing the format by extension
+func Save(path string, doc *Document) error {
+	var buf bytes.Buffer
+	if err := Encode(&buf, doc, FormatForPath(path)); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,697 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// ExportOptions controls what Export reads
+type ExportOptions struct {
+	// Sections limits subnets, folders and addresses to the named sections; all
+	// sections when empty. Other object kinds are always exported in full.
+	Sections []string
+	// SkipAddresses leaves out addresses, which take one request per subnet
+	SkipAddresses bool
+}
+
+// exporter holds the ID to natural key mappings built while exporting
+type exporter struct {
+	api  *phpipam.PHPIPAM
+	opts ExportOptions
+	only map[string]bool // sections whose subnets are read, nil for all
+	doc  *Document
+
+	sections    map[int]string    // section ID to name
+	vrfs        map[int]string    // VRF ID to name
+	domains     map[string]string // L2 domain ID to name
+	vlans       map[int]VLANRef   // VLAN ID to reference
+	nameservers map[int]string    // nameserver ID to name
+	locations   map[string]string // location ID to name
+	racks       map[string]string // rack ID to name
+	devices     map[string]string // device ID to hostname
+	tags        map[int]string    // tag ID to name
+	folders     map[int]Folder    // folder ID to exported folder
+	subnets     map[int]Subnet    // subnet ID to exported subnet
+	addresses   map[int]string    // address ID to key
+	nats        map[string]string // NAT ID to name
+
+	claimed    map[string]string // kind and natural key to the phpIPAM ID exporting it
+	duplicates []error
+}
+
+// Export reads the configuration of a phpIPAM instance into a document. IDs are
+// replaced by natural keys and every list is sorted, so exporting an unchanged
+// instance twice produces identical output. References to objects that could
+// not be resolved are written as "#<id>". Objects that share a natural key,
+// such as two folders with the same path or a CIDR repeated in one section and
+// VRF, are an error listing their IDs, since Import could not tell them apart.
+//
+// Locations, racks and NATs are skipped on servers that do not provide them.
+func Export(api *phpipam.PHPIPAM, opts ExportOptions) (*Document, error) {
+	e := newExporter(api, opts)
+	if err := e.run(); err != nil {
+		return nil, err
+	}
+	e.doc.Sort()
+	return e.doc, nil
+}
+
+// newExporter creates an exporter with empty mappings
+func newExporter(api *phpipam.PHPIPAM, opts ExportOptions) *exporter {
+	e := &exporter{
+		api:         api,
+		opts:        opts,
+		doc:         &Document{Version: Version},
+		sections:    make(map[int]string),
+		vrfs:        make(map[int]string),
+		domains:     make(map[string]string),
+		vlans:       make(map[int]VLANRef),
+		nameservers: make(map[int]string),
+		locations:   make(map[string]string),
+		racks:       make(map[string]string),
+		devices:     make(map[string]string),
+		tags:        make(map[int]string),
+		folders:     make(map[int]Folder),
+		subnets:     make(map[int]Subnet),
+		addresses:   make(map[int]string),
+		nats:        make(map[string]string),
+		claimed:     make(map[string]string),
+	}
+	if len(opts.Sections) > 0 {
+		e.only = make(map[string]bool, len(opts.Sections))
+		for _, name := range opts.Sections {
+			e.only[name] = true
+		}
+	}
+	return e
+}
+
+// run reads all object kinds in dependency order
+func (e *exporter) run() error {
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"tags", e.exportTags},
+		{"sections", e.exportSections},
+		{"VRFs", e.exportVRFs},
+		{"L2 domains", e.exportL2Domains},
+		{"nameservers", e.exportNameservers},
+		{"locations", e.exportLocations},
+		{"racks", e.exportRacks},
+		{"devices", e.exportDevices},
+		{"subnets", e.exportSubnets},
+		{"NATs", e.exportNATs},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			return fmt.Errorf("failed to export %s: %w", step.name, err)
+		}
+	}
+	if len(e.duplicates) > 0 {
+		return fmt.Errorf("objects share natural keys and could not be imported, rename them in phpIPAM: %w", errors.Join(e.duplicates...))
+	}
+	return nil
+}
+
+// claim records the natural key of an exported object, noting a duplicate when
+// another object already has it
+func (e *exporter) claim(kind, key string, id interface{}) {
+	ref := kind + " " + key
+	if first, ok := e.claimed[ref]; ok {
+		e.duplicates = append(e.duplicates, fmt.Errorf("%s %q: IDs %s and %v", kind, key, first, id))
+		return
+	}
+	e.claimed[ref] = fmt.Sprint(id)
+}
+
+// ExportFile exports a phpIPAM instance to a file, choosing the format by extension
+func ExportFile(api *phpipam.PHPIPAM, path string, opts ExportOptions) error {
+	doc, err := Export(api, opts)
+	if err != nil {
+		return err
+	}
+	return Save(path, doc)
+}
+
+// optional ignores errors from controllers the server does not provide
+func optional(err error) error {
+	if errors.Is(err, phpipam.ErrUnsupported) {
+		return nil
+	}
+	return err
+}
+
+// unresolved formats a reference to an unknown object
+func unresolved(id string) string {
+	return "#" + id
+}
+
+// lookup resolves an ID through a mapping, "" for no reference
+func lookup(names map[string]string, id string) string {
+	if id == "" || id == "0" {
+		return ""
+	}
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return unresolved(id)
+}
+
+// lookupInt resolves a numeric ID through a mapping, "" for no reference
+func lookupInt(names map[int]string, id int) string {
+	if id == 0 {
+		return ""
+	}
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return unresolved(strconv.Itoa(id))
+}
+
+// sectionNames resolves section IDs to names
+func (e *exporter) sectionNames(ids phpipam.SectionIDs) []string {
+	var names []string
+	for _, id := range ids {
+		names = append(names, lookupInt(e.sections, id))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseSectionList resolves a "1;2" section list to names
+func (e *exporter) parseSectionList(s string) []string {
+	ids, err := phpipam.ParseSectionIDs(s)
+	if err != nil {
+		return nil
+	}
+	return e.sectionNames(ids)
+}
+
+// includeSection reports whether subnets of a section are exported
+func (e *exporter) includeSection(name string) bool {
+	return e.only == nil || e.only[name]
+}
+
+// exportTags reads the address tags
+func (e *exporter) exportTags() error {
+	tags, err := e.api.Addresses.Tags()
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		e.tags[tag.ID] = tag.Type
+		e.claim("tag", tag.Type, tag.ID)
+		e.doc.Tags = append(e.doc.Tags, Tag{
+			Name:        tag.Type,
+			Description: tag.Description,
+			ShowTag:     tag.ShowTag != 0,
+			BgColor:     tag.BgColor,
+			FgColor:     tag.FgColor,
+		})
+	}
+	return nil
+}
+
+// exportSections reads the sections
+func (e *exporter) exportSections() error {
+	sections, err := e.api.Sections.List()
+	if err != nil {
+		return err
+	}
+	for _, section := range sections {
+		if id, err := strconv.Atoi(section.ID); err == nil {
+			e.sections[id] = section.Name
+		}
+	}
+	for _, section := range sections {
+		e.claim("section", section.Name, section.ID)
+		e.doc.Sections = append(e.doc.Sections, Section{
+			Name:        section.Name,
+			Parent:      lookupInt(e.sections, section.MasterSection),
+			Description: section.Description,
+			StrictMode:  section.StrictMode != 0,
+			ShowVLAN:    section.ShowVLAN != 0,
+			ShowVRF:     section.ShowVRF != 0,
+		})
+	}
+	return nil
+}
+
+// exportVRFs reads the VRFs
+func (e *exporter) exportVRFs() error {
+	vrfs, err := e.api.VRFs.List()
+	if err != nil {
+		return err
+	}
+	for _, vrf := range vrfs {
+		if id, err := strconv.Atoi(vrf.ID); err == nil {
+			e.vrfs[id] = vrf.Name
+		}
+		e.claim("vrf", vrf.Name, vrf.ID)
+		e.doc.VRFs = append(e.doc.VRFs, VRF{
+			Name:        vrf.Name,
+			RD:          vrf.RD,
+			Description: vrf.Description,
+			Sections:    e.parseSectionList(vrf.Sections),
+		})
+	}
+	return nil
+}
+
+// exportL2Domains reads the L2 domains and their VLANs
+func (e *exporter) exportL2Domains() error {
+	domains, err := e.api.L2Domains.List()
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		e.domains[domain.ID] = domain.Name
+		e.claim("l2domain", domain.Name, domain.ID)
+		e.doc.L2Domains = append(e.doc.L2Domains, L2Domain{
+			Name:        domain.Name,
+			Description: domain.Description,
+			Sections:    e.sectionNames(domain.Permissions),
+		})
+
+		vlans, err := e.api.L2Domains.GetVLANs(domain.ID)
+		if err != nil {
+			return fmt.Errorf("L2 domain %s: %w", domain.Name, err)
+		}
+		for _, vlan := range vlans {
+			number, err := vlan.VLANNumber()
+			if err != nil {
+				return fmt.Errorf("VLAN %s: %w", vlan.ID, err)
+			}
+			if id, err := strconv.Atoi(vlan.ID); err == nil {
+				e.vlans[id] = VLANRef{Domain: domain.Name, Number: number}
+			}
+			e.claim("vlan", VLANKey(domain.Name, number), vlan.ID)
+			e.doc.VLANs = append(e.doc.VLANs, VLAN{
+				Domain:      domain.Name,
+				Number:      number,
+				Name:        vlan.Name,
+				Description: vlan.Description,
+			})
+		}
+	}
+	return nil
+}
+
+// exportNameservers reads the nameserver sets
+func (e *exporter) exportNameservers() error {
+	nameservers, err := e.api.Tools.GetNameservers()
+	if err != nil {
+		return err
+	}
+	for _, ns := range nameservers {
+		if id, err := strconv.Atoi(ns.ID); err == nil {
+			e.nameservers[id] = ns.Name
+		}
+		e.claim("nameserver", ns.Name, ns.ID)
+		e.doc.Nameservers = append(e.doc.Nameservers, Nameserver{
+			Name:        ns.Name,
+			Description: ns.Description,
+			Servers:     ns.Entries(),
+			Sections:    e.sectionNames(ns.Permissions),
+		})
+	}
+	return nil
+}
+
+// exportLocations reads the locations
+func (e *exporter) exportLocations() error {
+	locations, err := e.api.Tools.GetLocations()
+	if err != nil {
+		return optional(err)
+	}
+	for _, location := range locations {
+		e.locations[location.ID] = location.Name
+		e.claim("location", location.Name, location.ID)
+		e.doc.Locations = append(e.doc.Locations, Location{
+			Name:        location.Name,
+			Description: location.Description,
+			Address:     location.Address,
+			Lat:         location.Lat,
+			Long:        location.Long,
+		})
+	}
+	return nil
+}
+
+// exportRacks reads the racks
+func (e *exporter) exportRacks() error {
+	racks, err := e.api.Tools.GetRacks()
+	if err != nil {
+		return optional(err)
+	}
+	for _, rack := range racks {
+		e.racks[rack.ID] = rack.Name
+		e.claim("rack", rack.Name, rack.ID)
+		size, _ := strconv.Atoi(rack.Size)
+		e.doc.Racks = append(e.doc.Racks, Rack{
+			Name:        rack.Name,
+			Location:    lookup(e.locations, rack.Location),
+			Size:        size,
+			Description: rack.Description,
+		})
+	}
+	return nil
+}
+
+// exportDevices reads the devices
+func (e *exporter) exportDevices() error {
+	devices, err := e.api.Devices.List()
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		e.devices[device.ID] = device.Hostname
+		e.claim("device", device.Hostname, device.ID)
+		rackStart, _ := strconv.Atoi(device.RackStart)
+		rackSize, _ := strconv.Atoi(device.RackSize)
+		e.doc.Devices = append(e.doc.Devices, Device{
+			Hostname:    device.Hostname,
+			IP:          device.IPAddr,
+			Description: device.Description,
+			Sections:    e.parseSectionList(device.Sections),
+			Location:    lookup(e.locations, device.Location),
+			Rack:        lookup(e.racks, device.Rack),
+			RackStart:   rackStart,
+			RackSize:    rackSize,
+		})
+	}
+	return nil
+}
+
+// exportSubnets reads the folders, subnets and addresses of the exported sections
+func (e *exporter) exportSubnets() error {
+	sectionIDs := make([]int, 0, len(e.sections))
+	for id := range e.sections {
+		sectionIDs = append(sectionIDs, id)
+	}
+	sort.Ints(sectionIDs)
+
+	var subnets []phpipam.Subnet
+	for _, id := range sectionIDs {
+		if !e.includeSection(e.sections[id]) {
+			continue
+		}
+		list, err := e.api.Sections.GetSubnets(strconv.Itoa(id))
+		if err != nil {
+			return fmt.Errorf("section %s: %w", e.sections[id], err)
+		}
+		subnets = append(subnets, list...)
+	}
+
+	byID := make(map[int]*phpipam.Subnet, len(subnets))
+	for i := range subnets {
+		byID[subnets[i].ID] = &subnets[i]
+	}
+	for i := range subnets {
+		if subnets[i].IsFolder == 1 {
+			e.folderPath(byID, &subnets[i])
+			folder := e.folders[subnets[i].ID]
+			e.claim("folder", folder.Key(), subnets[i].ID)
+			e.doc.Folders = append(e.doc.Folders, folder)
+		}
+	}
+
+	for i := range subnets {
+		s := &subnets[i]
+		if s.IsFolder == 1 {
+			continue
+		}
+		prefix, err := s.Prefix()
+		if err != nil {
+			return fmt.Errorf("subnet %d: %w", s.ID, err)
+		}
+		subnet := Subnet{
+			Section:     lookupInt(e.sections, s.SectionID),
+			CIDR:        prefix.String(),
+			Description: s.Description,
+			IsPool:      s.IsPool != 0,
+			ShowName:    s.ShowName != 0,
+			Folder:      e.folders[s.MasterSubnetID].Path,
+			Nameserver:  lookupInt(e.nameservers, s.NameserverID),
+		}
+		if id, ok := s.GetVrfID(); ok {
+			subnet.VRF = lookupInt(e.vrfs, id)
+		}
+		if id, ok := s.GetVlanID(); ok && id != 0 {
+			if ref, ok := e.vlans[id]; ok {
+				subnet.VLAN = &ref
+			} else {
+				subnet.VLAN = &VLANRef{Domain: unresolved(strconv.Itoa(id))}
+			}
+		}
+		if id, ok := s.GetLocationID(); ok {
+			subnet.Location = lookup(e.locations, strconv.Itoa(id))
+		}
+		if id, ok := s.GetDeviceID(); ok {
+			subnet.Device = lookup(e.devices, strconv.Itoa(id))
+		}
+		e.claim("subnet", subnet.Key(), s.ID)
+		e.doc.Subnets = append(e.doc.Subnets, subnet)
+		e.subnets[s.ID] = subnet
+	}
+
+	if e.opts.SkipAddresses {
+		return nil
+	}
+	return e.exportAddresses(subnets)
+}
+
+// folderPath returns the path of a folder, following its enclosing folders, and
+// records the folder
+func (e *exporter) folderPath(byID map[int]*phpipam.Subnet, folder *phpipam.Subnet) string {
+	if known, ok := e.folders[folder.ID]; ok {
+		return known.Path
+	}
+
+	name := strings.ReplaceAll(folder.Description, FolderSeparator, "-")
+	if name == "" {
+		name = unresolved(strconv.Itoa(folder.ID))
+	}
+	exported := Folder{Section: lookupInt(e.sections, folder.SectionID), Path: name}
+	if parent, ok := byID[folder.MasterSubnetID]; ok && parent.IsFolder == 1 && parent.ID != folder.ID {
+		// Guard against cycles by recording the folder before walking up
+		e.folders[folder.ID] = exported
+		exported.Path = e.folderPath(byID, parent) + FolderSeparator + name
+	}
+	e.folders[folder.ID] = exported
+	return exported.Path
+}
+
+// exportAddresses reads the addresses of the exported subnets. An address found
+// in both a subnet and its child is kept once, in the more specific subnet.
+func (e *exporter) exportAddresses(subnets []phpipam.Subnet) error {
+	type found struct {
+		address Address
+		bits    int
+		id      int
+	}
+	byKey := make(map[string]found)
+
+	for i := range subnets {
+		s := &subnets[i]
+		subnet, ok := e.subnets[s.ID]
+		if !ok {
+			continue
+		}
+		prefix, _ := subnet.Prefix()
+
+		addresses, err := e.api.Subnets.GetAddresses(s.ID)
+		if err != nil {
+			return fmt.Errorf("subnet %s: %w", subnet.Key(), err)
+		}
+		for _, a := range addresses {
+			addr, err := a.Addr()
+			if err != nil {
+				return err
+			}
+			address := Address{
+				Section:     subnet.Section,
+				VRF:         subnet.VRF,
+				IP:          addr.String(),
+				Hostname:    a.Hostname,
+				Description: a.Description,
+				Owner:       a.Owner,
+				Tag:         lookupInt(e.tags, a.Tag),
+				Gateway:     a.IsGateway != 0,
+				Note:        a.Note,
+				Device:      lookup(e.devices, strconv.Itoa(a.DeviceID)),
+				Port:        a.Port,
+			}
+			if a.Mac != "" {
+				address.MAC = normalizeMAC(a.Mac)
+			}
+
+			key := address.Key()
+			if existing, ok := byKey[key]; ok && existing.bits > prefix.Bits() {
+				continue
+			}
+			byKey[key] = found{address: address, bits: prefix.Bits(), id: a.ID}
+		}
+	}
+
+	for key, f := range byKey {
+		e.doc.Addresses = append(e.doc.Addresses, f.address)
+		e.addresses[f.id] = key
+	}
+	return nil
+}
+
+// natObjects is the JSON encoding phpIPAM uses for the source and destination of a NAT
+type natObjects map[string][]phpipam.ResponseID
+
+// exportNATs reads the NAT rules
+func (e *exporter) exportNATs() error {
+	nats, err := e.api.Tools.GetNATs()
+	if err != nil {
+		return optional(err)
+	}
+
+	subnetKeys := make(map[int]string, len(e.subnets))
+	for id, subnet := range e.subnets {
+		subnetKeys[id] = subnet.Key()
+	}
+
+	for _, nat := range nats {
+		source, err := e.natEndpoints(nat.Src, subnetKeys)
+		if err != nil {
+			return fmt.Errorf("NAT %s: %w", nat.Name, err)
+		}
+		destination, err := e.natEndpoints(nat.Dst, subnetKeys)
+		if err != nil {
+			return fmt.Errorf("NAT %s: %w", nat.Name, err)
+		}
+		e.nats[nat.ID] = nat.Name
+		e.claim("nat", nat.Name, nat.ID)
+		e.doc.NATs = append(e.doc.NATs, NAT{
+			Name:        nat.Name,
+			Type:        nat.Type,
+			Device:      lookup(e.devices, nat.Device),
+			Source:      source,
+			Destination: destination,
+			Description: nat.Description,
+			Policy:      nat.Policy,
+		})
+	}
+	return nil
+}
+
+// natEndpoints resolves the objects of one side of a NAT
+func (e *exporter) natEndpoints(raw string, subnetKeys map[int]string) (NATEndpoints, error) {
+	var endpoints NATEndpoints
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "[]" || raw == "{}" {
+		return endpoints, nil
+	}
+
+	var objects natObjects
+	if err := json.Unmarshal([]byte(raw), &objects); err != nil {
+		return endpoints, fmt.Errorf("invalid NAT objects %q: %w", raw, err)
+	}
+	for _, id := range objects["subnets"] {
+		endpoints.Subnets = append(endpoints.Subnets, lookupInt(subnetKeys, id.Int()))
+	}
+	for _, id := range objects["ipaddresses"] {
+		endpoints.Addresses = append(endpoints.Addresses, lookupInt(e.addresses, id.Int()))
+	}
+	sort.Strings(endpoints.Subnets)
+	sort.Strings(endpoints.Addresses)
+	return endpoints, nil
+}
+
+// Sort orders every list of the document by natural key. Sections are ordered
+// so that parents come before their children, and subnets and addresses by
+// section, VRF and address.
+func (d *Document) Sort() {
+	depth := make(map[string]int, len(d.Sections))
+	parents := make(map[string]string, len(d.Sections))
+	for i := range d.Sections {
+		parents[d.Sections[i].Name] = d.Sections[i].Parent
+	}
+	for name := range parents {
+		for n, p := 0, parents[name]; p != "" && n < len(parents); n, p = n+1, parents[p] {
+			depth[name]++
+		}
+	}
+	sort.SliceStable(d.Sections, func(i, j int) bool {
+		a, b := d.Sections[i], d.Sections[j]
+		if depth[a.Name] != depth[b.Name] {
+			return depth[a.Name] < depth[b.Name]
+		}
+		return a.Name < b.Name
+	})
+
+	sort.SliceStable(d.VRFs, func(i, j int) bool { return d.VRFs[i].Name < d.VRFs[j].Name })
+	sort.SliceStable(d.L2Domains, func(i, j int) bool { return d.L2Domains[i].Name < d.L2Domains[j].Name })
+	sort.SliceStable(d.VLANs, func(i, j int) bool {
+		if d.VLANs[i].Domain != d.VLANs[j].Domain {
+			return d.VLANs[i].Domain < d.VLANs[j].Domain
+		}
+		return d.VLANs[i].Number < d.VLANs[j].Number
+	})
+	sort.SliceStable(d.Nameservers, func(i, j int) bool { return d.Nameservers[i].Name < d.Nameservers[j].Name })
+	sort.SliceStable(d.Locations, func(i, j int) bool { return d.Locations[i].Name < d.Locations[j].Name })
+	sort.SliceStable(d.Racks, func(i, j int) bool { return d.Racks[i].Name < d.Racks[j].Name })
+	sort.SliceStable(d.Devices, func(i, j int) bool { return d.Devices[i].Hostname < d.Devices[j].Hostname })
+	sort.SliceStable(d.Tags, func(i, j int) bool { return d.Tags[i].Name < d.Tags[j].Name })
+	sort.SliceStable(d.Folders, func(i, j int) bool {
+		if d.Folders[i].Section != d.Folders[j].Section {
+			return d.Folders[i].Section < d.Folders[j].Section
+		}
+		return d.Folders[i].Path < d.Folders[j].Path
+	})
+	sort.SliceStable(d.Subnets, func(i, j int) bool {
+		a, b := &d.Subnets[i], &d.Subnets[j]
+		if a.Section != b.Section {
+			return a.Section < b.Section
+		}
+		if a.VRF != b.VRF {
+			return a.VRF < b.VRF
+		}
+		pa, _ := a.Prefix()
+		pb, _ := b.Prefix()
+		return comparePrefixes(pa, pb) < 0
+	})
+	sort.SliceStable(d.Addresses, func(i, j int) bool {
+		a, b := &d.Addresses[i], &d.Addresses[j]
+		if a.Section != b.Section {
+			return a.Section < b.Section
+		}
+		if a.VRF != b.VRF {
+			return a.VRF < b.VRF
+		}
+		aa, _ := a.Addr()
+		ab, _ := b.Addr()
+		return aa.Less(ab)
+	})
+	sort.SliceStable(d.NATs, func(i, j int) bool { return d.NATs[i].Name < d.NATs[j].Name })
+}
+
+// comparePrefixes orders prefixes by address, then by length
+func comparePrefixes(a, b netip.Prefix) int {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c
+	}
+	return a.Bits() - b.Bits()
+}
+
+// normalizeMAC formats a MAC in standard notation, keeping unparsable values as they are
+func normalizeMAC(mac string) string {
+	if parsed, err := ipcalc.ParseMAC(mac); err == nil {
+		return parsed.String()
+	}
+	return strings.ToLower(mac)
+}
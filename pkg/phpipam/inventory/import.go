@@ -0,0 +1,903 @@
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/report"
+)
+
+// ImportMode decides what Import does with objects that already exist
+type ImportMode int
+
+const (
+	// ImportSkipExisting leaves existing objects untouched
+	ImportSkipExisting ImportMode = iota
+	// ImportOverwrite updates existing objects with the values of the document,
+	// clearing fields it leaves empty
+	ImportOverwrite
+)
+
+// ImportOptions controls Import
+type ImportOptions struct {
+	Mode ImportMode
+	// DryRun reads the current state and reports what would be done without
+	// changing anything
+	DryRun bool
+}
+
+// ImportAction is what Import did, or would do, with an object
+type ImportAction string
+
+const (
+	// ImportCreate creates a missing object
+	ImportCreate ImportAction = "create"
+	// ImportUpdate overwrites an existing object
+	ImportUpdate ImportAction = "update"
+	// ImportSkip leaves an existing object untouched
+	ImportSkip ImportAction = "skip"
+)
+
+// ImportEntry is the outcome of importing a single object
+type ImportEntry struct {
+	// Kind is the object kind, e.g. "subnet"
+	Kind   string
+	Key    string
+	Action ImportAction
+	// ID is the phpIPAM ID of the object, 0 for objects not created
+	ID  int
+	Err error
+}
+
+// ImportReport lists the outcome of every object in the document
+type ImportReport struct {
+	DryRun  bool
+	Entries []ImportEntry
+}
+
+// Count returns the number of successful entries with the given action
+func (r *ImportReport) Count(action ImportAction) int {
+	return report.Count(r.Entries, func(entry ImportEntry) bool { return entry.Action == action && entry.Err == nil })
+}
+
+// Failed returns the entries that could not be imported
+func (r *ImportReport) Failed() []ImportEntry {
+	var failed []ImportEntry
+	for _, entry := range r.Entries {
+		if entry.Err != nil {
+			failed = append(failed, entry)
+		}
+	}
+	return failed
+}
+
+// Err returns an error describing the failed entries, or nil
+func (r *ImportReport) Err() error {
+	var errs []error
+	for _, entry := range r.Failed() {
+		errs = append(errs, fmt.Errorf("%s %s: %w", entry.Kind, entry.Key, entry.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// Render writes the report in a human-readable form
+func (r *ImportReport) Render(w io.Writer) error {
+	return report.Render(w, func(b *strings.Builder) {
+		for _, entry := range r.Entries {
+			switch {
+			case entry.Err != nil:
+				fmt.Fprintf(b, "failed %s %s %s: %v\n", entry.Action, entry.Kind, entry.Key, entry.Err)
+			case entry.ID > 0:
+				fmt.Fprintf(b, "%-6s %s %s (id %d)\n", entry.Action, entry.Kind, entry.Key, entry.ID)
+			default:
+				fmt.Fprintf(b, "%-6s %s %s\n", entry.Action, entry.Kind, entry.Key)
+			}
+		}
+		b.WriteString(report.Summary("Import", r.DryRun,
+			report.Tally{N: r.Count(ImportCreate), Label: "created"},
+			report.Tally{N: r.Count(ImportUpdate), Label: "updated"},
+			report.Tally{N: r.Count(ImportSkip), Label: "skipped"},
+			report.Tally{N: len(r.Failed()), Label: "failed"}))
+	})
+}
+
+// String returns the rendered report
+func (r *ImportReport) String() string {
+	return report.String(r.Render)
+}
+
+// pendingID stands in for the ID of an object a dry run would create
+const pendingID = -1
+
+// importedSubnet is a subnet that addresses and child subnets can be placed in
+type importedSubnet struct {
+	prefix netip.Prefix
+	id     int
+}
+
+// importer creates the objects of a document and maps natural keys to IDs
+type importer struct {
+	api    *phpipam.PHPIPAM
+	opts   ImportOptions
+	report *ImportReport
+
+	ids     map[string]int              // "kind key" to ID
+	subnets map[string][]importedSubnet // section and VRF scope to subnets
+}
+
+// Import recreates the objects of a document in phpIPAM, for example to restore
+// an export or clone production into a lab instance. Objects are matched by
+// natural key; references are mapped to the IDs of the target instance.
+//
+// A failed object does not stop the import; objects referring to it fail with an
+// unknown reference. The returned error summarizes all failures.
+func Import(api *phpipam.PHPIPAM, doc *Document, opts ImportOptions) (*ImportReport, error) {
+	if err := doc.Normalize(); err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+
+	im := &importer{
+		api:     api,
+		opts:    opts,
+		report:  &ImportReport{DryRun: opts.DryRun},
+		ids:     make(map[string]int),
+		subnets: make(map[string][]importedSubnet),
+	}
+	if err := im.readState(doc); err != nil {
+		return nil, fmt.Errorf("failed to read current state: %w", err)
+	}
+
+	steps := []func(*Document){
+		im.importTags, im.importSections, im.importVRFs, im.importL2Domains, im.importVLANs,
+		im.importNameservers, im.importLocations, im.importRacks, im.importDevices,
+		im.importFolders, im.importSubnets, im.importAddresses, im.importNATs,
+	}
+	for _, step := range steps {
+		step(doc)
+	}
+
+	return im.report, im.report.Err()
+}
+
+// ImportFile imports a document from a file, choosing the format by extension
+func ImportFile(api *phpipam.PHPIPAM, path string, opts ImportOptions) (*ImportReport, error) {
+	doc, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return Import(api, doc, opts)
+}
+
+// importRef returns the key under which an object's ID is recorded
+func importRef(kind, key string) string {
+	return kind + " " + key
+}
+
+// scopeKey returns the key of the subnets sharing a section and VRF
+func scopeKey(section, vrf string) string {
+	return section + "\x00" + vrf
+}
+
+// readState records the IDs of the objects that already exist. Subnets and
+// addresses are only read for the sections the document uses.
+func (im *importer) readState(doc *Document) error {
+	sections := make(map[string]bool)
+	for i := range doc.Folders {
+		sections[doc.Folders[i].Section] = true
+	}
+	for i := range doc.Subnets {
+		sections[doc.Subnets[i].Section] = true
+	}
+	for i := range doc.Addresses {
+		sections[doc.Addresses[i].Section] = true
+	}
+
+	e := newExporter(im.api, ExportOptions{SkipAddresses: len(doc.Addresses) == 0})
+	e.only = sections
+	if err := e.run(); err != nil {
+		return err
+	}
+
+	for id, name := range e.tags {
+		im.ids[importRef("tag", name)] = id
+	}
+	for id, name := range e.sections {
+		im.ids[importRef("section", name)] = id
+	}
+	for id, name := range e.vrfs {
+		im.ids[importRef("vrf", name)] = id
+	}
+	for id, name := range e.nameservers {
+		im.ids[importRef("nameserver", name)] = id
+	}
+	for id, ref := range e.vlans {
+		im.ids[importRef("vlan", ref.Key())] = id
+	}
+	for id, folder := range e.folders {
+		im.ids[importRef("folder", folder.Key())] = id
+	}
+	for id, key := range e.addresses {
+		im.ids[importRef("address", key)] = id
+	}
+	for id, subnet := range e.subnets {
+		im.ids[importRef("subnet", subnet.Key())] = id
+		if prefix, err := subnet.Prefix(); err == nil {
+			im.addSubnet(subnet.Section, subnet.VRF, prefix, id)
+		}
+	}
+
+	byName := []struct {
+		kind  string
+		names map[string]string
+	}{
+		{"l2domain", e.domains},
+		{"location", e.locations},
+		{"rack", e.racks},
+		{"device", e.devices},
+		{"nat", e.nats},
+	}
+	for _, m := range byName {
+		for id, name := range m.names {
+			if n, err := strconv.Atoi(id); err == nil {
+				im.ids[importRef(m.kind, name)] = n
+			}
+		}
+	}
+	return nil
+}
+
+// addSubnet records a subnet that later subnets and addresses can be placed in
+func (im *importer) addSubnet(section, vrf string, prefix netip.Prefix, id int) {
+	key := scopeKey(section, vrf)
+	im.subnets[key] = append(im.subnets[key], importedSubnet{prefix: prefix, id: id})
+}
+
+// containing returns the ID of the most specific subnet in a section and VRF
+// that contains addr and is shorter than bits
+func (im *importer) containing(section, vrf string, addr netip.Addr, bits int) (int, bool) {
+	best, bestBits := 0, -1
+	for _, s := range im.subnets[scopeKey(section, vrf)] {
+		if s.prefix.Bits() < bits && s.prefix.Bits() > bestBits && s.prefix.Contains(addr) {
+			best, bestBits = s.id, s.prefix.Bits()
+		}
+	}
+	return best, bestBits >= 0
+}
+
+// resolver maps references of one object to IDs, keeping the first error
+type resolver struct {
+	ids map[string]int
+	err error
+}
+
+// id resolves a reference, 0 for an empty key
+func (r *resolver) id(kind, key string) int {
+	if key == "" {
+		return 0
+	}
+	id, ok := r.ids[importRef(kind, key)]
+	if !ok && r.err == nil {
+		r.err = fmt.Errorf("unknown %s %q", kind, key)
+	}
+	return id
+}
+
+// idString resolves a reference to a string ID, "" for an empty key
+func (r *resolver) idString(kind, key string) string {
+	if id := r.id(kind, key); id != 0 {
+		return strconv.Itoa(id)
+	}
+	return ""
+}
+
+// sectionIDs resolves section names
+func (r *resolver) sectionIDs(names []string) phpipam.SectionIDs {
+	ids := make(phpipam.SectionIDs, 0, len(names))
+	for _, name := range names {
+		ids = append(ids, r.id("section", name))
+	}
+	return ids
+}
+
+// resolver returns a resolver over the known IDs
+func (im *importer) resolver() *resolver {
+	return &resolver{ids: im.ids}
+}
+
+// apply creates or updates an object, or records why it was skipped or failed.
+// It returns the object's ID, pendingID for a dry-run create, and false when the
+// object does not exist afterwards.
+func (im *importer) apply(kind, key string, refErr error, create func() (int, error), update func(id int) error) (int, bool) {
+	entry := ImportEntry{Kind: kind, Key: key}
+	ref := importRef(kind, key)
+	id, exists := im.ids[ref]
+	if exists {
+		entry.ID = id
+		entry.Action = ImportSkip
+		if im.opts.Mode == ImportOverwrite && update != nil {
+			entry.Action = ImportUpdate
+		}
+	} else {
+		entry.Action = ImportCreate
+	}
+
+	switch {
+	case refErr != nil && entry.Action != ImportSkip:
+		entry.Err = refErr
+	case im.opts.DryRun && entry.Action == ImportCreate:
+		id, exists = pendingID, true
+		im.ids[ref] = id
+	case im.opts.DryRun:
+	case entry.Action == ImportCreate:
+		created, err := create()
+		if err == nil && created == 0 {
+			err = fmt.Errorf("phpIPAM did not return the ID of the created %s", kind)
+		}
+		if err == nil {
+			id, exists = created, true
+			im.ids[ref] = id
+			entry.ID = id
+		}
+		entry.Err = err
+	case entry.Action == ImportUpdate:
+		entry.Err = update(id)
+	}
+
+	if entry.ID == pendingID {
+		entry.ID = 0
+	}
+	im.report.Entries = append(im.report.Entries, entry)
+	return id, exists
+}
+
+// setRef sets a reference field of a patch to an ID, or to null for none
+func setRef(patch *phpipam.Patch, field string, id int) {
+	if id != 0 {
+		patch.Set(field, id)
+	} else {
+		patch.Clear(field)
+	}
+}
+
+// atoi converts a string ID returned by phpIPAM
+func atoi(id string) (int, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID %q", id)
+	}
+	return n, nil
+}
+
+// boolInt converts a flag to phpIPAM's 0/1 representation
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// importTags creates the address tags
+func (im *importer) importTags(doc *Document) {
+	created := false
+	for i := range doc.Tags {
+		t := &doc.Tags[i]
+		tag := &phpipam.Tag{
+			Type:        t.Name,
+			Description: t.Description,
+			ShowTag:     boolInt(t.ShowTag),
+			BgColor:     t.BgColor,
+			FgColor:     t.FgColor,
+		}
+		im.apply("tag", t.Key(), nil, func() (int, error) {
+			result, err := im.api.Tools.CreateIPTag(tag)
+			if err != nil {
+				return 0, err
+			}
+			created = true
+			return result.ID, nil
+		}, func(id int) error {
+			patch := phpipam.NewPatch().
+				Set("description", t.Description).
+				Set("showtag", boolInt(t.ShowTag)).
+				Set("bgcolor", t.BgColor).
+				Set("fgcolor", t.FgColor)
+			return im.api.Tools.PatchIPTag(id, patch)
+		})
+	}
+	if created {
+		im.api.Addresses.RefreshTags()
+	}
+}
+
+// importSections creates the sections, parents first
+func (im *importer) importSections(doc *Document) {
+	sorted := &Document{Sections: append([]Section(nil), doc.Sections...)}
+	sorted.Sort()
+	sections := sorted.Sections
+
+	for i := range sections {
+		s := &sections[i]
+		r := im.resolver()
+		parent := r.id("section", s.Parent)
+		im.apply("section", s.Key(), r.err, func() (int, error) {
+			result, err := im.api.Sections.Create(&phpipam.Section{
+				Name:          s.Name,
+				Description:   s.Description,
+				MasterSection: parent,
+				StrictMode:    boolInt(s.StrictMode),
+				ShowVLAN:      boolInt(s.ShowVLAN),
+				ShowVRF:       boolInt(s.ShowVRF),
+			})
+			if err != nil {
+				return 0, err
+			}
+			return atoi(result.ID)
+		}, func(id int) error {
+			patch := phpipam.NewPatch().
+				Set("description", s.Description).
+				Set("masterSection", parent).
+				Set("strictMode", boolInt(s.StrictMode)).
+				Set("showVLAN", boolInt(s.ShowVLAN)).
+				Set("showVRF", boolInt(s.ShowVRF))
+			return im.api.Sections.Patch(strconv.Itoa(id), patch)
+		})
+	}
+}
+
+// importVRFs creates the VRFs
+func (im *importer) importVRFs(doc *Document) {
+	for i := range doc.VRFs {
+		v := &doc.VRFs[i]
+		r := im.resolver()
+		sections := r.sectionIDs(v.Sections).String()
+		im.apply("vrf", v.Key(), r.err, func() (int, error) {
+			result, err := im.api.VRFs.Create(&phpipam.VRF{
+				Name:        v.Name,
+				RD:          v.RD,
+				Description: v.Description,
+				Sections:    sections,
+			})
+			if err != nil {
+				return 0, err
+			}
+			return atoi(result.ID)
+		}, func(id int) error {
+			patch := phpipam.NewPatch().
+				Set("rd", v.RD).
+				Set("description", v.Description).
+				Set("sections", sections)
+			return im.api.VRFs.Patch(strconv.Itoa(id), patch)
+		})
+	}
+}
+
+// importL2Domains creates the L2 domains
+func (im *importer) importL2Domains(doc *Document) {
+	for i := range doc.L2Domains {
+		l := &doc.L2Domains[i]
+		r := im.resolver()
+		sections := r.sectionIDs(l.Sections)
+		im.apply("l2domain", l.Key(), r.err, func() (int, error) {
+			result, err := im.api.L2Domains.Create(&phpipam.L2Domain{
+				Name:        l.Name,
+				Description: l.Description,
+				Permissions: sections,
+			})
+			if err != nil {
+				return 0, err
+			}
+			return atoi(result.ID)
+		}, func(id int) error {
+			patch := phpipam.NewPatch().
+				Set("description", l.Description).
+				Set("permissions", sections.String())
+			return im.api.L2Domains.Patch(strconv.Itoa(id), patch)
+		})
+	}
+}
+
+// importVLANs creates the VLANs
+func (im *importer) importVLANs(doc *Document) {
+	for i := range doc.VLANs {
+		v := &doc.VLANs[i]
+		r := im.resolver()
+		domain := r.idString("l2domain", v.Domain)
+		im.apply("vlan", v.Key(), r.err, func() (int, error) {
+			result, err := im.api.VLANs.Create(&phpipam.VLAN{
+				DomainID:    domain,
+				Number:      strconv.Itoa(v.Number),
+				Name:        v.Name,
+				Description: v.Description,
+			})
+			if err != nil {
+				return 0, err
+			}
+			return atoi(result.ID)
+		}, func(id int) error {
+			patch := phpipam.NewPatch().
+				Set("name", v.Name).
+				Set("description", v.Description)
+			return im.api.VLANs.Patch(strconv.Itoa(id), patch)
+		})
+	}
+}
+
+// importNameservers creates the nameserver sets
+func (im *importer) importNameservers(doc *Document) {
+	for i := range doc.Nameservers {
+		n := &doc.Nameservers[i]
+		r := im.resolver()
+		nameserver := &phpipam.Nameserver{
+			Name:        n.Name,
+			Description: n.Description,
+			Permissions: r.sectionIDs(n.Sections),
+			Namesrv1:    strings.Join(n.Servers, ";"),
+		}
+		im.apply("nameserver", n.Key(), r.err, func() (int, error) {
+			result, err := im.api.Tools.CreateNameserver(nameserver)
+			if err != nil {
+				return 0, err
+			}
+			return atoi(result.ID)
+		}, func(id int) error {
+			patch := phpipam.NewPatch().
+				Set("description", nameserver.Description).
+				Set("permissions", nameserver.Permissions.String()).
+				Set("namesrv1", nameserver.Namesrv1)
+			return im.api.Tools.PatchNameserver(strconv.Itoa(id), patch)
+		})
+	}
+}
+
+// importLocations creates the locations
+func (im *importer) importLocations(doc *Document) {
+	for i := range doc.Locations {
+		l := &doc.Locations[i]
+		location := &phpipam.Location{
+			Name:        l.Name,
+			Description: l.Description,
+			Address:     l.Address,
+			Lat:         l.Lat,
+			Long:        l.Long,
+		}
+		im.apply("location", l.Key(), nil, func() (int, error) {
+			result, err := im.api.Tools.CreateLocation(location)
+			if err != nil {
+				return 0, err
+			}
+			return atoi(result.ID)
+		}, func(id int) error {
+			patch := phpipam.NewPatch().
+				Set("description", l.Description).
+				Set("address", l.Address).
+				Set("lat", l.Lat).
+				Set("long", l.Long)
+			return im.api.Tools.PatchLocation(strconv.Itoa(id), patch)
+		})
+	}
+}
+
+// importRacks creates the racks
+func (im *importer) importRacks(doc *Document) {
+	for i := range doc.Racks {
+		k := &doc.Racks[i]
+		r := im.resolver()
+		location := r.id("location", k.Location)
+		rack := &phpipam.Rack{
+			Name:        k.Name,
+			Description: k.Description,
+		}
+		if location != 0 {
+			rack.Location = strconv.Itoa(location)
+		}
+		if k.Size != 0 {
+			rack.Size = strconv.Itoa(k.Size)
+		}
+		im.apply("rack", k.Key(), r.err, func() (int, error) {
+			result, err := im.api.Tools.CreateRack(rack)
+			if err != nil {
+				return 0, err
+			}
+			return atoi(result.ID)
+		}, func(id int) error {
+			patch := phpipam.NewPatch().
+				Set("size", k.Size).
+				Set("description", k.Description)
+			setRef(patch, "location", location)
+			return im.api.Tools.PatchRack(strconv.Itoa(id), patch)
+		})
+	}
+}
+
+// importDevices creates the devices
+func (im *importer) importDevices(doc *Document) {
+	for i := range doc.Devices {
+		d := &doc.Devices[i]
+		r := im.resolver()
+		sections := r.sectionIDs(d.Sections).String()
+		location := r.id("location", d.Location)
+		rack := r.id("rack", d.Rack)
+		device := &phpipam.Device{
+			Hostname:    d.Hostname,
+			IPAddr:      d.IP,
+			Description: d.Description,
+			Sections:    sections,
+		}
+		if location != 0 {
+			device.Location = strconv.Itoa(location)
+		}
+		if rack != 0 {
+			device.Rack = strconv.Itoa(rack)
+		}
+		if d.RackStart != 0 {
+			device.RackStart = strconv.Itoa(d.RackStart)
+		}
+		if d.RackSize != 0 {
+			device.RackSize = strconv.Itoa(d.RackSize)
+		}
+		im.apply("device", d.Key(), r.err, func() (int, error) {
+			result, err := im.api.Devices.Create(device)
+			if err != nil {
+				return 0, err
+			}
+			return atoi(result.ID)
+		}, func(id int) error {
+			patch := phpipam.NewPatch().
+				Set("ip_addr", d.IP).
+				Set("description", d.Description).
+				Set("sections", sections).
+				Set("rack_start", d.RackStart).
+				Set("rack_size", d.RackSize)
+			setRef(patch, "location", location)
+			setRef(patch, "rack", rack)
+			return im.api.Devices.Patch(strconv.Itoa(id), patch)
+		})
+	}
+}
+
+// importFolders creates the folders, enclosing folders first
+func (im *importer) importFolders(doc *Document) {
+	folders := append([]Folder(nil), doc.Folders...)
+	sort.SliceStable(folders, func(i, j int) bool {
+		return strings.Count(folders[i].Path, FolderSeparator) < strings.Count(folders[j].Path, FolderSeparator)
+	})
+
+	for i := range folders {
+		f := &folders[i]
+		r := im.resolver()
+		section := r.id("section", f.Section)
+		parent := 0
+		if f.Parent() != "" {
+			parent = r.id("folder", FolderKey(f.Section, f.Parent()))
+		}
+		im.apply("folder", f.Key(), r.err, func() (int, error) {
+			result, err := im.api.Subnets.CreateFolder(&phpipam.Subnet{
+				SectionID:      section,
+				Description:    f.Name(),
+				MasterSubnetID: parent,
+			})
+			if err != nil {
+				return 0, err
+			}
+			return result.ID, nil
+		}, nil)
+	}
+}
+
+// importSubnets creates the subnets, larger subnets first so they can be nested
+func (im *importer) importSubnets(doc *Document) {
+	subnets := append([]Subnet(nil), doc.Subnets...)
+	sort.SliceStable(subnets, func(i, j int) bool {
+		pi, _ := subnets[i].Prefix()
+		pj, _ := subnets[j].Prefix()
+		return pi.Bits() < pj.Bits()
+	})
+
+	for i := range subnets {
+		s := &subnets[i]
+		prefix, err := s.Prefix()
+		if err != nil {
+			im.apply("subnet", s.Key(), err, nil, nil)
+			continue
+		}
+
+		r := im.resolver()
+		section := r.id("section", s.Section)
+		vrf := r.id("vrf", s.VRF)
+		vlan := 0
+		if s.VLAN != nil {
+			vlan = r.id("vlan", s.VLAN.Key())
+		}
+		master, ok := im.containing(s.Section, s.VRF, prefix.Addr(), prefix.Bits())
+		if !ok && s.Folder != "" {
+			master = r.id("folder", FolderKey(s.Section, s.Folder))
+		}
+		location := r.id("location", s.Location)
+		nameserver := r.id("nameserver", s.Nameserver)
+		device := r.id("device", s.Device)
+
+		id, exists := im.apply("subnet", s.Key(), r.err, func() (int, error) {
+			subnet := &phpipam.Subnet{
+				Subnet:         prefix.Addr().String(),
+				Mask:           strconv.Itoa(prefix.Bits()),
+				SectionID:      section,
+				Description:    s.Description,
+				MasterSubnetID: master,
+				IsPool:         boolInt(s.IsPool),
+				ShowName:       boolInt(s.ShowName),
+				NameserverID:   nameserver,
+			}
+			if vrf != 0 {
+				subnet.SetVrfID(vrf)
+			}
+			if vlan != 0 {
+				subnet.SetVlanID(vlan)
+			}
+			if location != 0 {
+				subnet.SetLocationID(location)
+			}
+			if device != 0 {
+				subnet.Device = device
+			}
+			result, err := im.api.Subnets.Create(subnet)
+			if err != nil {
+				return 0, err
+			}
+			return result.ID, nil
+		}, func(id int) error {
+			patch := phpipam.NewSubnetPatch().
+				SetDescription(s.Description).
+				SetMasterSubnetID(master).
+				SetIsPool(s.IsPool).
+				SetShowName(s.ShowName).
+				SetNameserverID(nameserver)
+			if vlan != 0 {
+				patch.SetVlanID(vlan)
+			} else {
+				patch.ClearVlan()
+			}
+			if location != 0 {
+				patch.SetLocationID(location)
+			} else {
+				patch.ClearLocation()
+			}
+			if device != 0 {
+				patch.SetDeviceID(device)
+			} else {
+				patch.ClearDevice()
+			}
+			return im.api.Subnets.Patch(id, patch)
+		})
+
+		// Subnets that existed before were indexed by readState
+		if exists && !im.known(s.Section, s.VRF, prefix) {
+			im.addSubnet(s.Section, s.VRF, prefix, id)
+		}
+	}
+}
+
+// known reports whether a subnet is already indexed
+func (im *importer) known(section, vrf string, prefix netip.Prefix) bool {
+	for _, s := range im.subnets[scopeKey(section, vrf)] {
+		if s.prefix == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// importAddresses creates the addresses in the most specific subnet containing them
+func (im *importer) importAddresses(doc *Document) {
+	for i := range doc.Addresses {
+		a := &doc.Addresses[i]
+		addr, err := a.Addr()
+		if err != nil {
+			im.apply("address", a.Key(), err, nil, nil)
+			continue
+		}
+
+		r := im.resolver()
+		tag := r.id("tag", a.Tag)
+		device := r.id("device", a.Device)
+		subnet, ok := im.containing(a.Section, a.VRF, addr, addr.BitLen()+1)
+		if !ok && r.err == nil {
+			r.err = fmt.Errorf("no subnet contains %s", addr)
+		}
+
+		im.apply("address", a.Key(), r.err, func() (int, error) {
+			result, err := im.api.Addresses.Create(&phpipam.Address{
+				SubnetID:    subnet,
+				IP:          addr.String(),
+				Hostname:    a.Hostname,
+				Description: a.Description,
+				Mac:         a.MAC,
+				Owner:       a.Owner,
+				Tag:         tag,
+				IsGateway:   boolInt(a.Gateway),
+				Note:        a.Note,
+				DeviceID:    device,
+				Port:        a.Port,
+			})
+			if err != nil {
+				return 0, err
+			}
+			return result.ID, nil
+		}, func(id int) error {
+			patch := phpipam.NewAddressPatch().
+				SetHostname(a.Hostname).
+				SetDescription(a.Description).
+				SetMac(a.MAC).
+				SetOwner(a.Owner).
+				SetIsGateway(a.Gateway).
+				SetNote(a.Note).
+				SetDeviceID(device).
+				SetPort(a.Port)
+			if tag != 0 {
+				patch.SetTag(tag)
+			}
+			return im.api.Addresses.Patch(id, patch)
+		})
+	}
+}
+
+// importNATs creates the NAT rules
+func (im *importer) importNATs(doc *Document) {
+	for i := range doc.NATs {
+		n := &doc.NATs[i]
+		r := im.resolver()
+		device := r.id("device", n.Device)
+		nat := &phpipam.NAT{
+			Name:        n.Name,
+			Type:        n.Type,
+			Src:         r.natObjects(n.Source),
+			Dst:         r.natObjects(n.Destination),
+			Description: n.Description,
+			Policy:      n.Policy,
+		}
+		if device != 0 {
+			nat.Device = strconv.Itoa(device)
+		}
+		im.apply("nat", n.Key(), r.err, func() (int, error) {
+			result, err := im.api.Tools.CreateNAT(nat)
+			if err != nil {
+				return 0, err
+			}
+			return atoi(result.ID)
+		}, func(id int) error {
+			patch := phpipam.NewPatch().
+				Set("type", nat.Type).
+				Set("src", nat.Src).
+				Set("dst", nat.Dst).
+				Set("description", nat.Description).
+				Set("policy", nat.Policy)
+			setRef(patch, "device", device)
+			return im.api.Tools.PatchNAT(strconv.Itoa(id), patch)
+		})
+	}
+}
+
+// natObjects encodes one side of a NAT the way phpIPAM stores it
+func (r *resolver) natObjects(endpoints NATEndpoints) string {
+	objects := make(map[string][]string)
+	for _, key := range endpoints.Subnets {
+		objects["subnets"] = append(objects["subnets"], strconv.Itoa(r.id("subnet", key)))
+	}
+	for _, key := range endpoints.Addresses {
+		objects["ipaddresses"] = append(objects["ipaddresses"], strconv.Itoa(r.id("address", key)))
+	}
+	if len(objects) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(objects)
+	return string(data)
+}
@@ -0,0 +1,235 @@
+package inventory
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+)
+
+const (
+	notFound = `{"code":404,"success":false,"message":"No results (filter applied)"}`
+	ok       = `{"code":200,"success":true}`
+	rejected = `{"code":500,"success":false,"message":"Operation failed"}`
+)
+
+// newFakeIPAM serves hand-written phpIPAM responses by "METHOD endpoint" and
+// records the requests that change objects as "METHOD endpoint body"
+func newFakeIPAM(t *testing.T, responses map[string]string) (*phpipam.PHPIPAM, *[]string) {
+	t.Helper()
+	var writes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/test/"), "/")
+		key := r.Method + " " + endpoint
+		if r.Method != "GET" {
+			body, _ := io.ReadAll(r.Body)
+			writes = append(writes, key+" "+string(body))
+		}
+		body, ok := responses[key]
+		if !ok {
+			t.Errorf("unexpected request %s", key)
+			body = `{"code":400,"success":false,"message":"Invalid request"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := phpipam.NewTokenClient(srv.URL+"/api/", "test", "token", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api, &writes
+}
+
+// labResponses returns the responses for a phpIPAM holding the Used tag, the
+// Production section with 10.0.0.0/24, location DC1 with rack R1 and the
+// device sw1 in that rack, followed by the responses to the writes of an import
+// of labDocument
+func labResponses() map[string]string {
+	return map[string]string{
+		"GET addresses/tags":      `{"code":200,"success":true,"data":[{"id":2,"type":"Used"}]}`,
+		"GET sections":            `{"code":200,"success":true,"data":[{"id":"1","name":"Production"}]}`,
+		"GET vrf":                 notFound,
+		"GET l2domains":           notFound,
+		"GET tools/nameservers":   notFound,
+		"GET tools/locations":     `{"code":200,"success":true,"data":[{"id":"3","name":"DC1","description":"Main"}]}`,
+		"GET tools/racks":         `{"code":200,"success":true,"data":[{"id":"4","name":"R1","location":"3"}]}`,
+		"GET devices":             `{"code":200,"success":true,"data":[{"id":"5","hostname":"sw1","description":"core","location":"3","rack":"4"}]}`,
+		"GET sections/1/subnets":  `{"code":200,"success":true,"data":[{"id":7,"subnet":"10.0.0.0","mask":"24","sectionId":1}]}`,
+		"GET subnets/7/addresses": notFound,
+		"GET tools/nat":           notFound,
+		"POST sections":           `{"code":201,"success":true,"id":"2","data":{"id":"2","name":"Lab"}}`,
+		"POST subnets":            `{"code":201,"success":true,"id":"8","data":{"id":8,"subnet":"10.0.1.0","mask":"24","sectionId":2}}`,
+		"POST addresses":          `{"code":201,"success":true,"id":"9","data":{"id":9,"subnetId":8,"ip":"10.0.1.5"}}`,
+		"PATCH tools/tags/2":      ok,
+		"PATCH sections/1":        ok,
+		"PATCH tools/locations/3": ok,
+		"PATCH tools/racks/4":     ok,
+		"PATCH devices/5":         ok,
+		"PATCH subnets/7":         ok,
+	}
+}
+
+// labDocument declares the existing objects of labResponses, a Lab section
+// with a subnet and an address, a rack in an unknown location and a device in
+// that rack. sw1 no longer has a description, location or rack.
+func labDocument() *Document {
+	return &Document{
+		Tags:      []Tag{{Name: "Used", BgColor: "#ffffff"}},
+		Sections:  []Section{{Name: "Production"}, {Name: "Lab"}},
+		Locations: []Location{{Name: "DC1", Description: "Main"}},
+		Racks: []Rack{
+			{Name: "R1", Location: "DC1", Size: 42},
+			{Name: "R2", Location: "DC2"},
+		},
+		Devices: []Device{
+			{Hostname: "sw1"},
+			{Hostname: "sw2", Rack: "R2"},
+		},
+		Subnets: []Subnet{
+			{Section: "Production", CIDR: "10.0.0.0/24"},
+			{Section: "Lab", CIDR: "10.0.1.0/24"},
+		},
+		Addresses: []Address{{Section: "Lab", IP: "10.0.1.5"}},
+	}
+}
+
+// requestLines returns the method and endpoint of recorded writes
+func requestLines(writes []string) string {
+	lines := make([]string, len(writes))
+	for i, write := range writes {
+		fields := strings.Fields(write)
+		lines[i] = fields[0] + " " + fields[1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// findWrite returns the recorded write to an endpoint, or ""
+func findWrite(writes []string, request string) string {
+	for _, write := range writes {
+		if strings.HasPrefix(write, request+" ") {
+			return write
+		}
+	}
+	return ""
+}
+
+func TestImportDryRun(t *testing.T) {
+	api, writes := newFakeIPAM(t, labResponses())
+
+	report, err := Import(api, labDocument(), ImportOptions{Mode: ImportOverwrite, DryRun: true})
+	if err == nil {
+		t.Error("Import succeeded with unknown references")
+	}
+	if len(*writes) != 0 {
+		t.Errorf("dry run sent:\n%s", requestLines(*writes))
+	}
+
+	// The Lab subnet and address are placed in objects the run would create
+	want := `update tag Used (id 2)
+create section Lab
+update section Production (id 1)
+update location DC1 (id 3)
+update rack R1 (id 4)
+failed create rack R2: unknown location "DC2"
+update device sw1 (id 5)
+failed create device sw2: unknown rack "R2"
+update subnet 10.0.0.0/24 in Production (id 7)
+create subnet 10.0.1.0/24 in Lab
+create address 10.0.1.5 in Lab
+Dry run: 3 created, 6 updated, 0 skipped, 2 failed.
+`
+	if got := report.String(); got != want {
+		t.Errorf("report:\n%s\nwant:\n%s", got, want)
+	}
+	for _, entry := range report.Entries {
+		if entry.ID == pendingID {
+			t.Errorf("%s %s reports the pending ID", entry.Kind, entry.Key)
+		}
+	}
+}
+
+func TestImportSkipExisting(t *testing.T) {
+	api, writes := newFakeIPAM(t, labResponses())
+
+	report, err := Import(api, labDocument(), ImportOptions{})
+	if err == nil || !strings.Contains(err.Error(), `device sw2: unknown rack "R2"`) {
+		t.Errorf("err = %v, want the unknown rack of sw2", err)
+	}
+
+	want := "POST sections\nPOST subnets\nPOST addresses"
+	if got := requestLines(*writes); got != want {
+		t.Errorf("requests:\n%s\nwant:\n%s", got, want)
+	}
+	// References resolve to the IDs of the objects created before them
+	if write := findWrite(*writes, "POST subnets"); !strings.Contains(write, `"sectionId":2`) {
+		t.Errorf("subnet created with %s, want section 2", write)
+	}
+	if write := findWrite(*writes, "POST addresses"); !strings.Contains(write, `"subnetId":8`) {
+		t.Errorf("address created with %s, want subnet 8", write)
+	}
+
+	if got := report.Count(ImportSkip); got != 6 {
+		t.Errorf("skipped %d, want 6", got)
+	}
+	if got := report.Count(ImportCreate); got != 3 {
+		t.Errorf("created %d, want 3", got)
+	}
+	for _, entry := range report.Entries {
+		if entry.Action == ImportCreate && entry.Err == nil && entry.ID == 0 {
+			t.Errorf("%s %s created without an ID", entry.Kind, entry.Key)
+		}
+	}
+}
+
+func TestImportOverwrite(t *testing.T) {
+	responses := labResponses()
+	responses["PATCH tools/locations/3"] = rejected
+	api, writes := newFakeIPAM(t, responses)
+
+	report, err := Import(api, labDocument(), ImportOptions{Mode: ImportOverwrite})
+	if err == nil || !strings.Contains(err.Error(), "location DC1: phpIPAM API error 500: Operation failed") {
+		t.Errorf("err = %v, want the rejected location patch", err)
+	}
+
+	want := strings.Join([]string{
+		"PATCH tools/tags/2",
+		"POST sections",
+		"PATCH sections/1",
+		"PATCH tools/locations/3",
+		"PATCH tools/racks/4",
+		"PATCH devices/5",
+		"PATCH subnets/7",
+		"POST subnets",
+		"POST addresses",
+	}, "\n")
+	if got := requestLines(*writes); got != want {
+		t.Errorf("requests:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Fields the document leaves empty are sent, so they are cleared
+	for request, fields := range map[string][]string{
+		"PATCH tools/tags/2":      {`"bgcolor":"#ffffff"`, `"description":""`, `"showtag":0`},
+		"PATCH tools/locations/3": {`"address":""`, `"description":"Main"`},
+		"PATCH tools/racks/4":     {`"location":3`, `"size":42`},
+		"PATCH devices/5":         {`"description":""`, `"location":null`, `"rack":null`, `"rack_start":0`},
+	} {
+		write := findWrite(*writes, request)
+		for _, field := range fields {
+			if !strings.Contains(write, field) {
+				t.Errorf("%s does not contain %s", write, field)
+			}
+		}
+	}
+
+	if failed := report.Failed(); len(failed) != 3 {
+		t.Errorf("failed %v, want the location, R2 and sw2", failed)
+	}
+	if got := report.Count(ImportUpdate); got != 5 {
+		t.Errorf("updated %d, want 5", got)
+	}
+}
@@ -0,0 +1,455 @@
+// Package inventory defines a document format for phpIPAM configuration that
+// refers to objects by natural keys (names, CIDRs, VLAN numbers) instead of
+// database IDs, so it can be kept in version control, reviewed as a diff and
+// moved between phpIPAM instances.
+//
+// The same format is used as desired state by the reconcile package.
+package inventory
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// Version is the current document format version
+const Version = 1
+
+// DefaultDomain is the name of the L2 domain phpIPAM creates on installation
+const DefaultDomain = "default"
+
+// Document is a phpIPAM inventory keyed by natural keys
+type Document struct {
+	Version     int          `json:"version" yaml:"version"`
+	Sections    []Section    `json:"sections,omitempty" yaml:"sections,omitempty"`
+	VRFs        []VRF        `json:"vrfs,omitempty" yaml:"vrfs,omitempty"`
+	L2Domains   []L2Domain   `json:"l2domains,omitempty" yaml:"l2domains,omitempty"`
+	VLANs       []VLAN       `json:"vlans,omitempty" yaml:"vlans,omitempty"`
+	Nameservers []Nameserver `json:"nameservers,omitempty" yaml:"nameservers,omitempty"`
+	Locations   []Location   `json:"locations,omitempty" yaml:"locations,omitempty"`
+	Racks       []Rack       `json:"racks,omitempty" yaml:"racks,omitempty"`
+	Devices     []Device     `json:"devices,omitempty" yaml:"devices,omitempty"`
+	Tags        []Tag        `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Folders     []Folder     `json:"folders,omitempty" yaml:"folders,omitempty"`
+	Subnets     []Subnet     `json:"subnets,omitempty" yaml:"subnets,omitempty"`
+	Addresses   []Address    `json:"addresses,omitempty" yaml:"addresses,omitempty"`
+	NATs        []NAT        `json:"nats,omitempty" yaml:"nats,omitempty"`
+}
+
+// Section is a phpIPAM section, keyed by name
+type Section struct {
+	Name        string `json:"name" yaml:"name"`
+	Parent      string `json:"parent,omitempty" yaml:"parent,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	StrictMode  bool   `json:"strictMode,omitempty" yaml:"strictMode,omitempty"`
+	ShowVLAN    bool   `json:"showVLAN,omitempty" yaml:"showVLAN,omitempty"`
+	ShowVRF     bool   `json:"showVRF,omitempty" yaml:"showVRF,omitempty"`
+}
+
+// Key returns the natural key of the section
+func (s *Section) Key() string {
+	return s.Name
+}
+
+// VRF is a phpIPAM VRF, keyed by name
+type VRF struct {
+	Name        string `json:"name" yaml:"name"`
+	RD          string `json:"rd,omitempty" yaml:"rd,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Sections are the names of the sections the VRF is available in
+	Sections []string `json:"sections,omitempty" yaml:"sections,omitempty"`
+}
+
+// Key returns the natural key of the VRF
+func (v *VRF) Key() string {
+	return v.Name
+}
+
+// L2Domain is a phpIPAM L2 domain, keyed by name
+type L2Domain struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Sections are the names of the sections the domain is available in
+	Sections []string `json:"sections,omitempty" yaml:"sections,omitempty"`
+}
+
+// Key returns the natural key of the L2 domain
+func (l *L2Domain) Key() string {
+	return l.Name
+}
+
+// VLANRef refers to a VLAN by L2 domain name and number
+type VLANRef struct {
+	// Domain is the L2 domain name, DefaultDomain when empty
+	Domain string `json:"domain,omitempty" yaml:"domain,omitempty"`
+	Number int    `json:"number" yaml:"number"`
+}
+
+// Key returns the natural key of the referenced VLAN
+func (r VLANRef) Key() string {
+	return VLANKey(r.Domain, r.Number)
+}
+
+// VLANKey returns the natural key of a VLAN, e.g. "default/100"
+func VLANKey(domain string, number int) string {
+	if domain == "" {
+		domain = DefaultDomain
+	}
+	return domain + "/" + strconv.Itoa(number)
+}
+
+// VLAN is a phpIPAM VLAN, keyed by L2 domain and number
+type VLAN struct {
+	// Domain is the L2 domain name, DefaultDomain when empty
+	Domain      string `json:"domain,omitempty" yaml:"domain,omitempty"`
+	Number      int    `json:"number" yaml:"number"`
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Key returns the natural key of the VLAN
+func (v *VLAN) Key() string {
+	return VLANKey(v.Domain, v.Number)
+}
+
+// Nameserver is a phpIPAM nameserver set, keyed by name
+type Nameserver struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Servers     []string `json:"servers,omitempty" yaml:"servers,omitempty"`
+	// Sections are the names of the sections the set is available in
+	Sections []string `json:"sections,omitempty" yaml:"sections,omitempty"`
+}
+
+// Key returns the natural key of the nameserver set
+func (n *Nameserver) Key() string {
+	return n.Name
+}
+
+// Location is a phpIPAM location, keyed by name
+type Location struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Address     string `json:"address,omitempty" yaml:"address,omitempty"`
+	Lat         string `json:"lat,omitempty" yaml:"lat,omitempty"`
+	Long        string `json:"long,omitempty" yaml:"long,omitempty"`
+}
+
+// Key returns the natural key of the location
+func (l *Location) Key() string {
+	return l.Name
+}
+
+// Rack is a phpIPAM rack, keyed by name
+type Rack struct {
+	Name        string `json:"name" yaml:"name"`
+	Location    string `json:"location,omitempty" yaml:"location,omitempty"`
+	Size        int    `json:"size,omitempty" yaml:"size,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Key returns the natural key of the rack
+func (r *Rack) Key() string {
+	return r.Name
+}
+
+// Device is a phpIPAM device, keyed by hostname
+type Device struct {
+	Hostname    string `json:"hostname" yaml:"hostname"`
+	IP          string `json:"ip,omitempty" yaml:"ip,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Sections are the names of the sections the device is available in
+	Sections  []string `json:"sections,omitempty" yaml:"sections,omitempty"`
+	Location  string   `json:"location,omitempty" yaml:"location,omitempty"`
+	Rack      string   `json:"rack,omitempty" yaml:"rack,omitempty"`
+	RackStart int      `json:"rackStart,omitempty" yaml:"rackStart,omitempty"`
+	RackSize  int      `json:"rackSize,omitempty" yaml:"rackSize,omitempty"`
+}
+
+// Key returns the natural key of the device
+func (d *Device) Key() string {
+	return d.Hostname
+}
+
+// Tag is a phpIPAM address tag, keyed by name
+type Tag struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	ShowTag     bool   `json:"showTag,omitempty" yaml:"showTag,omitempty"`
+	BgColor     string `json:"bgColor,omitempty" yaml:"bgColor,omitempty"`
+	FgColor     string `json:"fgColor,omitempty" yaml:"fgColor,omitempty"`
+}
+
+// Key returns the natural key of the tag
+func (t *Tag) Key() string {
+	return t.Name
+}
+
+// FolderSeparator separates the folder names of a folder path
+const FolderSeparator = "/"
+
+// Folder is a phpIPAM folder, keyed by section and path. The path lists the
+// names of the enclosing folders and the folder itself, e.g. "Sites/Berlin".
+type Folder struct {
+	Section string `json:"section" yaml:"section"`
+	Path    string `json:"path" yaml:"path"`
+}
+
+// Key returns the natural key of the folder
+func (f *Folder) Key() string {
+	return FolderKey(f.Section, f.Path)
+}
+
+// Name returns the last element of the folder path
+func (f *Folder) Name() string {
+	return f.Path[strings.LastIndex(f.Path, FolderSeparator)+1:]
+}
+
+// Parent returns the path of the enclosing folder, "" for a top-level folder
+func (f *Folder) Parent() string {
+	if i := strings.LastIndex(f.Path, FolderSeparator); i >= 0 {
+		return f.Path[:i]
+	}
+	return ""
+}
+
+// FolderKey returns the natural key of a folder, e.g. "Sites/Berlin in Production"
+func FolderKey(section, path string) string {
+	return path + " in " + section
+}
+
+// Subnet is a phpIPAM subnet, keyed by section, VRF and CIDR. The master subnet
+// is not stored; it is the smallest subnet containing this one in the same
+// section and VRF, or the folder when the subnet is not nested in another one.
+type Subnet struct {
+	Section     string   `json:"section" yaml:"section"`
+	VRF         string   `json:"vrf,omitempty" yaml:"vrf,omitempty"`
+	CIDR        string   `json:"cidr" yaml:"cidr"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	VLAN        *VLANRef `json:"vlan,omitempty" yaml:"vlan,omitempty"`
+	IsPool      bool     `json:"isPool,omitempty" yaml:"isPool,omitempty"`
+	ShowName    bool     `json:"showName,omitempty" yaml:"showName,omitempty"`
+	// Folder is the path of the folder the subnet is placed in
+	Folder     string `json:"folder,omitempty" yaml:"folder,omitempty"`
+	Location   string `json:"location,omitempty" yaml:"location,omitempty"`
+	Nameserver string `json:"nameserver,omitempty" yaml:"nameserver,omitempty"`
+	Device     string `json:"device,omitempty" yaml:"device,omitempty"`
+}
+
+// Key returns the natural key of the subnet
+func (s *Subnet) Key() string {
+	return SubnetKey(s.Section, s.VRF, s.CIDR)
+}
+
+// Prefix returns the parsed CIDR of the subnet
+func (s *Subnet) Prefix() (netip.Prefix, error) {
+	return ipcalc.ParseCIDR(s.CIDR)
+}
+
+// SubnetKey returns the natural key of a subnet, e.g. "10.0.0.0/24 in Production"
+// or "10.0.0.0/24 in Production vrf blue"
+func SubnetKey(section, vrf, cidr string) string {
+	key := cidr + " in " + section
+	if vrf != "" {
+		key += " vrf " + vrf
+	}
+	return key
+}
+
+// Address is a phpIPAM address, keyed by section, VRF and IP. The subnet is the
+// most specific subnet containing the IP in that section and VRF.
+type Address struct {
+	Section     string `json:"section" yaml:"section"`
+	VRF         string `json:"vrf,omitempty" yaml:"vrf,omitempty"`
+	IP          string `json:"ip" yaml:"ip"`
+	Hostname    string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	MAC         string `json:"mac,omitempty" yaml:"mac,omitempty"`
+	Owner       string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	// Tag is the address tag name, e.g. "Used" or "Reserved"; empty leaves the
+	// tag to phpIPAM
+	Tag     string `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Gateway bool   `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	Note    string `json:"note,omitempty" yaml:"note,omitempty"`
+	// Device is the hostname of the device the address is assigned to
+	Device string `json:"device,omitempty" yaml:"device,omitempty"`
+	Port   string `json:"port,omitempty" yaml:"port,omitempty"`
+}
+
+// Key returns the natural key of the address
+func (a *Address) Key() string {
+	return AddressKey(a.Section, a.VRF, a.IP)
+}
+
+// Addr returns the parsed IP of the address
+func (a *Address) Addr() (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(a.IP))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
+
+// AddressKey returns the natural key of an address, e.g. "10.0.0.5 in Production"
+func AddressKey(section, vrf, ip string) string {
+	return SubnetKey(section, vrf, ip)
+}
+
+// NATEndpoints are the objects on one side of a NAT, by natural key
+type NATEndpoints struct {
+	Subnets   []string `json:"subnets,omitempty" yaml:"subnets,omitempty"`
+	Addresses []string `json:"addresses,omitempty" yaml:"addresses,omitempty"`
+}
+
+// NAT is a phpIPAM NAT rule, keyed by name
+type NAT struct {
+	Name        string       `json:"name" yaml:"name"`
+	Type        string       `json:"type,omitempty" yaml:"type,omitempty"`
+	Device      string       `json:"device,omitempty" yaml:"device,omitempty"`
+	Source      NATEndpoints `json:"source,omitempty" yaml:"source,omitempty"`
+	Destination NATEndpoints `json:"destination,omitempty" yaml:"destination,omitempty"`
+	Description string       `json:"description,omitempty" yaml:"description,omitempty"`
+	Policy      string       `json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+// Key returns the natural key of the NAT
+func (n *NAT) Key() string {
+	return n.Name
+}
+
+// Normalize brings the document into canonical form: CIDRs with host bits
+// cleared, IPs and MAC addresses in standard notation and the default L2 domain
+// spelled out. It returns an error for values that cannot be parsed.
+func (d *Document) Normalize() error {
+	if d.Version == 0 {
+		d.Version = Version
+	}
+	for i := range d.VLANs {
+		if d.VLANs[i].Domain == "" {
+			d.VLANs[i].Domain = DefaultDomain
+		}
+	}
+	for i := range d.Subnets {
+		s := &d.Subnets[i]
+		prefix, err := s.Prefix()
+		if err != nil {
+			return fmt.Errorf("subnet %q: %w", s.CIDR, err)
+		}
+		s.CIDR = prefix.String()
+		if s.VLAN != nil && s.VLAN.Domain == "" {
+			s.VLAN.Domain = DefaultDomain
+		}
+	}
+	for i := range d.Folders {
+		d.Folders[i].Path = strings.Trim(d.Folders[i].Path, FolderSeparator)
+	}
+	for i := range d.Addresses {
+		a := &d.Addresses[i]
+		addr, err := a.Addr()
+		if err != nil {
+			return fmt.Errorf("address %q: %w", a.IP, err)
+		}
+		a.IP = addr.String()
+		if a.MAC != "" {
+			mac, err := ipcalc.ParseMAC(a.MAC)
+			if err != nil {
+				return fmt.Errorf("address %s: invalid MAC %q", a.IP, a.MAC)
+			}
+			a.MAC = mac.String()
+		}
+	}
+	return nil
+}
+
+// Validate checks that required fields are set and natural keys are unique
+func (d *Document) Validate() error {
+	if d.Version > Version {
+		return fmt.Errorf("document version %d is newer than supported version %d", d.Version, Version)
+	}
+
+	seen := make(map[string]bool)
+	check := func(kind, key string, ok bool) error {
+		if !ok {
+			return fmt.Errorf("%s %q: missing required field", kind, key)
+		}
+		if seen[kind+" "+key] {
+			return fmt.Errorf("duplicate %s %q", kind, key)
+		}
+		seen[kind+" "+key] = true
+		return nil
+	}
+
+	for i := range d.Sections {
+		if err := check("section", d.Sections[i].Key(), d.Sections[i].Name != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.VRFs {
+		if err := check("vrf", d.VRFs[i].Key(), d.VRFs[i].Name != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.L2Domains {
+		if err := check("l2domain", d.L2Domains[i].Key(), d.L2Domains[i].Name != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.VLANs {
+		v := &d.VLANs[i]
+		if err := check("vlan", v.Key(), v.Number >= 1 && v.Number <= 4094); err != nil {
+			return err
+		}
+	}
+	for i := range d.Nameservers {
+		if err := check("nameserver", d.Nameservers[i].Key(), d.Nameservers[i].Name != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.Locations {
+		if err := check("location", d.Locations[i].Key(), d.Locations[i].Name != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.Racks {
+		if err := check("rack", d.Racks[i].Key(), d.Racks[i].Name != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.Devices {
+		if err := check("device", d.Devices[i].Key(), d.Devices[i].Hostname != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.Tags {
+		if err := check("tag", d.Tags[i].Key(), d.Tags[i].Name != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.Folders {
+		f := &d.Folders[i]
+		if err := check("folder", f.Key(), f.Section != "" && f.Path != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.Subnets {
+		s := &d.Subnets[i]
+		if err := check("subnet", s.Key(), s.Section != "" && s.CIDR != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.Addresses {
+		a := &d.Addresses[i]
+		if err := check("address", a.Key(), a.Section != "" && a.IP != ""); err != nil {
+			return err
+		}
+	}
+	for i := range d.NATs {
+		if err := check("nat", d.NATs[i].Key(), d.NATs[i].Name != ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
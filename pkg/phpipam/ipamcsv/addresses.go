@@ -0,0 +1,519 @@
+package ipamcsv
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// addressColumns are the standard address columns in the order of phpIPAM's export
+var addressColumns = []column{
+	{ColumnIP, "IP address"},
+	{ColumnState, "IP state"},
+	{ColumnDescription, "Description"},
+	{ColumnHostname, "Hostname"},
+	{ColumnMAC, "MAC address"},
+	{ColumnOwner, "Owner"},
+	{ColumnDevice, "Device"},
+	{ColumnPort, "Port"},
+	{ColumnNote, "Note"},
+}
+
+// addressAliases maps normalized headers to address columns
+var addressAliases = map[string]string{
+	"ip":          ColumnIP,
+	"ip address":  ColumnIP,
+	"ip addr":     ColumnIP,
+	"address":     ColumnIP,
+	"state":       ColumnState,
+	"ip state":    ColumnState,
+	"tag":         ColumnState,
+	"description": ColumnDescription,
+	"hostname":    ColumnHostname,
+	"host name":   ColumnHostname,
+	"dns name":    ColumnHostname,
+	"mac":         ColumnMAC,
+	"mac address": ColumnMAC,
+	"owner":       ColumnOwner,
+	"device":      ColumnDevice,
+	"switch":      ColumnDevice,
+	"port":        ColumnPort,
+	"note":        ColumnNote,
+	"notes":       ColumnNote,
+}
+
+// AddressRow is one row of an address sheet
+type AddressRow struct {
+	// Line is the line number of the row in the file
+	Line        int
+	IP          string
+	State       string
+	Description string
+	Hostname    string
+	MAC         string
+	Owner       string
+	Device      string
+	Port        string
+	Note        string
+	// Custom holds custom field values by column header
+	Custom map[string]string
+}
+
+// AddressSheet is an address CSV file
+type AddressSheet struct {
+	// Columns are the standard columns present in the file
+	Columns []string
+	// CustomFields are the custom field column headers
+	CustomFields []string
+	Rows         []AddressRow
+}
+
+// ReadAddresses parses an address CSV file. The file must have an IP column.
+func ReadAddresses(r io.Reader, opts Options) (*AddressSheet, error) {
+	t, err := readTable(r, opts, addressAliases)
+	if err != nil {
+		return nil, err
+	}
+	if !hasColumn(t.columns, ColumnIP) {
+		return nil, fmt.Errorf("missing IP address column")
+	}
+
+	sheet := &AddressSheet{Columns: t.columns, CustomFields: t.custom}
+	for _, rec := range t.records {
+		sheet.Rows = append(sheet.Rows, AddressRow{
+			Line:        rec.line,
+			IP:          rec.values[ColumnIP],
+			State:       rec.values[ColumnState],
+			Description: rec.values[ColumnDescription],
+			Hostname:    rec.values[ColumnHostname],
+			MAC:         rec.values[ColumnMAC],
+			Owner:       rec.values[ColumnOwner],
+			Device:      rec.values[ColumnDevice],
+			Port:        rec.values[ColumnPort],
+			Note:        rec.values[ColumnNote],
+			Custom:      rec.custom,
+		})
+	}
+	return sheet, nil
+}
+
+// value returns the value of a standard column
+func (row *AddressRow) value(name string) string {
+	switch name {
+	case ColumnIP:
+		return row.IP
+	case ColumnState:
+		return row.State
+	case ColumnDescription:
+		return row.Description
+	case ColumnHostname:
+		return row.Hostname
+	case ColumnMAC:
+		return row.MAC
+	case ColumnOwner:
+		return row.Owner
+	case ColumnDevice:
+		return row.Device
+	case ColumnPort:
+		return row.Port
+	case ColumnNote:
+		return row.Note
+	}
+	return ""
+}
+
+// WriteAddresses writes an address sheet. Without Columns all standard columns
+// are written, with the headers phpIPAM's export uses.
+func WriteAddresses(w io.Writer, sheet *AddressSheet, opts Options) error {
+	columns := sheet.Columns
+	if len(columns) == 0 {
+		for _, c := range addressColumns {
+			columns = append(columns, c.name)
+		}
+	}
+
+	header := labels(addressColumns, columns)
+	header = append(header, sheet.CustomFields...)
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for i := range sheet.Rows {
+		row := &sheet.Rows[i]
+		fields := make([]string, 0, len(header))
+		for _, c := range columns {
+			fields = append(fields, row.value(c))
+		}
+		for _, name := range sheet.CustomFields {
+			fields = append(fields, row.Custom[name])
+		}
+		rows = append(rows, fields)
+	}
+	return writeTable(w, opts, header, rows)
+}
+
+// labels returns the export headers of columns
+func labels(known []column, columns []string) []string {
+	header := make([]string, 0, len(columns))
+	for _, name := range columns {
+		label := name
+		for _, c := range known {
+			if c.name == name {
+				label = c.label
+			}
+		}
+		header = append(header, label)
+	}
+	return header
+}
+
+// rawObjects fetches objects with all fields, including custom fields the typed
+// structs do not carry
+func rawObjects(api *phpipam.PHPIPAM, endpoint string) (map[int]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+	if _, err := api.Client.Request("GET", endpoint, nil, &objects); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]map[string]interface{}, len(objects))
+	for _, object := range objects {
+		if id, err := strconv.Atoi(fmt.Sprint(object["id"])); err == nil {
+			byID[id] = object
+		}
+	}
+	return byID, nil
+}
+
+// deviceNames returns device hostnames by ID
+func deviceNames(api *phpipam.PHPIPAM) (map[string]string, error) {
+	devices, err := api.Devices.List()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(devices))
+	for _, device := range devices {
+		names[device.ID] = device.Hostname
+	}
+	return names, nil
+}
+
+// ExportAddresses reads the addresses of a subnet into a sheet with all standard
+// columns and the server's address custom fields
+func ExportAddresses(api *phpipam.PHPIPAM, subnetID int) (*AddressSheet, error) {
+	addresses, err := api.Subnets.GetAddresses(subnetID)
+	if err != nil {
+		return nil, err
+	}
+	defined, err := api.Addresses.GetCustomFields()
+	if err != nil {
+		return nil, err
+	}
+	devices, err := deviceNames(api)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[int]map[string]interface{}
+	customNames, customHeaders := customFieldColumns(defined)
+	if len(customNames) > 0 {
+		raw, err = rawObjects(api, fmt.Sprintf("subnets/%d/addresses", subnetID))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(addresses, func(i, j int) bool {
+		a, _ := addresses[i].Addr()
+		b, _ := addresses[j].Addr()
+		return a.Less(b)
+	})
+
+	sheet := &AddressSheet{CustomFields: customHeaders}
+	for i := range addresses {
+		a := &addresses[i]
+		row := AddressRow{
+			Line:        i + 2,
+			IP:          a.IP,
+			Description: a.Description,
+			Hostname:    a.Hostname,
+			MAC:         a.Mac,
+			Owner:       a.Owner,
+			Port:        a.Port,
+			Note:        a.Note,
+			Custom:      make(map[string]string, len(customNames)),
+		}
+		if a.Tag != 0 {
+			if row.State, err = api.Addresses.TagName(a.Tag); err != nil {
+				row.State = strconv.Itoa(a.Tag)
+			}
+		}
+		if a.DeviceID != 0 {
+			row.Device = devices[strconv.Itoa(a.DeviceID)]
+		}
+		for j, name := range customNames {
+			row.Custom[customHeaders[j]] = customValue(raw[a.ID], name)
+		}
+		sheet.Rows = append(sheet.Rows, row)
+	}
+	return sheet, nil
+}
+
+// addressImport holds what ImportAddresses looks up once per file
+type addressImport struct {
+	api    *phpipam.PHPIPAM
+	sheet  *AddressSheet
+	opts   ImportOptions
+	prefix netip.Prefix
+
+	existing map[netip.Addr]*phpipam.Address
+	raw      map[int]map[string]interface{}
+	devices  map[string]int    // lower-case hostname to ID
+	custom   map[string]string // column header to custom field name
+}
+
+// ImportAddresses validates a sheet against a subnet and creates the addresses it
+// lists. Existing addresses are updated with the columns present in the file when
+// opts.Update is set. Invalid rows are reported and skipped; the returned error
+// summarizes invalid and failed rows.
+func ImportAddresses(api *phpipam.PHPIPAM, subnetID int, sheet *AddressSheet, opts ImportOptions) (*Report, error) {
+	subnet, err := api.Subnets.Get(subnetID)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := subnet.Prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	im := &addressImport{api: api, sheet: sheet, opts: opts, prefix: prefix, existing: make(map[netip.Addr]*phpipam.Address)}
+	if err := im.load(subnetID); err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: opts.DryRun}
+	seen := make(map[netip.Addr]int)
+	for i := range sheet.Rows {
+		row := &sheet.Rows[i]
+		result := RowResult{Line: row.Line, Key: row.IP}
+
+		addr, address, problems := im.validate(row)
+		if addr.IsValid() {
+			result.Key = addr.String()
+			if line, ok := seen[addr]; ok {
+				problems = append(problems, fmt.Sprintf("duplicate of line %d", line))
+			}
+			seen[addr] = row.Line
+		}
+		if len(problems) > 0 {
+			result.Action, result.Errors = ActionInvalid, problems
+			report.Rows = append(report.Rows, result)
+			continue
+		}
+
+		result.Action = im.apply(subnetID, addr, address, row, &result)
+		report.Rows = append(report.Rows, result)
+	}
+
+	return report, report.Err()
+}
+
+// load reads the existing addresses, devices and custom field definitions
+func (im *addressImport) load(subnetID int) error {
+	addresses, err := im.api.Subnets.GetAddresses(subnetID)
+	if err != nil {
+		return err
+	}
+	for i := range addresses {
+		if addr, err := addresses[i].Addr(); err == nil {
+			im.existing[addr] = &addresses[i]
+		}
+	}
+
+	if hasColumn(im.sheet.Columns, ColumnDevice) {
+		devices, err := im.api.Devices.List()
+		if err != nil {
+			return err
+		}
+		im.devices = make(map[string]int, len(devices))
+		for _, device := range devices {
+			if id, err := strconv.Atoi(device.ID); err == nil {
+				im.devices[strings.ToLower(device.Hostname)] = id
+			}
+		}
+	}
+
+	if len(im.sheet.CustomFields) > 0 {
+		defined, err := im.api.Addresses.GetCustomFields()
+		if err != nil {
+			return err
+		}
+		if im.custom, err = customFieldNames(im.sheet.CustomFields, defined); err != nil {
+			return err
+		}
+		if im.opts.Update {
+			if im.raw, err = rawObjects(im.api, fmt.Sprintf("subnets/%d/addresses", subnetID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks a row and converts it to an address
+func (im *addressImport) validate(row *AddressRow) (netip.Addr, *phpipam.Address, []string) {
+	var problems []string
+	address := &phpipam.Address{
+		Description: row.Description,
+		Hostname:    row.Hostname,
+		Owner:       row.Owner,
+		Port:        row.Port,
+		Note:        row.Note,
+	}
+
+	addr, err := netip.ParseAddr(row.IP)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("invalid IP address %q", row.IP))
+	} else {
+		addr = addr.Unmap()
+		address.IP = addr.String()
+		if !im.prefix.Contains(addr) {
+			problems = append(problems, fmt.Sprintf("%s is outside subnet %s", addr, im.prefix))
+		}
+	}
+
+	if row.MAC != "" {
+		mac, err := ipcalc.ParseMAC(row.MAC)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid MAC address %q", row.MAC))
+		} else {
+			address.Mac = mac.String()
+		}
+	}
+	if row.State != "" {
+		tag, err := im.api.Addresses.ResolveTag(row.State)
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			address.Tag = tag.ID
+		}
+	}
+	if row.Device != "" {
+		id, ok := im.devices[strings.ToLower(row.Device)]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown device %q", row.Device))
+		}
+		address.DeviceID = id
+	}
+	return addr, address, problems
+}
+
+// patch builds an update of the columns present in the sheet that differ from
+// the existing address
+func (im *addressImport) patch(current, address *phpipam.Address, row *AddressRow) *phpipam.AddressPatch {
+	patch := phpipam.NewAddressPatch()
+	for _, c := range im.sheet.Columns {
+		switch c {
+		case ColumnState:
+			if address.Tag != 0 && address.Tag != current.Tag {
+				patch.SetTag(address.Tag)
+			}
+		case ColumnDescription:
+			if address.Description != current.Description {
+				patch.SetDescription(address.Description)
+			}
+		case ColumnHostname:
+			if address.Hostname != current.Hostname {
+				patch.SetHostname(address.Hostname)
+			}
+		case ColumnMAC:
+			if !sameMAC(address.Mac, current.Mac) {
+				patch.SetMac(address.Mac)
+			}
+		case ColumnOwner:
+			if address.Owner != current.Owner {
+				patch.SetOwner(address.Owner)
+			}
+		case ColumnDevice:
+			if address.DeviceID != current.DeviceID {
+				patch.SetDeviceID(address.DeviceID)
+			}
+		case ColumnPort:
+			if address.Port != current.Port {
+				patch.SetPort(address.Port)
+			}
+		case ColumnNote:
+			if address.Note != current.Note {
+				patch.SetNote(address.Note)
+			}
+		}
+	}
+	for _, header := range im.sheet.CustomFields {
+		name := im.custom[header]
+		if value := row.Custom[header]; value != customValue(im.raw[current.ID], name) {
+			patch.SetCustomField(name, value)
+		}
+	}
+	return patch
+}
+
+// sameMAC compares MAC addresses regardless of notation
+func sameMAC(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	ma, errA := ipcalc.ParseMAC(a)
+	mb, errB := ipcalc.ParseMAC(b)
+	return errA == nil && errB == nil && ma.String() == mb.String()
+}
+
+// apply creates or updates the address of a valid row
+func (im *addressImport) apply(subnetID int, addr netip.Addr, address *phpipam.Address, row *AddressRow, result *RowResult) Action {
+	fail := func(err error) Action {
+		result.Errors = append(result.Errors, err.Error())
+		return ActionFailed
+	}
+
+	if current, ok := im.existing[addr]; ok {
+		if !im.opts.Update {
+			return ActionSkip
+		}
+		patch := im.patch(current, address, row)
+		if patch.Len() == 0 {
+			return ActionUnchanged
+		}
+		if !im.opts.DryRun {
+			if err := im.api.Addresses.Patch(current.ID, patch); err != nil {
+				return fail(err)
+			}
+		}
+		return ActionUpdate
+	}
+
+	if im.opts.DryRun {
+		return ActionCreate
+	}
+	address.SubnetID = subnetID
+	created, err := im.api.Addresses.Create(address)
+	if err != nil {
+		return fail(err)
+	}
+	if len(im.sheet.CustomFields) > 0 {
+		patch := phpipam.NewAddressPatch()
+		for _, header := range im.sheet.CustomFields {
+			if value := row.Custom[header]; value != "" {
+				patch.SetCustomField(im.custom[header], value)
+			}
+		}
+		if patch.Len() > 0 {
+			if err := im.api.Addresses.Patch(created.ID, patch); err != nil {
+				// Leave nothing behind so that a re-run creates the row again
+				return fail(rollback(err, "address", created.ID, im.api.Addresses.Delete))
+			}
+		}
+	}
+	return ActionCreate
+}
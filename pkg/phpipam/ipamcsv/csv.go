@@ -0,0 +1,350 @@
+// Package ipamcsv reads and writes address and subnet spreadsheets in the CSV
+// layout of the phpIPAM web UI import and export, and imports them in bulk.
+//
+// Headers are matched case-insensitively and accept both the UI labels ("IP
+// address", "MAC address") and the API field names ("ip_addr", "mac"). Columns
+// that are not standard fields are treated as custom fields.
+package ipamcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/report"
+)
+
+// Standard columns
+const (
+	ColumnIP          = "ip"
+	ColumnState       = "state"
+	ColumnDescription = "description"
+	ColumnHostname    = "hostname"
+	ColumnMAC         = "mac"
+	ColumnOwner       = "owner"
+	ColumnDevice      = "device"
+	ColumnPort        = "port"
+	ColumnNote        = "note"
+	ColumnSection     = "section"
+	ColumnSubnet      = "subnet"
+	ColumnMask        = "mask"
+	ColumnVLAN        = "vlan"
+	ColumnDomain      = "domain"
+	ColumnVRF         = "vrf"
+)
+
+// column is a standard column with the header phpIPAM's export uses
+type column struct {
+	name  string
+	label string
+}
+
+// Options controls reading and writing CSV files
+type Options struct {
+	// Comma is the field delimiter, ',' when zero
+	Comma rune
+	// Headers maps additional header names to standard columns, e.g.
+	// {"Switch port": ColumnPort}
+	Headers map[string]string
+}
+
+// comma returns the field delimiter
+func (o Options) comma() rune {
+	if o.Comma == 0 {
+		return ','
+	}
+	return o.Comma
+}
+
+// normalizeHeader folds case and separators so "IP_Address" matches "ip address"
+func normalizeHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+	h = strings.NewReplacer("_", " ", "-", " ").Replace(h)
+	return strings.Join(strings.Fields(h), " ")
+}
+
+// record is a data row with its values by column
+type record struct {
+	line   int
+	values map[string]string
+	custom map[string]string
+}
+
+// table is a parsed CSV file
+type table struct {
+	columns []string // standard columns present, in file order
+	custom  []string // custom field columns, in file order
+	records []record
+}
+
+// readTable parses a CSV file with a header row, mapping headers through aliases
+func readTable(r io.Reader, opts Options, aliases map[string]string) (*table, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = opts.comma()
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	extra := make(map[string]string, len(opts.Headers))
+	for h, c := range opts.Headers {
+		extra[normalizeHeader(h)] = c
+	}
+
+	t := &table{}
+	mapping := make([]string, len(header)) // standard column, or "" for custom
+	seen := make(map[string]bool)
+	for i, h := range header {
+		key := normalizeHeader(h)
+		name, ok := extra[key]
+		if !ok {
+			name, ok = aliases[key]
+		}
+		if !ok {
+			name = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+			if name == "" {
+				return nil, fmt.Errorf("column %d has no header", i+1)
+			}
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column %q", h)
+		}
+		seen[name] = true
+
+		if ok {
+			mapping[i] = name
+			t.columns = append(t.columns, name)
+		} else {
+			t.custom = append(t.custom, name)
+		}
+	}
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if blank(fields) {
+			continue
+		}
+
+		rec := record{line: line, values: make(map[string]string), custom: make(map[string]string)}
+		for i, value := range fields {
+			if i >= len(header) {
+				break
+			}
+			value = strings.TrimSpace(value)
+			if mapping[i] != "" {
+				rec.values[mapping[i]] = value
+			} else {
+				rec.custom[strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))] = value
+			}
+		}
+		t.records = append(t.records, rec)
+	}
+	return t, nil
+}
+
+// blank reports whether all fields of a row are empty
+func blank(fields []string) bool {
+	for _, f := range fields {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// writeTable writes a header and rows
+func writeTable(w io.Writer, opts Options, header []string, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = opts.comma()
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// hasColumn reports whether a column is present
+func hasColumn(columns []string, name string) bool {
+	for _, c := range columns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// customFieldNames maps sheet columns to the names of the server's custom
+// fields, accepting names with or without the "custom_" prefix
+func customFieldNames(columns []string, defined map[string]phpipam.CustomField) (map[string]string, error) {
+	byName := make(map[string]string, len(defined))
+	for name := range defined {
+		byName[strings.ToLower(name)] = name
+		byName[strings.ToLower(strings.TrimPrefix(name, "custom_"))] = name
+	}
+
+	names := make(map[string]string, len(columns))
+	var unknown []string
+	for _, column := range columns {
+		name, ok := byName[strings.ToLower(column)]
+		if !ok {
+			unknown = append(unknown, column)
+			continue
+		}
+		names[column] = name
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown columns %s: not a standard field or custom field", strings.Join(unknown, ", "))
+	}
+	return names, nil
+}
+
+// customFieldColumns returns the sorted custom field names and their column
+// headers without the "custom_" prefix
+func customFieldColumns(defined map[string]phpipam.CustomField) (names, headers []string) {
+	for name := range defined {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		headers = append(headers, strings.TrimPrefix(name, "custom_"))
+	}
+	return names, headers
+}
+
+// customValue formats a custom field value from a raw API object
+func customValue(object map[string]interface{}, name string) string {
+	value, ok := object[name]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// rollback deletes an object whose custom fields could not be set after it was
+// created and returns the cause, extended with any failure to delete
+func rollback(cause error, kind string, id int, remove func(id int) error) error {
+	err := fmt.Errorf("setting custom fields failed, created %s removed: %w", kind, cause)
+	if removeErr := remove(id); removeErr != nil {
+		err = errors.Join(fmt.Errorf("setting custom fields failed: %w", cause),
+			fmt.Errorf("rollback of created %s %d failed, remove it by hand: %w", kind, id, removeErr))
+	}
+	return err
+}
+
+// Action is what an import did, or would do, with a row
+type Action string
+
+const (
+	// ActionCreate creates a new object
+	ActionCreate Action = "create"
+	// ActionUpdate updates an existing object
+	ActionUpdate Action = "update"
+	// ActionUnchanged leaves an existing object that already matches the row
+	ActionUnchanged Action = "unchanged"
+	// ActionSkip leaves an existing object because updates are disabled
+	ActionSkip Action = "skip"
+	// ActionInvalid rejects a row that failed validation
+	ActionInvalid Action = "invalid"
+	// ActionFailed marks a row phpIPAM rejected
+	ActionFailed Action = "failed"
+)
+
+// RowResult is the outcome of a single row
+type RowResult struct {
+	// Line is the line number of the row in the file
+	Line   int
+	Key    string
+	Action Action
+	// Errors lists the validation problems or the API error of the row
+	Errors []string
+}
+
+// Report lists the outcome of every row of an import
+type Report struct {
+	DryRun bool
+	Rows   []RowResult
+}
+
+// Count returns the number of rows with the given action
+func (r *Report) Count(action Action) int {
+	return report.Count(r.Rows, func(row RowResult) bool { return row.Action == action })
+}
+
+// Problems returns the invalid and failed rows
+func (r *Report) Problems() []RowResult {
+	var problems []RowResult
+	for _, row := range r.Rows {
+		if row.Action == ActionInvalid || row.Action == ActionFailed {
+			problems = append(problems, row)
+		}
+	}
+	return problems
+}
+
+// Err returns an error describing the invalid and failed rows, or nil
+func (r *Report) Err() error {
+	var errs []error
+	for _, row := range r.Problems() {
+		errs = append(errs, fmt.Errorf("line %d (%s): %s", row.Line, row.Key, strings.Join(row.Errors, "; ")))
+	}
+	return errors.Join(errs...)
+}
+
+// Render writes the report in a human-readable form
+func (r *Report) Render(w io.Writer) error {
+	return report.Render(w, func(b *strings.Builder) {
+		for _, row := range r.Rows {
+			fmt.Fprintf(b, "line %d: %-9s %s", row.Line, row.Action, row.Key)
+			if len(row.Errors) > 0 {
+				fmt.Fprintf(b, ": %s", strings.Join(row.Errors, "; "))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(report.Summary("Import", r.DryRun,
+			report.Tally{N: r.Count(ActionCreate), Label: "created"},
+			report.Tally{N: r.Count(ActionUpdate), Label: "updated"},
+			report.Tally{N: r.Count(ActionUnchanged), Label: "unchanged"},
+			report.Tally{N: r.Count(ActionSkip), Label: "skipped"},
+			report.Tally{N: r.Count(ActionInvalid), Label: "invalid"},
+			report.Tally{N: r.Count(ActionFailed), Label: "failed"}))
+	})
+}
+
+// String returns the rendered report
+func (r *Report) String() string {
+	return report.String(r.Render)
+}
+
+// sortRows orders the report by line
+func (r *Report) sortRows() {
+	sort.SliceStable(r.Rows, func(i, j int) bool { return r.Rows[i].Line < r.Rows[j].Line })
+}
+
+// ImportOptions controls ImportAddresses and ImportSubnets
+type ImportOptions struct {
+	// Update overwrites existing objects with the columns present in the file;
+	// otherwise they are skipped
+	Update bool
+	// DryRun validates the file and reports what would be done without changing
+	// anything
+	DryRun bool
+}
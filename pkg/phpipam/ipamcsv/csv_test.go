@@ -0,0 +1,177 @@
+package ipamcsv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadAddressesHeaders(t *testing.T) {
+	input := "\ufeffIP_Address;State;host-name;MAC Address;Switch port;Asset tag\n" +
+		"10.0.0.1; Used ;gw;00:1a:2b:3c:4d:5e;ge-0/0/1;A-1\n" +
+		";;;;;\n" +
+		"10.0.0.2;;db;;;\n"
+	sheet, err := ReadAddresses(strings.NewReader(input), Options{
+		Comma:   ';',
+		Headers: map[string]string{"Switch Port": ColumnPort},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantColumns := []string{ColumnIP, ColumnState, ColumnHostname, ColumnMAC, ColumnPort}
+	if !reflect.DeepEqual(sheet.Columns, wantColumns) {
+		t.Errorf("Columns = %v, want %v", sheet.Columns, wantColumns)
+	}
+	if !reflect.DeepEqual(sheet.CustomFields, []string{"Asset tag"}) {
+		t.Errorf("CustomFields = %v, want [Asset tag]", sheet.CustomFields)
+	}
+
+	// The blank row is dropped and line numbers count it
+	want := []AddressRow{
+		{Line: 2, IP: "10.0.0.1", State: "Used", Hostname: "gw", MAC: "00:1a:2b:3c:4d:5e", Port: "ge-0/0/1", Custom: map[string]string{"Asset tag": "A-1"}},
+		{Line: 4, IP: "10.0.0.2", Hostname: "db", Custom: map[string]string{"Asset tag": ""}},
+	}
+	if !reflect.DeepEqual(sheet.Rows, want) {
+		t.Errorf("Rows = %+v, want %+v", sheet.Rows, want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		subnets bool
+		wantErr string
+	}{
+		{name: "empty file", input: "", wantErr: "CSV file is empty"},
+		{name: "no IP column", input: "Hostname,Description\nhost,desc\n", wantErr: "missing IP address column"},
+		{name: "same column twice", input: "IP address,ip_addr\n10.0.0.1,10.0.0.1\n", wantErr: `duplicate column "ip_addr"`},
+		{name: "same custom column twice", input: "ip,Rack,Rack\n10.0.0.1,1,2\n", wantErr: `duplicate column "Rack"`},
+		{name: "empty header", input: "ip,,note\n10.0.0.1,x,y\n", wantErr: "column 2 has no header"},
+		{name: "no section column", input: "Subnet,Mask\n10.0.0.0,24\n", subnets: true, wantErr: "missing section column"},
+		{name: "no subnet column", input: "Section,Mask\nProduction,24\n", subnets: true, wantErr: "missing subnet column"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.subnets {
+				_, err = ReadSubnets(strings.NewReader(tt.input), Options{})
+			} else {
+				_, err = ReadAddresses(strings.NewReader(tt.input), Options{})
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubnetRowPrefix(t *testing.T) {
+	tests := []struct {
+		row     SubnetRow
+		want    string
+		wantErr bool
+	}{
+		{row: SubnetRow{Subnet: "10.0.0.0/24"}, want: "10.0.0.0/24"},
+		{row: SubnetRow{Subnet: "10.0.0.0", Mask: "24"}, want: "10.0.0.0/24"},
+		{row: SubnetRow{Subnet: "10.0.0.0", Mask: "255.255.255.0"}, want: "10.0.0.0/24"},
+		{row: SubnetRow{Subnet: "2001:db8::", Mask: "64"}, want: "2001:db8::/64"},
+		{row: SubnetRow{Subnet: "10.0.0.0"}, wantErr: true},
+		{row: SubnetRow{Subnet: "10.0.0.0", Mask: "33"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.row.Prefix()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Prefix(%q, %q) = %s, want an error", tt.row.Subnet, tt.row.Mask, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("Prefix(%q, %q) = %s, %v, want %s", tt.row.Subnet, tt.row.Mask, got, err, tt.want)
+		}
+	}
+}
+
+func TestAddressRoundTrip(t *testing.T) {
+	sheet := &AddressSheet{
+		CustomFields: []string{"Asset tag"},
+		Rows: []AddressRow{
+			{IP: "10.0.0.1", State: "Used", Description: "gateway, core", Hostname: "gw", MAC: "00:1a:2b:3c:4d:5e",
+				Owner: "netops", Device: "sw1", Port: "ge-0/0/1", Note: "line one\nline two", Custom: map[string]string{"Asset tag": "A-1"}},
+			{IP: "10.0.0.2", Description: `quoted "db"`, Custom: map[string]string{"Asset tag": ""}},
+		},
+	}
+
+	var b strings.Builder
+	if err := WriteAddresses(&b, sheet, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := strings.Cut(b.String(), "\n")
+	if want := "IP address,IP state,Description,Hostname,MAC address,Owner,Device,Port,Note,Asset tag"; header != want {
+		t.Errorf("header = %q, want %q", header, want)
+	}
+
+	read, err := ReadAddresses(strings.NewReader(b.String()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range read.Rows {
+		read.Rows[i].Line = 0
+	}
+	if !reflect.DeepEqual(read.Rows, sheet.Rows) {
+		t.Errorf("rows after a round trip:\n%+v\nwant:\n%+v", read.Rows, sheet.Rows)
+	}
+	if !reflect.DeepEqual(read.CustomFields, sheet.CustomFields) {
+		t.Errorf("CustomFields = %v, want %v", read.CustomFields, sheet.CustomFields)
+	}
+
+	// Writing the sheet read back keeps its columns
+	var again strings.Builder
+	if err := WriteAddresses(&again, read, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != b.String() {
+		t.Errorf("second write:\n%s\nwant:\n%s", again.String(), b.String())
+	}
+}
+
+func TestSubnetRoundTrip(t *testing.T) {
+	sheet := &SubnetSheet{
+		Columns: []string{ColumnSection, ColumnSubnet, ColumnMask, ColumnVLAN, ColumnVRF},
+		Rows: []SubnetRow{
+			{Section: "Production", Subnet: "10.0.0.0", Mask: "24", VLAN: "100", VRF: "blue"},
+			{Section: "Lab", Subnet: "2001:db8::", Mask: "64"},
+		},
+	}
+
+	var b strings.Builder
+	if err := WriteSubnets(&b, sheet, Options{Comma: '\t'}); err != nil {
+		t.Fatal(err)
+	}
+	want := "Section\tSubnet\tMask\tVLAN\tVRF\n" +
+		"Production\t10.0.0.0\t24\t100\tblue\n" +
+		"Lab\t2001:db8::\t64\t\t\n"
+	if b.String() != want {
+		t.Errorf("written:\n%q\nwant:\n%q", b.String(), want)
+	}
+
+	read, err := ReadSubnets(strings.NewReader(b.String()), Options{Comma: '\t'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.Columns, sheet.Columns) {
+		t.Errorf("Columns = %v, want %v", read.Columns, sheet.Columns)
+	}
+	for i := range read.Rows {
+		if read.Rows[i].Line != i+2 {
+			t.Errorf("row %d is on line %d, want %d", i, read.Rows[i].Line, i+2)
+		}
+		read.Rows[i].Line = 0
+		read.Rows[i].Custom = nil
+	}
+	if !reflect.DeepEqual(read.Rows, sheet.Rows) {
+		t.Errorf("rows after a round trip:\n%+v\nwant:\n%+v", read.Rows, sheet.Rows)
+	}
+}
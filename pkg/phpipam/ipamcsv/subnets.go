@@ -0,0 +1,638 @@
+package ipamcsv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// DefaultDomain is the L2 domain VLAN numbers are looked up in when a row has no
+// domain column
+const DefaultDomain = "default"
+
+// subnetColumns are the standard subnet columns in the order of phpIPAM's export
+var subnetColumns = []column{
+	{ColumnSection, "Section"},
+	{ColumnSubnet, "Subnet"},
+	{ColumnMask, "Mask"},
+	{ColumnDescription, "Description"},
+	{ColumnVLAN, "VLAN"},
+	{ColumnDomain, "Domain"},
+	{ColumnVRF, "VRF"},
+}
+
+// subnetAliases maps normalized headers to subnet columns
+var subnetAliases = map[string]string{
+	"section":      ColumnSection,
+	"section name": ColumnSection,
+	"subnet":       ColumnSubnet,
+	"network":      ColumnSubnet,
+	"prefix":       ColumnSubnet,
+	"mask":         ColumnMask,
+	"netmask":      ColumnMask,
+	"bitmask":      ColumnMask,
+	"description":  ColumnDescription,
+	"vlan":         ColumnVLAN,
+	"vlan number":  ColumnVLAN,
+	"domain":       ColumnDomain,
+	"vlan domain":  ColumnDomain,
+	"l2 domain":    ColumnDomain,
+	"vrf":          ColumnVRF,
+	"vrf name":     ColumnVRF,
+}
+
+// SubnetRow is one row of a subnet sheet
+type SubnetRow struct {
+	// Line is the line number of the row in the file
+	Line    int
+	Section string
+	// Subnet is the network in CIDR notation, or the network address when Mask
+	// is set
+	Subnet      string
+	Mask        string
+	Description string
+	// VLAN is the VLAN number within Domain
+	VLAN   string
+	Domain string
+	// VRF is the VRF name or route distinguisher
+	VRF string
+	// Custom holds custom field values by column header
+	Custom map[string]string
+}
+
+// SubnetSheet is a subnet CSV file
+type SubnetSheet struct {
+	// Columns are the standard columns present in the file
+	Columns []string
+	// CustomFields are the custom field column headers
+	CustomFields []string
+	Rows         []SubnetRow
+}
+
+// ReadSubnets parses a subnet CSV file. The file must have section and subnet
+// columns.
+func ReadSubnets(r io.Reader, opts Options) (*SubnetSheet, error) {
+	t, err := readTable(r, opts, subnetAliases)
+	if err != nil {
+		return nil, err
+	}
+	if !hasColumn(t.columns, ColumnSection) {
+		return nil, fmt.Errorf("missing section column")
+	}
+	if !hasColumn(t.columns, ColumnSubnet) {
+		return nil, fmt.Errorf("missing subnet column")
+	}
+
+	sheet := &SubnetSheet{Columns: t.columns, CustomFields: t.custom}
+	for _, rec := range t.records {
+		sheet.Rows = append(sheet.Rows, SubnetRow{
+			Line:        rec.line,
+			Section:     rec.values[ColumnSection],
+			Subnet:      rec.values[ColumnSubnet],
+			Mask:        rec.values[ColumnMask],
+			Description: rec.values[ColumnDescription],
+			VLAN:        rec.values[ColumnVLAN],
+			Domain:      rec.values[ColumnDomain],
+			VRF:         rec.values[ColumnVRF],
+			Custom:      rec.custom,
+		})
+	}
+	return sheet, nil
+}
+
+// value returns the value of a standard column
+func (row *SubnetRow) value(name string) string {
+	switch name {
+	case ColumnSection:
+		return row.Section
+	case ColumnSubnet:
+		return row.Subnet
+	case ColumnMask:
+		return row.Mask
+	case ColumnDescription:
+		return row.Description
+	case ColumnVLAN:
+		return row.VLAN
+	case ColumnDomain:
+		return row.Domain
+	case ColumnVRF:
+		return row.VRF
+	}
+	return ""
+}
+
+// Prefix returns the subnet of the row
+func (row *SubnetRow) Prefix() (netip.Prefix, error) {
+	if row.Mask == "" {
+		prefix, err := netip.ParsePrefix(row.Subnet)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid subnet %q", row.Subnet)
+		}
+		return prefix, nil
+	}
+	prefix, err := ipcalc.ParsePrefix(row.Subnet, row.Mask)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid subnet %q with mask %q", row.Subnet, row.Mask)
+	}
+	return prefix, nil
+}
+
+// WriteSubnets writes a subnet sheet. Without Columns all standard columns are
+// written, with the headers phpIPAM's export uses.
+func WriteSubnets(w io.Writer, sheet *SubnetSheet, opts Options) error {
+	columns := sheet.Columns
+	if len(columns) == 0 {
+		for _, c := range subnetColumns {
+			columns = append(columns, c.name)
+		}
+	}
+
+	header := labels(subnetColumns, columns)
+	header = append(header, sheet.CustomFields...)
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for i := range sheet.Rows {
+		row := &sheet.Rows[i]
+		fields := make([]string, 0, len(header))
+		for _, c := range columns {
+			fields = append(fields, row.value(c))
+		}
+		for _, name := range sheet.CustomFields {
+			fields = append(fields, row.Custom[name])
+		}
+		rows = append(rows, fields)
+	}
+	return writeTable(w, opts, header, rows)
+}
+
+// subnetRefs holds the sections, VRFs, L2 domains and VLANs subnet rows refer to
+type subnetRefs struct {
+	sections map[string]phpipam.Section // by ID
+	vrfs     map[string]phpipam.VRF     // by ID
+	domains  map[string]phpipam.L2Domain
+	vlans    map[string]phpipam.VLAN
+}
+
+// loadSubnetRefs reads the objects subnet rows refer to. VRFs and VLANs are
+// optional controllers.
+func loadSubnetRefs(api *phpipam.PHPIPAM) (*subnetRefs, error) {
+	refs := &subnetRefs{
+		sections: make(map[string]phpipam.Section),
+		vrfs:     make(map[string]phpipam.VRF),
+		domains:  make(map[string]phpipam.L2Domain),
+		vlans:    make(map[string]phpipam.VLAN),
+	}
+
+	sections, err := api.Sections.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sections {
+		refs.sections[s.ID] = s
+	}
+
+	vrfs, err := api.VRFs.List()
+	if err != nil && !errors.Is(err, phpipam.ErrUnsupported) {
+		return nil, err
+	}
+	for _, v := range vrfs {
+		refs.vrfs[v.ID] = v
+	}
+
+	domains, err := api.L2Domains.List()
+	if err != nil && !errors.Is(err, phpipam.ErrUnsupported) {
+		return nil, err
+	}
+	for _, d := range domains {
+		refs.domains[d.ID] = d
+	}
+
+	vlans, err := api.VLANs.List()
+	if err != nil && !errors.Is(err, phpipam.ErrUnsupported) {
+		return nil, err
+	}
+	for _, v := range vlans {
+		refs.vlans[v.ID] = v
+	}
+	return refs, nil
+}
+
+// section resolves a section name or ID
+func (r *subnetRefs) section(ref string) (string, error) {
+	if _, ok := r.sections[ref]; ok {
+		return ref, nil
+	}
+	for id, s := range r.sections {
+		if strings.EqualFold(s.Name, ref) {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("unknown section %q", ref)
+}
+
+// vrf resolves a VRF name, route distinguisher or ID
+func (r *subnetRefs) vrf(ref string) (int, error) {
+	for id, v := range r.vrfs {
+		if id == ref || strings.EqualFold(v.Name, ref) || (v.RD != "" && v.RD == ref) {
+			return strconv.Atoi(id)
+		}
+	}
+	return 0, fmt.Errorf("unknown VRF %q", ref)
+}
+
+// vlan resolves a VLAN number within an L2 domain given by name or ID
+func (r *subnetRefs) vlan(number, domain string) (int, error) {
+	if domain == "" {
+		domain = DefaultDomain
+	}
+	domainID := ""
+	for id, d := range r.domains {
+		if id == domain || strings.EqualFold(d.Name, domain) {
+			domainID = id
+			break
+		}
+	}
+	if domainID == "" {
+		return 0, fmt.Errorf("unknown L2 domain %q", domain)
+	}
+	for id, v := range r.vlans {
+		if v.DomainID == domainID && v.Number == number {
+			return strconv.Atoi(id)
+		}
+	}
+	return 0, fmt.Errorf("no VLAN %s in L2 domain %q", number, domain)
+}
+
+// ExportSubnets reads the subnets of a section into a sheet with all standard
+// columns and the server's subnet custom fields. Folders are left out.
+func ExportSubnets(api *phpipam.PHPIPAM, sectionID string) (*SubnetSheet, error) {
+	refs, err := loadSubnetRefs(api)
+	if err != nil {
+		return nil, err
+	}
+	section, ok := refs.sections[sectionID]
+	if !ok {
+		return nil, fmt.Errorf("section %s not found", sectionID)
+	}
+	subnets, err := api.Sections.GetSubnets(sectionID)
+	if err != nil {
+		return nil, err
+	}
+	defined, err := api.Subnets.GetCustomFields()
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[int]map[string]interface{}
+	customNames, customHeaders := customFieldColumns(defined)
+	if len(customNames) > 0 {
+		raw, err = rawObjects(api, fmt.Sprintf("sections/%s/subnets", sectionID))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	type entry struct {
+		subnet *phpipam.Subnet
+		prefix netip.Prefix
+	}
+	var entries []entry
+	for i := range subnets {
+		if subnets[i].IsFolder == 1 {
+			continue
+		}
+		prefix, err := subnets[i].Prefix()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{&subnets[i], prefix})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].prefix, entries[j].prefix
+		if a.Addr() != b.Addr() {
+			return a.Addr().Less(b.Addr())
+		}
+		return a.Bits() < b.Bits()
+	})
+
+	sheet := &SubnetSheet{CustomFields: customHeaders}
+	for i, e := range entries {
+		s := e.subnet
+		row := SubnetRow{
+			Line:        i + 2,
+			Section:     section.Name,
+			Subnet:      e.prefix.Addr().String(),
+			Mask:        strconv.Itoa(e.prefix.Bits()),
+			Description: s.Description,
+			Custom:      make(map[string]string, len(customNames)),
+		}
+		if id, ok := s.GetVlanID(); ok && id != 0 {
+			if vlan, ok := refs.vlans[strconv.Itoa(id)]; ok {
+				row.VLAN = vlan.Number
+				row.Domain = refs.domains[vlan.DomainID].Name
+			}
+		}
+		if id, ok := s.GetVrfID(); ok && id != 0 {
+			if vrf, ok := refs.vrfs[strconv.Itoa(id)]; ok {
+				row.VRF = vrf.Name
+			}
+		}
+		for j, name := range customNames {
+			row.Custom[customHeaders[j]] = customValue(raw[s.ID], name)
+		}
+		sheet.Rows = append(sheet.Rows, row)
+	}
+	return sheet, nil
+}
+
+// subnetKey identifies a subnet by section, VRF and prefix
+type subnetKey struct {
+	section string
+	vrf     int
+	prefix  netip.Prefix
+}
+
+// scope returns the section and VRF part of the key
+func (k subnetKey) scope() string {
+	return fmt.Sprintf("%s/%d", k.section, k.vrf)
+}
+
+// subnetImport holds what ImportSubnets looks up once per file
+type subnetImport struct {
+	api   *phpipam.PHPIPAM
+	sheet *SubnetSheet
+	opts  ImportOptions
+	refs  *subnetRefs
+
+	existing map[subnetKey]*phpipam.Subnet
+	known    map[string][]subnetKey // keys by scope, existing and created
+	ids      map[subnetKey]int
+	raw      map[int]map[string]interface{}
+	custom   map[string]string // column header to custom field name
+}
+
+// validSubnet is a validated row with its resolved references
+type validSubnet struct {
+	row    *SubnetRow
+	key    subnetKey
+	vlanID int
+	result int // index into the report
+}
+
+// ImportSubnets validates a sheet and creates the subnets it lists. Rows are
+// processed from the shortest prefix so that each subnet is nested under the most
+// specific existing or imported subnet containing it in the same section and VRF.
+// Existing subnets are updated with the columns present in the file when
+// opts.Update is set. Invalid rows are reported and skipped; the returned error
+// summarizes invalid and failed rows.
+func ImportSubnets(api *phpipam.PHPIPAM, sheet *SubnetSheet, opts ImportOptions) (*Report, error) {
+	refs, err := loadSubnetRefs(api)
+	if err != nil {
+		return nil, err
+	}
+	im := &subnetImport{
+		api:      api,
+		sheet:    sheet,
+		opts:     opts,
+		refs:     refs,
+		existing: make(map[subnetKey]*phpipam.Subnet),
+		known:    make(map[string][]subnetKey),
+		ids:      make(map[subnetKey]int),
+		raw:      make(map[int]map[string]interface{}),
+	}
+	if len(sheet.CustomFields) > 0 {
+		defined, err := api.Subnets.GetCustomFields()
+		if err != nil {
+			return nil, err
+		}
+		if im.custom, err = customFieldNames(sheet.CustomFields, defined); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &Report{DryRun: opts.DryRun}
+	loaded := make(map[string]bool)
+	seen := make(map[subnetKey]int)
+	var valid []validSubnet
+	for i := range sheet.Rows {
+		row := &sheet.Rows[i]
+		result := RowResult{Line: row.Line, Key: row.Subnet}
+
+		key, vlanID, problems := im.validate(row)
+		if key.prefix.IsValid() {
+			result.Key = key.prefix.String()
+			if s, ok := refs.sections[key.section]; ok {
+				result.Key += " in " + s.Name
+			}
+			if line, ok := seen[key]; ok {
+				problems = append(problems, fmt.Sprintf("duplicate of line %d", line))
+			}
+			seen[key] = row.Line
+		}
+		if len(problems) == 0 && !loaded[key.section] {
+			if err := im.load(key.section); err != nil {
+				return nil, err
+			}
+			loaded[key.section] = true
+		}
+		if len(problems) > 0 {
+			result.Action, result.Errors = ActionInvalid, problems
+		}
+		report.Rows = append(report.Rows, result)
+		if len(problems) == 0 {
+			valid = append(valid, validSubnet{row: row, key: key, vlanID: vlanID, result: len(report.Rows) - 1})
+		}
+	}
+
+	sort.SliceStable(valid, func(i, j int) bool {
+		return valid[i].key.prefix.Bits() < valid[j].key.prefix.Bits()
+	})
+	for _, v := range valid {
+		result := &report.Rows[v.result]
+		result.Action = im.apply(v, result)
+	}
+
+	report.sortRows()
+	return report, report.Err()
+}
+
+// load reads the existing subnets of a section
+func (im *subnetImport) load(sectionID string) error {
+	subnets, err := im.api.Sections.GetSubnets(sectionID)
+	if err != nil {
+		return err
+	}
+	for i := range subnets {
+		s := &subnets[i]
+		if s.IsFolder == 1 {
+			continue
+		}
+		prefix, err := s.Prefix()
+		if err != nil {
+			continue
+		}
+		vrf, _ := s.GetVrfID()
+		key := subnetKey{section: sectionID, vrf: vrf, prefix: prefix}
+		im.existing[key] = s
+		im.remember(key, s.ID)
+	}
+
+	if len(im.sheet.CustomFields) > 0 && im.opts.Update {
+		raw, err := rawObjects(im.api, fmt.Sprintf("sections/%s/subnets", sectionID))
+		if err != nil {
+			return err
+		}
+		for id, object := range raw {
+			im.raw[id] = object
+		}
+	}
+	return nil
+}
+
+// remember records a subnet as a possible master for later rows
+func (im *subnetImport) remember(key subnetKey, id int) {
+	im.known[key.scope()] = append(im.known[key.scope()], key)
+	im.ids[key] = id
+}
+
+// master returns the ID of the most specific known subnet containing key, or 0
+func (im *subnetImport) master(key subnetKey) int {
+	best, bits := 0, -1
+	for _, k := range im.known[key.scope()] {
+		if k.prefix.Bits() < key.prefix.Bits() && k.prefix.Contains(key.prefix.Addr()) && k.prefix.Bits() > bits {
+			best, bits = im.ids[k], k.prefix.Bits()
+		}
+	}
+	return best
+}
+
+// validate checks a row and resolves its references
+func (im *subnetImport) validate(row *SubnetRow) (subnetKey, int, []string) {
+	var problems []string
+	var key subnetKey
+
+	if row.Section == "" {
+		problems = append(problems, "missing section")
+	} else if id, err := im.refs.section(row.Section); err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		key.section = id
+	}
+
+	prefix, err := row.Prefix()
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if prefix.Masked() != prefix {
+		problems = append(problems, fmt.Sprintf("%s is not a network address, did you mean %s?", prefix, prefix.Masked()))
+	} else {
+		key.prefix = prefix
+	}
+
+	if row.VRF != "" {
+		if key.vrf, err = im.refs.vrf(row.VRF); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	vlanID := 0
+	if row.VLAN != "" {
+		if vlanID, err = im.refs.vlan(row.VLAN, row.Domain); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return key, vlanID, problems
+}
+
+// patch builds an update of the columns present in the sheet that differ from
+// the existing subnet
+func (im *subnetImport) patch(current *phpipam.Subnet, v validSubnet) *phpipam.SubnetPatch {
+	patch := phpipam.NewSubnetPatch()
+	if hasColumn(im.sheet.Columns, ColumnDescription) && v.row.Description != current.Description {
+		patch.SetDescription(v.row.Description)
+	}
+	if hasColumn(im.sheet.Columns, ColumnVLAN) {
+		if vlan, _ := current.GetVlanID(); vlan != v.vlanID {
+			if v.vlanID == 0 {
+				patch.ClearVlan()
+			} else {
+				patch.SetVlanID(v.vlanID)
+			}
+		}
+	}
+	for _, header := range im.sheet.CustomFields {
+		name := im.custom[header]
+		if value := v.row.Custom[header]; value != customValue(im.raw[current.ID], name) {
+			patch.SetCustomField(name, value)
+		}
+	}
+	return patch
+}
+
+// apply creates or updates the subnet of a valid row
+func (im *subnetImport) apply(v validSubnet, result *RowResult) Action {
+	fail := func(err error) Action {
+		result.Errors = append(result.Errors, err.Error())
+		return ActionFailed
+	}
+
+	if current, ok := im.existing[v.key]; ok {
+		if !im.opts.Update {
+			return ActionSkip
+		}
+		patch := im.patch(current, v)
+		if patch.Len() == 0 {
+			return ActionUnchanged
+		}
+		if !im.opts.DryRun {
+			if err := im.api.Subnets.Patch(current.ID, patch); err != nil {
+				return fail(err)
+			}
+		}
+		return ActionUpdate
+	}
+
+	if im.opts.DryRun {
+		im.remember(v.key, 0)
+		return ActionCreate
+	}
+
+	sectionID, _ := strconv.Atoi(v.key.section)
+	subnet := &phpipam.Subnet{
+		Subnet:         v.key.prefix.Addr().String(),
+		Mask:           strconv.Itoa(v.key.prefix.Bits()),
+		SectionID:      sectionID,
+		Description:    v.row.Description,
+		MasterSubnetID: im.master(v.key),
+	}
+	subnet.SetVrfID(v.key.vrf)
+	subnet.SetVlanID(v.vlanID)
+
+	created, err := im.api.Subnets.Create(subnet)
+	if err != nil {
+		return fail(err)
+	}
+
+	if len(im.sheet.CustomFields) > 0 {
+		patch := phpipam.NewSubnetPatch()
+		for _, header := range im.sheet.CustomFields {
+			if value := v.row.Custom[header]; value != "" {
+				patch.SetCustomField(im.custom[header], value)
+			}
+		}
+		if patch.Len() > 0 {
+			if err := im.api.Subnets.Patch(created.ID, patch); err != nil {
+				// Leave nothing behind so that a re-run creates the row again
+				return fail(rollback(err, "subnet", created.ID, im.api.Subnets.Delete))
+			}
+		}
+	}
+	im.remember(v.key, created.ID)
+	return ActionCreate
+}
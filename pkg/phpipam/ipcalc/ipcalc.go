@@ -0,0 +1,214 @@
+// Package ipcalc provides offline IP address arithmetic for IPv4 and IPv6, so
+// subnets and addresses can be planned without round-trips to phpIPAM.
+//
+// The functions work on net/netip values. Subnet.Prefix and Address.Addr in the
+// phpipam package convert API objects into them.
+package ipcalc
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ParsePrefix parses a subnet address and mask as returned by phpIPAM. The mask
+// can be a prefix length ("24") or, for IPv4, a dotted netmask ("255.255.255.0").
+// Host bits in the address are cleared.
+func ParsePrefix(address, mask string) (netip.Prefix, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(address))
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid subnet address %q: %w", address, err)
+	}
+	addr = addr.Unmap()
+
+	mask = strings.TrimSpace(strings.TrimPrefix(mask, "/"))
+	bits, err := strconv.Atoi(mask)
+	if err != nil {
+		bits, err = netmaskBits(mask)
+		if err != nil || !addr.Is4() {
+			return netip.Prefix{}, fmt.Errorf("invalid subnet mask %q", mask)
+		}
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid subnet mask %q: %w", mask, err)
+	}
+	return prefix, nil
+}
+
+// ParseCIDR parses a prefix in CIDR notation, clearing host bits
+func ParseCIDR(cidr string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
+// netmaskBits converts a dotted IPv4 netmask to a prefix length
+func netmaskBits(mask string) (int, error) {
+	addr, err := netip.ParseAddr(mask)
+	if err != nil || !addr.Is4() {
+		return 0, fmt.Errorf("invalid netmask %q", mask)
+	}
+
+	value := addrToInt(addr).Uint64()
+	bits := 0
+	for i := 31; i >= 0 && value&(1<<uint(i)) != 0; i-- {
+		bits++
+	}
+	if value != (0xffffffff<<uint(32-bits))&0xffffffff {
+		return 0, fmt.Errorf("non-contiguous netmask %q", mask)
+	}
+	return bits, nil
+}
+
+// Network returns the network (first) address of a prefix
+func Network(p netip.Prefix) netip.Addr {
+	return p.Masked().Addr()
+}
+
+// Broadcast returns the last address of a prefix. For IPv6, which has no
+// broadcast, this is simply the highest address in the prefix.
+func Broadcast(p netip.Prefix) netip.Addr {
+	return lastAddr(p)
+}
+
+// Netmask returns the dotted netmask of an IPv4 prefix
+func Netmask(p netip.Prefix) (netip.Addr, bool) {
+	if !p.Addr().Is4() {
+		return netip.Addr{}, false
+	}
+	value := uint32(0xffffffff) << uint(32-p.Bits())
+	if p.Bits() == 0 {
+		value = 0
+	}
+	return netip.AddrFrom4([4]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}), true
+}
+
+// Size returns the number of addresses in a prefix
+func Size(p netip.Prefix) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+}
+
+// HostRange returns the usable host addresses of a prefix. For IPv4 prefixes
+// shorter than /31 the network and broadcast addresses are excluded; /31 and
+// /32 prefixes and all IPv6 prefixes use every address.
+func HostRange(p netip.Prefix) Range {
+	r := PrefixRange(p)
+	if p.Addr().Is4() && p.Bits() < 31 {
+		r.First = r.First.Next()
+		r.Last = r.Last.Prev()
+	}
+	return r
+}
+
+// HostCount returns the number of usable host addresses of a prefix, see HostRange
+func HostCount(p netip.Prefix) *big.Int {
+	return HostRange(p).Size()
+}
+
+// Contains reports whether outer contains inner entirely
+func Contains(outer, inner netip.Prefix) bool {
+	return outer.Addr().BitLen() == inner.Addr().BitLen() &&
+		outer.Bits() <= inner.Bits() &&
+		outer.Contains(inner.Addr())
+}
+
+// Overlaps reports whether two prefixes share any address. Since prefixes are
+// aligned, this means one of them contains the other.
+func Overlaps(a, b netip.Prefix) bool {
+	return a.Overlaps(b)
+}
+
+// Supernet returns the prefix one bit shorter than p that contains it
+func Supernet(p netip.Prefix) (netip.Prefix, bool) {
+	if p.Bits() == 0 {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(p.Addr(), p.Bits()-1).Masked(), true
+}
+
+// Split returns the two halves of a prefix
+func Split(p netip.Prefix) (netip.Prefix, netip.Prefix, bool) {
+	if p.Bits() >= p.Addr().BitLen() {
+		return netip.Prefix{}, netip.Prefix{}, false
+	}
+	low := netip.PrefixFrom(Network(p), p.Bits()+1)
+	high := netip.PrefixFrom(lastAddr(low).Next(), p.Bits()+1)
+	return low, high, true
+}
+
+// Add returns the address n positions after a (or before it for negative n).
+// It reports false when the result leaves the address family.
+func Add(a netip.Addr, n *big.Int) (netip.Addr, bool) {
+	value := new(big.Int).Add(addrToInt(a), n)
+	if value.Sign() < 0 || value.BitLen() > a.BitLen() {
+		return netip.Addr{}, false
+	}
+	return intToAddr(value, a.Is4()), true
+}
+
+// Distance returns the number of addresses from a to b (b - a)
+func Distance(a, b netip.Addr) *big.Int {
+	return new(big.Int).Sub(addrToInt(b), addrToInt(a))
+}
+
+// AddrToInt returns the numeric value of an address
+func AddrToInt(a netip.Addr) *big.Int {
+	return addrToInt(a)
+}
+
+// IntToAddr returns the IPv4 or IPv6 address with the given numeric value
+func IntToAddr(value *big.Int, ipv4 bool) (netip.Addr, error) {
+	bits, version := 128, 6
+	if ipv4 {
+		bits, version = 32, 4
+	}
+	if value.Sign() < 0 || value.BitLen() > bits {
+		return netip.Addr{}, fmt.Errorf("value %s out of range for IPv%d", value, version)
+	}
+	return intToAddr(value, ipv4), nil
+}
+
+// addrToInt returns the numeric value of an address
+func addrToInt(a netip.Addr) *big.Int {
+	if a.Is4() {
+		b := a.As4()
+		return new(big.Int).SetBytes(b[:])
+	}
+	b := a.As16()
+	return new(big.Int).SetBytes(b[:])
+}
+
+// intToAddr converts a value known to fit the address family into an address
+func intToAddr(value *big.Int, ipv4 bool) netip.Addr {
+	if ipv4 {
+		var b [4]byte
+		value.FillBytes(b[:])
+		return netip.AddrFrom4(b)
+	}
+	var b [16]byte
+	value.FillBytes(b[:])
+	return netip.AddrFrom16(b)
+}
+
+// lastAddr returns the highest address of a prefix
+func lastAddr(p netip.Prefix) netip.Addr {
+	p = p.Masked()
+	if p.Addr().Is4() {
+		b := p.Addr().As4()
+		for i := p.Bits(); i < 32; i++ {
+			b[i/8] |= 0x80 >> uint(i%8)
+		}
+		return netip.AddrFrom4(b)
+	}
+	b := p.Addr().As16()
+	for i := p.Bits(); i < 128; i++ {
+		b[i/8] |= 0x80 >> uint(i%8)
+	}
+	return netip.AddrFrom16(b)
+}
@@ -0,0 +1,134 @@
+package ipcalc
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		address, mask string
+		want          string
+		wantErr       bool
+	}{
+		{"10.0.0.0", "24", "10.0.0.0/24", false},
+		{"10.0.0.5", "255.255.255.0", "10.0.0.0/24", false},
+		{"192.0.2.1", "32", "192.0.2.1/32", false},
+		{"2001:db8::", "64", "2001:db8::/64", false},
+		{"10.0.0.0", "255.0.255.0", "", true},
+		{"10.0.0.0", "33", "", true},
+		{"not-an-ip", "24", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePrefix(tt.address, tt.mask)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParsePrefix(%q, %q) = %s, want error", tt.address, tt.mask, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("ParsePrefix(%q, %q) = %s, %v, want %s", tt.address, tt.mask, got, err, tt.want)
+		}
+	}
+}
+
+func TestHostRange(t *testing.T) {
+	tests := []struct {
+		prefix      string
+		first, last string
+		count       string
+	}{
+		{"10.0.0.0/24", "10.0.0.1", "10.0.0.254", "254"},
+		{"10.0.0.0/30", "10.0.0.1", "10.0.0.2", "2"},
+		{"10.0.0.0/31", "10.0.0.0", "10.0.0.1", "2"},
+		{"10.0.0.7/32", "10.0.0.7", "10.0.0.7", "1"},
+		{"2001:db8::/64", "2001:db8::", "2001:db8::ffff:ffff:ffff:ffff", "18446744073709551616"},
+		{"2001:db8::/127", "2001:db8::", "2001:db8::1", "2"},
+		{"2001:db8::1/128", "2001:db8::1", "2001:db8::1", "1"},
+	}
+	for _, tt := range tests {
+		p := netip.MustParsePrefix(tt.prefix)
+		r := HostRange(p)
+		if r.First.String() != tt.first || r.Last.String() != tt.last {
+			t.Errorf("HostRange(%s) = %s, want %s-%s", tt.prefix, r, tt.first, tt.last)
+		}
+		if got := HostCount(p).String(); got != tt.count {
+			t.Errorf("HostCount(%s) = %s, want %s", tt.prefix, got, tt.count)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		outer, inner string
+		want         bool
+	}{
+		{"10.0.0.0/16", "10.0.1.0/24", true},
+		{"10.0.0.0/24", "10.0.0.0/24", true},
+		{"10.0.1.0/24", "10.0.0.0/16", false},
+		{"10.0.0.0/24", "10.0.1.0/24", false},
+		{"10.0.0.0/31", "10.0.0.1/32", true},
+		{"0.0.0.0/0", "2001:db8::/32", false},
+		{"2001:db8::/32", "2001:db8:0:1::/64", true},
+		{"2001:db8::/127", "2001:db8::1/128", true},
+		{"2001:db8::/128", "2001:db8::1/128", false},
+	}
+	for _, tt := range tests {
+		got := Contains(netip.MustParsePrefix(tt.outer), netip.MustParsePrefix(tt.inner))
+		if got != tt.want {
+			t.Errorf("Contains(%s, %s) = %v, want %v", tt.outer, tt.inner, got, tt.want)
+		}
+	}
+}
+
+func TestNetmask(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+		ok     bool
+	}{
+		{"10.0.0.0/0", "0.0.0.0", true},
+		{"10.0.0.0/24", "255.255.255.0", true},
+		{"10.0.0.0/31", "255.255.255.254", true},
+		{"10.0.0.0/32", "255.255.255.255", true},
+		{"2001:db8::/64", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Netmask(netip.MustParsePrefix(tt.prefix))
+		if ok != tt.ok || ok && got.String() != tt.want {
+			t.Errorf("Netmask(%s) = %s, %v, want %s, %v", tt.prefix, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSplitAndSupernet(t *testing.T) {
+	tests := []struct {
+		prefix    string
+		low, high string
+		ok        bool
+	}{
+		{"10.0.0.0/24", "10.0.0.0/25", "10.0.0.128/25", true},
+		{"10.0.0.0/31", "10.0.0.0/32", "10.0.0.1/32", true},
+		{"10.0.0.1/32", "", "", false},
+		{"2001:db8::/127", "2001:db8::/128", "2001:db8::1/128", true},
+		{"2001:db8::1/128", "", "", false},
+	}
+	for _, tt := range tests {
+		p := netip.MustParsePrefix(tt.prefix)
+		low, high, ok := Split(p)
+		if ok != tt.ok {
+			t.Errorf("Split(%s) ok = %v, want %v", tt.prefix, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if low.String() != tt.low || high.String() != tt.high {
+			t.Errorf("Split(%s) = %s, %s, want %s, %s", tt.prefix, low, high, tt.low, tt.high)
+		}
+		if super, _ := Supernet(high); super != p.Masked() {
+			t.Errorf("Supernet(%s) = %s, want %s", high, super, p.Masked())
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package ipcalc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// SLAACBits is the prefix length of IPv6 subnets that hosts can autoconfigure in
+const SLAACBits = 64
+
+// ParseMAC parses a MAC address in any common notation: colon, dash or dot
+// separated, or 12 bare hex digits as sometimes stored in phpIPAM
+func ParseMAC(s string) (net.HardwareAddr, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 12 {
+		if b, err := hex.DecodeString(s); err == nil {
+			return net.HardwareAddr(b), nil
+		}
+	}
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC address %q: expected 48 bits", s)
+	}
+	return mac, nil
+}
+
+// EUI64 derives the modified EUI-64 address (RFC 4291) of a MAC in a prefix of
+// at most 64 bits: the MAC is split by ff:fe and the universal/local bit flipped
+func EUI64(p netip.Prefix, mac net.HardwareAddr) (netip.Addr, error) {
+	if !p.Addr().Is6() || p.Bits() > SLAACBits {
+		return netip.Addr{}, fmt.Errorf("EUI-64 needs an IPv6 prefix of /%d or shorter, got %s", SLAACBits, p)
+	}
+	if len(mac) != 6 {
+		return netip.Addr{}, fmt.Errorf("EUI-64 needs a 48-bit MAC address, got %s", mac)
+	}
+
+	b := p.Masked().Addr().As16()
+	b[8] = mac[0] ^ 0x02
+	b[9], b[10] = mac[1], mac[2]
+	b[11], b[12] = 0xff, 0xfe
+	b[13], b[14], b[15] = mac[3], mac[4], mac[5]
+	return netip.AddrFrom16(b), nil
+}
+
+// IsEUI64 reports whether an IPv6 address has a modified EUI-64 interface identifier
+func IsEUI64(a netip.Addr) bool {
+	if !a.Is6() || a.Is4In6() {
+		return false
+	}
+	b := a.As16()
+	return b[11] == 0xff && b[12] == 0xfe
+}
+
+// EUI64MAC recovers the MAC address from a modified EUI-64 address
+func EUI64MAC(a netip.Addr) (net.HardwareAddr, bool) {
+	if !IsEUI64(a) {
+		return nil, false
+	}
+	b := a.As16()
+	return net.HardwareAddr{b[8] ^ 0x02, b[9], b[10], b[13], b[14], b[15]}, true
+}
+
+// IsNibbleAligned reports whether an IPv6 prefix ends on a nibble (4-bit) boundary,
+// so it maps to a single ip6.arpa reverse zone
+func IsNibbleAligned(p netip.Prefix) bool {
+	return p.Bits()%4 == 0
+}
+
+// ReservedInterfaceIDs returns the ranges of a /64 whose interface identifiers are
+// reserved by RFC 5453: the subnet-router anycast address and the subnet anycast
+// addresses at the top of the range
+func ReservedInterfaceIDs(p netip.Prefix) []Range {
+	if !p.Addr().Is6() || p.Bits() != SLAACBits {
+		return nil
+	}
+	network := p.Masked().Addr()
+
+	b := network.As16()
+	copy(b[8:], []byte{0xfd, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x80})
+	anycast := netip.AddrFrom16(b)
+
+	return []Range{
+		AddrRange(network),
+		{First: anycast, Last: lastAddr(p)},
+	}
+}
+
+// StaticRange returns the part of a /64 for manually assigned addresses: the
+// interface identifiers ::1 to ::ffff. Autoconfigured hosts use EUI-64 or random
+// (RFC 7217, RFC 8981) identifiers, which practically never fall in this range.
+// For other prefixes the whole prefix is returned.
+func StaticRange(p netip.Prefix) Range {
+	if !p.Addr().Is6() || p.Bits() != SLAACBits {
+		return PrefixRange(p)
+	}
+	network := p.Masked().Addr()
+
+	b := network.As16()
+	b[14], b[15] = 0xff, 0xff
+	return Range{First: network.Next(), Last: netip.AddrFrom16(b)}
+}
@@ -0,0 +1,141 @@
+package ipcalc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestParseMAC(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "00:1a:2b:3c:4d:5e", want: "00:1a:2b:3c:4d:5e"},
+		{in: "00-1A-2B-3C-4D-5E", want: "00:1a:2b:3c:4d:5e"},
+		{in: "001a.2b3c.4d5e", want: "00:1a:2b:3c:4d:5e"},
+		{in: "001A2B3C4D5E", want: "00:1a:2b:3c:4d:5e"},
+		{in: " 00:1a:2b:3c:4d:5e\n", want: "00:1a:2b:3c:4d:5e"},
+		{in: "00:1a:2b:3c:4d:5e:6f:70", wantErr: true},
+		{in: "00:1a:2b:3c:4d", wantErr: true},
+		{in: "00112233445g", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		mac, err := ParseMAC(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMAC(%q) = %s, want an error", tt.in, mac)
+			}
+			continue
+		}
+		if err != nil || mac.String() != tt.want {
+			t.Errorf("ParseMAC(%q) = %s, %v, want %s", tt.in, mac, err, tt.want)
+		}
+	}
+}
+
+func TestEUI64(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		mac     string
+		want    string
+		wantErr bool
+	}{
+		{prefix: "2001:db8::/64", mac: "00:1a:2b:3c:4d:5e", want: "2001:db8::21a:2bff:fe3c:4d5e"},
+		// The universal/local bit is flipped, so a locally administered MAC clears it
+		{prefix: "2001:db8::/64", mac: "02:00:00:00:00:01", want: "2001:db8::ff:fe00:1"},
+		{prefix: "2001:db8:0:7::/64", mac: "ff:ff:ff:ff:ff:ff", want: "2001:db8:0:7:fdff:ffff:feff:ffff"},
+		// Host bits of the prefix are cleared
+		{prefix: "2001:db8:0:7::99/64", mac: "00:1a:2b:3c:4d:5e", want: "2001:db8:0:7:21a:2bff:fe3c:4d5e"},
+		{prefix: "2001:db8::/48", mac: "00:1a:2b:3c:4d:5e", want: "2001:db8::21a:2bff:fe3c:4d5e"},
+		{prefix: "2001:db8::/80", mac: "00:1a:2b:3c:4d:5e", wantErr: true},
+		{prefix: "10.0.0.0/24", mac: "00:1a:2b:3c:4d:5e", wantErr: true},
+		{prefix: "2001:db8::/64", mac: "00:1a:2b:3c:4d:5e:6f:70", wantErr: true},
+	}
+	for _, tt := range tests {
+		mac, err := net.ParseMAC(tt.mac)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := EUI64(netip.MustParsePrefix(tt.prefix), mac)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("EUI64(%s, %s) = %s, want an error", tt.prefix, tt.mac, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("EUI64(%s, %s) = %s, %v, want %s", tt.prefix, tt.mac, got, err, tt.want)
+			continue
+		}
+
+		// The MAC can be recovered from the address
+		if !IsEUI64(got) {
+			t.Errorf("IsEUI64(%s) = false", got)
+		}
+		if back, ok := EUI64MAC(got); !ok || back.String() != mac.String() {
+			t.Errorf("EUI64MAC(%s) = %s, %v, want %s", got, back, ok, mac)
+		}
+	}
+}
+
+func TestIsEUI64(t *testing.T) {
+	tests := map[string]bool{
+		"2001:db8::21a:2bff:fe3c:4d5e": true,
+		"2001:db8::1":                  false,
+		"2001:db8::21a:2bff:fe3c:4d5f": true,
+		"2001:db8::21a:2bfe:ff3c:4d5e": false,
+		"10.0.0.1":                     false,
+		"::ffff:10.255.254.1":          false,
+	}
+	for addr, want := range tests {
+		if got := IsEUI64(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsEUI64(%s) = %v, want %v", addr, got, want)
+		}
+	}
+	if _, ok := EUI64MAC(netip.MustParseAddr("2001:db8::1")); ok {
+		t.Error("EUI64MAC recovered a MAC from a static address")
+	}
+}
+
+func TestSLAACRanges(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		reserved string
+		static   string
+	}{
+		{
+			prefix:   "2001:db8:0:7::/64",
+			reserved: "2001:db8:0:7::,2001:db8:0:7:fdff:ffff:ffff:ff80-2001:db8:0:7:ffff:ffff:ffff:ffff",
+			static:   "2001:db8:0:7::1-2001:db8:0:7::ffff",
+		},
+		{
+			prefix:   "2001:db8:0:7::1234/64",
+			reserved: "2001:db8:0:7::,2001:db8:0:7:fdff:ffff:ffff:ff80-2001:db8:0:7:ffff:ffff:ffff:ffff",
+			static:   "2001:db8:0:7::1-2001:db8:0:7::ffff",
+		},
+		{
+			prefix: "2001:db8::/120",
+			static: "2001:db8::-2001:db8::ff",
+		},
+		{
+			prefix: "2001:db8::/56",
+			static: "2001:db8::-2001:db8:0:ff:ffff:ffff:ffff:ffff",
+		},
+		{
+			prefix: "10.0.0.0/24",
+			static: "10.0.0.0-10.0.0.255",
+		},
+	}
+	for _, tt := range tests {
+		p := netip.MustParsePrefix(tt.prefix)
+		if got := ranges(ReservedInterfaceIDs(p)); got != tt.reserved {
+			t.Errorf("ReservedInterfaceIDs(%s) = %s, want %s", tt.prefix, got, tt.reserved)
+		}
+		if got := StaticRange(p).String(); got != tt.static {
+			t.Errorf("StaticRange(%s) = %s, want %s", tt.prefix, got, tt.static)
+		}
+	}
+}
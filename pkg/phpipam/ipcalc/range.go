@@ -0,0 +1,258 @@
+package ipcalc
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+)
+
+// Range is an inclusive range of addresses of one address family
+type Range struct {
+	First netip.Addr
+	Last  netip.Addr
+}
+
+// NewRange creates a range from first to last inclusive
+func NewRange(first, last netip.Addr) (Range, error) {
+	if first.BitLen() != last.BitLen() {
+		return Range{}, fmt.Errorf("range %s-%s mixes address families", first, last)
+	}
+	if last.Less(first) {
+		return Range{}, fmt.Errorf("range %s-%s ends before it starts", first, last)
+	}
+	return Range{First: first, Last: last}, nil
+}
+
+// PrefixRange returns all addresses of a prefix as a range
+func PrefixRange(p netip.Prefix) Range {
+	return Range{First: Network(p), Last: lastAddr(p)}
+}
+
+// AddrRange returns a range holding a single address
+func AddrRange(a netip.Addr) Range {
+	return Range{First: a, Last: a}
+}
+
+// IsValid reports whether the range holds at least one address
+func (r Range) IsValid() bool {
+	return r.First.IsValid() && r.Last.IsValid() &&
+		r.First.BitLen() == r.Last.BitLen() && !r.Last.Less(r.First)
+}
+
+// Contains reports whether the range contains an address
+func (r Range) Contains(a netip.Addr) bool {
+	return a.BitLen() == r.First.BitLen() && !a.Less(r.First) && !r.Last.Less(a)
+}
+
+// ContainsRange reports whether the range contains another range entirely
+func (r Range) ContainsRange(o Range) bool {
+	return r.Contains(o.First) && r.Contains(o.Last)
+}
+
+// Overlaps reports whether two ranges share any address
+func (r Range) Overlaps(o Range) bool {
+	return r.First.BitLen() == o.First.BitLen() && !r.Last.Less(o.First) && !o.Last.Less(r.First)
+}
+
+// Size returns the number of addresses in the range
+func (r Range) Size() *big.Int {
+	if !r.IsValid() {
+		return new(big.Int)
+	}
+	size := Distance(r.First, r.Last)
+	return size.Add(size, big.NewInt(1))
+}
+
+// String returns the range as "first-last", or a single address
+func (r Range) String() string {
+	if r.First == r.Last {
+		return r.First.String()
+	}
+	return r.First.String() + "-" + r.Last.String()
+}
+
+// Prefixes returns the smallest list of prefixes that exactly covers the range
+func (r Range) Prefixes() []netip.Prefix {
+	if !r.IsValid() {
+		return nil
+	}
+
+	var prefixes []netip.Prefix
+	cur := r.First
+	for {
+		// Find the largest aligned block starting at cur that ends within the range
+		var block netip.Prefix
+		for bits := 0; bits <= cur.BitLen(); bits++ {
+			candidate := netip.PrefixFrom(cur, bits)
+			if candidate.Masked().Addr() == cur && !r.Last.Less(lastAddr(candidate)) {
+				block = candidate
+				break
+			}
+		}
+		prefixes = append(prefixes, block)
+
+		end := lastAddr(block)
+		if end == r.Last {
+			return prefixes
+		}
+		cur = end.Next()
+	}
+}
+
+// Merge returns the valid ranges sorted by first address, with overlapping and
+// adjacent ranges joined
+func Merge(ranges []Range) []Range {
+	sorted := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		if r.IsValid() {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].First.Less(sorted[j].First) })
+
+	var merged []Range
+	for _, r := range sorted {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if last.Overlaps(r) || last.Last.Next() == r.First {
+				if last.Last.Less(r.Last) {
+					last.Last = r.Last
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Subtract returns the parts of the range not covered by any of the taken ranges,
+// in ascending order. Taken ranges of another address family are ignored.
+func Subtract(r Range, taken []Range) []Range {
+	if !r.IsValid() {
+		return nil
+	}
+
+	sorted := make([]Range, 0, len(taken))
+	for _, t := range taken {
+		if t.IsValid() && t.Overlaps(r) {
+			sorted = append(sorted, t)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].First.Less(sorted[j].First) })
+
+	var free []Range
+	cur := r.First
+	for _, t := range sorted {
+		if t.Last.Less(cur) {
+			continue
+		}
+		if cur.Less(t.First) {
+			free = append(free, Range{First: cur, Last: t.First.Prev()})
+		}
+		if !t.Last.Less(r.Last) {
+			return free
+		}
+		cur = t.Last.Next()
+	}
+	return append(free, Range{First: cur, Last: r.Last})
+}
+
+// FreeRanges returns the addresses of the range not in used, as ascending ranges
+func FreeRanges(r Range, used []netip.Addr) []Range {
+	taken := make([]Range, len(used))
+	for i, a := range used {
+		taken[i] = AddrRange(a)
+	}
+	return Subtract(r, taken)
+}
+
+// FirstFree returns the lowest address of the range not in used
+func FirstFree(r Range, used []netip.Addr) (netip.Addr, bool) {
+	free := FreeRanges(r, used)
+	if len(free) == 0 {
+		return netip.Addr{}, false
+	}
+	return free[0].First, true
+}
+
+// LastFree returns the highest address of the range not in used
+func LastFree(r Range, used []netip.Addr) (netip.Addr, bool) {
+	free := FreeRanges(r, used)
+	if len(free) == 0 {
+		return netip.Addr{}, false
+	}
+	return free[len(free)-1].Last, true
+}
+
+// PrefixRanges converts prefixes into ranges
+func PrefixRanges(prefixes []netip.Prefix) []Range {
+	ranges := make([]Range, len(prefixes))
+	for i, p := range prefixes {
+		ranges[i] = PrefixRange(p)
+	}
+	return ranges
+}
+
+// FreeBlocks returns the largest aligned prefixes within parent that do not
+// overlap any of the used prefixes, in ascending order
+func FreeBlocks(parent netip.Prefix, used []netip.Prefix) []netip.Prefix {
+	var blocks []netip.Prefix
+	for _, free := range Subtract(PrefixRange(parent), PrefixRanges(used)) {
+		blocks = append(blocks, free.Prefixes()...)
+	}
+	return blocks
+}
+
+// FreePrefixes returns the child prefixes of the given length within parent that
+// do not overlap any of the used prefixes, in ascending order. At most limit
+// prefixes are returned; a limit of 0 or less returns all of them, which can be
+// a very large number for IPv6.
+func FreePrefixes(parent netip.Prefix, used []netip.Prefix, bits int, limit int) []netip.Prefix {
+	if bits < parent.Bits() || bits > parent.Addr().BitLen() {
+		return nil
+	}
+
+	var prefixes []netip.Prefix
+	for _, block := range FreeBlocks(parent, used) {
+		if block.Bits() > bits {
+			continue
+		}
+		child := netip.PrefixFrom(block.Addr(), bits)
+		for {
+			if limit > 0 && len(prefixes) >= limit {
+				return prefixes
+			}
+			prefixes = append(prefixes, child)
+
+			end := lastAddr(child)
+			if end == lastAddr(block) {
+				break
+			}
+			child = netip.PrefixFrom(end.Next(), bits)
+		}
+	}
+	return prefixes
+}
+
+// FirstFreePrefix returns the lowest free child prefix of the given length, see FreePrefixes
+func FirstFreePrefix(parent netip.Prefix, used []netip.Prefix, bits int) (netip.Prefix, bool) {
+	prefixes := FreePrefixes(parent, used, bits, 1)
+	if len(prefixes) == 0 {
+		return netip.Prefix{}, false
+	}
+	return prefixes[0], true
+}
+
+// LastFreePrefix returns the highest free child prefix of the given length
+func LastFreePrefix(parent netip.Prefix, used []netip.Prefix, bits int) (netip.Prefix, bool) {
+	blocks := FreeBlocks(parent, used)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if blocks[i].Bits() <= bits {
+			last := lastAddr(blocks[i])
+			return netip.PrefixFrom(last, bits).Masked(), true
+		}
+	}
+	return netip.Prefix{}, false
+}
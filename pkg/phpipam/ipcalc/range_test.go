@@ -0,0 +1,169 @@
+package ipcalc
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// ranges formats ranges as a comma-separated list
+func ranges(rs []Range) string {
+	parts := make([]string, len(rs))
+	for i, r := range rs {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// mustRange parses "first-last" or a single address
+func mustRange(s string) Range {
+	first, last, ok := strings.Cut(s, "-")
+	if !ok {
+		last = first
+	}
+	return Range{First: netip.MustParseAddr(first), Last: netip.MustParseAddr(last)}
+}
+
+func TestPrefixRange(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+		size   string
+	}{
+		{"10.0.0.0/24", "10.0.0.0-10.0.0.255", "256"},
+		{"10.0.0.5/24", "10.0.0.0-10.0.0.255", "256"},
+		{"10.0.0.0/31", "10.0.0.0-10.0.0.1", "2"},
+		{"10.0.0.9/32", "10.0.0.9", "1"},
+		{"2001:db8::/64", "2001:db8::-2001:db8::ffff:ffff:ffff:ffff", "18446744073709551616"},
+		{"2001:db8::/127", "2001:db8::-2001:db8::1", "2"},
+		{"2001:db8::5/128", "2001:db8::5", "1"},
+	}
+	for _, tt := range tests {
+		r := PrefixRange(netip.MustParsePrefix(tt.prefix))
+		if r.String() != tt.want {
+			t.Errorf("PrefixRange(%s) = %s, want %s", tt.prefix, r, tt.want)
+		}
+		if got := r.Size().String(); got != tt.size {
+			t.Errorf("PrefixRange(%s).Size() = %s, want %s", tt.prefix, got, tt.size)
+		}
+	}
+}
+
+func TestRangeContains(t *testing.T) {
+	r := mustRange("10.0.0.10-10.0.0.20")
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.0.0.9", false},
+		{"10.0.0.10", true},
+		{"10.0.0.15", true},
+		{"10.0.0.20", true},
+		{"10.0.0.21", false},
+		{"::ffff:10.0.0.15", false},
+	}
+	for _, tt := range tests {
+		if got := r.Contains(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("%s.Contains(%s) = %v, want %v", r, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		name  string
+		r     string
+		taken []string
+		want  string
+	}{
+		{"nothing taken", "10.0.0.1-10.0.0.10", nil, "10.0.0.1-10.0.0.10"},
+		{"middle", "10.0.0.1-10.0.0.10", []string{"10.0.0.5"}, "10.0.0.1-10.0.0.4,10.0.0.6-10.0.0.10"},
+		{"edges", "10.0.0.1-10.0.0.10", []string{"10.0.0.1", "10.0.0.10"}, "10.0.0.2-10.0.0.9"},
+		{"unsorted overlapping", "10.0.0.1-10.0.0.10", []string{"10.0.0.6-10.0.0.8", "10.0.0.2-10.0.0.7"}, "10.0.0.1,10.0.0.9-10.0.0.10"},
+		{"outside", "10.0.0.1-10.0.0.10", []string{"10.0.1.0-10.0.1.255", "10.0.0.0"}, "10.0.0.1-10.0.0.10"},
+		{"everything", "10.0.0.1-10.0.0.10", []string{"10.0.0.0-10.0.0.255"}, ""},
+		{"other family", "10.0.0.1-10.0.0.2", []string{"2001:db8::1"}, "10.0.0.1-10.0.0.2"},
+		{"ipv4 /31", "10.0.0.0-10.0.0.1", []string{"10.0.0.0"}, "10.0.0.1"},
+		{"ipv4 /32", "10.0.0.1", []string{"10.0.0.1"}, ""},
+		{"ipv6 /127", "2001:db8::-2001:db8::1", []string{"2001:db8::1"}, "2001:db8::"},
+		{"ipv6 /128", "2001:db8::1", nil, "2001:db8::1"},
+		{"ipv6 top of space", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			[]string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe"}, "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+	}
+	for _, tt := range tests {
+		var taken []Range
+		for _, s := range tt.taken {
+			taken = append(taken, mustRange(s))
+		}
+		if got := ranges(Subtract(mustRange(tt.r), taken)); got != tt.want {
+			t.Errorf("%s: Subtract = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRangePrefixes(t *testing.T) {
+	tests := []struct {
+		r    string
+		want string
+	}{
+		{"10.0.0.0-10.0.0.255", "10.0.0.0/24"},
+		{"10.0.0.1-10.0.0.6", "10.0.0.1/32,10.0.0.2/31,10.0.0.4/31,10.0.0.6/32"},
+		{"10.0.0.7", "10.0.0.7/32"},
+		{"2001:db8::-2001:db8::1", "2001:db8::/127"},
+	}
+	for _, tt := range tests {
+		var parts []string
+		for _, p := range mustRange(tt.r).Prefixes() {
+			parts = append(parts, p.String())
+		}
+		if got := strings.Join(parts, ","); got != tt.want {
+			t.Errorf("%s.Prefixes() = %s, want %s", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestFreePrefixes(t *testing.T) {
+	parent := netip.MustParsePrefix("10.0.0.0/24")
+	used := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/26"), netip.MustParsePrefix("10.0.0.128/27")}
+
+	var got []string
+	for _, p := range FreePrefixes(parent, used, 26, 0) {
+		got = append(got, p.String())
+	}
+	if want := "10.0.0.64/26,10.0.0.192/26"; strings.Join(got, ",") != want {
+		t.Errorf("FreePrefixes = %v, want %s", got, want)
+	}
+	if p, ok := FirstFreePrefix(parent, used, 27); !ok || p.String() != "10.0.0.64/27" {
+		t.Errorf("FirstFreePrefix = %s, %v, want 10.0.0.64/27", p, ok)
+	}
+	if p, ok := LastFreePrefix(parent, used, 27); !ok || p.String() != "10.0.0.224/27" {
+		t.Errorf("LastFreePrefix = %s, %v, want 10.0.0.224/27", p, ok)
+	}
+	if _, ok := FirstFreePrefix(parent, used, 23); ok {
+		t.Error("FirstFreePrefix with a length shorter than the parent succeeded")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"10.0.0.20-10.0.0.30", "10.0.0.1-10.0.0.10"}, "10.0.0.1-10.0.0.10,10.0.0.20-10.0.0.30"},
+		{[]string{"10.0.0.5-10.0.0.15", "10.0.0.1-10.0.0.10", "10.0.0.12"}, "10.0.0.1-10.0.0.15"},
+		{[]string{"10.0.0.11-10.0.0.20", "10.0.0.1-10.0.0.10"}, "10.0.0.1-10.0.0.20"},
+		{[]string{"2001:db8::1", "10.0.0.1", "2001:db8::2"}, "10.0.0.1,2001:db8::1-2001:db8::2"},
+		{[]string{"255.255.255.255", "255.255.255.254"}, "255.255.255.254-255.255.255.255"},
+	}
+	for _, tt := range tests {
+		var in []Range
+		for _, s := range tt.in {
+			in = append(in, mustRange(s))
+		}
+		in = append(in, Range{})
+		if got := ranges(Merge(in)); got != tt.want {
+			t.Errorf("Merge(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ipcalc
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ReverseName returns the PTR owner name of an address, e.g.
+// "5.0.0.10.in-addr.arpa." for 10.0.0.5
+func ReverseName(a netip.Addr) string {
+	a = a.Unmap()
+	if a.Is4() {
+		b := a.As4()
+		return reverseV4(b[:]) + "in-addr.arpa."
+	}
+	return reverseNibbles(a.As16(), 32) + "ip6.arpa."
+}
+
+// ReverseZone returns the reverse zone name of a prefix whose length is on an
+// octet (IPv4) or nibble (IPv6) boundary, e.g. "0.10.in-addr.arpa." for
+// 10.0.0.0/16. It returns false for other prefix lengths.
+func ReverseZone(p netip.Prefix) (string, bool) {
+	p = p.Masked()
+	if p.Addr().Is4() {
+		if p.Bits()%8 != 0 {
+			return "", false
+		}
+		b := p.Addr().As4()
+		return reverseV4(b[:p.Bits()/8]) + "in-addr.arpa.", true
+	}
+	if !IsNibbleAligned(p) {
+		return "", false
+	}
+	return reverseNibbles(p.Addr().As16(), p.Bits()/4) + "ip6.arpa.", true
+}
+
+// ReverseZoneBits returns the prefix length of the reverse zones a prefix is
+// published in: its own length rounded up to the next octet or nibble boundary,
+// and at most /24 for IPv4 so that every address has its own label
+func ReverseZoneBits(p netip.Prefix) int {
+	if p.Addr().Unmap().Is4() {
+		bits := (p.Bits() + 7) / 8 * 8
+		if bits > 24 {
+			bits = 24
+		}
+		if bits < 8 {
+			bits = 8
+		}
+		return bits
+	}
+	bits := (p.Bits() + 3) / 4 * 4
+	if bits > 124 {
+		bits = 124
+	}
+	if bits < 4 {
+		bits = 4
+	}
+	return bits
+}
+
+// reverseV4 returns the octets in reverse order, each followed by a dot
+func reverseV4(octets []byte) string {
+	var b strings.Builder
+	for i := len(octets) - 1; i >= 0; i-- {
+		b.WriteString(strconv.Itoa(int(octets[i])))
+		b.WriteByte('.')
+	}
+	return b.String()
+}
+
+// reverseNibbles returns the first n nibbles in reverse order, each followed by a
+// dot
+func reverseNibbles(b [16]byte, n int) string {
+	const hex = "0123456789abcdef"
+	var s strings.Builder
+	for i := n - 1; i >= 0; i-- {
+		v := b[i/2]
+		if i%2 == 0 {
+			v >>= 4
+		}
+		s.WriteByte(hex[v&0x0f])
+		s.WriteByte('.')
+	}
+	return s.String()
+}
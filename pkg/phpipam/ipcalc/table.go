@@ -0,0 +1,232 @@
+package ipcalc
+
+import "net/netip"
+
+// Entry is a prefix stored in a Table with its value
+type Entry[T any] struct {
+	Prefix netip.Prefix
+	Value  T
+}
+
+// Table maps prefixes to values and answers longest-prefix-match and
+// containment queries. It is a binary trie with one root per address family, so
+// a lookup visits at most 32 (IPv4) or 128 (IPv6) nodes.
+//
+// The zero value is an empty table ready to use. A Table is not safe for
+// concurrent modification; guard it with a lock when it is updated while read.
+type Table[T any] struct {
+	v4, v6 *tableNode[T]
+	len    int
+}
+
+// tableNode is a trie node; set marks nodes that hold a prefix
+type tableNode[T any] struct {
+	children [2]*tableNode[T]
+	prefix   netip.Prefix
+	value    T
+	set      bool
+}
+
+// bitAt returns bit i of an address, counting from the most significant bit
+func bitAt(a netip.Addr, i int) int {
+	if a.Is4() {
+		b := a.As4()
+		return int(b[i/8]>>(7-uint(i%8))) & 1
+	}
+	b := a.As16()
+	return int(b[i/8]>>(7-uint(i%8))) & 1
+}
+
+// root returns the root node for the address family of a, creating it if asked
+func (t *Table[T]) root(a netip.Addr, create bool) *tableNode[T] {
+	r := &t.v6
+	if a.Is4() {
+		r = &t.v4
+	}
+	if *r == nil && create {
+		*r = &tableNode[T]{}
+	}
+	return *r
+}
+
+// normalize unmaps IPv4-mapped IPv6 prefixes and clears host bits
+func normalize(p netip.Prefix) (netip.Prefix, bool) {
+	if !p.IsValid() {
+		return netip.Prefix{}, false
+	}
+	addr := p.Addr()
+	bits := p.Bits()
+	if addr.Is4In6() {
+		addr = addr.Unmap()
+		bits -= 96
+		if bits < 0 {
+			return netip.Prefix{}, false
+		}
+	}
+	p, err := addr.Prefix(bits)
+	return p, err == nil
+}
+
+// Len returns the number of prefixes in the table
+func (t *Table[T]) Len() int {
+	return t.len
+}
+
+// Insert stores a value for a prefix, replacing any previous value. Host bits
+// are cleared. Invalid prefixes are ignored.
+func (t *Table[T]) Insert(p netip.Prefix, value T) {
+	p, ok := normalize(p)
+	if !ok {
+		return
+	}
+
+	n := t.root(p.Addr(), true)
+	for i := 0; i < p.Bits(); i++ {
+		bit := bitAt(p.Addr(), i)
+		if n.children[bit] == nil {
+			n.children[bit] = &tableNode[T]{}
+		}
+		n = n.children[bit]
+	}
+
+	if !n.set {
+		t.len++
+	}
+	n.prefix, n.value, n.set = p, value, true
+}
+
+// Delete removes a prefix and reports whether it was present
+func (t *Table[T]) Delete(p netip.Prefix) bool {
+	p, ok := normalize(p)
+	if !ok {
+		return false
+	}
+
+	n := t.root(p.Addr(), false)
+	path := make([]*tableNode[T], 0, p.Bits()+1)
+	for i := 0; n != nil && i < p.Bits(); i++ {
+		path = append(path, n)
+		n = n.children[bitAt(p.Addr(), i)]
+	}
+	if n == nil || !n.set {
+		return false
+	}
+
+	var zero T
+	n.prefix, n.value, n.set = netip.Prefix{}, zero, false
+	t.len--
+
+	// Prune nodes that no longer lead to any prefix
+	for i := len(path) - 1; i >= 0; i-- {
+		if n.set || n.children[0] != nil || n.children[1] != nil {
+			break
+		}
+		path[i].children[bitAt(p.Addr(), i)] = nil
+		n = path[i]
+	}
+	return true
+}
+
+// Get returns the value stored for exactly this prefix
+func (t *Table[T]) Get(p netip.Prefix) (T, bool) {
+	var zero T
+	p, ok := normalize(p)
+	if !ok {
+		return zero, false
+	}
+
+	n := t.root(p.Addr(), false)
+	for i := 0; n != nil && i < p.Bits(); i++ {
+		n = n.children[bitAt(p.Addr(), i)]
+	}
+	if n == nil || !n.set {
+		return zero, false
+	}
+	return n.value, true
+}
+
+// Lookup returns the longest prefix containing the address and its value
+func (t *Table[T]) Lookup(a netip.Addr) (netip.Prefix, T, bool) {
+	var best *tableNode[T]
+	if a.Is4In6() {
+		a = a.Unmap()
+	}
+
+	n := t.root(a, false)
+	for i := 0; n != nil; i++ {
+		if n.set {
+			best = n
+		}
+		if i == a.BitLen() {
+			break
+		}
+		n = n.children[bitAt(a, i)]
+	}
+
+	if best == nil {
+		var zero T
+		return netip.Prefix{}, zero, false
+	}
+	return best.prefix, best.value, true
+}
+
+// Covering returns the prefixes that contain p, including p itself, shortest first
+func (t *Table[T]) Covering(p netip.Prefix) []Entry[T] {
+	p, ok := normalize(p)
+	if !ok {
+		return nil
+	}
+
+	var entries []Entry[T]
+	n := t.root(p.Addr(), false)
+	for i := 0; n != nil; i++ {
+		if n.set {
+			entries = append(entries, Entry[T]{Prefix: n.prefix, Value: n.value})
+		}
+		if i == p.Bits() {
+			break
+		}
+		n = n.children[bitAt(p.Addr(), i)]
+	}
+	return entries
+}
+
+// CoveredBy returns the prefixes inside p, including p itself, in address order
+// with shorter prefixes before their subdivisions
+func (t *Table[T]) CoveredBy(p netip.Prefix) []Entry[T] {
+	p, ok := normalize(p)
+	if !ok {
+		return nil
+	}
+
+	n := t.root(p.Addr(), false)
+	for i := 0; n != nil && i < p.Bits(); i++ {
+		n = n.children[bitAt(p.Addr(), i)]
+	}
+
+	var entries []Entry[T]
+	walkTable(n, func(prefix netip.Prefix, value T) bool {
+		entries = append(entries, Entry[T]{Prefix: prefix, Value: value})
+		return true
+	})
+	return entries
+}
+
+// Walk calls fn for every prefix in the table, IPv4 before IPv6, in the same order
+// as CoveredBy. It stops when fn returns false.
+func (t *Table[T]) Walk(fn func(p netip.Prefix, value T) bool) {
+	if walkTable(t.v4, fn) {
+		walkTable(t.v6, fn)
+	}
+}
+
+// walkTable walks a subtree in pre-order and reports whether to continue
+func walkTable[T any](n *tableNode[T], fn func(p netip.Prefix, value T) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.set && !fn(n.prefix, n.value) {
+		return false
+	}
+	return walkTable(n.children[0], fn) && walkTable(n.children[1], fn)
+}
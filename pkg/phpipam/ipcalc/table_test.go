@@ -0,0 +1,209 @@
+package ipcalc
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// testTable returns a table mapping each prefix to itself as a string
+func testTable(prefixes ...string) *Table[string] {
+	t := &Table[string]{}
+	for _, p := range prefixes {
+		t.Insert(netip.MustParsePrefix(p), p)
+	}
+	return t
+}
+
+// entries formats table entries as a space-separated list of prefixes
+func entries(es []Entry[string]) string {
+	parts := make([]string, len(es))
+	for i, e := range es {
+		parts[i] = e.Prefix.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+// countNodes returns the number of nodes in a subtree
+func countNodes[T any](n *tableNode[T]) int {
+	if n == nil {
+		return 0
+	}
+	return 1 + countNodes(n.children[0]) + countNodes(n.children[1])
+}
+
+func TestTableLookup(t *testing.T) {
+	table := testTable(
+		"0.0.0.0/0",
+		"10.0.0.0/8",
+		"10.1.0.0/16",
+		"10.1.2.0/24",
+		"10.1.2.3/32",
+		"2001:db8::/32",
+		"2001:db8:1::/48",
+	)
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"10.1.2.3", "10.1.2.3/32"},
+		{"10.1.2.4", "10.1.2.0/24"},
+		{"10.1.3.1", "10.1.0.0/16"},
+		{"10.200.0.1", "10.0.0.0/8"},
+		{"192.168.0.1", "0.0.0.0/0"},
+		{"::ffff:10.1.2.3", "10.1.2.3/32"},
+		{"2001:db8:1::1", "2001:db8:1::/48"},
+		{"2001:db8:2::1", "2001:db8::/32"},
+		{"2001:db9::1", ""},
+	}
+	for _, tt := range tests {
+		prefix, value, ok := table.Lookup(netip.MustParseAddr(tt.addr))
+		if tt.want == "" {
+			if ok {
+				t.Errorf("Lookup(%s) = %s, want no match", tt.addr, prefix)
+			}
+			continue
+		}
+		if !ok || prefix.String() != tt.want || value != tt.want {
+			t.Errorf("Lookup(%s) = %s, %q, %v, want %s", tt.addr, prefix, value, ok, tt.want)
+		}
+	}
+}
+
+func TestTableInsert(t *testing.T) {
+	table := testTable("10.0.0.0/8")
+
+	// Host bits are cleared and a second insert replaces the value
+	table.Insert(netip.MustParsePrefix("10.9.9.9/8"), "replaced")
+	if table.Len() != 1 {
+		t.Errorf("Len = %d, want 1", table.Len())
+	}
+	if value, ok := table.Get(netip.MustParsePrefix("10.0.0.0/8")); !ok || value != "replaced" {
+		t.Errorf("Get(10.0.0.0/8) = %q, %v, want replaced", value, ok)
+	}
+
+	// IPv4-mapped prefixes are stored as IPv4
+	table.Insert(netip.MustParsePrefix("::ffff:192.168.0.0/112"), "mapped")
+	if value, ok := table.Get(netip.MustParsePrefix("192.168.0.0/16")); !ok || value != "mapped" {
+		t.Errorf("Get(192.168.0.0/16) = %q, %v, want mapped", value, ok)
+	}
+
+	table.Insert(netip.Prefix{}, "invalid")
+	if table.Len() != 2 {
+		t.Errorf("Len = %d after inserting an invalid prefix, want 2", table.Len())
+	}
+	if _, ok := table.Get(netip.MustParsePrefix("10.0.0.0/9")); ok {
+		t.Error("Get(10.0.0.0/9) found a prefix that was never inserted")
+	}
+}
+
+func TestTableDelete(t *testing.T) {
+	table := testTable("10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24")
+	before := countNodes(table.v4)
+
+	if table.Delete(netip.MustParsePrefix("10.2.0.0/16")) {
+		t.Error("Delete of a missing prefix reported success")
+	}
+	if table.Delete(netip.MustParsePrefix("10.1.0.0/24")) {
+		t.Error("Delete of an intermediate node without a prefix reported success")
+	}
+
+	// A prefix with descendants keeps its node
+	if !table.Delete(netip.MustParsePrefix("10.1.0.0/16")) {
+		t.Fatal("Delete(10.1.0.0/16) failed")
+	}
+	if got := countNodes(table.v4); got != before {
+		t.Errorf("%d nodes after deleting an inner prefix, want %d", got, before)
+	}
+	if prefix, _, _ := table.Lookup(netip.MustParseAddr("10.1.3.1")); prefix.String() != "10.0.0.0/8" {
+		t.Errorf("Lookup(10.1.3.1) = %s after delete, want 10.0.0.0/8", prefix)
+	}
+
+	// A leaf is pruned back to the nearest remaining prefix
+	if !table.Delete(netip.MustParsePrefix("10.1.2.0/24")) {
+		t.Fatal("Delete(10.1.2.0/24) failed")
+	}
+	if got := countNodes(table.v4); got != 9 {
+		t.Errorf("%d nodes after deleting the leaf, want 9 (root and the path to /8)", got)
+	}
+
+	if !table.Delete(netip.MustParsePrefix("10.0.0.0/8")) {
+		t.Fatal("Delete(10.0.0.0/8) failed")
+	}
+	if table.Len() != 0 || countNodes(table.v4) != 1 {
+		t.Errorf("Len = %d with %d nodes after deleting everything, want 0 and only the root", table.Len(), countNodes(table.v4))
+	}
+	if _, _, ok := table.Lookup(netip.MustParseAddr("10.1.2.3")); ok {
+		t.Error("Lookup matched in an empty table")
+	}
+}
+
+func TestTableCovering(t *testing.T) {
+	table := testTable(
+		"10.0.0.0/8",
+		"10.1.0.0/16",
+		"10.1.2.0/24",
+		"10.2.0.0/16",
+		"2001:db8::/32",
+		"2001:db8:1::/48",
+		"2001:db8:1:1::/64",
+	)
+
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"10.1.2.0/24", "10.0.0.0/8 10.1.0.0/16 10.1.2.0/24"},
+		{"10.1.2.128/25", "10.0.0.0/8 10.1.0.0/16 10.1.2.0/24"},
+		{"10.1.0.0/16", "10.0.0.0/8 10.1.0.0/16"},
+		{"10.0.0.0/7", ""},
+		{"192.168.0.0/16", ""},
+		{"2001:db8:1:1::/64", "2001:db8::/32 2001:db8:1::/48 2001:db8:1:1::/64"},
+		{"2001:db8:2::/48", "2001:db8::/32"},
+	}
+	for _, tt := range tests {
+		if got := entries(table.Covering(netip.MustParsePrefix(tt.prefix))); got != tt.want {
+			t.Errorf("Covering(%s) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestTableCoveredBy(t *testing.T) {
+	table := testTable(
+		"10.0.0.0/8",
+		"10.2.0.0/16",
+		"10.1.2.0/24",
+		"10.1.0.0/16",
+		"10.1.0.0/24",
+		"2001:db8:1:1::/64",
+		"2001:db8::/32",
+		"2001:db8:1::/48",
+	)
+
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8 10.1.0.0/16 10.1.0.0/24 10.1.2.0/24 10.2.0.0/16"},
+		{"10.1.0.0/16", "10.1.0.0/16 10.1.0.0/24 10.1.2.0/24"},
+		{"10.1.0.0/17", "10.1.0.0/24 10.1.2.0/24"},
+		{"10.3.0.0/16", ""},
+		{"2001:db8::/33", "2001:db8:1::/48 2001:db8:1:1::/64"},
+		{"::/0", "2001:db8::/32 2001:db8:1::/48 2001:db8:1:1::/64"},
+	}
+	for _, tt := range tests {
+		if got := entries(table.CoveredBy(netip.MustParsePrefix(tt.prefix))); got != tt.want {
+			t.Errorf("CoveredBy(%s) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+
+	var walked []string
+	table.Walk(func(p netip.Prefix, value string) bool {
+		walked = append(walked, p.String())
+		return len(walked) < 6
+	})
+	if got := strings.Join(walked, " "); got != "10.0.0.0/8 10.1.0.0/16 10.1.0.0/24 10.1.2.0/24 10.2.0.0/16 2001:db8::/32" {
+		t.Errorf("Walk = %s", got)
+	}
+}
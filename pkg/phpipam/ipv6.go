@@ -0,0 +1,178 @@
+package phpipam
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// EUI64Address returns the address a host with the given MAC autoconfigures
+// (SLAAC with EUI-64) in an IPv6 /64 subnet
+func EUI64Address(subnet *Subnet, mac string) (netip.Addr, error) {
+	prefix, err := subnet.Prefix()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	if !prefix.Addr().Is6() || prefix.Bits() != ipcalc.SLAACBits {
+		return netip.Addr{}, fmt.Errorf("subnet %s is not an IPv6 /%d", prefix, ipcalc.SLAACBits)
+	}
+	hardwareAddr, err := ipcalc.ParseMAC(mac)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return ipcalc.EUI64(prefix, hardwareAddr)
+}
+
+// ReserveIPv6 reserves one address in an IPv6 subnet without colliding with
+// autoconfigured hosts.
+//
+// In a /64 with template.Mac set, the EUI-64 address of that MAC is reserved, so
+// the record matches what the host configures itself. Otherwise the lowest free
+// address among the static interface identifiers (ipcalc.StaticRange) is used,
+// skipping the anycast identifiers reserved by RFC 5453. Subnets longer than /64
+// cannot use SLAAC, so any free address is taken there.
+func (a *Allocator) ReserveIPv6(subnetID int, template Address) (*Address, error) {
+	subnet, err := a.subnets.Get(subnetID)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := subnet.Prefix()
+	if err != nil {
+		return nil, err
+	}
+	if !prefix.Addr().Is6() {
+		return nil, fmt.Errorf("subnet %s is not an IPv6 subnet", prefix)
+	}
+
+	if template.Mac != "" && prefix.Bits() == ipcalc.SLAACBits {
+		ip, err := EUI64Address(subnet, template.Mac)
+		if err != nil {
+			return nil, err
+		}
+		address := template
+		address.ID = 0
+		address.IP = ip.String()
+		address.SubnetID = subnetID
+		created, err := a.addresses.Create(&address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create EUI-64 address %s: %w", ip, err)
+		}
+		return created, nil
+	}
+
+	created, err := a.Allocate(AllocationRequest{
+		SubnetID: subnetID,
+		Count:    1,
+		Within:   []ipcalc.Range{ipcalc.StaticRange(prefix)},
+		Exclude:  ipcalc.ReservedInterfaceIDs(prefix),
+		Template: template,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created[0], nil
+}
+
+// Delegate creates the first free child prefix of the given length inside an
+// IPv6 subnet, e.g. a /56 for a site out of a /48 or a /64 out of a /56. Child
+// prefixes longer than /64 are rejected since hosts cannot autoconfigure in them.
+func (s *SubnetsService) Delegate(parentID int, mask int, template *Subnet) (*Subnet, error) {
+	parent, err := s.Get(parentID)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := parent.Prefix()
+	if err != nil {
+		return nil, err
+	}
+	if !prefix.Addr().Is6() {
+		return nil, fmt.Errorf("subnet %s is not an IPv6 subnet", prefix)
+	}
+	if mask <= prefix.Bits() || mask > ipcalc.SLAACBits {
+		return nil, fmt.Errorf("cannot delegate a /%d from %s: mask must be between /%d and /%d",
+			mask, prefix, prefix.Bits()+1, ipcalc.SLAACBits)
+	}
+
+	subnet := Subnet{}
+	if template != nil {
+		subnet = *template
+	}
+	subnet.ID = 0
+	return s.CreateFirstSubnet(parentID, mask, &subnet)
+}
+
+// DelegateMany delegates count child prefixes of the given length, see Delegate.
+// If one cannot be created, the ones created so far are deleted again.
+func (s *SubnetsService) DelegateMany(parentID int, mask int, count int, template *Subnet) ([]Subnet, error) {
+	var created []Subnet
+	for i := 0; i < count; i++ {
+		subnet, err := s.Delegate(parentID, mask, template)
+		if err != nil {
+			errs := []error{fmt.Errorf("failed to delegate /%d %d of %d: %w", mask, i+1, count, err)}
+			for j := len(created) - 1; j >= 0; j-- {
+				if err := s.Delete(created[j].ID); err != nil {
+					errs = append(errs, fmt.Errorf("rollback of subnet %s failed: %w", created[j].CIDR(), err))
+				}
+			}
+			return nil, errors.Join(errs...)
+		}
+		created = append(created, *subnet)
+	}
+	return created, nil
+}
+
+// parseCount parses a host count reported by phpIPAM. IPv6 counts exceed 64 bits
+// and PHP may print them in float notation (e.g. "1.8446744073709552E+19"); those
+// are converted with the precision they were printed with.
+func parseCount(s string) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return new(big.Int), nil
+	}
+	if n, ok := new(big.Int).SetString(s, 10); ok {
+		return n, nil
+	}
+	f, _, err := big.ParseFloat(s, 10, 256, big.ToNearestEven)
+	if err != nil || f.Sign() < 0 {
+		return nil, fmt.Errorf("invalid count %q", s)
+	}
+	n, _ := f.Int(nil)
+	return n, nil
+}
+
+// UsedCount returns the number of used addresses as a big integer
+func (u *SubnetUsage) UsedCount() (*big.Int, error) {
+	return parseCount(u.Used)
+}
+
+// MaxHostsCount returns the number of usable addresses as a big integer
+func (u *SubnetUsage) MaxHostsCount() (*big.Int, error) {
+	return parseCount(u.MaxHosts)
+}
+
+// FreeCount returns the number of free addresses as a big integer
+func (u *SubnetUsage) FreeCount() (*big.Int, error) {
+	return parseCount(u.Freehosts)
+}
+
+// UsedPercent returns the used share of usable addresses in percent, computed
+// from the counts instead of the rounded percentage phpIPAM reports
+func (u *SubnetUsage) UsedPercent() (float64, error) {
+	used, err := u.UsedCount()
+	if err != nil {
+		return 0, err
+	}
+	maxHosts, err := u.MaxHostsCount()
+	if err != nil {
+		return 0, err
+	}
+	if maxHosts.Sign() == 0 {
+		return 0, nil
+	}
+	ratio, _ := new(big.Rat).SetFrac(used, maxHosts).Float64()
+	return ratio * 100, nil
+}
@@ -0,0 +1,161 @@
+package phpipam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEUI64Address(t *testing.T) {
+	tests := []struct {
+		subnet  Subnet
+		mac     string
+		want    string
+		wantErr string
+	}{
+		{subnet: testSubnet(7, "2001:db8:0:7::/64"), mac: "00:1A:2B:3C:4D:5E", want: "2001:db8:0:7:21a:2bff:fe3c:4d5e"},
+		{subnet: testSubnet(7, "2001:db8:0:7::/64"), mac: "001a2b3c4d5e", want: "2001:db8:0:7:21a:2bff:fe3c:4d5e"},
+		{subnet: testSubnet(7, "2001:db8::/56"), mac: "00:1a:2b:3c:4d:5e", wantErr: "is not an IPv6 /64"},
+		{subnet: testSubnet(7, "10.0.0.0/24"), mac: "00:1a:2b:3c:4d:5e", wantErr: "is not an IPv6 /64"},
+		{subnet: testSubnet(7, "2001:db8:0:7::/64"), mac: "00:1a:2b", wantErr: "invalid MAC"},
+	}
+	for _, tt := range tests {
+		got, err := EUI64Address(&tt.subnet, tt.mac)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("EUI64Address(%s, %s) = %s, %v, want %q", tt.subnet.CIDR(), tt.mac, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("EUI64Address(%s, %s) = %s, %v, want %s", tt.subnet.CIDR(), tt.mac, got, err, tt.want)
+		}
+	}
+}
+
+func TestReserveIPv6(t *testing.T) {
+	tests := []struct {
+		name      string
+		subnet    string
+		addresses string
+		mac       string
+		want      string
+	}{
+		{
+			name:   "EUI-64 address of the MAC",
+			subnet: "2001:db8:0:7::/64",
+			mac:    "00:1a:2b:3c:4d:5e",
+			want:   "2001:db8:0:7:21a:2bff:fe3c:4d5e",
+		},
+		{
+			name:      "lowest static identifier",
+			subnet:    "2001:db8:0:7::/64",
+			addresses: `[{"id":101,"subnetId":7,"ip":"2001:db8:0:7::1"},{"id":102,"subnetId":7,"ip":"2001:db8:0:7:21a:2bff:fe3c:4d5e"}]`,
+			want:      "2001:db8:0:7::2",
+		},
+		{
+			name:      "static identifiers exhausted does not fall into SLAAC space",
+			subnet:    "2001:db8:0:7::/64",
+			addresses: staticAddresses("2001:db8:0:7::", 0xffff),
+		},
+		{
+			name:      "longer prefix uses any free address",
+			subnet:    "2001:db8::/126",
+			addresses: `[{"id":101,"subnetId":7,"ip":"2001:db8::1"}]`,
+			mac:       "00:1a:2b:3c:4d:5e",
+			want:      "2001:db8::2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/test/"), "/")
+				address, mask, _ := strings.Cut(tt.subnet, "/")
+				switch {
+				case endpoint == "subnets/7":
+					fmt.Fprintf(w, `{"code":200,"success":true,"data":{"id":7,"subnet":"%s","mask":"%s","sectionId":1}}`, address, mask)
+				case endpoint == "subnets/7/slaves":
+					fmt.Fprint(w, `{"code":404,"success":false,"message":"No slaves"}`)
+				case endpoint == "subnets/7/addresses" && tt.addresses == "":
+					fmt.Fprint(w, notFound)
+				case endpoint == "subnets/7/addresses":
+					fmt.Fprintf(w, `{"code":200,"success":true,"data":%s}`, tt.addresses)
+				case r.Method == "POST" && endpoint == "addresses":
+					var request Address
+					json.NewDecoder(r.Body).Decode(&request)
+					created = append(created, request.IP)
+					fmt.Fprintf(w, `{"code":201,"success":true,"id":"200","data":{"id":200,"subnetId":7,"ip":"%s","mac":"%s"}}`, request.IP, request.Mac)
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, endpoint)
+					fmt.Fprint(w, `{"code":400,"success":false,"message":"Invalid request"}`)
+				}
+			}))
+			defer srv.Close()
+			api, err := NewTokenClient(srv.URL+"/api/", "test", "token", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			address, err := api.NewAllocator().ReserveIPv6(7, Address{Mac: tt.mac, Hostname: "host"})
+			if tt.want == "" {
+				if !errors.Is(err, ErrInsufficientSpace) {
+					t.Errorf("err = %v, want ErrInsufficientSpace", err)
+				}
+				if len(created) != 0 {
+					t.Errorf("created %v", created)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if address.IP != tt.want || len(created) != 1 || created[0] != tt.want {
+				t.Errorf("reserved %s (created %v), want %s", address.IP, created, tt.want)
+			}
+		})
+	}
+}
+
+// staticAddresses returns JSON for addresses using the interface identifiers
+// 1 to n of a /64
+func staticAddresses(network string, n int) string {
+	addresses := make([]string, n)
+	for i := range addresses {
+		addresses[i] = fmt.Sprintf(`{"id":%d,"subnetId":7,"ip":"%s%x"}`, 1000+i, network, i+1)
+	}
+	return "[" + strings.Join(addresses, ",") + "]"
+}
+
+func TestParseCount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: "0"},
+		{in: "254", want: "254"},
+		{in: " 18446744073709551614 ", want: "18446744073709551614"},
+		// Float notation keeps only the printed digits
+		{in: "1.8446744073709552E+19", want: "18446744073709552000"},
+		{in: "-1.5E+3", wantErr: true},
+		{in: "many", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCount(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseCount(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("parseCount(%q) = %v, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+}
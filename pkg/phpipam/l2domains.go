@@ -7,10 +7,10 @@ import (
 
 // L2Domain represents a phpIPAM VLAN domain (L2 domain) object
 type L2Domain struct {
-	ID          string `json:"id,omitempty"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-	Permissions string `json:"permissions,omitempty"`
+	ID          string     `json:"id,omitempty"`
+	Name        string     `json:"name,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Permissions SectionIDs `json:"permissions,omitempty"` // Sections the domain is available in
 }
 
 // L2DomainsService handles communication with the L2 domains related methods of the API
@@ -65,6 +65,9 @@ func (l *L2DomainsService) Create(domain *L2Domain) (*L2Domain, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the domain data, retrieve the full domain
 	if resp.ID != 0 && createdDomain.ID == "" {
@@ -85,8 +88,27 @@ func (l *L2DomainsService) Update(domain *L2Domain) (*L2Domain, error) {
 	return &updatedDomain, err
 }
 
+// Patch sends a partial update for an L2 domain, including fields set to zero or empty
+func (l *L2DomainsService) Patch(id string, patch *Patch) error {
+	if id == "" {
+		return fmt.Errorf("L2 domain ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("L2 domain patch contains no fields")
+	}
+
+	resp, err := l.client.Request("PATCH", fmt.Sprintf("l2domains/%s", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
 // Delete deletes a L2 domain
 func (l *L2DomainsService) Delete(id string) error {
-	_, err := l.client.Request("DELETE", fmt.Sprintf("l2domains/%s", id), nil, nil)
-	return err
+	resp, err := l.client.Request("DELETE", fmt.Sprintf("l2domains/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
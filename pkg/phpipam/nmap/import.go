@@ -0,0 +1,381 @@
+package nmap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/report"
+)
+
+// DefaultTag is the state of created addresses when ImportOptions.Tag is empty
+const DefaultTag = "Used"
+
+// vendorPrefix starts the line of an address note that records the MAC vendor
+const vendorPrefix = "Vendor: "
+
+// ImportOptions controls how a scan is applied
+type ImportOptions struct {
+	// VRF is the VRF whose subnets hosts are matched against, phpipam.GlobalVRF
+	// by default
+	VRF int
+	// Create adds hosts that are up but not in phpIPAM to the most specific
+	// subnet containing them
+	Create bool
+	// Tag is the name or ID of the state of created addresses, DefaultTag when
+	// empty
+	Tag string
+	// OverwriteHostnames replaces existing hostnames with the PTR name; by
+	// default only empty hostnames are filled in
+	OverwriteHostnames bool
+	// OverwriteMACs replaces existing MACs; by default a different MAC is
+	// reported as a conflict and left alone
+	OverwriteMACs bool
+	// DeleteDown deletes the addresses of hosts nmap reported down. Nothing is
+	// deleted otherwise.
+	DeleteDown bool
+	// DryRun computes the report without changing anything
+	DryRun bool
+}
+
+// Action is what the import did, or would do, with a host
+type Action string
+
+const (
+	// ActionCreate creates an address for the host
+	ActionCreate Action = "create"
+	// ActionUpdate updates the host's address
+	ActionUpdate Action = "update"
+	// ActionUnchanged leaves an address that already matches the host
+	ActionUnchanged Action = "unchanged"
+	// ActionDelete deletes the address of a host that is down
+	ActionDelete Action = "delete"
+	// ActionSkip ignores the host, see Change.Reason
+	ActionSkip Action = "skip"
+	// ActionFailed marks a change phpIPAM rejected
+	ActionFailed Action = "failed"
+)
+
+// FieldDiff is an address field the import changes
+type FieldDiff = report.FieldDiff
+
+// Change is the effect of the import on one host
+type Change struct {
+	Host   Host
+	Action Action
+	// Subnet is the most specific subnet containing the host
+	Subnet string
+	// AddressID is the existing address, or the created one
+	AddressID int
+	Diffs     []FieldDiff
+	// Conflicts lists scanned values that differ from phpIPAM and were kept
+	Conflicts []FieldDiff
+	// Reason explains skipped hosts
+	Reason string
+	Err    error
+}
+
+// Report lists the effect of an import on every scanned host
+type Report struct {
+	DryRun  bool
+	Changes []Change
+}
+
+// Count returns the number of changes with the given action
+func (r *Report) Count(action Action) int {
+	return report.Count(r.Changes, func(c Change) bool { return c.Action == action })
+}
+
+// Err returns an error describing the failed changes, or nil
+func (r *Report) Err() error {
+	var errs []error
+	for _, c := range r.Changes {
+		if c.Action == ActionFailed {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Host.IP, c.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Render writes the report in a human-readable form. Unchanged hosts are left
+// out.
+func (r *Report) Render(w io.Writer) error {
+	return report.Render(w, func(b *strings.Builder) {
+		for _, c := range r.Changes {
+			if c.Action == ActionUnchanged && len(c.Conflicts) == 0 {
+				continue
+			}
+			fmt.Fprintf(b, "%-9s %s", c.Action, c.Host.IP)
+			if c.Subnet != "" {
+				fmt.Fprintf(b, " in %s", c.Subnet)
+			}
+			switch {
+			case c.Err != nil:
+				fmt.Fprintf(b, ": %v", c.Err)
+			case c.Reason != "":
+				fmt.Fprintf(b, ": %s", c.Reason)
+			}
+			b.WriteString("\n")
+			report.WriteDiffs(b, c.Diffs, false)
+			report.WriteConflicts(b, c.Conflicts, "scan found")
+		}
+		b.WriteString(report.Summary("Import", r.DryRun,
+			report.Tally{N: r.Count(ActionCreate), Label: "created"},
+			report.Tally{N: r.Count(ActionUpdate), Label: "updated"},
+			report.Tally{N: r.Count(ActionUnchanged), Label: "unchanged"},
+			report.Tally{N: r.Count(ActionDelete), Label: "deleted"},
+			report.Tally{N: r.Count(ActionSkip), Label: "skipped"},
+			report.Tally{N: r.Count(ActionFailed), Label: "failed"}))
+	})
+}
+
+// String returns the rendered report
+func (r *Report) String() string {
+	return report.String(r.Render)
+}
+
+// importer holds what Import looks up once per scan
+type importer struct {
+	api       *phpipam.PHPIPAM
+	opts      ImportOptions
+	index     *phpipam.SubnetIndex
+	tag       int
+	addresses map[int]map[netip.Addr]*phpipam.Address
+}
+
+// Import applies a scan to phpIPAM. Each host is matched to the most specific
+// subnet containing it. Hosts that are up update the last seen time of their
+// address, fill in its hostname from the PTR name and its MAC, and record the
+// MAC vendor in its note when the address has or takes the scanned MAC. The
+// returned error summarizes failed changes.
+func Import(api *phpipam.PHPIPAM, scan *Scan, opts ImportOptions) (*Report, error) {
+	index, err := api.GetSubnetIndex()
+	if err != nil {
+		return nil, err
+	}
+	im := &importer{api: api, opts: opts, index: index, addresses: make(map[int]map[netip.Addr]*phpipam.Address)}
+	if opts.Create {
+		name := opts.Tag
+		if name == "" {
+			name = DefaultTag
+		}
+		tag, err := api.Addresses.ResolveTag(name)
+		if err != nil {
+			return nil, err
+		}
+		im.tag = tag.ID
+	}
+
+	report := &Report{DryRun: opts.DryRun}
+	for _, host := range scan.Hosts {
+		change, err := im.host(host)
+		if err != nil {
+			return nil, err
+		}
+		report.Changes = append(report.Changes, change)
+	}
+	return report, report.Err()
+}
+
+// existing returns the addresses of a subnet by IP
+func (im *importer) existing(subnet *phpipam.Subnet) (map[netip.Addr]*phpipam.Address, error) {
+	if addresses, ok := im.addresses[subnet.ID]; ok {
+		return addresses, nil
+	}
+	list, err := im.api.Subnets.GetAddresses(subnet.ID)
+	if err != nil {
+		return nil, fmt.Errorf("subnet %s: %w", subnet.CIDR(), err)
+	}
+	addresses := make(map[netip.Addr]*phpipam.Address, len(list))
+	for i := range list {
+		if addr, err := list[i].Addr(); err == nil {
+			addresses[addr] = &list[i]
+		}
+	}
+	im.addresses[subnet.ID] = addresses
+	return addresses, nil
+}
+
+// host plans and applies the change of one host
+func (im *importer) host(host Host) (Change, error) {
+	change := Change{Host: host, Action: ActionSkip}
+
+	subnet, ok := im.index.Lookup(im.opts.VRF, host.IP)
+	if !ok {
+		change.Reason = "no subnet contains the address"
+		return change, nil
+	}
+	change.Subnet = subnet.CIDR()
+
+	addresses, err := im.existing(subnet)
+	if err != nil {
+		return change, err
+	}
+	current, exists := addresses[host.IP]
+	if exists {
+		change.AddressID = current.ID
+	}
+
+	switch {
+	case !host.Up && exists && im.opts.DeleteDown:
+		change.Action = ActionDelete
+		if !im.opts.DryRun {
+			if err := im.api.Addresses.Delete(current.ID); err != nil {
+				change.Action, change.Err = ActionFailed, err
+			}
+		}
+	case !host.Up:
+		change.Reason = "host is down"
+	case !exists && !im.opts.Create:
+		change.Reason = "not in phpIPAM"
+	case !exists:
+		im.create(subnet, &change)
+	default:
+		im.update(current, &change)
+	}
+	return change, nil
+}
+
+// create adds the address of a host that is up
+func (im *importer) create(subnet *phpipam.Subnet, change *Change) {
+	host := change.Host
+	address := &phpipam.Address{
+		SubnetID: subnet.ID,
+		IP:       host.IP.String(),
+		Hostname: host.Hostname,
+		Tag:      im.tag,
+	}
+	if host.MAC != nil {
+		address.Mac = host.MAC.String()
+	}
+	if host.Vendor != "" {
+		address.Note = vendorPrefix + host.Vendor
+	}
+	if !host.SeenAt.IsZero() {
+		address.LastSeen = im.api.Client.FormatLastSeen(host.SeenAt)
+	}
+	change.Diffs = createDiffs(address)
+
+	change.Action = ActionCreate
+	if im.opts.DryRun {
+		return
+	}
+	created, err := im.api.Addresses.Create(address)
+	if err != nil {
+		change.Action, change.Err = ActionFailed, err
+		return
+	}
+	change.AddressID = created.ID
+}
+
+// createDiffs lists the fields of a new address
+func createDiffs(a *phpipam.Address) []FieldDiff {
+	var diffs []FieldDiff
+	for _, f := range []FieldDiff{
+		{Field: "hostname", New: a.Hostname},
+		{Field: "mac", New: a.Mac},
+		{Field: "note", New: a.Note},
+		{Field: "lastSeen", New: a.LastSeen},
+	} {
+		if f.New != "" {
+			diffs = append(diffs, f)
+		}
+	}
+	return diffs
+}
+
+// update fills in and refreshes the fields of an existing address. Values are
+// only added or replaced, never cleared.
+func (im *importer) update(current *phpipam.Address, change *Change) {
+	host := change.Host
+	patch := phpipam.NewAddressPatch()
+	diff := func(field, old, value string) {
+		change.Diffs = append(change.Diffs, FieldDiff{Field: field, Old: old, New: value})
+	}
+	conflict := func(field, old, value string) {
+		change.Conflicts = append(change.Conflicts, FieldDiff{Field: field, Old: old, New: value})
+	}
+
+	if host.Hostname != "" && !strings.EqualFold(host.Hostname, current.Hostname) {
+		switch {
+		case current.Hostname == "" || im.opts.OverwriteHostnames:
+			diff("hostname", current.Hostname, host.Hostname)
+			patch.SetHostname(host.Hostname)
+		default:
+			conflict("hostname", current.Hostname, host.Hostname)
+		}
+	}
+
+	// the vendor belongs to the scanned MAC, so it is only recorded when the
+	// address keeps or takes that MAC
+	macKept := false
+	if host.MAC != nil {
+		old, err := ipcalc.ParseMAC(current.Mac)
+		switch {
+		case err == nil && old.String() == host.MAC.String():
+			macKept = true
+		case current.Mac == "" || im.opts.OverwriteMACs:
+			diff("mac", current.Mac, host.MAC.String())
+			patch.SetMac(host.MAC.String())
+			macKept = true
+		default:
+			conflict("mac", current.Mac, host.MAC.String())
+		}
+	}
+
+	if macKept && host.Vendor != "" {
+		if note := vendorNote(current.Note, host.Vendor); note != current.Note {
+			diff("note", current.Note, note)
+			patch.SetNote(note)
+		}
+	}
+
+	if !host.SeenAt.IsZero() {
+		seen, err := im.api.Client.ParseLastSeen(current.LastSeen)
+		if err != nil || host.SeenAt.After(seen) {
+			value := im.api.Client.FormatLastSeen(host.SeenAt)
+			diff("lastSeen", current.LastSeen, value)
+			patch.SetLastSeen(value)
+		}
+	}
+
+	if patch.Len() == 0 {
+		change.Action = ActionUnchanged
+		return
+	}
+	change.Action = ActionUpdate
+	if im.opts.DryRun {
+		return
+	}
+	if err := im.api.Addresses.Patch(current.ID, patch); err != nil {
+		change.Action, change.Err = ActionFailed, err
+	}
+}
+
+// vendorNote returns the note with its vendor line set to vendor. An existing
+// vendor line is replaced and any further ones dropped; otherwise the line is
+// appended.
+func vendorNote(note, vendor string) string {
+	line := vendorPrefix + vendor
+	if note == "" {
+		return line
+	}
+	var lines []string
+	found := false
+	for _, l := range strings.Split(note, "\n") {
+		if !strings.HasPrefix(l, vendorPrefix) {
+			lines = append(lines, l)
+		} else if !found {
+			lines = append(lines, line)
+			found = true
+		}
+	}
+	if !found {
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
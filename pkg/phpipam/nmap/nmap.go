@@ -0,0 +1,175 @@
+// Package nmap imports nmap XML scan results (nmap -oX) into phpIPAM: hosts that
+// are up update the last seen time, hostname, MAC and vendor note of their
+// addresses, and can optionally be created in the subnet that owns them.
+//
+//	scan, err := nmap.ParseFile("scan.xml")
+//	report, err := nmap.Import(client, scan, nmap.ImportOptions{DryRun: true})
+//	fmt.Print(report)
+package nmap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// Host is a scanned host
+type Host struct {
+	IP  netip.Addr
+	MAC net.HardwareAddr
+	// Vendor is the MAC vendor nmap derived from the OUI
+	Vendor string
+	// Hostname is the reverse DNS name nmap resolved
+	Hostname string
+	// Up reports whether nmap found the host up
+	Up bool
+	// SeenAt is when the host was scanned
+	SeenAt time.Time
+}
+
+// Scan is a parsed nmap run
+type Scan struct {
+	Args     string
+	Start    time.Time
+	Finished time.Time
+	Hosts    []Host
+}
+
+// xmlRun mirrors the parts of the nmap XML output the importer uses
+type xmlRun struct {
+	XMLName  xml.Name  `xml:"nmaprun"`
+	Args     string    `xml:"args,attr"`
+	Start    int64     `xml:"start,attr"`
+	Hosts    []xmlHost `xml:"host"`
+	Finished struct {
+		Time int64 `xml:"time,attr"`
+	} `xml:"runstats>finished"`
+}
+
+// xmlHost is a host element of the nmap XML output
+type xmlHost struct {
+	StartTime int64 `xml:"starttime,attr"`
+	EndTime   int64 `xml:"endtime,attr"`
+	Status    struct {
+		State string `xml:"state,attr"`
+	} `xml:"status"`
+	Addresses []struct {
+		Addr     string `xml:"addr,attr"`
+		AddrType string `xml:"addrtype,attr"`
+		Vendor   string `xml:"vendor,attr"`
+	} `xml:"address"`
+	Hostnames []struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"hostnames>hostname"`
+}
+
+// Parse reads nmap XML output. Hosts without an IP address are skipped; a host
+// listed more than once keeps its last entry.
+func Parse(r io.Reader) (*Scan, error) {
+	var run xmlRun
+	if err := xml.NewDecoder(r).Decode(&run); err != nil {
+		return nil, fmt.Errorf("invalid nmap XML: %w", err)
+	}
+
+	scan := &Scan{Args: run.Args, Start: unixTime(run.Start), Finished: unixTime(run.Finished.Time)}
+	byIP := make(map[netip.Addr]int)
+	for _, h := range run.Hosts {
+		host := Host{Up: h.Status.State == "up"}
+		for _, a := range h.Addresses {
+			switch a.AddrType {
+			case "ipv4", "ipv6":
+				addr, err := netip.ParseAddr(a.Addr)
+				if err != nil {
+					return nil, fmt.Errorf("invalid host address %q: %w", a.Addr, err)
+				}
+				host.IP = addr.Unmap()
+			case "mac":
+				mac, err := ipcalc.ParseMAC(a.Addr)
+				if err != nil {
+					return nil, fmt.Errorf("invalid MAC address %q: %w", a.Addr, err)
+				}
+				host.MAC, host.Vendor = mac, a.Vendor
+			}
+		}
+		if !host.IP.IsValid() {
+			continue
+		}
+		for _, name := range h.Hostnames {
+			if name.Type == "PTR" {
+				host.Hostname = strings.TrimSuffix(name.Name, ".")
+				break
+			}
+		}
+
+		switch {
+		case h.EndTime != 0:
+			host.SeenAt = unixTime(h.EndTime)
+		case h.StartTime != 0:
+			host.SeenAt = unixTime(h.StartTime)
+		case run.Finished.Time != 0:
+			host.SeenAt = scan.Finished
+		default:
+			host.SeenAt = scan.Start
+		}
+
+		if i, ok := byIP[host.IP]; ok {
+			scan.Hosts[i] = host
+			continue
+		}
+		byIP[host.IP] = len(scan.Hosts)
+		scan.Hosts = append(scan.Hosts, host)
+	}
+
+	sort.Slice(scan.Hosts, func(i, j int) bool { return scan.Hosts[i].IP.Less(scan.Hosts[j].IP) })
+	return scan, nil
+}
+
+// ParseFile reads an nmap XML file
+func ParseFile(path string) (*Scan, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// unixTime converts an nmap timestamp, zero for missing values
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+// String returns a one-line summary of a host
+func (h Host) String() string {
+	state := "down"
+	if h.Up {
+		state = "up"
+	}
+	var b strings.Builder
+	b.WriteString(h.IP.String() + " " + state)
+	if h.Hostname != "" {
+		b.WriteString(" " + h.Hostname)
+	}
+	if h.MAC != nil {
+		b.WriteString(" " + h.MAC.String())
+		if h.Vendor != "" {
+			b.WriteString(" (" + h.Vendor + ")")
+		}
+	}
+	if !h.SeenAt.IsZero() {
+		b.WriteString(" at " + h.SeenAt.Format(time.RFC3339))
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package nmap
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	f, err := os.Open("testdata/scan.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scan, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "nmap -sn -oX scan.xml 10.0.0.0/29"; scan.Args != want {
+		t.Errorf("Args = %q, want %q", scan.Args, want)
+	}
+	if want := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC); !scan.Start.Equal(want) {
+		t.Errorf("Start = %s, want %s", scan.Start, want)
+	}
+	if want := time.Date(2024, 5, 1, 10, 1, 0, 0, time.UTC); !scan.Finished.Equal(want) {
+		t.Errorf("Finished = %s, want %s", scan.Finished, want)
+	}
+
+	hosts := make([]string, len(scan.Hosts))
+	for i, h := range scan.Hosts {
+		hosts[i] = h.String()
+	}
+	got := strings.Join(hosts, "\n")
+	want := strings.Join([]string{
+		"10.0.0.1 up gw.example.com 52:54:00:00:00:01 (Cisco Systems) at 2024-05-01T10:00:20Z",
+		"10.0.0.3 down at 2024-05-01T10:01:00Z",
+		"10.0.0.5 down at 2024-05-01T10:00:40Z",
+		"2001:db8::7 up at 2024-05-01T10:01:00Z",
+	}, "\n")
+	if got != want {
+		t.Errorf("Parse hosts =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"<nmaprun><host>", "invalid nmap XML"},
+		{`<html></html>`, "invalid nmap XML"},
+		{`<nmaprun><host><address addr="10.0.0.300" addrtype="ipv4"/></host></nmaprun>`, "invalid host address"},
+		{`<nmaprun><host><address addr="zz" addrtype="mac"/></host></nmaprun>`, "invalid MAC address"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestVendorNote(t *testing.T) {
+	tests := []struct {
+		note string
+		want string
+	}{
+		{"", "Vendor: Cisco"},
+		{"rack 4", "rack 4\nVendor: Cisco"},
+		{"Vendor: Cisco", "Vendor: Cisco"},
+		{"rack 4\nVendor: QEMU\nspare", "rack 4\nVendor: Cisco\nspare"},
+		{"Vendor: QEMU\nrack 4\nVendor: Intel", "Vendor: Cisco\nrack 4"},
+	}
+	for _, tt := range tests {
+		if got := vendorNote(tt.note, "Cisco"); got != tt.want {
+			t.Errorf("vendorNote(%q) = %q, want %q", tt.note, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package phpipam
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// ConflictKind describes how two subnets collide
+type ConflictKind string
+
+const (
+	// ConflictOverlap means one subnet lies inside another without being its child
+	ConflictOverlap ConflictKind = "overlap"
+	// ConflictDuplicate means two subnets have the same prefix
+	ConflictDuplicate ConflictKind = "duplicate"
+)
+
+// Conflict is a pair of colliding subnets in the same VRF
+type Conflict struct {
+	Kind  ConflictKind
+	VrfID int
+	// Outer is the larger subnet, or the one with the lower ID for duplicates
+	Outer Subnet
+	// Inner is the subnet inside Outer
+	Inner Subnet
+}
+
+// String describes the conflict, e.g. "10.0.1.0/24 (id 7) overlaps 10.0.0.0/16 (id 3) in VRF 0"
+func (c Conflict) String() string {
+	verb := "overlaps"
+	if c.Kind == ConflictDuplicate {
+		verb = "duplicates"
+	}
+	return fmt.Sprintf("%s (id %d) %s %s (id %d) in VRF %d",
+		c.Inner.CIDR(), c.Inner.ID, verb, c.Outer.CIDR(), c.Outer.ID, c.VrfID)
+}
+
+// OverlapOptions controls which subnets are compared by FindOverlaps
+type OverlapOptions struct {
+	// AcrossSections also reports conflicts between subnets of different sections.
+	// phpIPAM allows the same space in several sections, so by default only
+	// subnets of the same section are compared.
+	AcrossSections bool
+}
+
+// OverlapError is returned by SubnetsService.Create when the overlap pre-flight
+// check finds conflicts
+type OverlapError struct {
+	Subnet    string
+	Conflicts []Conflict
+}
+
+// Error implements the error interface
+func (e *OverlapError) Error() string {
+	descriptions := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		descriptions[i] = conflict.String()
+	}
+	return fmt.Sprintf("subnet %s conflicts with existing subnets: %s", e.Subnet, strings.Join(descriptions, "; "))
+}
+
+// overlapCandidate is a subnet with its parsed prefix and comparison group
+type overlapCandidate struct {
+	subnet *Subnet
+	prefix netip.Prefix
+	vrf    int
+	group  string
+}
+
+// FindOverlaps reports duplicate and overlapping subnets. Subnets are only
+// compared within the same VRF (GetVrfID, no VRF counts as VRF 0) and, unless
+// AcrossSections is set, the same section.
+//
+// A subnet inside another is legitimate when it descends from it through
+// MasterSubnetID; ancestry is followed only through the given subnets. Folders
+// are skipped. Conflicts are ordered by VRF and address.
+func FindOverlaps(subnets []Subnet, opts OverlapOptions) ([]Conflict, error) {
+	byID := make(map[int]*Subnet, len(subnets))
+	var candidates []overlapCandidate
+	for i := range subnets {
+		byID[subnets[i].ID] = &subnets[i]
+		if subnets[i].IsFolder == 1 {
+			continue
+		}
+		prefix, err := subnets[i].Prefix()
+		if err != nil {
+			return nil, fmt.Errorf("subnet %d: %w", subnets[i].ID, err)
+		}
+
+		candidate := overlapCandidate{subnet: &subnets[i], prefix: prefix, vrf: subnetVRF(&subnets[i])}
+		candidate.group = fmt.Sprintf("%d", candidate.vrf)
+		if !opts.AcrossSections {
+			candidate.group += fmt.Sprintf("/%d", subnets[i].SectionID)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	// Order by group, then address with larger prefixes first, so every prefix
+	// follows all prefixes containing it
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.vrf != b.vrf {
+			return a.vrf < b.vrf
+		}
+		if a.group != b.group {
+			return a.group < b.group
+		}
+		if a.prefix.Addr().BitLen() != b.prefix.Addr().BitLen() {
+			return a.prefix.Addr().BitLen() < b.prefix.Addr().BitLen()
+		}
+		if a.prefix.Addr() != b.prefix.Addr() {
+			return a.prefix.Addr().Less(b.prefix.Addr())
+		}
+		if a.prefix.Bits() != b.prefix.Bits() {
+			return a.prefix.Bits() < b.prefix.Bits()
+		}
+		return a.subnet.ID < b.subnet.ID
+	})
+
+	var conflicts []Conflict
+	var enclosing []overlapCandidate
+	for i, candidate := range candidates {
+		if i > 0 && candidates[i-1].group != candidate.group {
+			enclosing = nil
+		}
+
+		// Drop prefixes that end before this one; the rest all contain it
+		for len(enclosing) > 0 && !enclosing[len(enclosing)-1].prefix.Contains(candidate.prefix.Addr()) {
+			enclosing = enclosing[:len(enclosing)-1]
+		}
+
+		for _, outer := range enclosing {
+			if outer.prefix == candidate.prefix {
+				conflicts = append(conflicts, Conflict{Kind: ConflictDuplicate, VrfID: candidate.vrf, Outer: *outer.subnet, Inner: *candidate.subnet})
+			} else if !isDescendant(candidate.subnet, outer.subnet.ID, byID) {
+				conflicts = append(conflicts, Conflict{Kind: ConflictOverlap, VrfID: candidate.vrf, Outer: *outer.subnet, Inner: *candidate.subnet})
+			}
+		}
+		enclosing = append(enclosing, candidate)
+	}
+
+	return conflicts, nil
+}
+
+// isDescendant reports whether ancestorID is reachable from subnet through
+// MasterSubnetID links
+func isDescendant(subnet *Subnet, ancestorID int, byID map[int]*Subnet) bool {
+	seen := make(map[int]bool)
+	for id := subnet.MasterSubnetID; id != 0 && !seen[id]; {
+		if id == ancestorID {
+			return true
+		}
+		seen[id] = true
+		parent, ok := byID[id]
+		if !ok {
+			return false
+		}
+		id = parent.MasterSubnetID
+	}
+	return false
+}
+
+// FindOverlaps checks a subnet that is about to be created against the existing
+// subnets the server reports as overlapping (GetOverlapping), applying the VRF,
+// section and ancestry rules of FindOverlaps
+func (s *SubnetsService) FindOverlaps(subnet *Subnet, opts OverlapOptions) ([]Conflict, error) {
+	prefix, err := subnet.Prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.GetOverlapping(prefix.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// The new subnet has no ID and no children yet, so only its own ancestry matters
+	candidate := *subnet
+	candidate.ID = 0
+	conflicts, err := FindOverlaps(append(existing, candidate), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Conflict
+	for _, conflict := range conflicts {
+		if conflict.Outer.ID == 0 || conflict.Inner.ID == 0 {
+			result = append(result, conflict)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package phpipam
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindOverlaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		subnets []Subnet
+		opts    OverlapOptions
+		// want lists the conflicts as Conflict.String, separated by "; "
+		want string
+	}{
+		{
+			name: "unrelated subnet inside another",
+			subnets: []Subnet{
+				{ID: 3, Subnet: "10.0.0.0", Mask: "16", SectionID: 1},
+				{ID: 7, Subnet: "10.0.1.0", Mask: "24", SectionID: 1},
+			},
+			want: "10.0.1.0/24 (id 7) overlaps 10.0.0.0/16 (id 3) in VRF 0",
+		},
+		{
+			name: "child and grandchild",
+			subnets: []Subnet{
+				{ID: 3, Subnet: "10.0.0.0", Mask: "16", SectionID: 1},
+				{ID: 4, Subnet: "10.0.1.0", Mask: "24", SectionID: 1, MasterSubnetID: 3},
+				{ID: 5, Subnet: "10.0.1.128", Mask: "25", SectionID: 1, MasterSubnetID: 4},
+			},
+		},
+		{
+			name: "child through a folder",
+			subnets: []Subnet{
+				{ID: 3, Subnet: "10.0.0.0", Mask: "16", SectionID: 1},
+				{ID: 4, IsFolder: 1, SectionID: 1, MasterSubnetID: 3},
+				{ID: 5, Subnet: "10.0.1.0", Mask: "24", SectionID: 1, MasterSubnetID: 4},
+			},
+		},
+		{
+			name: "duplicate in the same VRF",
+			subnets: []Subnet{
+				{ID: 9, Subnet: "10.0.1.0", Mask: "24", SectionID: 1},
+				{ID: 4, Subnet: "10.0.1.0", Mask: "24", SectionID: 1, VrfID: "0"},
+			},
+			want: "10.0.1.0/24 (id 9) duplicates 10.0.1.0/24 (id 4) in VRF 0",
+		},
+		{
+			name: "same prefix in different VRFs",
+			subnets: []Subnet{
+				{ID: 4, Subnet: "10.0.1.0", Mask: "24", SectionID: 1},
+				{ID: 9, Subnet: "10.0.1.0", Mask: "24", SectionID: 1, VrfID: "2"},
+				{ID: 10, Subnet: "10.0.0.0", Mask: "8", SectionID: 1, VrfID: 3},
+			},
+		},
+		{
+			name: "overlaps reported per VRF",
+			subnets: []Subnet{
+				{ID: 1, Subnet: "10.0.0.0", Mask: "8", SectionID: 1, VrfID: 2},
+				{ID: 2, Subnet: "10.1.0.0", Mask: "16", SectionID: 1, VrfID: "2"},
+				{ID: 3, Subnet: "10.0.0.0", Mask: "8", SectionID: 1},
+				{ID: 4, Subnet: "10.2.0.0", Mask: "16", SectionID: 1},
+			},
+			want: "10.2.0.0/16 (id 4) overlaps 10.0.0.0/8 (id 3) in VRF 0; " +
+				"10.1.0.0/16 (id 2) overlaps 10.0.0.0/8 (id 1) in VRF 2",
+		},
+		{
+			name: "different sections are ignored by default",
+			subnets: []Subnet{
+				{ID: 3, Subnet: "10.0.0.0", Mask: "16", SectionID: 1},
+				{ID: 7, Subnet: "10.0.1.0", Mask: "24", SectionID: 2},
+			},
+		},
+		{
+			name: "different sections compared across sections",
+			subnets: []Subnet{
+				{ID: 3, Subnet: "10.0.0.0", Mask: "16", SectionID: 1},
+				{ID: 7, Subnet: "10.0.1.0", Mask: "24", SectionID: 2},
+				{ID: 8, Subnet: "10.0.1.0", Mask: "24", SectionID: 3, VrfID: 5},
+			},
+			opts: OverlapOptions{AcrossSections: true},
+			want: "10.0.1.0/24 (id 7) overlaps 10.0.0.0/16 (id 3) in VRF 0",
+		},
+		{
+			name: "nested overlaps report every enclosing subnet",
+			subnets: []Subnet{
+				{ID: 1, Subnet: "10.0.0.0", Mask: "8", SectionID: 1},
+				{ID: 2, Subnet: "10.1.0.0", Mask: "16", SectionID: 1, MasterSubnetID: 1},
+				{ID: 3, Subnet: "10.1.1.0", Mask: "24", SectionID: 1},
+				{ID: 4, Subnet: "10.2.0.0", Mask: "24", SectionID: 1, MasterSubnetID: 1},
+			},
+			want: "10.1.1.0/24 (id 3) overlaps 10.0.0.0/8 (id 1) in VRF 0; " +
+				"10.1.1.0/24 (id 3) overlaps 10.1.0.0/16 (id 2) in VRF 0",
+		},
+		{
+			name: "ipv6 and ipv4 are separate",
+			subnets: []Subnet{
+				{ID: 1, Subnet: "::", Mask: "0", SectionID: 1},
+				{ID: 2, Subnet: "10.0.0.0", Mask: "8", SectionID: 1},
+				{ID: 3, Subnet: "2001:db8::", Mask: "32", SectionID: 1},
+			},
+			want: "2001:db8::/32 (id 3) overlaps ::/0 (id 1) in VRF 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts, err := FindOverlaps(tt.subnets, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, conflict := range conflicts {
+				got = append(got, conflict.String())
+			}
+			if strings.Join(got, "; ") != tt.want {
+				t.Errorf("conflicts:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.ReplaceAll(tt.want, "; ", "\n"))
+			}
+		})
+	}
+}
+
+func TestFindOverlapsInvalidSubnet(t *testing.T) {
+	_, err := FindOverlaps([]Subnet{{ID: 3, Subnet: "10.0.0.0", Mask: "40"}}, OverlapOptions{})
+	if err == nil || !strings.Contains(err.Error(), "subnet 3") {
+		t.Errorf("err = %v, want an error naming subnet 3", err)
+	}
+}
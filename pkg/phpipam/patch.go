@@ -0,0 +1,362 @@
+package phpipam
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Patch holds the set of fields to send in a PATCH request.
+//
+// The Update methods marshal the whole object and rely on omitempty, so a
+// field can never be cleared or set back to zero through them. A Patch sends
+// exactly the fields that were set on it, including zeros, empty strings and
+// explicit nulls.
+type Patch struct {
+	fields map[string]interface{}
+}
+
+// NewPatch creates an empty patch
+func NewPatch() *Patch {
+	return &Patch{fields: map[string]interface{}{}}
+}
+
+// set records a field value, creating the field map on first use
+func (p *Patch) set(field string, value interface{}) {
+	if p.fields == nil {
+		p.fields = map[string]interface{}{}
+	}
+	p.fields[field] = value
+}
+
+// Set sets a field to the given value, using the phpIPAM API field name
+func (p *Patch) Set(field string, value interface{}) *Patch {
+	p.set(field, value)
+	return p
+}
+
+// Clear sets a field to null
+func (p *Patch) Clear(field string) *Patch {
+	p.set(field, nil)
+	return p
+}
+
+// Has reports whether the patch contains the given field
+func (p *Patch) Has(field string) bool {
+	_, ok := p.fields[field]
+	return ok
+}
+
+// Fields returns the names of all fields in the patch in sorted order
+func (p *Patch) Fields() []string {
+	fields := make([]string, 0, len(p.fields))
+	for field := range p.fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Len returns the number of fields in the patch
+func (p *Patch) Len() int {
+	return len(p.fields)
+}
+
+// MarshalJSON implements json.Marshaler, emitting only the fields that were set
+func (p Patch) MarshalJSON() ([]byte, error) {
+	if p.fields == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(p.fields)
+}
+
+// AddressPatch builds a partial update for an address
+type AddressPatch struct {
+	Patch
+}
+
+// NewAddressPatch creates an empty address patch
+func NewAddressPatch() *AddressPatch {
+	return &AddressPatch{}
+}
+
+// SetSubnetID moves the address to another subnet
+func (p *AddressPatch) SetSubnetID(id int) *AddressPatch {
+	p.set("subnetId", id)
+	return p
+}
+
+// SetIsGateway marks or unmarks the address as the subnet gateway
+func (p *AddressPatch) SetIsGateway(isGateway bool) *AddressPatch {
+	p.set("is_gateway", boolToInt(isGateway))
+	return p
+}
+
+// SetDescription sets the address description
+func (p *AddressPatch) SetDescription(description string) *AddressPatch {
+	p.set("description", description)
+	return p
+}
+
+// ClearDescription removes the address description
+func (p *AddressPatch) ClearDescription() *AddressPatch {
+	p.set("description", "")
+	return p
+}
+
+// SetHostname sets the address hostname
+func (p *AddressPatch) SetHostname(hostname string) *AddressPatch {
+	p.set("hostname", hostname)
+	return p
+}
+
+// ClearHostname removes the address hostname
+func (p *AddressPatch) ClearHostname() *AddressPatch {
+	p.set("hostname", "")
+	return p
+}
+
+// SetMac sets the address MAC
+func (p *AddressPatch) SetMac(mac string) *AddressPatch {
+	p.set("mac", mac)
+	return p
+}
+
+// ClearMac removes the address MAC
+func (p *AddressPatch) ClearMac() *AddressPatch {
+	p.set("mac", "")
+	return p
+}
+
+// SetOwner sets the address owner
+func (p *AddressPatch) SetOwner(owner string) *AddressPatch {
+	p.set("owner", owner)
+	return p
+}
+
+// ClearOwner removes the address owner
+func (p *AddressPatch) ClearOwner() *AddressPatch {
+	p.set("owner", "")
+	return p
+}
+
+// SetTag sets the address tag (state)
+func (p *AddressPatch) SetTag(tag int) *AddressPatch {
+	p.set("tag", tag)
+	return p
+}
+
+// SetPTRIgnore enables or disables PTR record management for the address
+func (p *AddressPatch) SetPTRIgnore(ignore bool) *AddressPatch {
+	p.set("PTRignore", boolToInt(ignore))
+	return p
+}
+
+// SetDeviceID assigns the address to a device
+func (p *AddressPatch) SetDeviceID(id int) *AddressPatch {
+	p.set("deviceId", id)
+	return p
+}
+
+// ClearDevice unassigns the address from its device
+func (p *AddressPatch) ClearDevice() *AddressPatch {
+	p.set("deviceId", nil)
+	return p
+}
+
+// SetPort sets the device port of the address
+func (p *AddressPatch) SetPort(port string) *AddressPatch {
+	p.set("port", port)
+	return p
+}
+
+// ClearPort removes the device port of the address
+func (p *AddressPatch) ClearPort() *AddressPatch {
+	p.set("port", "")
+	return p
+}
+
+// SetNote sets the address note
+func (p *AddressPatch) SetNote(note string) *AddressPatch {
+	p.set("note", note)
+	return p
+}
+
+// ClearNote removes the address note
+func (p *AddressPatch) ClearNote() *AddressPatch {
+	p.set("note", "")
+	return p
+}
+
+// SetLastSeen sets the last seen timestamp of the address
+func (p *AddressPatch) SetLastSeen(lastSeen string) *AddressPatch {
+	p.set("lastSeen", lastSeen)
+	return p
+}
+
+// SetExcludePing excludes or includes the address in ping checks
+func (p *AddressPatch) SetExcludePing(exclude bool) *AddressPatch {
+	p.set("excludePing", boolToInt(exclude))
+	return p
+}
+
+// SetCustomField sets a custom field; a nil value clears it
+func (p *AddressPatch) SetCustomField(name string, value interface{}) *AddressPatch {
+	p.set(name, value)
+	return p
+}
+
+// SubnetPatch builds a partial update for a subnet
+type SubnetPatch struct {
+	Patch
+}
+
+// NewSubnetPatch creates an empty subnet patch
+func NewSubnetPatch() *SubnetPatch {
+	return &SubnetPatch{}
+}
+
+// SetDescription sets the subnet description
+func (p *SubnetPatch) SetDescription(description string) *SubnetPatch {
+	p.set("description", description)
+	return p
+}
+
+// ClearDescription removes the subnet description
+func (p *SubnetPatch) ClearDescription() *SubnetPatch {
+	p.set("description", "")
+	return p
+}
+
+// SetVrfID assigns the subnet to a VRF
+func (p *SubnetPatch) SetVrfID(id int) *SubnetPatch {
+	p.set("vrfId", id)
+	return p
+}
+
+// ClearVrf removes the subnet from its VRF
+func (p *SubnetPatch) ClearVrf() *SubnetPatch {
+	p.set("vrfId", nil)
+	return p
+}
+
+// SetVlanID assigns the subnet to a VLAN
+func (p *SubnetPatch) SetVlanID(id int) *SubnetPatch {
+	p.set("vlanId", id)
+	return p
+}
+
+// ClearVlan removes the subnet from its VLAN
+func (p *SubnetPatch) ClearVlan() *SubnetPatch {
+	p.set("vlanId", nil)
+	return p
+}
+
+// SetMasterSubnetID moves the subnet under another master subnet; 0 makes it a root subnet
+func (p *SubnetPatch) SetMasterSubnetID(id int) *SubnetPatch {
+	p.set("masterSubnetId", id)
+	return p
+}
+
+// SetDeviceID assigns the subnet to a device
+func (p *SubnetPatch) SetDeviceID(id int) *SubnetPatch {
+	p.set("device", id)
+	return p
+}
+
+// ClearDevice unassigns the subnet from its device
+func (p *SubnetPatch) ClearDevice() *SubnetPatch {
+	p.set("device", nil)
+	return p
+}
+
+// SetLocationID assigns the subnet to a location
+func (p *SubnetPatch) SetLocationID(id int) *SubnetPatch {
+	p.set("location", id)
+	return p
+}
+
+// ClearLocation removes the subnet location
+func (p *SubnetPatch) ClearLocation() *SubnetPatch {
+	p.set("location", nil)
+	return p
+}
+
+// SetNameserverID sets the nameserver set used by the subnet; 0 removes it
+func (p *SubnetPatch) SetNameserverID(id int) *SubnetPatch {
+	p.set("nameserverId", id)
+	return p
+}
+
+// SetShowName enables or disables showing the subnet name instead of the address
+func (p *SubnetPatch) SetShowName(show bool) *SubnetPatch {
+	p.set("showName", boolToInt(show))
+	return p
+}
+
+// SetAllowRequests enables or disables IP requests for the subnet
+func (p *SubnetPatch) SetAllowRequests(allow bool) *SubnetPatch {
+	p.set("allowRequests", boolToInt(allow))
+	return p
+}
+
+// SetPingSubnet enables or disables ping status checks for the subnet
+func (p *SubnetPatch) SetPingSubnet(ping bool) *SubnetPatch {
+	p.set("pingSubnet", boolToInt(ping))
+	return p
+}
+
+// SetDiscoverSubnet enables or disables discovery of new hosts in the subnet
+func (p *SubnetPatch) SetDiscoverSubnet(discover bool) *SubnetPatch {
+	p.set("discoverSubnet", boolToInt(discover))
+	return p
+}
+
+// SetResolveDNS enables or disables DNS resolving for the subnet
+func (p *SubnetPatch) SetResolveDNS(resolve bool) *SubnetPatch {
+	p.set("resolveDNS", boolToInt(resolve))
+	return p
+}
+
+// SetIsFull marks or unmarks the subnet as full
+func (p *SubnetPatch) SetIsFull(full bool) *SubnetPatch {
+	p.set("isFull", boolToInt(full))
+	return p
+}
+
+// SetIsPool marks or unmarks the subnet as an address pool
+func (p *SubnetPatch) SetIsPool(pool bool) *SubnetPatch {
+	p.set("isPool", boolToInt(pool))
+	return p
+}
+
+// SetTag sets the subnet tag (state)
+func (p *SubnetPatch) SetTag(tag int) *SubnetPatch {
+	p.set("tag", tag)
+	return p
+}
+
+// SetThreshold sets the usage alert threshold in percent; 0 disables it
+func (p *SubnetPatch) SetThreshold(threshold int) *SubnetPatch {
+	p.set("threshold", threshold)
+	return p
+}
+
+// SetScanAgent sets the scan agent used for the subnet; 0 removes it
+func (p *SubnetPatch) SetScanAgent(id int) *SubnetPatch {
+	p.set("scanAgent", id)
+	return p
+}
+
+// SetCustomField sets a custom field; a nil value clears it
+func (p *SubnetPatch) SetCustomField(name string, value interface{}) *SubnetPatch {
+	p.set(name, value)
+	return p
+}
+
+// Helper function to convert bool to int 1 or 0
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
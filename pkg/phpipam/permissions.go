@@ -0,0 +1,265 @@
+package phpipam
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PermissionLevel represents the access level a user group has on an object
+type PermissionLevel int
+
+const (
+	// PermissionNA denies access
+	PermissionNA PermissionLevel = 0
+	// PermissionRead grants read-only access
+	PermissionRead PermissionLevel = 1
+	// PermissionWrite grants read/write access
+	PermissionWrite PermissionLevel = 2
+	// PermissionAdmin grants read/write/admin access
+	PermissionAdmin PermissionLevel = 3
+)
+
+// String returns the short name phpIPAM uses for the level (na, ro, rw, rwa)
+func (l PermissionLevel) String() string {
+	switch l {
+	case PermissionNA:
+		return "na"
+	case PermissionRead:
+		return "ro"
+	case PermissionWrite:
+		return "rw"
+	case PermissionAdmin:
+		return "rwa"
+	}
+	return strconv.Itoa(int(l))
+}
+
+// ParsePermissionLevel parses a permission level from its numeric value (0-3)
+// or its name (na, ro, rw, rwa)
+func ParsePermissionLevel(s string) (PermissionLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "0", "na":
+		return PermissionNA, nil
+	case "1", "ro", "read":
+		return PermissionRead, nil
+	case "2", "rw", "write":
+		return PermissionWrite, nil
+	case "3", "rwa", "admin":
+		return PermissionAdmin, nil
+	}
+	return PermissionNA, fmt.Errorf("invalid permission level %q", s)
+}
+
+// Permissions maps user group IDs to permission levels.
+//
+// Sections and subnets store permissions as a JSON-encoded string such as
+// "{\"2\":\"2\",\"3\":\"1\"}". Permissions decodes that form as well as a plain
+// JSON object, and encodes back into the string form phpIPAM expects.
+type Permissions map[int]PermissionLevel
+
+// ParsePermissions parses permissions in the form stored by phpIPAM
+func ParsePermissions(s string) (Permissions, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "null" {
+		return Permissions{}, nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse permissions: %w", err)
+	}
+
+	permissions := make(Permissions, len(raw))
+	for group, value := range raw {
+		groupID, err := strconv.Atoi(group)
+		if err != nil {
+			return nil, fmt.Errorf("invalid permission group ID %q", group)
+		}
+
+		// Levels are usually strings, but accept plain numbers as well
+		levelStr := string(value)
+		var str string
+		if err := json.Unmarshal(value, &str); err == nil {
+			levelStr = str
+		}
+
+		level, err := ParsePermissionLevel(levelStr)
+		if err != nil {
+			return nil, err
+		}
+		permissions[groupID] = level
+	}
+
+	return permissions, nil
+}
+
+// Groups returns the group IDs in the permissions in ascending order
+func (p Permissions) Groups() []int {
+	groups := make([]int, 0, len(p))
+	for group := range p {
+		groups = append(groups, group)
+	}
+	sort.Ints(groups)
+	return groups
+}
+
+// String returns the permissions in the form stored by phpIPAM, with groups in ascending order
+func (p Permissions) String() string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, group := range p.Groups() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, "\"%d\":\"%d\"", group, int(p[group]))
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// MarshalJSON implements json.Marshaler, encoding the permissions as a JSON string
+func (p Permissions) MarshalJSON() ([]byte, error) {
+	if p == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting a JSON-encoded string,
+// a plain object or null
+func (p *Permissions) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		// Not a string, so treat it as the object itself (or null)
+		str = string(data)
+	}
+
+	permissions, err := ParsePermissions(str)
+	if err != nil {
+		return err
+	}
+	*p = permissions
+	return nil
+}
+
+// levels returns the permissions in the form accepted by the subnet permissions endpoint
+func (p Permissions) levels() map[string]string {
+	levels := make(map[string]string, len(p))
+	for group, level := range p {
+		levels[strconv.Itoa(group)] = level.String()
+	}
+	return levels
+}
+
+// GroupPermissions maps user group names to permission levels
+type GroupPermissions map[string]PermissionLevel
+
+// Resolve converts group names to IDs using the given name to ID mapping.
+// Group names are matched case-insensitively.
+//
+// The phpIPAM API has no endpoint that lists user groups, so the mapping has
+// to come from the caller: the group IDs are shown under Administration >
+// Groups in the web UI. Subnet permissions can be set by name without a
+// mapping, see SubnetsService.SetGroupPermissions.
+func (g GroupPermissions) Resolve(groups map[string]int) (Permissions, error) {
+	byName := make(map[string]int, len(groups))
+	for name, id := range groups {
+		byName[strings.ToLower(name)] = id
+	}
+
+	permissions := make(Permissions, len(g))
+	for name, level := range g {
+		id, ok := byName[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown user group %q", name)
+		}
+		permissions[id] = level
+	}
+
+	return permissions, nil
+}
+
+// SectionIDs is a list of section IDs, stored by phpIPAM as a semicolon-separated string.
+//
+// L2 domains and nameservers use their permissions field for this list: it
+// restricts the sections the object is available in rather than granting
+// access to user groups.
+type SectionIDs []int
+
+// ParseSectionIDs parses a semicolon-separated list of section IDs
+func ParseSectionIDs(s string) (SectionIDs, error) {
+	var ids SectionIDs
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid section ID %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// String returns the section IDs in the semicolon-separated form stored by phpIPAM
+func (s SectionIDs) String() string {
+	parts := make([]string, len(s))
+	for i, id := range s {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ";")
+}
+
+// Contains reports whether the list contains the given section ID
+func (s SectionIDs) Contains(id int) bool {
+	for _, sectionID := range s {
+		if sectionID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON implements json.Marshaler, encoding the list as a semicolon-separated string
+func (s SectionIDs) MarshalJSON() ([]byte, error) {
+	if s == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting a semicolon-separated
+// string, an array of IDs or null
+func (s *SectionIDs) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		ids, err := ParseSectionIDs(str)
+		if err != nil {
+			return err
+		}
+		*s = ids
+		return nil
+	}
+
+	var ids []ResponseID
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return fmt.Errorf("failed to parse section IDs: %w", err)
+	}
+	if ids == nil {
+		*s = nil
+		return nil
+	}
+
+	list := make(SectionIDs, len(ids))
+	for i, id := range ids {
+		list[i] = id.Int()
+	}
+	*s = list
+	return nil
+}
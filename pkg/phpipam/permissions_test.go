@@ -0,0 +1,127 @@
+package phpipam
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePermissions(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Permissions
+	}{
+		{`{"2":"2","3":"1"}`, Permissions{2: PermissionWrite, 3: PermissionRead}},
+		{`{"2":3,"4":0}`, Permissions{2: PermissionAdmin, 4: PermissionNA}},
+		{`{"2":"rw","3":"ro","5":"rwa"}`, Permissions{2: PermissionWrite, 3: PermissionRead, 5: PermissionAdmin}},
+		{``, Permissions{}},
+		{`null`, Permissions{}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePermissions(tt.input)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePermissions(%q) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []string{`{"admins":"2"}`, `{"2":"9"}`, `{"2":`} {
+		if _, err := ParsePermissions(input); err == nil {
+			t.Errorf("ParsePermissions(%q) succeeded", input)
+		}
+	}
+}
+
+func TestPermissionsJSON(t *testing.T) {
+	type object struct {
+		Permissions Permissions `json:"permissions,omitempty"`
+	}
+
+	// phpIPAM returns permissions as a JSON-encoded string
+	var section object
+	if err := json.Unmarshal([]byte(`{"permissions":"{\"3\":\"1\",\"2\":\"2\"}"}`), &section); err != nil {
+		t.Fatal(err)
+	}
+	want := Permissions{2: PermissionWrite, 3: PermissionRead}
+	if !reflect.DeepEqual(section.Permissions, want) {
+		t.Errorf("decoded string form = %v, want %v", section.Permissions, want)
+	}
+
+	data, err := json.Marshal(section)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); got != `{"permissions":"{\"2\":\"2\",\"3\":\"1\"}"}` {
+		t.Errorf("encoded = %s", got)
+	}
+
+	var plain object
+	if err := json.Unmarshal([]byte(`{"permissions":{"2":"2","3":"1"}}`), &plain); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plain.Permissions, want) {
+		t.Errorf("decoded object form = %v, want %v", plain.Permissions, want)
+	}
+
+	if data, _ := json.Marshal(object{}); string(data) != `{}` {
+		t.Errorf("encoded empty permissions = %s, want {}", data)
+	}
+}
+
+func TestGroupPermissionsResolve(t *testing.T) {
+	groups := map[string]int{"Operators": 3, "Guests": 4}
+	got, err := GroupPermissions{"operators": PermissionWrite, "GUESTS": PermissionRead}.Resolve(groups)
+	want := Permissions{3: PermissionWrite, 4: PermissionRead}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve = %v, %v, want %v", got, err, want)
+	}
+
+	_, err = GroupPermissions{"Admins": PermissionAdmin}.Resolve(groups)
+	if err == nil || !strings.Contains(err.Error(), `unknown user group "Admins"`) {
+		t.Errorf("Resolve with an unknown group error = %v", err)
+	}
+}
+
+func TestSectionIDs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  SectionIDs
+		str   string
+	}{
+		{"1;2", SectionIDs{1, 2}, "1;2"},
+		{" 3 ; 1 ;", SectionIDs{3, 1}, "3;1"},
+		{"", nil, ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseSectionIDs(tt.input)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSectionIDs(%q) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+		if got.String() != tt.str {
+			t.Errorf("ParseSectionIDs(%q).String() = %q, want %q", tt.input, got.String(), tt.str)
+		}
+	}
+	if _, err := ParseSectionIDs("1;x"); err == nil {
+		t.Error(`ParseSectionIDs("1;x") succeeded`)
+	}
+	if !(SectionIDs{1, 2}).Contains(2) || (SectionIDs{1, 2}).Contains(3) {
+		t.Error("Contains does not match the list")
+	}
+
+	type domain struct {
+		Permissions SectionIDs `json:"permissions,omitempty"`
+	}
+	for _, input := range []string{`{"permissions":"1;2"}`, `{"permissions":[1,"2"]}`} {
+		var d domain
+		if err := json.Unmarshal([]byte(input), &d); err != nil || !reflect.DeepEqual(d.Permissions, SectionIDs{1, 2}) {
+			t.Errorf("decoding %s = %v, %v", input, d.Permissions, err)
+		}
+		if data, _ := json.Marshal(d); string(data) != `{"permissions":"1;2"}` {
+			t.Errorf("encoding %s = %s", input, data)
+		}
+	}
+	var d domain
+	if err := json.Unmarshal([]byte(`{"permissions":null}`), &d); err != nil || d.Permissions != nil {
+		t.Errorf("decoding null = %v, %v", d.Permissions, err)
+	}
+}
@@ -0,0 +1,188 @@
+package phpipam
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// SubnetRequest describes a child subnet to carve out of a parent subnet
+type SubnetRequest struct {
+	// Name identifies the request in the plan and becomes the subnet
+	// description unless Template sets one
+	Name string
+	// Mask is the prefix length of the child subnet
+	Mask int
+	// Template holds additional fields for the created subnet; address, mask,
+	// section and master subnet are set by the planner
+	Template Subnet
+}
+
+// PlannedSubnet is a request placed at a concrete prefix
+type PlannedSubnet struct {
+	Request SubnetRequest
+	Prefix  netip.Prefix
+}
+
+// SubnetPlan is a non-overlapping layout of child subnets inside a parent
+type SubnetPlan struct {
+	Parent Subnet
+	// Existing are the child subnets already present in the parent
+	Existing []netip.Prefix
+	// Subnets are the planned child subnets in address order
+	Subnets []PlannedSubnet
+}
+
+// PlanSubnets lays out the requested child subnets inside parent without
+// overlapping its existing children (SubnetsService.GetSlaves).
+//
+// Requests are placed largest first, each into the smallest free aligned block
+// that can hold it (lowest address on ties). With power-of-two sized blocks this
+// keeps the remaining free space as large and unfragmented as possible. If any
+// request cannot be placed, an error wrapping ErrInsufficientSpace is returned.
+func PlanSubnets(parent *Subnet, existing []Subnet, requests []SubnetRequest) (*SubnetPlan, error) {
+	parentPrefix, err := parent.Prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &SubnetPlan{Parent: *parent}
+	for i := range existing {
+		if existing[i].IsFolder == 1 {
+			continue
+		}
+		child, err := existing[i].Prefix()
+		if err != nil {
+			return nil, fmt.Errorf("child subnet %d: %w", existing[i].ID, err)
+		}
+		if ipcalc.Contains(parentPrefix, child) {
+			plan.Existing = append(plan.Existing, child)
+		}
+	}
+
+	for _, req := range requests {
+		if req.Mask <= parentPrefix.Bits() || req.Mask > parentPrefix.Addr().BitLen() {
+			return nil, fmt.Errorf("request %q: mask /%d does not fit inside %s", req.Name, req.Mask, parentPrefix)
+		}
+	}
+
+	// Place the largest requests first, keeping input order for equal sizes
+	order := make([]int, len(requests))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return requests[order[i]].Mask < requests[order[j]].Mask })
+
+	free := ipcalc.FreeBlocks(parentPrefix, plan.Existing)
+	var unplaced []string
+	for _, i := range order {
+		req := requests[i]
+
+		// Best fit: the smallest free block that can hold the request
+		best := -1
+		for j, block := range free {
+			if block.Bits() > req.Mask {
+				continue
+			}
+			if best == -1 || block.Bits() > free[best].Bits() {
+				best = j
+			}
+		}
+		if best == -1 {
+			unplaced = append(unplaced, fmt.Sprintf("%s (/%d)", req.Name, req.Mask))
+			continue
+		}
+
+		// Split the block down to the requested size, keeping the upper halves free
+		block := free[best]
+		free = append(free[:best], free[best+1:]...)
+		for block.Bits() < req.Mask {
+			low, high, _ := ipcalc.Split(block)
+			free = append(free, high)
+			block = low
+		}
+		sort.Slice(free, func(a, b int) bool { return free[a].Addr().Less(free[b].Addr()) })
+
+		plan.Subnets = append(plan.Subnets, PlannedSubnet{Request: req, Prefix: block})
+	}
+
+	if len(unplaced) > 0 {
+		return nil, fmt.Errorf("%w in %s for %s", ErrInsufficientSpace, parentPrefix, strings.Join(unplaced, ", "))
+	}
+
+	sort.Slice(plan.Subnets, func(i, j int) bool {
+		return plan.Subnets[i].Prefix.Addr().Less(plan.Subnets[j].Prefix.Addr())
+	})
+	return plan, nil
+}
+
+// String renders the plan as a table of prefixes and request names
+func (p *SubnetPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Plan for %s (%d existing children)\n", p.Parent.CIDR(), len(p.Existing))
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tNAME\tADDRESSES")
+	for _, planned := range p.Subnets {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", planned.Prefix, planned.Request.Name, ipcalc.Size(planned.Prefix))
+	}
+	w.Flush()
+
+	return b.String()
+}
+
+// subnet returns the subnet object to create for a planned subnet
+func (p *SubnetPlan) subnet(planned PlannedSubnet) *Subnet {
+	subnet := planned.Request.Template
+	subnet.ID = 0
+	subnet.Subnet = planned.Prefix.Addr().String()
+	subnet.Mask = fmt.Sprintf("%d", planned.Prefix.Bits())
+	subnet.SectionID = p.Parent.SectionID
+	subnet.MasterSubnetID = p.Parent.ID
+	if subnet.Description == "" {
+		subnet.Description = planned.Request.Name
+	}
+	if subnet.VrfID == nil {
+		subnet.VrfID = p.Parent.VrfID
+	}
+	return &subnet
+}
+
+// PlanSubnets fetches a parent subnet and its children and plans the requested
+// child subnets, see PlanSubnets
+func (s *SubnetsService) PlanSubnets(parentID int, requests []SubnetRequest) (*SubnetPlan, error) {
+	parent, err := s.Get(parentID)
+	if err != nil {
+		return nil, err
+	}
+	slaves, err := s.GetSlaves(parentID)
+	if err != nil {
+		return nil, err
+	}
+	return PlanSubnets(parent, slaves, requests)
+}
+
+// ApplyPlan creates the planned subnets in address order. If a subnet cannot be
+// created, the subnets created so far are deleted again and the error is returned.
+func (s *SubnetsService) ApplyPlan(plan *SubnetPlan) ([]Subnet, error) {
+	var created []Subnet
+	for _, planned := range plan.Subnets {
+		subnet, err := s.Create(plan.subnet(planned))
+		if err != nil {
+			errs := []error{fmt.Errorf("failed to create %s (%s): %w", planned.Prefix, planned.Request.Name, err)}
+			for i := len(created) - 1; i >= 0; i-- {
+				if err := s.Delete(created[i].ID); err != nil {
+					errs = append(errs, fmt.Errorf("rollback of subnet %s failed: %w", created[i].CIDR(), err))
+				}
+			}
+			return nil, errors.Join(errs...)
+		}
+		created = append(created, *subnet)
+	}
+	return created, nil
+}
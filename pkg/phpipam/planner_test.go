@@ -0,0 +1,148 @@
+package phpipam
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPlanSubnets(t *testing.T) {
+	tests := []struct {
+		name     string
+		parent   string
+		existing []string
+		// requests are name/mask pairs
+		requests []string
+		// want lists the planned subnets in address order as name=prefix
+		want string
+		// wantErr is a substring of the expected error
+		wantErr string
+	}{
+		{
+			name:     "largest first into the remaining space",
+			parent:   "10.0.0.0/24",
+			existing: []string{"10.0.0.0/26"},
+			requests: []string{"a/27", "b/25", "d/27"},
+			want:     "a=10.0.0.64/27 d=10.0.0.96/27 b=10.0.0.128/25",
+		},
+		{
+			name:     "best fit keeps the larger block free",
+			parent:   "10.0.0.0/24",
+			existing: []string{"10.0.0.0/25", "10.0.0.192/27", "10.0.0.224/28"},
+			requests: []string{"small/28", "medium/26"},
+			want:     "medium=10.0.0.128/26 small=10.0.0.240/28",
+		},
+		{
+			name:     "equal sizes keep input order",
+			parent:   "10.0.0.0/24",
+			requests: []string{"first/26", "second/26", "third/26"},
+			want:     "first=10.0.0.0/26 second=10.0.0.64/26 third=10.0.0.128/26",
+		},
+		{
+			name:     "children outside the parent and folders are ignored",
+			parent:   "10.0.0.0/24",
+			existing: []string{"10.0.1.0/24", "folder"},
+			requests: []string{"all/25", "rest/25"},
+			want:     "all=10.0.0.0/25 rest=10.0.0.128/25",
+		},
+		{
+			name:     "ipv6",
+			parent:   "2001:db8::/48",
+			existing: []string{"2001:db8::/56"},
+			requests: []string{"lan1/64", "site/56", "lan2/64"},
+			want:     "site=2001:db8:0:100::/56 lan1=2001:db8:0:200::/64 lan2=2001:db8:0:201::/64",
+		},
+		{
+			name:     "exhausted",
+			parent:   "10.0.0.0/24",
+			existing: []string{"10.0.0.0/26"},
+			requests: []string{"a/27", "b/25", "c/28", "d/27"},
+			wantErr:  "in 10.0.0.0/24 for c (/28)",
+		},
+		{
+			name:     "mask not inside the parent",
+			parent:   "10.0.0.0/24",
+			requests: []string{"whole/24"},
+			wantErr:  `request "whole": mask /24 does not fit inside 10.0.0.0/24`,
+		},
+		{
+			name:     "mask longer than the address",
+			parent:   "10.0.0.0/24",
+			requests: []string{"host/33"},
+			wantErr:  "mask /33 does not fit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := testSubnet(7, tt.parent)
+			var existing []Subnet
+			for i, prefix := range tt.existing {
+				if prefix == "folder" {
+					existing = append(existing, Subnet{ID: 100 + i, IsFolder: 1})
+					continue
+				}
+				existing = append(existing, testSubnet(100+i, prefix))
+			}
+			var requests []SubnetRequest
+			for _, r := range tt.requests {
+				name, mask, _ := strings.Cut(r, "/")
+				req := SubnetRequest{Name: name}
+				fmt.Sscan(mask, &req.Mask)
+				requests = append(requests, req)
+			}
+
+			plan, err := PlanSubnets(&parent, existing, requests)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if strings.Contains(tt.name, "exhausted") && !errors.Is(err, ErrInsufficientSpace) {
+					t.Errorf("err = %v, want ErrInsufficientSpace", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, planned := range plan.Subnets {
+				got = append(got, planned.Request.Name+"="+planned.Prefix.String())
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("plan = %s, want %s", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestSubnetPlanSubnet(t *testing.T) {
+	parent := testSubnet(7, "10.0.0.0/24")
+	parent.SectionID = 3
+	requests := []SubnetRequest{
+		{Name: "servers", Mask: 26},
+		{Name: "clients", Mask: 26, Template: Subnet{Description: "Client LAN", ID: 99}},
+	}
+
+	plan, err := PlanSubnets(&parent, nil, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servers := plan.subnet(plan.Subnets[0])
+	if servers.Subnet != "10.0.0.0" || servers.Mask != "26" || servers.SectionID != 3 || servers.MasterSubnetID != 7 || servers.Description != "servers" {
+		t.Errorf("servers = %+v", servers)
+	}
+	clients := plan.subnet(plan.Subnets[1])
+	if clients.ID != 0 || clients.Subnet != "10.0.0.64" || clients.Description != "Client LAN" {
+		t.Errorf("clients = %+v", clients)
+	}
+}
+
+// testSubnet returns a subnet of section 1 for a prefix such as "10.0.0.0/24"
+func testSubnet(id int, prefix string) Subnet {
+	address, mask, _ := strings.Cut(prefix, "/")
+	return Subnet{ID: id, Subnet: address, Mask: mask, SectionID: 1}
+}
@@ -0,0 +1,390 @@
+package reconcile
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/inventory"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// applier executes changes and keeps the state up to date with created objects
+type applier struct {
+	api   *phpipam.PHPIPAM
+	state *state
+}
+
+// apply executes a single change
+func (a *applier) apply(change *Change) error {
+	if change.Action == ActionDelete {
+		return a.delete(change)
+	}
+
+	switch desired := change.desired.(type) {
+	case *inventory.Section:
+		return a.applySection(change, desired)
+	case *inventory.VRF:
+		return a.applyVRF(change, desired)
+	case *inventory.L2Domain:
+		return a.applyL2Domain(change, desired)
+	case *inventory.VLAN:
+		return a.applyVLAN(change, desired)
+	case *inventory.Subnet:
+		return a.applySubnet(change, desired)
+	case *inventory.Address:
+		return a.applyAddress(change, desired)
+	}
+	return fmt.Errorf("unsupported change for %s", change.Kind)
+}
+
+// delete removes an object
+func (a *applier) delete(change *Change) error {
+	switch current := change.current.(type) {
+	case *phpipam.Subnet:
+		return a.api.Subnets.Delete(current.ID)
+	case *phpipam.Address:
+		return a.api.Addresses.Delete(current.ID)
+	case *phpipam.VLAN:
+		return a.api.VLANs.Delete(current.ID)
+	}
+	return fmt.Errorf("unsupported delete for %s", change.Kind)
+}
+
+// sectionID resolves a section name to its ID
+func (a *applier) sectionID(name string) (int, error) {
+	section, ok := a.state.sections[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown section %q", name)
+	}
+	return strconv.Atoi(section.ID)
+}
+
+// sectionIDs resolves section names to IDs
+func (a *applier) sectionIDs(names []string) (phpipam.SectionIDs, error) {
+	ids := make(phpipam.SectionIDs, 0, len(names))
+	for _, name := range names {
+		id, err := a.sectionID(name)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// vrfID resolves a VRF name to its ID, 0 for none
+func (a *applier) vrfID(name string) (int, error) {
+	if name == "" {
+		return 0, nil
+	}
+	vrf, ok := a.state.vrfs[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown VRF %q", name)
+	}
+	return strconv.Atoi(vrf.ID)
+}
+
+// vlanID resolves a VLAN reference to its ID, 0 for none
+func (a *applier) vlanID(ref *inventory.VLANRef) (int, error) {
+	if ref == nil {
+		return 0, nil
+	}
+	vlan, ok := a.state.vlans[ref.Key()]
+	if !ok {
+		return 0, fmt.Errorf("unknown VLAN %s", ref.Key())
+	}
+	return strconv.Atoi(vlan.ID)
+}
+
+// containingSubnet returns the most specific existing subnet in a section and VRF
+// that contains prefix and is not prefix itself
+func (a *applier) containingSubnet(section, vrf string, prefix netip.Prefix) *currentSubnet {
+	var best *currentSubnet
+	for _, current := range a.state.subnets {
+		if current.section != section || current.vrf != vrf ||
+			current.prefix.Bits() >= prefix.Bits() || !ipcalc.Contains(current.prefix, prefix) {
+			continue
+		}
+		if best == nil || current.prefix.Bits() > best.prefix.Bits() {
+			best = current
+		}
+	}
+	return best
+}
+
+// subnetForAddress returns the most specific existing subnet in a section and
+// VRF that contains the address
+func (a *applier) subnetForAddress(section, vrf string, addr netip.Addr) *currentSubnet {
+	var best *currentSubnet
+	for _, current := range a.state.subnets {
+		if current.section != section || current.vrf != vrf || !current.prefix.Contains(addr) {
+			continue
+		}
+		if best == nil || current.prefix.Bits() > best.prefix.Bits() {
+			best = current
+		}
+	}
+	return best
+}
+
+// applySection creates or updates a section
+func (a *applier) applySection(change *Change, desired *inventory.Section) error {
+	parentID := 0
+	if desired.Parent != "" {
+		id, err := a.sectionID(desired.Parent)
+		if err != nil {
+			return err
+		}
+		parentID = id
+	}
+
+	if change.Action == ActionCreate {
+		created, err := a.api.Sections.Create(&phpipam.Section{
+			Name:          desired.Name,
+			Description:   desired.Description,
+			MasterSection: parentID,
+			StrictMode:    boolInt(desired.StrictMode),
+			ShowVLAN:      boolInt(desired.ShowVLAN),
+			ShowVRF:       boolInt(desired.ShowVRF),
+		})
+		if err != nil {
+			return err
+		}
+		a.state.addSection(created)
+		return nil
+	}
+
+	current := change.current.(*phpipam.Section)
+	patch := phpipam.NewPatch()
+	setDiffs(patch, change, map[string]interface{}{
+		"description":   desired.Description,
+		"masterSection": parentID,
+		"strictMode":    boolInt(desired.StrictMode),
+		"showVLAN":      boolInt(desired.ShowVLAN),
+		"showVRF":       boolInt(desired.ShowVRF),
+	})
+	return a.api.Sections.Patch(current.ID, patch)
+}
+
+// applyVRF creates or updates a VRF
+func (a *applier) applyVRF(change *Change, desired *inventory.VRF) error {
+	sections, err := a.sectionIDs(desired.Sections)
+	if err != nil {
+		return err
+	}
+
+	if change.Action == ActionCreate {
+		created, err := a.api.VRFs.Create(&phpipam.VRF{
+			Name:        desired.Name,
+			RD:          desired.RD,
+			Description: desired.Description,
+			Sections:    sections.String(),
+		})
+		if err != nil {
+			return err
+		}
+		a.state.addVRF(created)
+		return nil
+	}
+
+	current := change.current.(*phpipam.VRF)
+	patch := phpipam.NewPatch()
+	setDiffs(patch, change, map[string]interface{}{
+		"rd":          desired.RD,
+		"description": desired.Description,
+		"sections":    sections.String(),
+	})
+	return a.api.VRFs.Patch(current.ID, patch)
+}
+
+// applyL2Domain creates or updates an L2 domain
+func (a *applier) applyL2Domain(change *Change, desired *inventory.L2Domain) error {
+	sections, err := a.sectionIDs(desired.Sections)
+	if err != nil {
+		return err
+	}
+
+	if change.Action == ActionCreate {
+		created, err := a.api.L2Domains.Create(&phpipam.L2Domain{
+			Name:        desired.Name,
+			Description: desired.Description,
+			Permissions: sections,
+		})
+		if err != nil {
+			return err
+		}
+		a.state.addDomain(created)
+		return nil
+	}
+
+	current := change.current.(*phpipam.L2Domain)
+	patch := phpipam.NewPatch()
+	setDiffs(patch, change, map[string]interface{}{
+		"description": desired.Description,
+		"permissions": sections.String(),
+	})
+	return a.api.L2Domains.Patch(current.ID, patch)
+}
+
+// applyVLAN creates or updates a VLAN
+func (a *applier) applyVLAN(change *Change, desired *inventory.VLAN) error {
+	if change.Action == ActionCreate {
+		domain, ok := a.state.domains[desired.Domain]
+		if !ok {
+			return fmt.Errorf("unknown L2 domain %q", desired.Domain)
+		}
+		created, err := a.api.VLANs.Create(&phpipam.VLAN{
+			DomainID:    domain.ID,
+			Number:      strconv.Itoa(desired.Number),
+			Name:        desired.Name,
+			Description: desired.Description,
+		})
+		if err != nil {
+			return err
+		}
+		return a.state.addVLAN(created)
+	}
+
+	current := change.current.(*phpipam.VLAN)
+	patch := phpipam.NewPatch()
+	setDiffs(patch, change, map[string]interface{}{
+		"name":        desired.Name,
+		"description": desired.Description,
+	})
+	return a.api.VLANs.Patch(current.ID, patch)
+}
+
+// applySubnet creates or updates a subnet
+func (a *applier) applySubnet(change *Change, desired *inventory.Subnet) error {
+	prefix, err := desired.Prefix()
+	if err != nil {
+		return err
+	}
+	sectionID, err := a.sectionID(desired.Section)
+	if err != nil {
+		return err
+	}
+	vrfID, err := a.vrfID(desired.VRF)
+	if err != nil {
+		return err
+	}
+	vlanID, err := a.vlanID(desired.VLAN)
+	if err != nil {
+		return err
+	}
+	masterID := 0
+	if parent := a.containingSubnet(desired.Section, desired.VRF, prefix); parent != nil {
+		masterID = parent.subnet.ID
+	}
+
+	if change.Action == ActionCreate {
+		subnet := &phpipam.Subnet{
+			Subnet:         prefix.Addr().String(),
+			Mask:           strconv.Itoa(prefix.Bits()),
+			SectionID:      sectionID,
+			Description:    desired.Description,
+			MasterSubnetID: masterID,
+			IsPool:         boolInt(desired.IsPool),
+			ShowName:       boolInt(desired.ShowName),
+		}
+		if vrfID != 0 {
+			subnet.VrfID = vrfID
+		}
+		if vlanID != 0 {
+			subnet.VlanID = vlanID
+		}
+		created, err := a.api.Subnets.Create(subnet)
+		if err != nil {
+			return err
+		}
+		if created.ID == 0 {
+			return fmt.Errorf("phpIPAM did not return the ID of the created subnet")
+		}
+		// Keep the natural key even if the response omits fields
+		created.Subnet, created.Mask, created.SectionID = subnet.Subnet, subnet.Mask, sectionID
+		a.state.putSubnet(change.Key, &currentSubnet{subnet: created, section: desired.Section, vrf: desired.VRF, prefix: prefix})
+		return nil
+	}
+
+	current := change.current.(*phpipam.Subnet)
+	var vlanValue interface{}
+	if vlanID != 0 {
+		vlanValue = vlanID
+	}
+	patch := phpipam.NewSubnetPatch()
+	setDiffs(&patch.Patch, change, map[string]interface{}{
+		"description":    desired.Description,
+		"vlanId":         vlanValue,
+		"masterSubnetId": masterID,
+		"isPool":         boolInt(desired.IsPool),
+		"showName":       boolInt(desired.ShowName),
+	})
+	return a.api.Subnets.Patch(current.ID, patch)
+}
+
+// applyAddress creates or updates an address
+func (a *applier) applyAddress(change *Change, desired *inventory.Address) error {
+	addr, err := desired.Addr()
+	if err != nil {
+		return err
+	}
+	tag := 0
+	if desired.Tag != "" {
+		resolved, err := a.api.Addresses.ResolveTag(desired.Tag)
+		if err != nil {
+			return err
+		}
+		tag = resolved.ID
+	}
+
+	if change.Action == ActionCreate {
+		subnet := a.subnetForAddress(desired.Section, desired.VRF, addr)
+		if subnet == nil {
+			return fmt.Errorf("no subnet contains %s", addr)
+		}
+		_, err := a.api.Addresses.Create(&phpipam.Address{
+			SubnetID:    subnet.subnet.ID,
+			IP:          addr.String(),
+			Hostname:    desired.Hostname,
+			Description: desired.Description,
+			Mac:         desired.MAC,
+			Owner:       desired.Owner,
+			Tag:         tag,
+			IsGateway:   boolInt(desired.Gateway),
+			Note:        desired.Note,
+		})
+		return err
+	}
+
+	current := change.current.(*phpipam.Address)
+	patch := phpipam.NewAddressPatch()
+	setDiffs(&patch.Patch, change, map[string]interface{}{
+		"hostname":    desired.Hostname,
+		"description": desired.Description,
+		"mac":         desired.MAC,
+		"owner":       desired.Owner,
+		"is_gateway":  boolInt(desired.Gateway),
+		"note":        desired.Note,
+		"tag":         tag,
+	})
+	return a.api.Addresses.Patch(current.ID, patch)
+}
+
+// setDiffs copies the values of the changed fields into a patch
+func setDiffs(patch *phpipam.Patch, change *Change, values map[string]interface{}) {
+	for _, diff := range change.Diffs {
+		if value, ok := values[diff.Field]; ok {
+			patch.Set(diff.Field, value)
+		}
+	}
+}
+
+// boolInt converts a flag to phpIPAM's 0/1 representation
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
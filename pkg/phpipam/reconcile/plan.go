@@ -0,0 +1,602 @@
+package reconcile
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/inventory"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// field is a compared field with display values
+type field struct {
+	name    string
+	current string
+	desired string
+}
+
+// diffFields returns the fields whose values differ
+func diffFields(fields []field) []FieldDiff {
+	var diffs []FieldDiff
+	for _, f := range fields {
+		if f.current != f.desired {
+			diffs = append(diffs, FieldDiff{Field: f.name, Old: f.current, New: f.desired})
+		}
+	}
+	return diffs
+}
+
+// createFields lists the non-empty fields of a new object
+func createFields(fields []field) []FieldDiff {
+	var diffs []FieldDiff
+	for _, f := range fields {
+		if f.desired != "" && f.desired != "false" {
+			diffs = append(diffs, FieldDiff{Field: f.name, New: f.desired})
+		}
+	}
+	return diffs
+}
+
+// planner computes the changes between a document and the current state
+type planner struct {
+	api   *phpipam.PHPIPAM
+	doc   *inventory.Document
+	state *state
+	prune bool
+
+	changes []Change
+	// subnets are the prefixes of all declared and existing subnets, used to
+	// find parents of subnets and subnets of addresses
+	subnets map[string]subnetRef
+}
+
+// subnetRef is a known subnet, declared or existing
+type subnetRef struct {
+	key     string
+	section string
+	vrf     string
+	prefix  netip.Prefix
+}
+
+// plan computes all changes in apply order
+func (p *planner) plan() ([]Change, error) {
+	p.subnets = make(map[string]subnetRef)
+	for key, current := range p.state.subnets {
+		p.subnets[key] = subnetRef{key: key, section: current.section, vrf: current.vrf, prefix: current.prefix}
+	}
+	for i := range p.doc.Subnets {
+		s := &p.doc.Subnets[i]
+		prefix, err := s.Prefix()
+		if err != nil {
+			return nil, err
+		}
+		p.subnets[s.Key()] = subnetRef{key: s.Key(), section: s.Section, vrf: s.VRF, prefix: prefix}
+	}
+
+	steps := []func() error{p.planSections, p.planVRFs, p.planL2Domains, p.planVLANs, p.planSubnets, p.planAddresses}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+	if p.prune {
+		if err := p.planDeletes(); err != nil {
+			return nil, err
+		}
+	}
+
+	sortChanges(p.changes)
+	return p.changes, nil
+}
+
+// sortChanges orders creates and updates by dependency, followed by deletes in
+// reverse dependency order
+func sortChanges(changes []Change) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		aDelete, bDelete := a.Action == ActionDelete, b.Action == ActionDelete
+		if aDelete != bDelete {
+			return !aDelete
+		}
+		if kindOrder[a.Kind] != kindOrder[b.Kind] {
+			if aDelete {
+				return kindOrder[a.Kind] > kindOrder[b.Kind]
+			}
+			return kindOrder[a.Kind] < kindOrder[b.Kind]
+		}
+		if a.rank != b.rank {
+			if aDelete {
+				return a.rank > b.rank
+			}
+			return a.rank < b.rank
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Key < b.Key
+	})
+}
+
+// add records a create or update for a declared object
+func (p *planner) add(kind Kind, key string, desired, current interface{}, fields []field, rank int, requires []string) {
+	change := Change{Kind: kind, Key: key, desired: desired, current: current, rank: rank, requires: requires}
+	if current == nil {
+		change.Action = ActionCreate
+		change.Diffs = createFields(fields)
+	} else {
+		change.Action = ActionUpdate
+		change.Diffs = diffFields(fields)
+		if len(change.Diffs) == 0 {
+			return
+		}
+	}
+	p.changes = append(p.changes, change)
+}
+
+// boolString formats a flag for display
+func boolString(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+// sectionNameList formats section IDs as a sorted list of names
+func (p *planner) sectionNameList(ids phpipam.SectionIDs) string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, p.state.sectionName(id))
+	}
+	return nameList(names)
+}
+
+// nameList formats names as a sorted comma-separated list
+func nameList(names []string) string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ", ")
+}
+
+// requireSection returns the dependency on a section, checking that it exists
+// in the document or in phpIPAM
+func (p *planner) requireSection(name string) (string, error) {
+	if _, ok := p.state.sections[name]; !ok && !p.declaresSection(name) {
+		return "", fmt.Errorf("unknown section %q", name)
+	}
+	return objectRef(KindSection, name), nil
+}
+
+// declaresSection reports whether the document declares a section
+func (p *planner) declaresSection(name string) bool {
+	for i := range p.doc.Sections {
+		if p.doc.Sections[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionDepth returns the number of parents of a declared section
+func (p *planner) sectionDepth(name string) int {
+	depth := 0
+	seen := map[string]bool{name: true}
+	for {
+		parent := ""
+		for i := range p.doc.Sections {
+			if p.doc.Sections[i].Name == name {
+				parent = p.doc.Sections[i].Parent
+			}
+		}
+		if parent == "" || seen[parent] {
+			return depth
+		}
+		seen[parent] = true
+		name = parent
+		depth++
+	}
+}
+
+// planSections plans section creates and updates
+func (p *planner) planSections() error {
+	for i := range p.doc.Sections {
+		desired := &p.doc.Sections[i]
+		var requires []string
+		if desired.Parent != "" {
+			ref, err := p.requireSection(desired.Parent)
+			if err != nil {
+				return fmt.Errorf("section %s: parent: %w", desired.Name, err)
+			}
+			requires = append(requires, ref)
+		}
+
+		fields := []field{
+			{name: "description", desired: desired.Description},
+			{name: "masterSection", desired: desired.Parent},
+			{name: "strictMode", desired: boolString(desired.StrictMode)},
+			{name: "showVLAN", desired: boolString(desired.ShowVLAN)},
+			{name: "showVRF", desired: boolString(desired.ShowVRF)},
+		}
+		var current interface{}
+		if section, ok := p.state.sections[desired.Name]; ok {
+			current = section
+			parent := ""
+			if section.MasterSection != 0 {
+				parent = p.state.sectionName(section.MasterSection)
+			}
+			fields[0].current = section.Description
+			fields[1].current = parent
+			fields[2].current = boolString(section.StrictMode != 0)
+			fields[3].current = boolString(section.ShowVLAN != 0)
+			fields[4].current = boolString(section.ShowVRF != 0)
+		}
+		p.add(KindSection, desired.Key(), desired, current, fields, p.sectionDepth(desired.Name), requires)
+	}
+	return nil
+}
+
+// planVRFs plans VRF creates and updates
+func (p *planner) planVRFs() error {
+	for i := range p.doc.VRFs {
+		desired := &p.doc.VRFs[i]
+		var requires []string
+		for _, name := range desired.Sections {
+			ref, err := p.requireSection(name)
+			if err != nil {
+				return fmt.Errorf("vrf %s: %w", desired.Name, err)
+			}
+			requires = append(requires, ref)
+		}
+
+		fields := []field{
+			{name: "rd", desired: desired.RD},
+			{name: "description", desired: desired.Description},
+			{name: "sections", desired: nameList(desired.Sections)},
+		}
+		var current interface{}
+		if vrf, ok := p.state.vrfs[desired.Name]; ok {
+			current = vrf
+			ids, err := phpipam.ParseSectionIDs(vrf.Sections)
+			if err != nil {
+				return fmt.Errorf("vrf %s: %w", vrf.Name, err)
+			}
+			fields[0].current = vrf.RD
+			fields[1].current = vrf.Description
+			fields[2].current = p.sectionNameList(ids)
+		}
+		p.add(KindVRF, desired.Key(), desired, current, fields, 0, requires)
+	}
+	return nil
+}
+
+// planL2Domains plans L2 domain creates and updates
+func (p *planner) planL2Domains() error {
+	for i := range p.doc.L2Domains {
+		desired := &p.doc.L2Domains[i]
+		var requires []string
+		for _, name := range desired.Sections {
+			ref, err := p.requireSection(name)
+			if err != nil {
+				return fmt.Errorf("l2domain %s: %w", desired.Name, err)
+			}
+			requires = append(requires, ref)
+		}
+
+		fields := []field{
+			{name: "description", desired: desired.Description},
+			{name: "permissions", desired: nameList(desired.Sections)},
+		}
+		var current interface{}
+		if domain, ok := p.state.domains[desired.Name]; ok {
+			current = domain
+			fields[0].current = domain.Description
+			fields[1].current = p.sectionNameList(domain.Permissions)
+		}
+		p.add(KindL2Domain, desired.Key(), desired, current, fields, 0, requires)
+	}
+	return nil
+}
+
+// requireDomain returns the dependency on an L2 domain, checking that it exists
+func (p *planner) requireDomain(name string) (string, error) {
+	if _, ok := p.state.domains[name]; !ok {
+		declared := false
+		for i := range p.doc.L2Domains {
+			declared = declared || p.doc.L2Domains[i].Name == name
+		}
+		if !declared {
+			return "", fmt.Errorf("unknown L2 domain %q", name)
+		}
+	}
+	return objectRef(KindL2Domain, name), nil
+}
+
+// planVLANs plans VLAN creates and updates
+func (p *planner) planVLANs() error {
+	for i := range p.doc.VLANs {
+		desired := &p.doc.VLANs[i]
+		ref, err := p.requireDomain(desired.Domain)
+		if err != nil {
+			return fmt.Errorf("vlan %s: %w", desired.Key(), err)
+		}
+
+		fields := []field{
+			{name: "name", desired: desired.Name},
+			{name: "description", desired: desired.Description},
+		}
+		var current interface{}
+		if vlan, ok := p.state.vlans[desired.Key()]; ok {
+			current = vlan
+			fields[0].current = vlan.Name
+			fields[1].current = vlan.Description
+		}
+		p.add(KindVLAN, desired.Key(), desired, current, fields, 0, []string{ref})
+	}
+	return nil
+}
+
+// requireVLAN returns the dependency on a VLAN, checking that it exists
+func (p *planner) requireVLAN(key string) (string, error) {
+	if _, ok := p.state.vlans[key]; !ok {
+		declared := false
+		for i := range p.doc.VLANs {
+			declared = declared || p.doc.VLANs[i].Key() == key
+		}
+		if !declared {
+			return "", fmt.Errorf("unknown VLAN %s", key)
+		}
+	}
+	return objectRef(KindVLAN, key), nil
+}
+
+// requireVRF returns the dependency on a VRF, checking that it exists
+func (p *planner) requireVRF(name string) (string, error) {
+	if _, ok := p.state.vrfs[name]; !ok {
+		declared := false
+		for i := range p.doc.VRFs {
+			declared = declared || p.doc.VRFs[i].Name == name
+		}
+		if !declared {
+			return "", fmt.Errorf("unknown VRF %q", name)
+		}
+	}
+	return objectRef(KindVRF, name), nil
+}
+
+// parentSubnet returns the smallest known subnet strictly containing prefix in
+// the same section and VRF
+func (p *planner) parentSubnet(section, vrf string, prefix netip.Prefix) (subnetRef, bool) {
+	var parent subnetRef
+	found := false
+	for _, candidate := range p.subnets {
+		if candidate.section != section || candidate.vrf != vrf ||
+			candidate.prefix.Bits() >= prefix.Bits() || !ipcalc.Contains(candidate.prefix, prefix) {
+			continue
+		}
+		if !found || candidate.prefix.Bits() > parent.prefix.Bits() {
+			parent, found = candidate, true
+		}
+	}
+	return parent, found
+}
+
+// addressSubnet returns the most specific known subnet holding an address
+func (p *planner) addressSubnet(section, vrf string, addr netip.Addr) (subnetRef, bool) {
+	var subnet subnetRef
+	found := false
+	for _, candidate := range p.subnets {
+		if candidate.section != section || candidate.vrf != vrf || !candidate.prefix.Contains(addr) {
+			continue
+		}
+		if !found || candidate.prefix.Bits() > subnet.prefix.Bits() {
+			subnet, found = candidate, true
+		}
+	}
+	return subnet, found
+}
+
+// planSubnets plans subnet creates and updates
+func (p *planner) planSubnets() error {
+	for i := range p.doc.Subnets {
+		desired := &p.doc.Subnets[i]
+		key := desired.Key()
+		prefix, err := desired.Prefix()
+		if err != nil {
+			return fmt.Errorf("subnet %s: %w", key, err)
+		}
+
+		ref, err := p.requireSection(desired.Section)
+		if err != nil {
+			return fmt.Errorf("subnet %s: %w", key, err)
+		}
+		requires := []string{ref}
+		if desired.VRF != "" {
+			ref, err := p.requireVRF(desired.VRF)
+			if err != nil {
+				return fmt.Errorf("subnet %s: %w", key, err)
+			}
+			requires = append(requires, ref)
+		}
+		vlan := ""
+		if desired.VLAN != nil {
+			vlan = desired.VLAN.Key()
+			ref, err := p.requireVLAN(vlan)
+			if err != nil {
+				return fmt.Errorf("subnet %s: %w", key, err)
+			}
+			requires = append(requires, ref)
+		}
+		parent := ""
+		if ref, ok := p.parentSubnet(desired.Section, desired.VRF, prefix); ok {
+			parent = ref.prefix.String()
+			requires = append(requires, objectRef(KindSubnet, ref.key))
+		}
+
+		fields := []field{
+			{name: "description", desired: desired.Description},
+			{name: "vlanId", desired: vlan},
+			{name: "masterSubnetId", desired: parent},
+			{name: "isPool", desired: boolString(desired.IsPool)},
+			{name: "showName", desired: boolString(desired.ShowName)},
+		}
+		var current interface{}
+		if existing, ok := p.state.subnets[key]; ok {
+			subnet := existing.subnet
+			current = subnet
+			fields[0].current = subnet.Description
+			if id, ok := subnet.GetVlanID(); ok && id != 0 {
+				fields[1].current = p.state.vlanKeys[id]
+			}
+			if master, ok := p.state.subnetIDs[subnet.MasterSubnetID]; ok {
+				fields[2].current = master.prefix.String()
+			}
+			fields[3].current = boolString(subnet.IsPool != 0)
+			fields[4].current = boolString(subnet.ShowName != 0)
+		}
+		p.add(KindSubnet, key, desired, current, fields, prefix.Bits(), requires)
+	}
+	return nil
+}
+
+// normalizeMAC formats a MAC for comparison, keeping unparsable values as they are
+func normalizeMAC(mac string) string {
+	if mac == "" {
+		return ""
+	}
+	if parsed, err := ipcalc.ParseMAC(mac); err == nil {
+		return parsed.String()
+	}
+	return strings.ToLower(mac)
+}
+
+// planAddresses plans address creates and updates
+func (p *planner) planAddresses() error {
+	for i := range p.doc.Addresses {
+		desired := &p.doc.Addresses[i]
+		key := desired.Key()
+		addr, err := desired.Addr()
+		if err != nil {
+			return fmt.Errorf("address %s: %w", key, err)
+		}
+
+		subnet, ok := p.addressSubnet(desired.Section, desired.VRF, addr)
+		if !ok {
+			return fmt.Errorf("address %s: no subnet contains it", key)
+		}
+		requires := []string{objectRef(KindSubnet, subnet.key)}
+
+		fields := []field{
+			{name: "hostname", desired: desired.Hostname},
+			{name: "description", desired: desired.Description},
+			{name: "mac", desired: desired.MAC},
+			{name: "owner", desired: desired.Owner},
+			{name: "is_gateway", desired: boolString(desired.Gateway)},
+			{name: "note", desired: desired.Note},
+		}
+		if desired.Tag != "" {
+			tag, err := p.api.Addresses.ResolveTag(desired.Tag)
+			if err != nil {
+				return fmt.Errorf("address %s: %w", key, err)
+			}
+			fields = append(fields, field{name: "tag", desired: tag.Type})
+		}
+
+		var current interface{}
+		if address, ok := p.state.addresses[key]; ok {
+			current = address
+			fields[0].current = address.Hostname
+			fields[1].current = address.Description
+			fields[2].current = normalizeMAC(address.Mac)
+			fields[3].current = address.Owner
+			fields[4].current = boolString(address.IsGateway != 0)
+			fields[5].current = address.Note
+			if desired.Tag != "" {
+				name, err := p.api.Addresses.TagName(address.Tag)
+				if err != nil {
+					name = strconv.Itoa(address.Tag)
+				}
+				fields[6].current = name
+			}
+		}
+		p.add(KindAddress, key, desired, current, fields, 0, requires)
+	}
+	return nil
+}
+
+// planDeletes plans deletes of undeclared subnets, addresses and VLANs.
+// phpIPAM deletes the child subnets and addresses of a subnet along with it,
+// so pruning a subnet that holds declared subnets or addresses is an error.
+func (p *planner) planDeletes() error {
+	declared := make(map[string]bool)
+	for i := range p.doc.Subnets {
+		declared[objectRef(KindSubnet, p.doc.Subnets[i].Key())] = true
+	}
+	for i := range p.doc.Addresses {
+		declared[objectRef(KindAddress, p.doc.Addresses[i].Key())] = true
+	}
+	for i := range p.doc.VLANs {
+		declared[objectRef(KindVLAN, p.doc.VLANs[i].Key())] = true
+	}
+
+	keys := make([]string, 0, len(p.state.subnets))
+	for key := range p.state.subnets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		current := p.state.subnets[key]
+		if !declared[objectRef(KindSubnet, key)] {
+			if inside := p.declaredInside(current); inside != "" {
+				return fmt.Errorf("cannot prune subnet %s: it holds declared %s, which phpIPAM would delete with it", key, inside)
+			}
+			p.changes = append(p.changes, Change{Action: ActionDelete, Kind: KindSubnet, Key: key, current: current.subnet, rank: current.prefix.Bits()})
+		}
+	}
+	for key, address := range p.state.addresses {
+		if !declared[objectRef(KindAddress, key)] {
+			p.changes = append(p.changes, Change{Action: ActionDelete, Kind: KindAddress, Key: key, current: address})
+		}
+	}
+
+	domains := make(map[string]bool)
+	for i := range p.doc.L2Domains {
+		domains[p.doc.L2Domains[i].Name] = true
+	}
+	for i := range p.doc.VLANs {
+		domains[p.doc.VLANs[i].Domain] = true
+	}
+	for key, vlan := range p.state.vlans {
+		domain, ok := p.state.domainNames[vlan.DomainID]
+		if ok && domains[domain] && !declared[objectRef(KindVLAN, key)] {
+			p.changes = append(p.changes, Change{Action: ActionDelete, Kind: KindVLAN, Key: key, current: vlan})
+		}
+	}
+	return nil
+}
+
+// declaredInside returns a declared subnet or address of the subnet's section
+// that lies inside it, or "". Other VRFs count too, since phpIPAM nests
+// subnets by master subnet rather than by VRF.
+func (p *planner) declaredInside(current *currentSubnet) string {
+	for i := range p.doc.Subnets {
+		s := &p.doc.Subnets[i]
+		prefix, err := s.Prefix()
+		if err != nil || s.Section != current.section {
+			continue
+		}
+		if prefix != current.prefix && ipcalc.Contains(current.prefix, prefix) {
+			return "subnet " + s.Key()
+		}
+	}
+	for i := range p.doc.Addresses {
+		a := &p.doc.Addresses[i]
+		addr, err := a.Addr()
+		if err != nil || a.Section != current.section {
+			continue
+		}
+		if current.prefix.Contains(addr) {
+			return "address " + a.Key()
+		}
+	}
+	return ""
+}
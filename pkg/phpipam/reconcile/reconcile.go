@@ -0,0 +1,259 @@
+// Package reconcile brings phpIPAM in line with a desired-state inventory
+// document: it reads the current objects, computes a plan of creates, updates
+// and deletes, and applies it in dependency order.
+//
+// Sections, VRFs, L2 domains, VLANs, subnets and addresses are reconciled; the
+// other object kinds and reference fields of the document are ignored.
+//
+//	r := reconcile.New(client)
+//	plan, err := r.Plan(doc)
+//	fmt.Print(plan)
+//	result, err := r.Apply(plan)
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/inventory"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/report"
+)
+
+// Action is what a change does to an object
+type Action string
+
+const (
+	// ActionCreate creates a missing object
+	ActionCreate Action = "create"
+	// ActionUpdate changes fields of an existing object
+	ActionUpdate Action = "update"
+	// ActionDelete removes an object that is not in the document
+	ActionDelete Action = "delete"
+)
+
+// symbol returns the plan marker of an action
+func (a Action) symbol() string {
+	switch a {
+	case ActionCreate:
+		return "+"
+	case ActionDelete:
+		return "-"
+	}
+	return "~"
+}
+
+// Kind is the type of object a change applies to
+type Kind string
+
+const (
+	// KindSection is a section
+	KindSection Kind = "section"
+	// KindVRF is a VRF
+	KindVRF Kind = "vrf"
+	// KindL2Domain is an L2 domain
+	KindL2Domain Kind = "l2domain"
+	// KindVLAN is a VLAN
+	KindVLAN Kind = "vlan"
+	// KindSubnet is a subnet
+	KindSubnet Kind = "subnet"
+	// KindAddress is an address
+	KindAddress Kind = "address"
+)
+
+// kindOrder is the order objects are created in; deletes run in reverse
+var kindOrder = map[Kind]int{
+	KindSection:  0,
+	KindVRF:      1,
+	KindL2Domain: 1,
+	KindVLAN:     2,
+	KindSubnet:   3,
+	KindAddress:  4,
+}
+
+// FieldDiff is a field that differs between phpIPAM and the document
+type FieldDiff = report.FieldDiff
+
+// Change is a single create, update or delete in a plan
+type Change struct {
+	Action Action
+	Kind   Kind
+	// Key is the natural key of the object, e.g. "10.0.0.0/24 in Production"
+	Key string
+	// Diffs lists the changed fields of an update, or the fields of a create
+	Diffs []FieldDiff
+
+	desired  interface{} // inventory object for creates and updates
+	current  interface{} // phpipam object for updates and deletes
+	rank     int         // order within a kind: section depth or prefix length
+	requires []string    // refs of objects this change depends on
+}
+
+// ref returns the kind-qualified key of the change's object
+func (c *Change) ref() string {
+	return objectRef(c.Kind, c.Key)
+}
+
+// objectRef returns a kind-qualified key
+func objectRef(kind Kind, key string) string {
+	return string(kind) + " " + key
+}
+
+// String returns a one-line summary such as "+ subnet 10.0.0.0/24 in Production"
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s %s", c.Action.symbol(), c.Kind, c.Key)
+}
+
+// Plan is the ordered list of changes that brings phpIPAM to the desired state
+type Plan struct {
+	Changes []Change
+
+	state *state
+}
+
+// Empty reports whether phpIPAM already matches the document
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Counts returns the number of creates, updates and deletes in the plan
+func (p *Plan) Counts() (create, update, delete int) {
+	for _, change := range p.Changes {
+		switch change.Action {
+		case ActionCreate:
+			create++
+		case ActionUpdate:
+			update++
+		case ActionDelete:
+			delete++
+		}
+	}
+	return create, update, delete
+}
+
+// Render writes the plan in a human-readable form
+func (p *Plan) Render(w io.Writer) error {
+	return report.Render(w, func(b *strings.Builder) {
+		for _, change := range p.Changes {
+			fmt.Fprintln(b, change.String())
+			if change.Action != ActionDelete {
+				report.WriteDiffs(b, change.Diffs, change.Action == ActionCreate)
+			}
+		}
+
+		create, update, delete := p.Counts()
+		if p.Empty() {
+			fmt.Fprintln(b, "No changes. phpIPAM matches the desired state.")
+		} else {
+			fmt.Fprintf(b, "Plan: %d to create, %d to update, %d to delete.\n", create, update, delete)
+		}
+	})
+}
+
+// String returns the rendered plan
+func (p *Plan) String() string {
+	return report.String(p.Render)
+}
+
+// Failure is a change that failed or was skipped during Apply
+type Failure struct {
+	Change Change
+	Err    error
+}
+
+// Result reports the outcome of applying a plan
+type Result struct {
+	// Applied are the changes that succeeded
+	Applied []Change
+	// Failed are the changes phpIPAM rejected
+	Failed []Failure
+	// Skipped are the changes not attempted because a change they depend on failed
+	Skipped []Failure
+}
+
+// Err returns an error describing the failed and skipped changes, or nil
+func (r *Result) Err() error {
+	var errs []error
+	for _, failure := range r.Failed {
+		errs = append(errs, fmt.Errorf("%s %s: %w", failure.Change.Kind, failure.Change.Key, failure.Err))
+	}
+	if len(r.Skipped) > 0 {
+		errs = append(errs, fmt.Errorf("%d dependent changes skipped", len(r.Skipped)))
+	}
+	return errors.Join(errs...)
+}
+
+// Reconciler plans and applies changes against a phpIPAM instance
+type Reconciler struct {
+	api *phpipam.PHPIPAM
+
+	// Prune deletes subnets and addresses in the document's sections, and VLANs in
+	// its L2 domains, that the document does not declare. Sections, VRFs and L2
+	// domains themselves are never deleted.
+	Prune bool
+}
+
+// New creates a reconciler for the given phpIPAM client
+func New(api *phpipam.PHPIPAM) *Reconciler {
+	return &Reconciler{api: api}
+}
+
+// Plan reads the current state of phpIPAM and computes the changes needed to
+// match the document. Nothing is modified.
+func (r *Reconciler) Plan(doc *inventory.Document) (*Plan, error) {
+	if err := doc.Normalize(); err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+
+	st, err := readState(r.api, doc, r.Prune)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current state: %w", err)
+	}
+
+	planner := &planner{api: r.api, doc: doc, state: st, prune: r.Prune}
+	changes, err := planner.plan()
+	if err != nil {
+		return nil, err
+	}
+	return &Plan{Changes: changes, state: st}, nil
+}
+
+// Apply executes a plan in order. A failed change does not stop the run; changes
+// that depend on it are skipped. The returned error summarizes all failures.
+func (r *Reconciler) Apply(plan *Plan) (*Result, error) {
+	result := &Result{}
+	failed := make(map[string]bool)
+	a := &applier{api: r.api, state: plan.state}
+
+	for _, change := range plan.Changes {
+		if dependency := failedDependency(change.requires, failed); dependency != "" {
+			result.Skipped = append(result.Skipped, Failure{Change: change, Err: fmt.Errorf("depends on failed %s", dependency)})
+			failed[change.ref()] = true
+			continue
+		}
+
+		if err := a.apply(&change); err != nil {
+			result.Failed = append(result.Failed, Failure{Change: change, Err: err})
+			failed[change.ref()] = true
+			continue
+		}
+		result.Applied = append(result.Applied, change)
+	}
+
+	return result, result.Err()
+}
+
+// failedDependency returns the first required ref that failed, or ""
+func failedDependency(requires []string, failed map[string]bool) string {
+	for _, ref := range requires {
+		if failed[ref] {
+			return ref
+		}
+	}
+	return ""
+}
@@ -0,0 +1,375 @@
+package reconcile
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/inventory"
+)
+
+const (
+	notFound = `{"code":404,"success":false,"message":"No results (filter applied)"}`
+	ok       = `{"code":200,"success":true}`
+	rejected = `{"code":500,"success":false,"message":"Operation failed"}`
+)
+
+// fakeIPAM serves hand-written phpIPAM responses and records the requests that
+// change objects
+type fakeIPAM struct {
+	// responses maps "METHOD endpoint" to the responses of consecutive calls;
+	// the last one is repeated
+	responses map[string][]string
+	// writes lists the non-GET requests as "METHOD endpoint body"
+	writes []string
+}
+
+// newFakeIPAM starts a fake phpIPAM and returns a client for it
+func newFakeIPAM(t *testing.T, responses map[string][]string) (*phpipam.PHPIPAM, *fakeIPAM) {
+	t.Helper()
+	fake := &fakeIPAM{responses: responses}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/test/"), "/")
+		key := r.Method + " " + endpoint
+		if r.Method != "GET" {
+			body, _ := io.ReadAll(r.Body)
+			fake.writes = append(fake.writes, strings.TrimSpace(key+" "+string(body)))
+		}
+		queue := fake.responses[key]
+		if len(queue) == 0 {
+			t.Errorf("unexpected request %s", key)
+			queue = []string{`{"code":400,"success":false,"message":"Invalid request"}`}
+		}
+		if len(queue) > 1 {
+			fake.responses[key] = queue[1:]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(queue[0]))
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := phpipam.NewTokenClient(srv.URL+"/api/", "test", "token", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api, fake
+}
+
+// requests returns the method and endpoint of the recorded writes
+func (f *fakeIPAM) requests() []string {
+	requests := make([]string, len(f.writes))
+	for i, write := range f.writes {
+		fields := strings.Fields(write)
+		requests[i] = fields[0] + " " + fields[1]
+	}
+	return requests
+}
+
+// productionState returns the responses for a phpIPAM with a Production
+// section holding 10.0.0.0/16, its children 10.0.1.0/24 and 10.0.9.0/24, the
+// address 10.0.1.5 and VLANs 100 and 200 in the default L2 domain
+func productionState() map[string][]string {
+	return map[string][]string{
+		"GET sections":  {`{"code":200,"success":true,"data":[{"id":"1","name":"Production"}]}`},
+		"GET vrf":       {notFound},
+		"GET l2domains": {`{"code":200,"success":true,"data":[{"id":"1","name":"default"}]}`},
+		"GET l2domains/1/vlans": {`{"code":200,"success":true,"data":[` +
+			`{"id":"5","domainId":"1","number":"100","name":"old"},` +
+			`{"id":"6","domainId":"1","number":"200","name":"legacy"}]}`},
+		"GET sections/1/subnets": {`{"code":200,"success":true,"data":[` +
+			`{"id":3,"subnet":"10.0.0.0","mask":"16","sectionId":1},` +
+			`{"id":4,"subnet":"10.0.1.0","mask":"24","sectionId":1,"masterSubnetId":3,"description":"old"},` +
+			`{"id":9,"subnet":"10.0.9.0","mask":"24","sectionId":1,"masterSubnetId":3}]}`},
+		"GET subnets/3/addresses": {notFound},
+		"GET subnets/4/addresses": {`{"code":200,"success":true,"data":[{"id":50,"subnetId":4,"ip":"10.0.1.5"}]}`},
+		"GET subnets/9/addresses": {notFound},
+	}
+}
+
+// productionDocument declares a Lab section under Production, renames VLAN 100,
+// adds VLAN 300, changes 10.0.1.0/24 and adds 10.0.2.0/24 with a /26 and an
+// address inside it
+func productionDocument() *inventory.Document {
+	return &inventory.Document{
+		Sections: []inventory.Section{{Name: "Production"}, {Name: "Lab", Parent: "Production"}},
+		VLANs: []inventory.VLAN{
+			{Number: 100, Name: "web"},
+			{Number: 300, Name: "db"},
+		},
+		Subnets: []inventory.Subnet{
+			{Section: "Production", CIDR: "10.0.0.0/16"},
+			{Section: "Production", CIDR: "10.0.1.0/24", Description: "web", VLAN: &inventory.VLANRef{Number: 100}},
+			{Section: "Production", CIDR: "10.0.2.0/26"},
+			{Section: "Production", CIDR: "10.0.2.0/24"},
+		},
+		Addresses: []inventory.Address{{Section: "Production", IP: "10.0.2.10", Hostname: "app"}},
+	}
+}
+
+// productionWrites returns the responses to the writes of the production plan
+func productionWrites(responses map[string][]string) map[string][]string {
+	responses["POST sections"] = []string{`{"code":201,"success":true,"id":"2","data":{"id":"2","name":"Lab","masterSection":"1"}}`}
+	responses["PATCH vlan/5"] = []string{ok}
+	responses["POST vlan"] = []string{`{"code":201,"success":true,"id":"7","data":{"id":"7","domainId":"1","number":"300","name":"db"}}`}
+	responses["PATCH subnets/4"] = []string{ok}
+	responses["POST subnets"] = []string{
+		`{"code":201,"success":true,"id":"10","data":{"id":10,"subnet":"10.0.2.0","mask":"24","sectionId":1,"masterSubnetId":3}}`,
+		`{"code":201,"success":true,"id":"11","data":{"id":11,"subnet":"10.0.2.0","mask":"26","sectionId":1,"masterSubnetId":10}}`,
+	}
+	responses["POST addresses"] = []string{`{"code":201,"success":true,"id":"60","data":{"id":60,"subnetId":11,"ip":"10.0.2.10"}}`}
+	responses["DELETE addresses/50"] = []string{ok}
+	responses["DELETE subnets/9"] = []string{ok}
+	responses["DELETE vlan/6"] = []string{ok}
+	return responses
+}
+
+// changeList formats changes one per line
+func changeList(changes []Change) string {
+	lines := make([]string, len(changes))
+	for i, change := range changes {
+		lines[i] = change.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestPlanRender(t *testing.T) {
+	api, _ := newFakeIPAM(t, productionState())
+	r := New(api)
+	r.Prune = true
+
+	plan, err := r.Plan(productionDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `+ section Lab
+    masterSection: "Production"
+~ vlan default/100
+    name: "old" -> "web"
++ vlan default/300
+    name: "db"
+~ subnet 10.0.1.0/24 in Production
+    description: "old" -> "web"
+    vlanId: "" -> "default/100"
++ subnet 10.0.2.0/24 in Production
+    masterSubnetId: "10.0.0.0/16"
++ subnet 10.0.2.0/26 in Production
+    masterSubnetId: "10.0.2.0/24"
++ address 10.0.2.10 in Production
+    hostname: "app"
+- address 10.0.1.5 in Production
+- subnet 10.0.9.0/24 in Production
+- vlan default/200
+Plan: 5 to create, 2 to update, 3 to delete.
+`
+	if got := plan.String(); got != want {
+		t.Errorf("plan:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPlanEmpty(t *testing.T) {
+	api, _ := newFakeIPAM(t, productionState())
+	doc := &inventory.Document{
+		Sections: []inventory.Section{{Name: "Production"}},
+		Subnets: []inventory.Subnet{
+			{Section: "Production", CIDR: "10.0.0.0/16"},
+			{Section: "Production", CIDR: "10.0.1.0/24", Description: "old"},
+		},
+	}
+
+	plan, err := New(api).Plan(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Fatalf("plan is not empty:\n%s", plan)
+	}
+	if got, want := plan.String(), "No changes. phpIPAM matches the desired state.\n"; got != want {
+		t.Errorf("plan = %q, want %q", got, want)
+	}
+}
+
+func TestApplyOrder(t *testing.T) {
+	api, fake := newFakeIPAM(t, productionWrites(productionState()))
+	r := New(api)
+	r.Prune = true
+
+	plan, err := r.Plan(productionDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Apply(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Applied) != len(plan.Changes) || len(result.Failed) != 0 || len(result.Skipped) != 0 {
+		t.Errorf("applied %d, failed %d, skipped %d of %d changes",
+			len(result.Applied), len(result.Failed), len(result.Skipped), len(plan.Changes))
+	}
+
+	want := []string{
+		"POST sections",
+		"PATCH vlan/5",
+		"POST vlan",
+		"PATCH subnets/4",
+		"POST subnets",
+		"POST subnets",
+		"POST addresses",
+		"DELETE addresses/50",
+		"DELETE subnets/9",
+		"DELETE vlan/6",
+	}
+	if got := fake.requests(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Later creates use the IDs of objects created earlier in the run
+	for _, check := range []struct{ request, field string }{
+		{"POST subnets", `"masterSubnetId":10`},
+		{"POST addresses", `"subnetId":11`},
+		{"PATCH subnets/4", `"vlanId":5`},
+	} {
+		found := false
+		for _, write := range fake.writes {
+			found = found || strings.HasPrefix(write, check.request+" ") && strings.Contains(write, check.field)
+		}
+		if !found {
+			t.Errorf("no %s request with %s in:\n%s", check.request, check.field, strings.Join(fake.writes, "\n"))
+		}
+	}
+}
+
+func TestApplySkipsDependents(t *testing.T) {
+	responses := productionWrites(productionState())
+	responses["POST subnets"] = []string{rejected}
+	responses["DELETE subnets/9"] = []string{rejected}
+	api, fake := newFakeIPAM(t, responses)
+	r := New(api)
+	r.Prune = true
+
+	plan, err := r.Plan(productionDocument())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Apply(plan)
+	if err == nil {
+		t.Fatal("Apply succeeded with failed changes")
+	}
+
+	var failed, skipped []Change
+	for _, failure := range result.Failed {
+		failed = append(failed, failure.Change)
+	}
+	for _, failure := range result.Skipped {
+		skipped = append(skipped, failure.Change)
+	}
+	wantFailed := "+ subnet 10.0.2.0/24 in Production\n- subnet 10.0.9.0/24 in Production"
+	if got := changeList(failed); got != wantFailed {
+		t.Errorf("failed:\n%s\nwant:\n%s", got, wantFailed)
+	}
+	// The /26 depends on the /24 and the address on the /26
+	wantSkipped := "+ subnet 10.0.2.0/26 in Production\n+ address 10.0.2.10 in Production"
+	if got := changeList(skipped); got != wantSkipped {
+		t.Errorf("skipped:\n%s\nwant:\n%s", got, wantSkipped)
+	}
+	if len(result.Applied) != 6 {
+		t.Errorf("applied:\n%s\nwant 6 changes", changeList(result.Applied))
+	}
+	if msg := err.Error(); !strings.Contains(msg, "Operation failed") || !strings.Contains(msg, "2 dependent changes skipped") {
+		t.Errorf("err = %v", err)
+	}
+	if got := result.Skipped[1].Err.Error(); got != "depends on failed subnet 10.0.2.0/26 in Production" {
+		t.Errorf("skipped address err = %q", got)
+	}
+
+	for _, request := range fake.requests() {
+		if request == "POST addresses" {
+			t.Error("created the address of a failed subnet")
+		}
+	}
+}
+
+func TestFailedDependency(t *testing.T) {
+	failed := map[string]bool{"subnet 10.0.2.0/24 in Production": true}
+	if got := failedDependency([]string{"section Production", "subnet 10.0.2.0/24 in Production"}, failed); got != "subnet 10.0.2.0/24 in Production" {
+		t.Errorf("failedDependency = %q", got)
+	}
+	if got := failedDependency([]string{"section Production"}, failed); got != "" {
+		t.Errorf("failedDependency = %q, want none", got)
+	}
+}
+
+func TestPruneRefusesParents(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     *inventory.Document
+		wantErr string
+	}{
+		{
+			name: "declared child subnet",
+			doc: &inventory.Document{
+				Subnets: []inventory.Subnet{{Section: "Production", CIDR: "10.0.1.0/24"}},
+			},
+			wantErr: "cannot prune subnet 10.0.0.0/16 in Production: it holds declared subnet 10.0.1.0/24 in Production",
+		},
+		{
+			name: "declared address",
+			doc: &inventory.Document{
+				Subnets: []inventory.Subnet{
+					{Section: "Production", CIDR: "10.0.0.0/16"},
+					{Section: "Production", CIDR: "10.0.1.0/24"},
+				},
+				Addresses: []inventory.Address{{Section: "Production", IP: "10.0.9.7"}},
+			},
+			wantErr: "cannot prune subnet 10.0.9.0/24 in Production: it holds declared address 10.0.9.7 in Production",
+		},
+		{
+			name: "child subnet in another VRF",
+			doc: &inventory.Document{
+				VRFs: []inventory.VRF{{Name: "blue"}},
+				Subnets: []inventory.Subnet{
+					{Section: "Production", CIDR: "10.0.0.0/16"},
+					{Section: "Production", CIDR: "10.0.1.0/24"},
+					{Section: "Production", VRF: "blue", CIDR: "10.0.9.128/25"},
+				},
+			},
+			wantErr: "cannot prune subnet 10.0.9.0/24 in Production: it holds declared subnet 10.0.9.128/25 in Production vrf blue",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, _ := newFakeIPAM(t, productionState())
+			r := New(api)
+			r.Prune = true
+			_, err := r.Plan(tt.doc)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+
+			// Without pruning the same document plans fine
+			api, _ = newFakeIPAM(t, productionState())
+			if _, err := New(api).Plan(tt.doc); err != nil {
+				t.Errorf("plan without prune: %v", err)
+			}
+		})
+	}
+}
+
+func TestPlanDuplicatePrefixes(t *testing.T) {
+	responses := productionState()
+	responses["GET sections/1/subnets"] = []string{`{"code":200,"success":true,"data":[` +
+		`{"id":3,"subnet":"10.0.0.0","mask":"16","sectionId":1},` +
+		`{"id":4,"subnet":"10.0.1.0","mask":"24","sectionId":1},` +
+		`{"id":8,"subnet":"10.0.1.0","mask":"24","sectionId":1,"vrfId":null}]}`}
+	api, _ := newFakeIPAM(t, responses)
+
+	_, err := New(api).Plan(&inventory.Document{
+		Subnets: []inventory.Subnet{{Section: "Production", CIDR: "10.0.1.0/24"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "subnet 10.0.1.0/24 in Production: IDs 4 and 8") {
+		t.Errorf("err = %v, want the duplicate subnet IDs", err)
+	}
+}
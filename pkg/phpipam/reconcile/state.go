@@ -0,0 +1,298 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam"
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/inventory"
+)
+
+// state is a snapshot of phpIPAM objects indexed by natural key. Apply adds
+// created objects so later changes can resolve them.
+type state struct {
+	sections     map[string]*phpipam.Section // by name
+	sectionNames map[string]string           // section ID to name
+	vrfs         map[string]*phpipam.VRF     // by name
+	vrfNames     map[int]string              // VRF ID to name
+	domains      map[string]*phpipam.L2Domain
+	domainNames  map[string]string           // L2 domain ID to name
+	vlans        map[string]*phpipam.VLAN    // by VLAN key
+	vlanKeys     map[int]string              // VLAN ID to key
+	subnets      map[string]*currentSubnet   // by subnet key
+	subnetIDs    map[int]*currentSubnet      // by subnet ID
+	addresses    map[string]*phpipam.Address // by address key
+
+	// duplicates lists subnets whose key another subnet already has
+	duplicates []error
+}
+
+// currentSubnet is a subnet in phpIPAM with its resolved natural key parts
+type currentSubnet struct {
+	subnet  *phpipam.Subnet
+	section string
+	vrf     string
+	prefix  netip.Prefix
+}
+
+// newState creates an empty state
+func newState() *state {
+	return &state{
+		sections:     make(map[string]*phpipam.Section),
+		sectionNames: make(map[string]string),
+		vrfs:         make(map[string]*phpipam.VRF),
+		vrfNames:     make(map[int]string),
+		domains:      make(map[string]*phpipam.L2Domain),
+		domainNames:  make(map[string]string),
+		vlans:        make(map[string]*phpipam.VLAN),
+		vlanKeys:     make(map[int]string),
+		subnets:      make(map[string]*currentSubnet),
+		subnetIDs:    make(map[int]*currentSubnet),
+		addresses:    make(map[string]*phpipam.Address),
+	}
+}
+
+// readState fetches the objects the document refers to. Subnets and addresses are
+// only read for the sections the document uses, and addresses only for subnets
+// holding declared addresses unless prune is set.
+func readState(api *phpipam.PHPIPAM, doc *inventory.Document, prune bool) (*state, error) {
+	st := newState()
+
+	sections, err := api.Sections.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sections {
+		st.addSection(&sections[i])
+	}
+
+	vrfs, err := api.VRFs.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range vrfs {
+		st.addVRF(&vrfs[i])
+	}
+
+	domains, err := api.L2Domains.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range domains {
+		st.addDomain(&domains[i])
+	}
+	for i := range domains {
+		vlans, err := api.L2Domains.GetVLANs(domains[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("L2 domain %s: %w", domains[i].Name, err)
+		}
+		for j := range vlans {
+			if err := st.addVLAN(&vlans[j]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, name := range documentSections(doc) {
+		section, ok := st.sections[name]
+		if !ok {
+			continue
+		}
+		subnets, err := api.Sections.GetSubnets(section.ID)
+		if err != nil {
+			return nil, fmt.Errorf("section %s: %w", name, err)
+		}
+		for i := range subnets {
+			if subnets[i].IsFolder == 1 {
+				continue
+			}
+			if err := st.addSubnet(&subnets[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(st.duplicates) > 0 {
+		return nil, fmt.Errorf("subnets share a prefix in one section and VRF, rename or merge them in phpIPAM: %w", errors.Join(st.duplicates...))
+	}
+
+	desired := desiredAddresses(doc)
+	keys := make([]string, 0, len(st.subnets))
+	for key := range st.subnets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		current := st.subnets[key]
+		if !prune && !current.holdsAny(desired) {
+			continue
+		}
+		addresses, err := api.Subnets.GetAddresses(current.subnet.ID)
+		if err != nil {
+			return nil, fmt.Errorf("subnet %s: %w", key, err)
+		}
+		for i := range addresses {
+			st.addAddress(current, &addresses[i])
+		}
+	}
+
+	return st, nil
+}
+
+// documentSections returns the names of all sections the document declares or uses
+func documentSections(doc *inventory.Document) []string {
+	names := make(map[string]bool)
+	for i := range doc.Sections {
+		names[doc.Sections[i].Name] = true
+	}
+	for i := range doc.Subnets {
+		names[doc.Subnets[i].Section] = true
+	}
+	for i := range doc.Addresses {
+		names[doc.Addresses[i].Section] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// desiredAddress is a declared address with its parsed IP
+type desiredAddress struct {
+	section string
+	vrf     string
+	addr    netip.Addr
+}
+
+// desiredAddresses parses the addresses of a normalized document
+func desiredAddresses(doc *inventory.Document) []desiredAddress {
+	addresses := make([]desiredAddress, 0, len(doc.Addresses))
+	for i := range doc.Addresses {
+		addr, err := doc.Addresses[i].Addr()
+		if err != nil {
+			continue
+		}
+		addresses = append(addresses, desiredAddress{section: doc.Addresses[i].Section, vrf: doc.Addresses[i].VRF, addr: addr})
+	}
+	return addresses
+}
+
+// holdsAny reports whether any of the addresses belongs in the subnet
+func (c *currentSubnet) holdsAny(addresses []desiredAddress) bool {
+	for _, a := range addresses {
+		if a.section == c.section && a.vrf == c.vrf && c.prefix.Contains(a.addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// addSection indexes a section
+func (st *state) addSection(section *phpipam.Section) {
+	st.sections[section.Name] = section
+	st.sectionNames[section.ID] = section.Name
+}
+
+// addVRF indexes a VRF
+func (st *state) addVRF(vrf *phpipam.VRF) {
+	st.vrfs[vrf.Name] = vrf
+	if id, err := strconv.Atoi(vrf.ID); err == nil {
+		st.vrfNames[id] = vrf.Name
+	}
+}
+
+// addDomain indexes an L2 domain
+func (st *state) addDomain(domain *phpipam.L2Domain) {
+	st.domains[domain.Name] = domain
+	st.domainNames[domain.ID] = domain.Name
+}
+
+// addVLAN indexes a VLAN under its domain name and number
+func (st *state) addVLAN(vlan *phpipam.VLAN) error {
+	number, err := vlan.VLANNumber()
+	if err != nil {
+		return fmt.Errorf("VLAN %s: %w", vlan.ID, err)
+	}
+	domain, ok := st.domainNames[vlan.DomainID]
+	if !ok {
+		domain = "#" + vlan.DomainID
+	}
+
+	key := inventory.VLANKey(domain, number)
+	st.vlans[key] = vlan
+	if id, err := strconv.Atoi(vlan.ID); err == nil {
+		st.vlanKeys[id] = key
+	}
+	return nil
+}
+
+// sectionName returns the name of a section ID
+func (st *state) sectionName(id int) string {
+	if name, ok := st.sectionNames[strconv.Itoa(id)]; ok {
+		return name
+	}
+	return "#" + strconv.Itoa(id)
+}
+
+// vrfName returns the VRF name of a subnet, "" for none
+func (st *state) vrfName(subnet *phpipam.Subnet) string {
+	id, ok := subnet.GetVrfID()
+	if !ok || id == 0 {
+		return ""
+	}
+	if name, ok := st.vrfNames[id]; ok {
+		return name
+	}
+	return "#" + strconv.Itoa(id)
+}
+
+// addSubnet indexes a subnet under its section, VRF and CIDR. A second subnet
+// with the same key is recorded as a duplicate, since changes could not tell
+// the two apart.
+func (st *state) addSubnet(subnet *phpipam.Subnet) error {
+	prefix, err := subnet.Prefix()
+	if err != nil {
+		return fmt.Errorf("subnet %d: %w", subnet.ID, err)
+	}
+	current := &currentSubnet{
+		subnet:  subnet,
+		section: st.sectionName(subnet.SectionID),
+		vrf:     st.vrfName(subnet),
+		prefix:  prefix,
+	}
+	key := inventory.SubnetKey(current.section, current.vrf, prefix.String())
+	if existing, ok := st.subnets[key]; ok && existing.subnet.ID != subnet.ID {
+		st.duplicates = append(st.duplicates, fmt.Errorf("subnet %s: IDs %d and %d", key, existing.subnet.ID, subnet.ID))
+		return nil
+	}
+	st.putSubnet(key, current)
+	return nil
+}
+
+// putSubnet indexes a subnet under its key and ID
+func (st *state) putSubnet(key string, current *currentSubnet) {
+	st.subnets[key] = current
+	st.subnetIDs[current.subnet.ID] = current
+}
+
+// addAddress indexes an address of a subnet
+func (st *state) addAddress(subnet *currentSubnet, address *phpipam.Address) {
+	addr, err := address.Addr()
+	if err != nil {
+		return
+	}
+	key := inventory.AddressKey(subnet.section, subnet.vrf, addr.String())
+
+	// An address in both a subnet and its child belongs to the more specific one
+	if existing, ok := st.addresses[key]; ok {
+		if parent, ok := st.subnetIDs[existing.SubnetID]; ok && parent.prefix.Bits() > subnet.prefix.Bits() {
+			return
+		}
+	}
+	st.addresses[key] = address
+}
@@ -0,0 +1,82 @@
+// Package report holds the helpers the importers and reconcilers use to count
+// and render the outcome of each object they handle.
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Count returns the number of items that match
+func Count[T any](items []T, match func(T) bool) int {
+	n := 0
+	for _, item := range items {
+		if match(item) {
+			n++
+		}
+	}
+	return n
+}
+
+// Tally is a count with the word it is reported with, e.g. {3, "created"}
+type Tally struct {
+	N     int
+	Label string
+}
+
+// Summary returns the closing line of a report, e.g.
+// "Import: 3 created, 0 failed." or "Dry run: ..." when nothing was changed
+func Summary(verb string, dryRun bool, tallies ...Tally) string {
+	if dryRun {
+		verb = "Dry run"
+	}
+	parts := make([]string, len(tallies))
+	for i, t := range tallies {
+		parts[i] = fmt.Sprintf("%d %s", t.N, t.Label)
+	}
+	return verb + ": " + strings.Join(parts, ", ") + ".\n"
+}
+
+// String returns what render writes
+func String(render func(w io.Writer) error) string {
+	var b strings.Builder
+	render(&b)
+	return b.String()
+}
+
+// Render builds a report in memory and writes it to w in one call
+func Render(w io.Writer, build func(b *strings.Builder)) error {
+	var b strings.Builder
+	build(&b)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// FieldDiff is a field whose value differs between phpIPAM and a source
+type FieldDiff struct {
+	// Field is the phpIPAM API field name
+	Field string
+	Old   string
+	New   string
+}
+
+// WriteDiffs writes one indented line per diff, `field: "old" -> "new"`, or
+// `field: "new"` for an object being created
+func WriteDiffs(b *strings.Builder, diffs []FieldDiff, create bool) {
+	for _, diff := range diffs {
+		if create {
+			fmt.Fprintf(b, "    %s: %q\n", diff.Field, diff.New)
+		} else {
+			fmt.Fprintf(b, "    %s: %q -> %q\n", diff.Field, diff.Old, diff.New)
+		}
+	}
+}
+
+// WriteConflicts writes one indented line per value that differs from phpIPAM
+// and was kept, e.g. `mac: kept "old", scan found "new"` for source "scan found"
+func WriteConflicts(b *strings.Builder, conflicts []FieldDiff, source string) {
+	for _, diff := range conflicts {
+		fmt.Fprintf(b, "    %s: kept %q, %s %q\n", diff.Field, diff.Old, source, diff.New)
+	}
+}
@@ -7,19 +7,19 @@ import (
 
 // Section represents a phpIPAM section object
 type Section struct {
-	ID               string `json:"id,omitempty"`
-	Name             string `json:"name"`
-	Description      string `json:"description,omitempty"`
-	MasterSection    int    `json:"masterSection,omitempty"`
-	Permissions      string `json:"permissions,omitempty"`
-	StrictMode       int    `json:"strictMode,omitempty"`
-	SubnetOrdering   string `json:"subnetOrdering,omitempty"`
-	Order            int    `json:"order,omitempty"`
-	EditDate         string `json:"editDate,omitempty"`
-	ShowVLAN         int    `json:"showVLAN,omitempty"`
-	ShowVRF          int    `json:"showVRF,omitempty"`
-	ShowSupernetOnly int    `json:"showSupernetOnly,omitempty"`
-	DNS              string `json:"DNS,omitempty"`
+	ID               string      `json:"id,omitempty"`
+	Name             string      `json:"name"`
+	Description      string      `json:"description,omitempty"`
+	MasterSection    int         `json:"masterSection,omitempty"`
+	Permissions      Permissions `json:"permissions,omitempty"`
+	StrictMode       int         `json:"strictMode,omitempty"`
+	SubnetOrdering   string      `json:"subnetOrdering,omitempty"`
+	Order            int         `json:"order,omitempty"`
+	EditDate         string      `json:"editDate,omitempty"`
+	ShowVLAN         int         `json:"showVLAN,omitempty"`
+	ShowVRF          int         `json:"showVRF,omitempty"`
+	ShowSupernetOnly int         `json:"showSupernetOnly,omitempty"`
+	DNS              string      `json:"DNS,omitempty"`
 }
 
 // CustomField represents a custom field definition
@@ -70,6 +70,9 @@ func (s *SectionsService) Create(section *Section) (*Section, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the section data, retrieve the full section
 	if resp.ID != 0 && createdSection.ID == "" {
@@ -90,10 +93,29 @@ func (s *SectionsService) Update(section *Section) (*Section, error) {
 	return &updatedSection, err
 }
 
+// Patch sends a partial update for a section, including fields set to zero or empty
+func (s *SectionsService) Patch(id string, patch *Patch) error {
+	if id == "" {
+		return fmt.Errorf("section ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("section patch contains no fields")
+	}
+
+	resp, err := s.client.Request("PATCH", fmt.Sprintf("sections/%s", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
 // Delete deletes a section
 func (s *SectionsService) Delete(id string) error {
-	_, err := s.client.Request("DELETE", fmt.Sprintf("sections/%s", id), nil, nil)
-	return err
+	resp, err := s.client.Request("DELETE", fmt.Sprintf("sections/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // GetSubnets returns all subnets in a section
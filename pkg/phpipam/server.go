@@ -0,0 +1,201 @@
+package phpipam
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnsupported is returned for endpoints the phpIPAM server does not provide,
+// either because its version predates them or because the module is disabled
+var ErrUnsupported = errors.New("endpoint not supported by phpIPAM server")
+
+// Capability identifies an optional phpIPAM API controller whose availability
+// differs between phpIPAM versions and installations
+type Capability string
+
+const (
+	// CapabilityFolders is the folders controller
+	CapabilityFolders Capability = "folders"
+	// CapabilityLocations is the tools/locations subcontroller
+	CapabilityLocations Capability = "tools/locations"
+	// CapabilityRacks is the tools/racks subcontroller
+	CapabilityRacks Capability = "tools/racks"
+	// CapabilityNAT is the tools/nat subcontroller
+	CapabilityNAT Capability = "tools/nat"
+	// CapabilityCircuits is the circuits controller
+	CapabilityCircuits Capability = "circuits"
+)
+
+// Capabilities lists all capabilities probed by DetectServer
+var Capabilities = []Capability{
+	CapabilityFolders,
+	CapabilityLocations,
+	CapabilityRacks,
+	CapabilityNAT,
+	CapabilityCircuits,
+}
+
+// ServerInfo describes the phpIPAM server the client talks to. The API does
+// not report the phpIPAM version, so features are detected by capability only.
+type ServerInfo struct {
+	// Capabilities records which optional controllers the server provides
+	Capabilities map[Capability]bool
+	// DetectedAt is when the capabilities were probed
+	DetectedAt time.Time
+}
+
+// Supports reports whether the server provides the given capability
+func (s *ServerInfo) Supports(capability Capability) bool {
+	return s.Capabilities[capability]
+}
+
+// serverState holds the detected server information of a client
+type serverState struct {
+	mu   sync.Mutex
+	info *ServerInfo
+	// probed holds capabilities probed one at a time by checkSupported
+	probed map[Capability]bool
+}
+
+// lookup returns the cached support of a capability and whether it is known
+func (s *serverState) lookup(capability Capability) (supported, known bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.info != nil {
+		supported, known = s.info.Capabilities[capability]
+		if known {
+			return supported, true
+		}
+	}
+	supported, known = s.probed[capability]
+	return supported, known
+}
+
+// store caches the support of a single capability
+func (s *serverState) store(capability Capability, supported bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.probed == nil {
+		s.probed = map[Capability]bool{}
+	}
+	s.probed[capability] = supported
+}
+
+// ServerInfo returns the server information, probing the server on first use
+func (c *Client) ServerInfo() (*ServerInfo, error) {
+	c.server.mu.Lock()
+	info := c.server.info
+	c.server.mu.Unlock()
+
+	if info != nil {
+		return info, nil
+	}
+	return c.DetectServer()
+}
+
+// DetectServer probes the server for all optional controllers and caches the
+// result. Once detected, requests to controllers the server lacks fail with
+// ErrUnsupported without being sent. Without it, Request probes only the
+// controller it is about to use, before its first request.
+func (c *Client) DetectServer() (*ServerInfo, error) {
+	info := &ServerInfo{
+		Capabilities: map[Capability]bool{},
+		DetectedAt:   time.Now(),
+	}
+
+	for _, capability := range Capabilities {
+		supported, err := c.probe(capability)
+		if err != nil {
+			return nil, fmt.Errorf("failed to probe %s: %w", capability, err)
+		}
+		info.Capabilities[capability] = supported
+	}
+
+	c.server.mu.Lock()
+	c.server.info = info
+	c.server.mu.Unlock()
+
+	return info, nil
+}
+
+// probe reports whether the server provides a controller by listing it. Only
+// the answers phpIPAM gives for a missing or disabled controller count as
+// unsupported; any other failure is returned as an error.
+func (c *Client) probe(capability Capability) (bool, error) {
+	if err := c.EnsureAuthenticated(); err != nil {
+		return false, err
+	}
+	req, err := c.newRequest("GET", string(capability), nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.do(req, nil)
+	var statusErr *statusError
+	switch {
+	case errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusNotImplemented):
+		// Servers that predate the controller may answer with a plain error page
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return !isUnsupportedResponse(resp), nil
+}
+
+// checkSupported returns ErrUnsupported when the server lacks the controller
+// an endpoint belongs to, probing that controller on its first request
+func (c *Client) checkSupported(endpoint string) error {
+	endpoint = strings.Trim(endpoint, "/")
+	capability, ok := capabilityOf(endpoint)
+	if !ok {
+		return nil
+	}
+
+	supported, known := c.server.lookup(capability)
+	if !known {
+		var err error
+		supported, err = c.probe(capability)
+		if err != nil {
+			// A failed probe (e.g. a timeout) says nothing about the controller,
+			// so the request is sent and reports its own error
+			return nil
+		}
+		c.server.store(capability, supported)
+	}
+	if !supported {
+		return fmt.Errorf("%w: %s", ErrUnsupported, endpoint)
+	}
+	return nil
+}
+
+// capabilityOf returns the optional controller an endpoint belongs to
+func capabilityOf(endpoint string) (Capability, bool) {
+	for _, capability := range Capabilities {
+		if endpoint == string(capability) || strings.HasPrefix(endpoint, string(capability)+"/") {
+			return capability, true
+		}
+	}
+	return "", false
+}
+
+// isUnsupportedResponse reports whether the response to a controller probe
+// means the controller does not exist ("Invalid controller" and "Invalid
+// subcontroller" are 400) or its module is disabled (503)
+func isUnsupportedResponse(resp *Response) bool {
+	if resp.Success {
+		return false
+	}
+	return resp.Code == http.StatusBadRequest || resp.Code == http.StatusServiceUnavailable
+}
+
+// ServerInfo returns the phpIPAM server information, probing the server on first use
+func (p *PHPIPAM) ServerInfo() (*ServerInfo, error) {
+	return p.Client.ServerInfo()
+}
@@ -0,0 +1,185 @@
+package phpipam
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingServer serves responses from handle and counts requests per endpoint
+func countingServer(t *testing.T, handle func(endpoint string, n int) (int, string)) (*PHPIPAM, func(endpoint string) int) {
+	t.Helper()
+	var mu sync.Mutex
+	counts := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/test/"), "/")
+		mu.Lock()
+		counts[endpoint]++
+		n := counts[endpoint]
+		mu.Unlock()
+
+		status, body := handle(endpoint, n)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := NewTokenClient(srv.URL+"/api/", "test", "token", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api, func(endpoint string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return counts[endpoint]
+	}
+}
+
+const (
+	emptyList            = `{"code":200,"success":true,"data":[]}`
+	invalidSubcontroller = `{"code":400,"success":false,"message":"Invalid subcontroller"}`
+)
+
+func TestIsUnsupportedResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		resp Response
+		want bool
+	}{
+		{"success", Response{Code: 200, Success: true}, false},
+		{"no entries", Response{Code: 404, Success: false, Message: "No objects found"}, false},
+		{"invalid controller", Response{Code: 400, Success: false, Message: "Invalid controller"}, true},
+		{"module disabled", Response{Code: 503, Success: false, Message: "Racks module disabled"}, true},
+		{"unauthorized", Response{Code: 401, Success: false, Message: "Invalid token"}, false},
+		{"server error", Response{Code: 500, Success: false}, false},
+	}
+	for _, tt := range tests {
+		if got := isUnsupportedResponse(&tt.resp); got != tt.want {
+			t.Errorf("%s: isUnsupportedResponse = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSupportedProbesRequestedController(t *testing.T) {
+	api, count := countingServer(t, func(endpoint string, n int) (int, string) {
+		if endpoint == "tools/nat" {
+			return http.StatusOK, invalidSubcontroller
+		}
+		return http.StatusOK, emptyList
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := api.Tools.GetRacks(); err != nil {
+			t.Fatalf("GetRacks: %v", err)
+		}
+	}
+	// One probe, then one request per call
+	if got := count("tools/racks"); got != 3 {
+		t.Errorf("tools/racks requested %d times, want 3", got)
+	}
+	for _, capability := range []Capability{CapabilityFolders, CapabilityLocations, CapabilityNAT, CapabilityCircuits} {
+		if got := count(string(capability)); got != 0 {
+			t.Errorf("%s probed %d times before being used", capability, got)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := api.Client.Request("GET", "tools/nat/", nil, nil)
+		if !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("request to missing controller: err = %v, want ErrUnsupported", err)
+		}
+	}
+	if got := count("tools/nat"); got != 1 {
+		t.Errorf("tools/nat requested %d times, want only the probe", got)
+	}
+}
+
+func TestCheckSupportedFailedProbeSendsRequest(t *testing.T) {
+	api, count := countingServer(t, func(endpoint string, n int) (int, string) {
+		if n == 1 {
+			return http.StatusBadGateway, "<html>Bad Gateway</html>"
+		}
+		return http.StatusOK, `{"code":200,"success":true,"data":[{"id":"1","name":"r1"}]}`
+	})
+
+	racks, err := api.Tools.GetRacks()
+	if err != nil {
+		t.Fatalf("GetRacks after failed probe: %v", err)
+	}
+	if len(racks) != 1 || racks[0].Name != "r1" {
+		t.Errorf("racks = %+v", racks)
+	}
+
+	// The failed probe is not cached, so the next request probes again
+	if _, err := api.Tools.GetRacks(); err != nil {
+		t.Fatal(err)
+	}
+	if got := count("tools/racks"); got != 4 {
+		t.Errorf("tools/racks requested %d times, want 4", got)
+	}
+}
+
+func TestServerInfoCaching(t *testing.T) {
+	api, count := countingServer(t, func(endpoint string, n int) (int, string) {
+		switch endpoint {
+		case "tools/racks":
+			return http.StatusOK, invalidSubcontroller
+		case "circuits":
+			return http.StatusNotFound, "<html>Not Found</html>"
+		}
+		return http.StatusOK, emptyList
+	})
+
+	info, err := api.ServerInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[Capability]bool{
+		CapabilityFolders:   true,
+		CapabilityLocations: true,
+		CapabilityRacks:     false,
+		CapabilityNAT:       true,
+		CapabilityCircuits:  false,
+	}
+	for capability, supported := range want {
+		if info.Supports(capability) != supported {
+			t.Errorf("Supports(%s) = %v, want %v", capability, !supported, supported)
+		}
+	}
+
+	again, err := api.ServerInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != info {
+		t.Error("second ServerInfo call did not return the cached info")
+	}
+	if _, err := api.Tools.GetRacks(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("GetRacks: err = %v, want ErrUnsupported", err)
+	}
+	for capability := range want {
+		if got := count(string(capability)); got != 1 {
+			t.Errorf("%s probed %d times, want 1", capability, got)
+		}
+	}
+
+	if _, err := api.Client.DetectServer(); err != nil {
+		t.Fatal(err)
+	}
+	if got := count("folders"); got != 2 {
+		t.Errorf("DetectServer did not probe again: folders requested %d times", got)
+	}
+}
+
+func TestDetectServerProbeError(t *testing.T) {
+	api, _ := countingServer(t, func(endpoint string, n int) (int, string) {
+		return http.StatusUnauthorized, "<html>Unauthorized</html>"
+	})
+
+	if _, err := api.ServerInfo(); err == nil {
+		t.Fatal("ServerInfo succeeded although every probe failed")
+	}
+}
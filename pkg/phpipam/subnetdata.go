@@ -0,0 +1,48 @@
+package phpipam
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SubnetData is a subnet with its addresses and the entries of its nameserver
+// set, the data zone files and DHCP reservations are built from
+type SubnetData struct {
+	Subnet    Subnet
+	Addresses []Address
+	// Nameservers are the entries of the subnet's nameserver set, names or IPs
+	Nameservers []string
+}
+
+// GetSubnetData reads the addresses and nameserver entries of the given
+// subnets. Folders and repeated subnets are skipped, and each nameserver set
+// is read once.
+func (p *PHPIPAM) GetSubnetData(subnets []Subnet) ([]SubnetData, error) {
+	nameservers := make(map[int][]string)
+	seen := make(map[int]bool)
+	var data []SubnetData
+	for _, subnet := range subnets {
+		if subnet.IsFolder == 1 || seen[subnet.ID] {
+			continue
+		}
+		seen[subnet.ID] = true
+
+		addresses, err := p.Subnets.GetAddresses(subnet.ID)
+		if err != nil {
+			return nil, fmt.Errorf("subnet %s: %w", subnet.CIDR(), err)
+		}
+
+		if id := subnet.NameserverID; id != 0 {
+			if _, ok := nameservers[id]; !ok {
+				ns, err := p.Tools.GetNameserver(strconv.Itoa(id))
+				if err != nil {
+					return nil, fmt.Errorf("nameserver set %d: %w", id, err)
+				}
+				nameservers[id] = ns.Entries()
+			}
+		}
+
+		data = append(data, SubnetData{Subnet: subnet, Addresses: addresses, Nameservers: nameservers[subnet.NameserverID]})
+	}
+	return data, nil
+}
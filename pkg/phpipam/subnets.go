@@ -3,43 +3,46 @@ package phpipam
 import (
 	"encoding/json"
 	"fmt"
+	"net/netip"
 	"strconv"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
 )
 
 // Subnet represents a phpIPAM subnet object with fields matching API response
 type Subnet struct {
-	ID                    int             `json:"id,omitempty"`
-	Subnet                string          `json:"subnet,omitempty"`
-	Mask                  string          `json:"mask,omitempty"`
-	SectionID             int             `json:"sectionId,omitempty"`
-	Description           string          `json:"description,omitempty"`
-	LinkedSubnet          interface{}     `json:"linked_subnet,omitempty"`
-	FirewallAddressObject interface{}     `json:"firewallAddressObject,omitempty"`
-	VrfID                 interface{}     `json:"vrfId,omitempty"`
-	MasterSubnetID        int             `json:"masterSubnetId,omitempty"`
-	AllowRequests         int             `json:"allowRequests,omitempty"`
-	VlanID                interface{}     `json:"vlanId,omitempty"`
-	ShowName              int             `json:"showName,omitempty"`
-	Device                interface{}     `json:"device,omitempty"`
-	Permissions           json.RawMessage `json:"permissions,omitempty"` // Using raw json.RawMessage directly
-	PingSubnet            int             `json:"pingSubnet,omitempty"`
-	DiscoverSubnet        int             `json:"discoverSubnet,omitempty"`
-	ResolveDNS            int             `json:"resolveDNS,omitempty"`
-	DNSRecursive          int             `json:"DNSrecursive,omitempty"`
-	DNSRecords            int             `json:"DNSrecords,omitempty"`
-	NameserverID          int             `json:"nameserverId,omitempty"`
-	ScanAgent             int             `json:"scanAgent,omitempty"`
-	CustomerID            interface{}     `json:"customer_id,omitempty"`
-	IsFolder              int             `json:"isFolder,omitempty"`
-	IsFull                int             `json:"isFull,omitempty"`
-	IsPool                int             `json:"isPool,omitempty"`
-	Tag                   int             `json:"tag,omitempty"`
-	Threshold             int             `json:"threshold,omitempty"`
-	Location              interface{}     `json:"location,omitempty"`
-	EditDate              interface{}     `json:"editDate,omitempty"`
-	LastScan              interface{}     `json:"lastScan,omitempty"`
-	LastDiscovery         interface{}     `json:"lastDiscovery,omitempty"`
-	Calculation           interface{}     `json:"calculation,omitempty"`
+	ID                    int         `json:"id,omitempty"`
+	Subnet                string      `json:"subnet,omitempty"`
+	Mask                  string      `json:"mask,omitempty"`
+	SectionID             int         `json:"sectionId,omitempty"`
+	Description           string      `json:"description,omitempty"`
+	LinkedSubnet          interface{} `json:"linked_subnet,omitempty"`
+	FirewallAddressObject interface{} `json:"firewallAddressObject,omitempty"`
+	VrfID                 interface{} `json:"vrfId,omitempty"`
+	MasterSubnetID        int         `json:"masterSubnetId,omitempty"`
+	AllowRequests         int         `json:"allowRequests,omitempty"`
+	VlanID                interface{} `json:"vlanId,omitempty"`
+	ShowName              int         `json:"showName,omitempty"`
+	Device                interface{} `json:"device,omitempty"`
+	Permissions           Permissions `json:"permissions,omitempty"`
+	PingSubnet            int         `json:"pingSubnet,omitempty"`
+	DiscoverSubnet        int         `json:"discoverSubnet,omitempty"`
+	ResolveDNS            int         `json:"resolveDNS,omitempty"`
+	DNSRecursive          int         `json:"DNSrecursive,omitempty"`
+	DNSRecords            int         `json:"DNSrecords,omitempty"`
+	NameserverID          int         `json:"nameserverId,omitempty"`
+	ScanAgent             int         `json:"scanAgent,omitempty"`
+	CustomerID            interface{} `json:"customer_id,omitempty"`
+	IsFolder              int         `json:"isFolder,omitempty"`
+	IsFull                int         `json:"isFull,omitempty"`
+	IsPool                int         `json:"isPool,omitempty"`
+	Tag                   int         `json:"tag,omitempty"`
+	Threshold             int         `json:"threshold,omitempty"`
+	Location              interface{} `json:"location,omitempty"`
+	EditDate              interface{} `json:"editDate,omitempty"`
+	LastScan              interface{} `json:"lastScan,omitempty"`
+	LastDiscovery         interface{} `json:"lastDiscovery,omitempty"`
+	Calculation           interface{} `json:"calculation,omitempty"`
 }
 
 // SubnetUsage represents usage statistics for a subnet
@@ -53,6 +56,11 @@ type SubnetUsage struct {
 // SubnetsService handles communication with the subnets related methods of the API
 type SubnetsService struct {
 	client *Client
+
+	// CheckOverlaps makes Create look for duplicate and overlapping subnets in
+	// the same VRF and section first (see FindOverlaps) and fail with an
+	// *OverlapError instead of sending the request
+	CheckOverlaps bool
 }
 
 // NewSubnetsService creates a new subnets service with the provided client
@@ -62,84 +70,61 @@ func NewSubnetsService(client *Client) *SubnetsService {
 
 // Helper methods for working with permissions
 
-// SetPermissionsString sets permissions using a JSON string
-func (s *Subnet) SetPermissionsString(permissions string) {
-	// Check if the string is already JSON formatted
-	if len(permissions) > 0 && permissions[0] == '{' {
-		// It's already a JSON object, no need for quotes
-		s.Permissions = json.RawMessage(permissions)
-	} else {
-		// For simple quoted string like "{\"3\":\"1\",\"2\":\"2\"}"
-		// We need to ensure it's treated as a JSON string by wrapping in quotes
-		jsonStr := fmt.Sprintf("%q", permissions)
-		s.Permissions = json.RawMessage(jsonStr)
+// SetPermissionsString sets permissions from the JSON form stored by phpIPAM.
+// Invalid permissions leave the field unchanged and return the parse error.
+//
+// Deprecated: assign a Permissions value to the Permissions field instead.
+func (s *Subnet) SetPermissionsString(permissions string) error {
+	// Accept both the plain object and the quoted string form
+	var unquoted string
+	if err := json.Unmarshal([]byte(permissions), &unquoted); err == nil {
+		permissions = unquoted
 	}
-}
 
-// SetPermissionsObject sets permissions using a map or array
-func (s *Subnet) SetPermissionsObject(permissions interface{}) error {
-	bytes, err := json.Marshal(permissions)
+	parsed, err := ParsePermissions(permissions)
 	if err != nil {
 		return err
 	}
-	s.Permissions = bytes
+	s.Permissions = parsed
 	return nil
 }
 
-// GetPermissionsAsString attempts to get permissions as a string
-func (s *Subnet) GetPermissionsAsString() (string, error) {
-	if s.Permissions == nil {
-		return "", nil
-	}
-
-	// Try to unmarshal as a string first
-	var strVal string
-	err := json.Unmarshal(s.Permissions, &strVal)
-	if err == nil {
-		return strVal, nil
-	}
-
-	// If that fails, it might be a map or array that we need to stringify
-	var objVal interface{}
-	err = json.Unmarshal(s.Permissions, &objVal)
+// SetPermissionsObject sets permissions using a map of group IDs to levels
+//
+// Deprecated: assign a Permissions value to the Permissions field instead.
+func (s *Subnet) SetPermissionsObject(permissions interface{}) error {
+	bytes, err := json.Marshal(permissions)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse permissions: %w", err)
+		return err
 	}
 
-	// Convert the object to a JSON string
-	jsonBytes, err := json.Marshal(objVal)
+	parsed, err := ParsePermissions(string(bytes))
 	if err != nil {
-		return "", fmt.Errorf("failed to stringify permissions: %w", err)
+		return err
 	}
-
-	return string(jsonBytes), nil
+	s.Permissions = parsed
+	return nil
 }
 
-// GetPermissionsAsMap attempts to get permissions as a map
-func (s *Subnet) GetPermissionsAsMap() (map[string]string, error) {
+// GetPermissionsAsString returns permissions in the JSON form stored by phpIPAM
+//
+// Deprecated: use Permissions.String instead.
+func (s *Subnet) GetPermissionsAsString() (string, error) {
 	if s.Permissions == nil {
-		return map[string]string{}, nil
-	}
-
-	// Try direct unmarshaling to map first
-	var mapVal map[string]string
-	err := json.Unmarshal(s.Permissions, &mapVal)
-	if err == nil {
-		return mapVal, nil
+		return "", nil
 	}
+	return s.Permissions.String(), nil
+}
 
-	// If that fails, it might be a JSON string that contains a map
-	var strVal string
-	err = json.Unmarshal(s.Permissions, &strVal)
-	if err == nil {
-		// Try to unmarshal the string content as a map
-		err = json.Unmarshal([]byte(strVal), &mapVal)
-		if err == nil {
-			return mapVal, nil
-		}
+// GetPermissionsAsMap returns permissions as a map of group IDs to numeric levels
+//
+// Deprecated: use the Permissions field directly.
+func (s *Subnet) GetPermissionsAsMap() (map[string]string, error) {
+	permissions := make(map[string]string, len(s.Permissions))
+	for group, level := range s.Permissions {
+		permissions[strconv.Itoa(group)] = strconv.Itoa(int(level))
 	}
-
-	return nil, fmt.Errorf("permissions not in expected format")
+	return permissions, nil
 }
 
 // Helper methods for nullable fields
@@ -204,6 +189,26 @@ func (s *Subnet) GetLocationID() (int, bool) {
 	return 0, false
 }
 
+// GetDeviceID returns the device ID as an integer if possible
+func (s *Subnet) GetDeviceID() (int, bool) {
+	if s.Device == nil {
+		return 0, false
+	}
+	switch v := s.Device.(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	case string:
+		// Try to parse as int
+		intVal, err := strconv.Atoi(v)
+		if err == nil {
+			return intVal, true
+		}
+	}
+	return 0, false
+}
+
 // SetVrfID sets the VRF ID (handles nil case)
 func (s *Subnet) SetVrfID(id int) {
 	if id == 0 {
@@ -231,6 +236,37 @@ func (s *Subnet) SetLocationID(id int) {
 	}
 }
 
+// Helper methods for IP arithmetic
+
+// Prefix returns the subnet as a netip.Prefix. Folders have no prefix and return an error.
+func (s *Subnet) Prefix() (netip.Prefix, error) {
+	if s.IsFolder == 1 {
+		return netip.Prefix{}, fmt.Errorf("subnet %d is a folder", s.ID)
+	}
+	return ipcalc.ParsePrefix(s.Subnet, s.Mask)
+}
+
+// CIDR returns the subnet in CIDR notation, e.g. "10.0.0.0/24"
+func (s *Subnet) CIDR() string {
+	return s.Subnet + "/" + s.Mask
+}
+
+// SubnetPrefixes returns the prefixes of the given subnets, skipping folders
+func SubnetPrefixes(subnets []Subnet) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(subnets))
+	for i := range subnets {
+		if subnets[i].IsFolder == 1 {
+			continue
+		}
+		prefix, err := subnets[i].Prefix()
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
 // List returns all subnets
 func (s *SubnetsService) List() ([]Subnet, error) {
 	var subnets []Subnet
@@ -340,11 +376,24 @@ func (s *SubnetsService) GetOverlapping(cidr string) ([]Subnet, error) {
 
 // Create creates a new subnet
 func (s *SubnetsService) Create(subnet *Subnet) (*Subnet, error) {
+	if s.CheckOverlaps {
+		conflicts, err := s.FindOverlaps(subnet, OverlapOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("overlap check failed: %w", err)
+		}
+		if len(conflicts) > 0 {
+			return nil, &OverlapError{Subnet: subnet.CIDR(), Conflicts: conflicts}
+		}
+	}
+
 	var createdSubnet Subnet
 	resp, err := s.client.Request("POST", "subnets", subnet, &createdSubnet)
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the subnet data, retrieve the full subnet
 	if resp.ID != 0 && createdSubnet.ID == 0 {
@@ -361,6 +410,9 @@ func (s *SubnetsService) CreateFirstSubnet(id int, mask int, subnet *Subnet) (*S
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the subnet data, retrieve the full subnet
 	if resp.ID != 0 && createdSubnet.ID == 0 {
@@ -370,6 +422,30 @@ func (s *SubnetsService) CreateFirstSubnet(id int, mask int, subnet *Subnet) (*S
 	return &createdSubnet, nil
 }
 
+// CreateFolder creates a folder through the folders controller. The folder's
+// Description is its name; MasterSubnetID places it inside another folder.
+func (s *SubnetsService) CreateFolder(folder *Subnet) (*Subnet, error) {
+	if folder.SectionID == 0 || folder.Description == "" {
+		return nil, fmt.Errorf("section ID and description are required for folder")
+	}
+
+	var createdFolder Subnet
+	resp, err := s.client.Request("POST", "folders", folder, &createdFolder)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+
+	// If we got an ID in the response but not in the folder data, retrieve the full folder
+	if resp.ID != 0 && createdFolder.ID == 0 {
+		return s.Get(resp.ID.Int())
+	}
+
+	return &createdFolder, nil
+}
+
 // Update updates an existing subnet
 func (s *SubnetsService) Update(subnet *Subnet) (*Subnet, error) {
 	if subnet.ID == 0 {
@@ -381,6 +457,25 @@ func (s *SubnetsService) Update(subnet *Subnet) (*Subnet, error) {
 	return &updatedSubnet, err
 }
 
+// Patch sends a partial update for a subnet, including fields set to zero or null
+func (s *SubnetsService) Patch(id int, patch *SubnetPatch) error {
+	if id == 0 {
+		return fmt.Errorf("subnet ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("subnet patch contains no fields")
+	}
+
+	resp, err := s.client.Request("PATCH", fmt.Sprintf("subnets/%d", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to patch subnet %d: %s", id, resp.Message)
+	}
+	return nil
+}
+
 // Resize resizes a subnet to a new mask
 func (s *SubnetsService) Resize(id int, mask int) error {
 	data := map[string]int{"mask": mask}
@@ -395,26 +490,60 @@ func (s *SubnetsService) Split(id int, subnets int) error {
 	return err
 }
 
-// SetPermissions sets subnet permissions
-func (s *SubnetsService) SetPermissions(id int, permissions map[string]string) error {
-	_, err := s.client.Request("PATCH", fmt.Sprintf("subnets/%d/permissions", id), permissions, nil)
-	return err
+// SetPermissions sets subnet permissions by user group ID
+func (s *SubnetsService) SetPermissions(id int, permissions Permissions) error {
+	if len(permissions) == 0 {
+		return fmt.Errorf("no permissions to set, use RemovePermissions to clear them")
+	}
+
+	resp, err := s.client.Request("PATCH", fmt.Sprintf("subnets/%d/permissions", id), permissions.levels(), nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+// SetGroupPermissions sets subnet permissions by user group name; phpIPAM resolves the names
+func (s *SubnetsService) SetGroupPermissions(id int, permissions GroupPermissions) error {
+	if len(permissions) == 0 {
+		return fmt.Errorf("no permissions to set, use RemovePermissions to clear them")
+	}
+
+	levels := make(map[string]string, len(permissions))
+	for group, level := range permissions {
+		levels[group] = level.String()
+	}
+
+	resp, err := s.client.Request("PATCH", fmt.Sprintf("subnets/%d/permissions", id), levels, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // Delete deletes a subnet
 func (s *SubnetsService) Delete(id int) error {
-	_, err := s.client.Request("DELETE", fmt.Sprintf("subnets/%d", id), nil, nil)
-	return err
+	resp, err := s.client.Request("DELETE", fmt.Sprintf("subnets/%d", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // Truncate removes all addresses from a subnet
 func (s *SubnetsService) Truncate(id int) error {
-	_, err := s.client.Request("DELETE", fmt.Sprintf("subnets/%d/truncate", id), nil, nil)
-	return err
+	resp, err := s.client.Request("DELETE", fmt.Sprintf("subnets/%d/truncate", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // RemovePermissions removes all permissions from a subnet
 func (s *SubnetsService) RemovePermissions(id int) error {
-	_, err := s.client.Request("DELETE", fmt.Sprintf("subnets/%d/permissions", id), nil, nil)
-	return err
+	resp, err := s.client.Request("DELETE", fmt.Sprintf("subnets/%d/permissions", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
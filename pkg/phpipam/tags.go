@@ -0,0 +1,127 @@
+package phpipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Built-in address tags (states) shipped with every phpIPAM installation
+const (
+	TagOffline  = 1
+	TagUsed     = 2
+	TagReserved = 3
+	TagDHCP     = 4
+)
+
+// Tag represents a phpIPAM IP address tag (state). It is returned by both the
+// addresses and the tools controllers.
+type Tag struct {
+	ID          int    `json:"id,omitempty"`
+	Type        string `json:"type,omitempty"`
+	ShowTag     int    `json:"showtag,omitempty"`
+	BgColor     string `json:"bgcolor,omitempty"`
+	FgColor     string `json:"fgcolor,omitempty"`
+	DisplayName string `json:"displayname,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// UnmarshalJSON implements custom unmarshaling for Tag, accepting numeric fields
+// as either JSON numbers or strings
+func (t *Tag) UnmarshalJSON(data []byte) error {
+	type tagAlias Tag
+	aux := struct {
+		ID      ResponseID `json:"id,omitempty"`
+		ShowTag ResponseID `json:"showtag,omitempty"`
+		*tagAlias
+	}{tagAlias: (*tagAlias)(t)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	t.ID = aux.ID.Int()
+	t.ShowTag = aux.ShowTag.Int()
+	return nil
+}
+
+// String returns the tag name
+func (t Tag) String() string {
+	if t.Type != "" {
+		return t.Type
+	}
+	return strconv.Itoa(t.ID)
+}
+
+// tagCache holds the address tags of the server once they have been loaded
+type tagCache struct {
+	mu   sync.Mutex
+	tags []Tag
+}
+
+// Tags returns all address tags, loading them from the server on first use. The
+// slice is a copy of the cache and may be modified.
+func (a *AddressesService) Tags() ([]Tag, error) {
+	a.tags.mu.Lock()
+	defer a.tags.mu.Unlock()
+
+	if a.tags.tags == nil {
+		tags, err := a.GetTags()
+		if err != nil {
+			return nil, err
+		}
+		if tags == nil {
+			tags = []Tag{}
+		}
+		a.tags.tags = tags
+	}
+
+	return append([]Tag(nil), a.tags.tags...), nil
+}
+
+// RefreshTags discards the cached address tags so they are loaded again on next use
+func (a *AddressesService) RefreshTags() {
+	a.tags.mu.Lock()
+	a.tags.tags = nil
+	a.tags.mu.Unlock()
+}
+
+// ResolveTag returns the address tag matching a name (case-insensitive) or numeric ID
+func (a *AddressesService) ResolveTag(nameOrID string) (*Tag, error) {
+	tags, err := a.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	nameOrID = strings.TrimSpace(nameOrID)
+	id, idErr := strconv.Atoi(nameOrID)
+	for i := range tags {
+		if idErr == nil && tags[i].ID == id || strings.EqualFold(tags[i].Type, nameOrID) {
+			tag := tags[i]
+			return &tag, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown address tag %q", nameOrID)
+}
+
+// TagName returns the name of the address tag with the given ID
+func (a *AddressesService) TagName(id int) (string, error) {
+	tag, err := a.ResolveTag(strconv.Itoa(id))
+	if err != nil {
+		return "", err
+	}
+	return tag.Type, nil
+}
+
+// SetState sets the tag (state) of an address by tag name or numeric ID,
+// e.g. SetState(10, "Reserved")
+func (a *AddressesService) SetState(id int, state string) error {
+	tag, err := a.ResolveTag(state)
+	if err != nil {
+		return err
+	}
+	return a.Patch(id, NewAddressPatch().SetTag(tag.ID))
+}
@@ -0,0 +1,43 @@
+package phpipam
+
+import "testing"
+
+func TestTagsReturnCopies(t *testing.T) {
+	api := newTestServer(t, map[string]string{
+		"addresses/tags": `{"code":200,"success":true,"data":[{"id":"2","type":"Used"},{"id":"3","type":"Reserved"}]}`,
+	})
+
+	tags, err := api.Addresses.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags[0].Type = "changed"
+	tags[1] = Tag{ID: 9, Type: "Extra"}
+
+	tag, err := api.Addresses.ResolveTag("used")
+	if err != nil {
+		t.Fatalf("ResolveTag(used) after modifying Tags: %v", err)
+	}
+	if tag.ID != TagUsed {
+		t.Errorf("ResolveTag(used).ID = %d, want %d", tag.ID, TagUsed)
+	}
+	tag.Type = "changed"
+
+	tests := []struct {
+		nameOrID string
+		want     string
+	}{
+		{"2", "Used"},
+		{" reserved ", "Reserved"},
+		{"USED", "Used"},
+	}
+	for _, tt := range tests {
+		tag, err := api.Addresses.ResolveTag(tt.nameOrID)
+		if err != nil || tag.Type != tt.want {
+			t.Errorf("ResolveTag(%q) = %v, %v, want %s", tt.nameOrID, tag, err, tt.want)
+		}
+	}
+	if _, err := api.Addresses.ResolveTag("Extra"); err == nil {
+		t.Error("ResolveTag(Extra) found a tag appended to a returned slice")
+	}
+}
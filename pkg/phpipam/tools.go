@@ -3,6 +3,7 @@ package phpipam
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // ToolsService handles communication with the tools related methods of the API
@@ -29,16 +30,10 @@ const (
 	SubcontrollerRacks       = "racks"
 )
 
-// Tag represents a phpIPAM IP address tag
-type IPTag struct {
-	ID          string `json:"id,omitempty"`
-	Type        string `json:"type,omitempty"`
-	ShowTag     string `json:"showtag,omitempty"`
-	BgColor     string `json:"bgcolor,omitempty"`
-	FgColor     string `json:"fgcolor,omitempty"`
-	DisplayName string `json:"displayname,omitempty"`
-	Description string `json:"description,omitempty"`
-}
+// IPTag represents a phpIPAM IP address tag
+//
+// Deprecated: use Tag, which is returned by both the addresses and tools controllers.
+type IPTag = Tag
 
 // DeviceType represents a phpIPAM device type
 type DeviceType struct {
@@ -49,13 +44,27 @@ type DeviceType struct {
 
 // Nameserver represents a phpIPAM nameserver
 type Nameserver struct {
-	ID          string `json:"id,omitempty"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-	Permissions string `json:"permissions,omitempty"`
-	Namesrv1    string `json:"namesrv1,omitempty"`
-	Namesrv2    string `json:"namesrv2,omitempty"`
-	Namesrv3    string `json:"namesrv3,omitempty"`
+	ID          string     `json:"id,omitempty"`
+	Name        string     `json:"name,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Permissions SectionIDs `json:"permissions,omitempty"` // Sections the nameserver set is available in
+	Namesrv1    string     `json:"namesrv1,omitempty"`
+	Namesrv2    string     `json:"namesrv2,omitempty"`
+	Namesrv3    string     `json:"namesrv3,omitempty"`
+}
+
+// Entries returns the servers of the set, names or IPs. phpIPAM keeps them
+// separated by semicolons, usually all in Namesrv1.
+func (n *Nameserver) Entries() []string {
+	var entries []string
+	for _, field := range []string{n.Namesrv1, n.Namesrv2, n.Namesrv3} {
+		for _, entry := range strings.Split(field, ";") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
 }
 
 // ScanAgent represents a phpIPAM scan agent
@@ -100,29 +109,32 @@ type Rack struct {
 }
 
 // GetIPTags returns all IP tags
-func (t *ToolsService) GetIPTags() ([]IPTag, error) {
-	var tags []IPTag
+func (t *ToolsService) GetIPTags() ([]Tag, error) {
+	var tags []Tag
 	_, err := t.client.Request("GET", "tools/tags", nil, &tags)
 	return tags, err
 }
 
 // GetIPTag returns a specific IP tag by ID
-func (t *ToolsService) GetIPTag(id string) (*IPTag, error) {
-	var tag IPTag
+func (t *ToolsService) GetIPTag(id string) (*Tag, error) {
+	var tag Tag
 	_, err := t.client.Request("GET", fmt.Sprintf("tools/tags/%s", id), nil, &tag)
 	return &tag, err
 }
 
 // CreateIPTag creates a new IP tag
-func (t *ToolsService) CreateIPTag(tag *IPTag) (*IPTag, error) {
-	var createdTag IPTag
+func (t *ToolsService) CreateIPTag(tag *Tag) (*Tag, error) {
+	var createdTag Tag
 	resp, err := t.client.Request("POST", "tools/tags", tag, &createdTag)
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the tag data, retrieve the full tag
-	if resp.ID != 0 && createdTag.ID == "" {
+	if resp.ID != 0 && createdTag.ID == 0 {
 		return t.GetIPTag(strconv.Itoa(resp.ID.Int()))
 	}
 
@@ -130,20 +142,39 @@ func (t *ToolsService) CreateIPTag(tag *IPTag) (*IPTag, error) {
 }
 
 // UpdateIPTag updates an IP tag
-func (t *ToolsService) UpdateIPTag(tag *IPTag) (*IPTag, error) {
-	if tag.ID == "" {
+func (t *ToolsService) UpdateIPTag(tag *Tag) (*Tag, error) {
+	if tag.ID == 0 {
 		return nil, fmt.Errorf("tag ID is required for update")
 	}
 
-	var updatedTag IPTag
-	_, err := t.client.Request("PATCH", fmt.Sprintf("tools/tags/%s", tag.ID), tag, &updatedTag)
+	var updatedTag Tag
+	_, err := t.client.Request("PATCH", fmt.Sprintf("tools/tags/%d", tag.ID), tag, &updatedTag)
 	return &updatedTag, err
 }
 
+// PatchIPTag sends a partial update for an IP tag, including fields set to zero or empty
+func (t *ToolsService) PatchIPTag(id int, patch *Patch) error {
+	if id == 0 {
+		return fmt.Errorf("tag ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("tag patch contains no fields")
+	}
+
+	resp, err := t.client.Request("PATCH", fmt.Sprintf("tools/tags/%d", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
 // DeleteIPTag deletes an IP tag
 func (t *ToolsService) DeleteIPTag(id string) error {
-	_, err := t.client.Request("DELETE", fmt.Sprintf("tools/tags/%s", id), nil, nil)
-	return err
+	resp, err := t.client.Request("DELETE", fmt.Sprintf("tools/tags/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // GetDeviceTypes returns all device types
@@ -174,6 +205,9 @@ func (t *ToolsService) CreateDeviceType(deviceType *DeviceType) (*DeviceType, er
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the device type data, retrieve the full device type
 	if resp.ID != 0 && createdDeviceType.ID == "" {
@@ -196,8 +230,11 @@ func (t *ToolsService) UpdateDeviceType(deviceType *DeviceType) (*DeviceType, er
 
 // DeleteDeviceType deletes a device type
 func (t *ToolsService) DeleteDeviceType(id string) error {
-	_, err := t.client.Request("DELETE", fmt.Sprintf("tools/device_types/%s", id), nil, nil)
-	return err
+	resp, err := t.client.Request("DELETE", fmt.Sprintf("tools/device_types/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // GetVLANsByToolsController returns all VLANs using tools controller
@@ -263,6 +300,9 @@ func (t *ToolsService) CreateNameserver(nameserver *Nameserver) (*Nameserver, er
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the nameserver data, retrieve the full nameserver
 	if resp.ID != 0 && createdNameserver.ID == "" {
@@ -283,10 +323,29 @@ func (t *ToolsService) UpdateNameserver(nameserver *Nameserver) (*Nameserver, er
 	return &updatedNameserver, err
 }
 
+// PatchNameserver sends a partial update for a nameserver, including fields set to zero or empty
+func (t *ToolsService) PatchNameserver(id string, patch *Patch) error {
+	if id == "" {
+		return fmt.Errorf("nameserver ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("nameserver patch contains no fields")
+	}
+
+	resp, err := t.client.Request("PATCH", fmt.Sprintf("tools/nameservers/%s", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
 // DeleteNameserver deletes a nameserver
 func (t *ToolsService) DeleteNameserver(id string) error {
-	_, err := t.client.Request("DELETE", fmt.Sprintf("tools/nameservers/%s", id), nil, nil)
-	return err
+	resp, err := t.client.Request("DELETE", fmt.Sprintf("tools/nameservers/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // GetScanagents returns all scanagents
@@ -352,6 +411,9 @@ func (t *ToolsService) CreateLocation(location *Location) (*Location, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the location data, retrieve the full location
 	if resp.ID != 0 && createdLocation.ID == "" {
@@ -372,10 +434,29 @@ func (t *ToolsService) UpdateLocation(location *Location) (*Location, error) {
 	return &updatedLocation, err
 }
 
+// PatchLocation sends a partial update for a location, including fields set to zero or empty
+func (t *ToolsService) PatchLocation(id string, patch *Patch) error {
+	if id == "" {
+		return fmt.Errorf("location ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("location patch contains no fields")
+	}
+
+	resp, err := t.client.Request("PATCH", fmt.Sprintf("tools/locations/%s", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
 // DeleteLocation deletes a location
 func (t *ToolsService) DeleteLocation(id string) error {
-	_, err := t.client.Request("DELETE", fmt.Sprintf("tools/locations/%s", id), nil, nil)
-	return err
+	resp, err := t.client.Request("DELETE", fmt.Sprintf("tools/locations/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // GetRacks returns all racks
@@ -406,6 +487,9 @@ func (t *ToolsService) CreateRack(rack *Rack) (*Rack, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the rack data, retrieve the full rack
 	if resp.ID != 0 && createdRack.ID == "" {
@@ -426,10 +510,29 @@ func (t *ToolsService) UpdateRack(rack *Rack) (*Rack, error) {
 	return &updatedRack, err
 }
 
+// PatchRack sends a partial update for a rack, including fields set to zero or empty
+func (t *ToolsService) PatchRack(id string, patch *Patch) error {
+	if id == "" {
+		return fmt.Errorf("rack ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("rack patch contains no fields")
+	}
+
+	resp, err := t.client.Request("PATCH", fmt.Sprintf("tools/racks/%s", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
 // DeleteRack deletes a rack
 func (t *ToolsService) DeleteRack(id string) error {
-	_, err := t.client.Request("DELETE", fmt.Sprintf("tools/racks/%s", id), nil, nil)
-	return err
+	resp, err := t.client.Request("DELETE", fmt.Sprintf("tools/racks/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
 
 // GetNATs returns all NATs
@@ -467,6 +570,9 @@ func (t *ToolsService) CreateNAT(nat *NAT) (*NAT, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the NAT data, retrieve the full NAT
 	if resp.ID != 0 && createdNAT.ID == "" {
@@ -487,8 +593,27 @@ func (t *ToolsService) UpdateNAT(nat *NAT) (*NAT, error) {
 	return &updatedNAT, err
 }
 
+// PatchNAT sends a partial update for a NAT, including fields set to zero or empty
+func (t *ToolsService) PatchNAT(id string, patch *Patch) error {
+	if id == "" {
+		return fmt.Errorf("NAT ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("NAT patch contains no fields")
+	}
+
+	resp, err := t.client.Request("PATCH", fmt.Sprintf("tools/nat/%s", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
 // DeleteNAT deletes a NAT
 func (t *ToolsService) DeleteNAT(id string) error {
-	_, err := t.client.Request("DELETE", fmt.Sprintf("tools/nat/%s", id), nil, nil)
-	return err
+	resp, err := t.client.Request("DELETE", fmt.Sprintf("tools/nat/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
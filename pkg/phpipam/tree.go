@@ -0,0 +1,413 @@
+package phpipam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/whogan00/phpipam-go-sdk/pkg/phpipam/ipcalc"
+)
+
+// NodeKind identifies the type of object a tree node holds
+type NodeKind string
+
+const (
+	// NodeSection is a section node
+	NodeSection NodeKind = "section"
+	// NodeFolder is a folder node
+	NodeFolder NodeKind = "folder"
+	// NodeSubnet is a subnet node
+	NodeSubnet NodeKind = "subnet"
+)
+
+// SkipChildren can be returned by a Walk callback to skip the children of the current node
+var SkipChildren = errors.New("skip children")
+
+// TreeNode is a section, folder or subnet in a Tree
+type TreeNode struct {
+	Kind NodeKind
+	// Section is set for section nodes
+	Section *Section
+	// Subnet is set for folder and subnet nodes
+	Subnet *Subnet
+	// Prefix is set for subnet nodes
+	Prefix netip.Prefix
+
+	Parent   *TreeNode
+	Children []*TreeNode
+}
+
+// Name returns the section name, folder description or subnet CIDR of the node
+func (n *TreeNode) Name() string {
+	switch n.Kind {
+	case NodeSection:
+		return n.Section.Name
+	case NodeFolder:
+		return n.Subnet.Description
+	}
+	return n.Prefix.String()
+}
+
+// Depth returns the number of ancestors of the node; roots have depth 0
+func (n *TreeNode) Depth() int {
+	depth := 0
+	for p := n.Parent; p != nil; p = p.Parent {
+		depth++
+	}
+	return depth
+}
+
+// Ancestors returns the ancestors of the node, nearest first
+func (n *TreeNode) Ancestors() []*TreeNode {
+	var ancestors []*TreeNode
+	for p := n.Parent; p != nil; p = p.Parent {
+		ancestors = append(ancestors, p)
+	}
+	return ancestors
+}
+
+// SectionNode returns the section the node belongs to, or nil if it is unknown
+func (n *TreeNode) SectionNode() *TreeNode {
+	for p := n; p != nil; p = p.Parent {
+		if p.Kind == NodeSection {
+			return p
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (n *TreeNode) MarshalJSON() ([]byte, error) {
+	node := struct {
+		Type        NodeKind    `json:"type"`
+		ID          string      `json:"id"`
+		Name        string      `json:"name"`
+		Description string      `json:"description,omitempty"`
+		Children    []*TreeNode `json:"children,omitempty"`
+	}{
+		Type:     n.Kind,
+		Name:     n.Name(),
+		Children: n.Children,
+	}
+
+	switch n.Kind {
+	case NodeSection:
+		node.ID = n.Section.ID
+		node.Description = n.Section.Description
+	case NodeFolder:
+		node.ID = strconv.Itoa(n.Subnet.ID)
+	default:
+		node.ID = strconv.Itoa(n.Subnet.ID)
+		node.Description = n.Subnet.Description
+	}
+
+	return json.Marshal(node)
+}
+
+// Tree is the hierarchy of sections, folders and subnets, as shown in the phpIPAM UI
+type Tree struct {
+	// Roots are the top-level sections, followed by any subnets whose section is unknown
+	Roots []*TreeNode
+
+	sections map[string]*TreeNode
+	subnets  map[int]*TreeNode
+}
+
+// BuildTree links flat section and subnet lists (e.g. from SectionsService.GetSubnets)
+// into a tree. Sections nest under their master section, subnets and folders under
+// their master subnet or, for top-level subnets, under their section. Master
+// links that form a cycle are an error.
+func BuildTree(sections []Section, subnets []Subnet) (*Tree, error) {
+	t := &Tree{
+		sections: make(map[string]*TreeNode, len(sections)),
+		subnets:  make(map[int]*TreeNode, len(subnets)),
+	}
+
+	for i := range sections {
+		t.sections[sections[i].ID] = &TreeNode{Kind: NodeSection, Section: &sections[i]}
+	}
+	for i := range subnets {
+		node := &TreeNode{Kind: NodeFolder, Subnet: &subnets[i]}
+		if subnets[i].IsFolder != 1 {
+			prefix, err := subnets[i].Prefix()
+			if err != nil {
+				return nil, fmt.Errorf("subnet %d: %w", subnets[i].ID, err)
+			}
+			node.Kind = NodeSubnet
+			node.Prefix = prefix
+		}
+		t.subnets[subnets[i].ID] = node
+	}
+
+	// Nodes in a master cycle would be unreachable from the roots
+	sectionMaster := make(map[string]string, len(sections))
+	for i := range sections {
+		sectionMaster[sections[i].ID] = strconv.Itoa(sections[i].MasterSection)
+	}
+	for i := range sections {
+		if cycle := masterCycle(sections[i].ID, sectionMaster); cycle != nil {
+			return nil, fmt.Errorf("sections %s form a master section cycle", strings.Join(cycle, ", "))
+		}
+	}
+	subnetMaster := make(map[int]int, len(subnets))
+	for i := range subnets {
+		subnetMaster[subnets[i].ID] = subnets[i].MasterSubnetID
+	}
+	for i := range subnets {
+		if cycle := masterCycle(subnets[i].ID, subnetMaster); cycle != nil {
+			ids := make([]string, len(cycle))
+			for j, id := range cycle {
+				ids[j] = strconv.Itoa(id)
+			}
+			return nil, fmt.Errorf("subnets %s form a master subnet cycle", strings.Join(ids, ", "))
+		}
+	}
+
+	for i := range sections {
+		node := t.sections[sections[i].ID]
+		if master, ok := t.sections[strconv.Itoa(sections[i].MasterSection)]; ok && master != node {
+			t.attach(master, node)
+		} else {
+			t.Roots = append(t.Roots, node)
+		}
+	}
+	for i := range subnets {
+		node := t.subnets[subnets[i].ID]
+		if master, ok := t.subnets[subnets[i].MasterSubnetID]; ok && master != node {
+			t.attach(master, node)
+		} else if section, ok := t.sections[strconv.Itoa(subnets[i].SectionID)]; ok {
+			t.attach(section, node)
+		} else {
+			t.Roots = append(t.Roots, node)
+		}
+	}
+
+	sortNodes(t.Roots)
+	t.Walk(func(n *TreeNode) error {
+		sortNodes(n.Children)
+		return nil
+	})
+
+	return t, nil
+}
+
+// masterCycle follows the master links from start and returns the IDs of the
+// cycle it runs into, or nil. A node that is its own master is a root, not a
+// cycle.
+func masterCycle[K comparable](start K, master map[K]K) []K {
+	seen := make(map[K]int)
+	var path []K
+	for id := start; ; {
+		if i, ok := seen[id]; ok {
+			return path[i:]
+		}
+		seen[id] = len(path)
+		path = append(path, id)
+
+		next, ok := master[id]
+		if !ok || next == id {
+			return nil
+		}
+		if _, known := master[next]; !known {
+			return nil
+		}
+		id = next
+	}
+}
+
+// attach adds a child node to a parent node
+func (t *Tree) attach(parent, child *TreeNode) {
+	child.Parent = parent
+	parent.Children = append(parent.Children, child)
+}
+
+// sortNodes orders sibling nodes like the phpIPAM UI: sections by order and name,
+// then folders by name, then subnets by address
+func sortNodes(nodes []*TreeNode) {
+	rank := map[NodeKind]int{NodeSection: 0, NodeFolder: 1, NodeSubnet: 2}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.Kind != b.Kind {
+			return rank[a.Kind] < rank[b.Kind]
+		}
+		switch a.Kind {
+		case NodeSection:
+			if a.Section.Order != b.Section.Order {
+				return a.Section.Order < b.Section.Order
+			}
+			return a.Section.Name < b.Section.Name
+		case NodeFolder:
+			return a.Subnet.Description < b.Subnet.Description
+		}
+		if a.Prefix.Addr() != b.Prefix.Addr() {
+			return a.Prefix.Addr().Less(b.Prefix.Addr())
+		}
+		return a.Prefix.Bits() < b.Prefix.Bits()
+	})
+}
+
+// Walk calls fn for every node in depth-first order, parents before children.
+// If fn returns SkipChildren, the children of that node are skipped; any other
+// error stops the walk and is returned.
+func (t *Tree) Walk(fn func(n *TreeNode) error) error {
+	for _, root := range t.Roots {
+		if err := walkNode(root, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkNode walks a node and its descendants
+func walkNode(n *TreeNode, fn func(n *TreeNode) error) error {
+	if err := fn(n); err != nil {
+		if err == SkipChildren {
+			return nil
+		}
+		return err
+	}
+	for _, child := range n.Children {
+		if err := walkNode(child, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindByID returns the folder or subnet node with the given ID, or nil
+func (t *Tree) FindByID(id int) *TreeNode {
+	return t.subnets[id]
+}
+
+// FindSection returns the section node with the given ID, or nil
+func (t *Tree) FindSection(id string) *TreeNode {
+	return t.sections[id]
+}
+
+// FindByPrefix returns all subnet nodes with the given prefix, e.g. "10.0.0.0/24".
+// The same prefix can exist in several sections or VRFs.
+func (t *Tree) FindByPrefix(cidr string) ([]*TreeNode, error) {
+	prefix, err := ipcalc.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*TreeNode
+	t.Walk(func(n *TreeNode) error {
+		if n.Kind == NodeSubnet && n.Prefix == prefix {
+			nodes = append(nodes, n)
+		}
+		return nil
+	})
+	return nodes, nil
+}
+
+// LongestMatch returns the most specific subnet node of a VRF (GlobalVRF for
+// subnets without one) containing the address, or nil. Ties between equally
+// specific subnets in different sections go to the lowest subnet ID.
+func (t *Tree) LongestMatch(vrfID int, addr netip.Addr) *TreeNode {
+	var best *TreeNode
+	t.Walk(func(n *TreeNode) error {
+		if n.Kind != NodeSubnet {
+			return nil
+		}
+		// Children of a subnet always lie inside it
+		if !n.Prefix.Contains(addr) {
+			return SkipChildren
+		}
+		if subnetVRF(n.Subnet) != vrfID {
+			return nil
+		}
+		if best == nil || n.Prefix.Bits() > best.Prefix.Bits() ||
+			(n.Prefix.Bits() == best.Prefix.Bits() && n.Subnet.ID < best.Subnet.ID) {
+			best = n
+		}
+		return nil
+	})
+	return best
+}
+
+// Render writes the tree as indented text, similar to the phpIPAM UI
+func (t *Tree) Render(w io.Writer) error {
+	for _, root := range t.Roots {
+		if _, err := fmt.Fprintln(w, nodeLabel(root)); err != nil {
+			return err
+		}
+		if err := renderChildren(w, root, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderChildren writes the children of a node with box-drawing connectors
+func renderChildren(w io.Writer, n *TreeNode, indent string) error {
+	for i, child := range n.Children {
+		connector, next := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			connector, next = "└── ", "    "
+		}
+		if _, err := fmt.Fprintln(w, indent+connector+nodeLabel(child)); err != nil {
+			return err
+		}
+		if err := renderChildren(w, child, indent+next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nodeLabel returns the text shown for a node when rendering
+func nodeLabel(n *TreeNode) string {
+	switch n.Kind {
+	case NodeSection:
+		return n.Section.Name
+	case NodeFolder:
+		return "[" + n.Subnet.Description + "]"
+	}
+	if description := strings.TrimSpace(n.Subnet.Description); description != "" {
+		return n.Prefix.String() + " " + description
+	}
+	return n.Prefix.String()
+}
+
+// MarshalJSON implements json.Marshaler
+func (t *Tree) MarshalJSON() ([]byte, error) {
+	roots := t.Roots
+	if roots == nil {
+		roots = []*TreeNode{}
+	}
+	return json.Marshal(roots)
+}
+
+// GetTree fetches all sections and their subnets and builds the subnet tree
+func (p *PHPIPAM) GetTree() (*Tree, error) {
+	sections, subnets, err := p.listAllSubnets()
+	if err != nil {
+		return nil, err
+	}
+	return BuildTree(sections, subnets)
+}
+
+// listAllSubnets fetches all sections and the subnets and folders of each
+func (p *PHPIPAM) listAllSubnets() ([]Section, []Subnet, error) {
+	sections, err := p.Sections.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var subnets []Subnet
+	for _, section := range sections {
+		sectionSubnets, err := p.Sections.GetSubnets(section.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get subnets of section %s: %w", section.Name, err)
+		}
+		subnets = append(subnets, sectionSubnets...)
+	}
+	return sections, subnets, nil
+}
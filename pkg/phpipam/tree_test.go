@@ -0,0 +1,173 @@
+package phpipam
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestBuildTreeCycles(t *testing.T) {
+	tests := []struct {
+		name     string
+		sections []Section
+		subnets  []Subnet
+		wantErr  string
+	}{
+		{
+			name:     "section cycle",
+			sections: []Section{{ID: "1", Name: "a", MasterSection: 2}, {ID: "2", Name: "b", MasterSection: 1}},
+			wantErr:  "sections 1, 2 form a master section cycle",
+		},
+		{
+			name:     "section that is its own master is a root",
+			sections: []Section{{ID: "1", Name: "a", MasterSection: 1}},
+		},
+		{
+			name:     "section with an unknown master is a root",
+			sections: []Section{{ID: "1", Name: "a", MasterSection: 9}},
+		},
+		{
+			name:     "subnet cycle reached from outside",
+			sections: []Section{{ID: "1", Name: "a"}},
+			subnets: []Subnet{
+				{ID: 9, Subnet: "10.0.0.0", Mask: "24", SectionID: 1, MasterSubnetID: 10},
+				{ID: 10, Subnet: "10.0.0.0", Mask: "16", SectionID: 1, MasterSubnetID: 11},
+				{ID: 11, Subnet: "10.0.0.0", Mask: "12", SectionID: 1, MasterSubnetID: 12},
+				{ID: 12, Subnet: "10.0.0.0", Mask: "8", SectionID: 1, MasterSubnetID: 10},
+			},
+			wantErr: "subnets 10, 11, 12 form a master subnet cycle",
+		},
+		{
+			name:     "folder cycle",
+			sections: []Section{{ID: "1", Name: "a"}},
+			subnets: []Subnet{
+				{ID: 3, IsFolder: 1, Description: "x", SectionID: 1, MasterSubnetID: 4},
+				{ID: 4, IsFolder: 1, Description: "y", SectionID: 1, MasterSubnetID: 3},
+			},
+			wantErr: "subnets 3, 4 form a master subnet cycle",
+		},
+		{
+			name:     "subnet that is its own master",
+			sections: []Section{{ID: "1", Name: "a"}},
+			subnets:  []Subnet{{ID: 3, Subnet: "10.0.0.0", Mask: "8", SectionID: 1, MasterSubnetID: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := BuildTree(tt.sections, tt.subnets)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every node is reachable from the roots
+			count := 0
+			tree.Walk(func(n *TreeNode) error {
+				count++
+				return nil
+			})
+			if count != len(tt.sections)+len(tt.subnets) {
+				t.Errorf("walked %d nodes, want %d", count, len(tt.sections)+len(tt.subnets))
+			}
+		})
+	}
+}
+
+func TestBuildTreeLayout(t *testing.T) {
+	sections := []Section{
+		{ID: "1", Name: "Production", Order: 2},
+		{ID: "2", Name: "Lab", Order: 1},
+		{ID: "3", Name: "Lab DC", MasterSection: 2},
+	}
+	subnets := []Subnet{
+		{ID: 10, Subnet: "10.1.0.0", Mask: "16", SectionID: 1},
+		{ID: 11, Subnet: "10.0.0.0", Mask: "16", SectionID: 1},
+		{ID: 12, Subnet: "10.1.1.0", Mask: "24", SectionID: 1, MasterSubnetID: 10},
+		{ID: 13, IsFolder: 1, Description: "Servers", SectionID: 1},
+		{ID: 14, Subnet: "192.168.0.0", Mask: "24", SectionID: 3},
+		{ID: 15, Subnet: "172.16.0.0", Mask: "12", SectionID: 8},
+	}
+
+	tree, err := BuildTree(sections, subnets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	tree.Walk(func(n *TreeNode) error {
+		lines = append(lines, strings.Repeat(" ", n.Depth())+n.Name())
+		return nil
+	})
+	want := []string{
+		"Lab",
+		" Lab DC",
+		"  192.168.0.0/24",
+		"Production",
+		" Servers",
+		" 10.0.0.0/16",
+		" 10.1.0.0/16",
+		"  10.1.1.0/24",
+		"172.16.0.0/12",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("tree:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	if section := tree.FindByID(14).SectionNode(); section == nil || section.Section.ID != "3" {
+		t.Errorf("SectionNode of subnet 14 = %v, want section 3", section)
+	}
+	if section := tree.FindByID(15).SectionNode(); section != nil {
+		t.Errorf("SectionNode of subnet 15 = %v, want nil for an unknown section", section)
+	}
+}
+
+func TestTreeLongestMatch(t *testing.T) {
+	sections := []Section{{ID: "1", Name: "a"}, {ID: "2", Name: "b"}, {ID: "3", Name: "c"}}
+	subnets := []Subnet{
+		{ID: 1, Subnet: "10.0.0.0", Mask: "8", SectionID: 1},
+		{ID: 2, Subnet: "10.1.0.0", Mask: "16", SectionID: 1, MasterSubnetID: 1},
+		{ID: 3, Subnet: "10.1.2.0", Mask: "24", SectionID: 1, MasterSubnetID: 2, VrfID: "2"},
+		{ID: 6, Subnet: "10.1.2.0", Mask: "24", SectionID: 3},
+		{ID: 5, Subnet: "10.1.2.0", Mask: "24", SectionID: 2, VrfID: "0"},
+		{ID: 7, Subnet: "10.0.0.0", Mask: "8", SectionID: 2, VrfID: 2},
+		{ID: 8, Subnet: "2001:db8::", Mask: "32", SectionID: 1},
+		{ID: 9, Subnet: "2001:db8:1::", Mask: "48", SectionID: 1, MasterSubnetID: 8},
+	}
+
+	tree, err := BuildTree(sections, subnets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		vrf  int
+		addr string
+		want int
+	}{
+		{GlobalVRF, "10.1.2.3", 5},
+		{2, "10.1.2.3", 3},
+		{2, "10.9.9.9", 7},
+		{GlobalVRF, "10.9.9.9", 1},
+		{GlobalVRF, "10.1.9.9", 2},
+		{3, "10.1.2.3", 0},
+		{GlobalVRF, "192.168.1.1", 0},
+		{GlobalVRF, "2001:db8:1::1", 9},
+		{GlobalVRF, "2001:db8:2::1", 8},
+	}
+	for _, tt := range tests {
+		got := tree.LongestMatch(tt.vrf, netip.MustParseAddr(tt.addr))
+		gotID := 0
+		if got != nil {
+			gotID = got.Subnet.ID
+		}
+		if gotID != tt.want {
+			t.Errorf("LongestMatch(%d, %s) = subnet %d, want %d", tt.vrf, tt.addr, gotID, tt.want)
+		}
+	}
+}
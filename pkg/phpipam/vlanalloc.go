@@ -0,0 +1,283 @@
+package phpipam
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	// MinVLAN is the lowest usable 802.1Q VLAN number
+	MinVLAN = 1
+	// MaxVLAN is the highest usable 802.1Q VLAN number
+	MaxVLAN = 4094
+)
+
+// ErrNoFreeVLAN is returned when an L2 domain has no unused VLAN number left in range
+var ErrNoFreeVLAN = errors.New("no free VLAN number")
+
+// VLANRange is an inclusive range of VLAN numbers
+type VLANRange struct {
+	Min int
+	Max int
+}
+
+// Contains reports whether the number lies in the range
+func (r VLANRange) Contains(number int) bool {
+	return number >= r.Min && number <= r.Max
+}
+
+// Size returns the number of VLAN numbers in the range
+func (r VLANRange) Size() int {
+	if r.Max < r.Min {
+		return 0
+	}
+	return r.Max - r.Min + 1
+}
+
+// Validate checks that the range is not reversed and lies within MinVLAN-MaxVLAN
+func (r VLANRange) Validate() error {
+	if r.Min > r.Max {
+		return fmt.Errorf("VLAN range %d-%d ends before it starts", r.Min, r.Max)
+	}
+	if r.Min < MinVLAN || r.Max > MaxVLAN {
+		return fmt.Errorf("VLAN range %s is outside %d-%d", r, MinVLAN, MaxVLAN)
+	}
+	return nil
+}
+
+// String returns the range as "min-max", or a single number
+func (r VLANRange) String() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// VLANNumber returns the VLAN number as an integer
+func (v *VLAN) VLANNumber() (int, error) {
+	number, err := strconv.Atoi(v.Number)
+	if err != nil {
+		return 0, fmt.Errorf("invalid VLAN number %q", v.Number)
+	}
+	return number, nil
+}
+
+// FreeVLANs returns the ranges of numbers in r that are neither used nor
+// reserved. An invalid range is an error, see VLANRange.Validate.
+func FreeVLANs(r VLANRange, used []int, reserved []VLANRange) ([]VLANRange, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	taken := make(map[int]bool, len(used))
+	for _, number := range used {
+		taken[number] = true
+	}
+
+	var free []VLANRange
+	for number := r.Min; number <= r.Max; number++ {
+		if taken[number] || inVLANRanges(number, reserved) {
+			continue
+		}
+		if n := len(free); n > 0 && free[n-1].Max == number-1 {
+			free[n-1].Max = number
+		} else {
+			free = append(free, VLANRange{Min: number, Max: number})
+		}
+	}
+	return free, nil
+}
+
+// inVLANRanges reports whether the number lies in any of the ranges
+func inVLANRanges(number int, ranges []VLANRange) bool {
+	for _, r := range ranges {
+		if r.Contains(number) {
+			return true
+		}
+	}
+	return false
+}
+
+// VLANUtilization reports how the VLAN numbers of an L2 domain are used
+type VLANUtilization struct {
+	DomainID   string
+	DomainName string
+	// Range is the range of numbers considered
+	Range VLANRange
+	// Used is the number of VLANs in range
+	Used int
+	// Reserved is the number of unused numbers excluded from allocation
+	Reserved int
+	// Free is the number of numbers still available
+	Free int
+	// FreeRanges are the available numbers in ascending order
+	FreeRanges []VLANRange
+	// OutOfRange lists VLAN numbers in the domain outside Range
+	OutOfRange []int
+}
+
+// UsedPercent returns the share of allocatable numbers in use, in percent
+func (u *VLANUtilization) UsedPercent() float64 {
+	allocatable := u.Used + u.Free
+	if allocatable == 0 {
+		return 0
+	}
+	return float64(u.Used) / float64(allocatable) * 100
+}
+
+// VLANAllocator picks and creates unused VLAN numbers in an L2 domain
+type VLANAllocator struct {
+	domains *L2DomainsService
+	vlans   *VLANsService
+
+	// Range limits the numbers that are allocated, MinVLAN-MaxVLAN by default
+	Range VLANRange
+	// Reserved lists numbers that are never allocated
+	Reserved []VLANRange
+	// MaxRetries is how many times an allocation is retried after a conflict
+	MaxRetries int
+}
+
+// NewVLANAllocator creates a new VLAN allocator using the provided services
+func NewVLANAllocator(domains *L2DomainsService, vlans *VLANsService) *VLANAllocator {
+	return &VLANAllocator{
+		domains:    domains,
+		vlans:      vlans,
+		Range:      VLANRange{Min: MinVLAN, Max: MaxVLAN},
+		MaxRetries: defaultMaxRetries,
+	}
+}
+
+// NewVLANAllocator creates a new VLAN allocator
+func (p *PHPIPAM) NewVLANAllocator() *VLANAllocator {
+	return NewVLANAllocator(p.L2Domains, p.VLANs)
+}
+
+// usedNumbers returns the VLAN numbers in use in a domain
+func (a *VLANAllocator) usedNumbers(domainID string) ([]int, error) {
+	vlans, err := a.domains.GetVLANs(domainID)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make([]int, 0, len(vlans))
+	for i := range vlans {
+		number, err := vlans[i].VLANNumber()
+		if err != nil {
+			return nil, fmt.Errorf("VLAN %s: %w", vlans[i].ID, err)
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+// NextFree returns the lowest unused, unreserved VLAN number of a domain
+func (a *VLANAllocator) NextFree(domainID string) (int, error) {
+	used, err := a.usedNumbers(domainID)
+	if err != nil {
+		return 0, err
+	}
+	free, err := FreeVLANs(a.Range, used, a.Reserved)
+	if err != nil {
+		return 0, err
+	}
+	if len(free) == 0 {
+		return 0, fmt.Errorf("L2 domain %s: %w in %s", domainID, ErrNoFreeVLAN, a.Range)
+	}
+	return free[0].Min, nil
+}
+
+// Allocate creates a VLAN with the lowest free number in a domain. The template
+// provides name, description and other fields; DomainID and Number are set by the
+// allocator. When another client takes the number first, the next one is tried.
+func (a *VLANAllocator) Allocate(domainID string, template VLAN) (*VLAN, error) {
+	if domainID == "" {
+		return nil, fmt.Errorf("L2 domain ID is required for VLAN allocation")
+	}
+
+	// Numbers that failed with a conflict stay excluded even if the domain
+	// listing has not caught up yet
+	reserved := append([]VLANRange(nil), a.Reserved...)
+	for attempt := 0; ; attempt++ {
+		used, err := a.usedNumbers(domainID)
+		if err != nil {
+			return nil, err
+		}
+		free, err := FreeVLANs(a.Range, used, reserved)
+		if err != nil {
+			return nil, err
+		}
+		if len(free) == 0 {
+			return nil, fmt.Errorf("L2 domain %s: %w in %s", domainID, ErrNoFreeVLAN, a.Range)
+		}
+
+		number := free[0].Min
+		vlan := template
+		vlan.ID = ""
+		vlan.DomainID = domainID
+		vlan.Number = strconv.Itoa(number)
+
+		created, err := a.vlans.Create(&vlan)
+		if err == nil {
+			return created, nil
+		}
+		if !IsConflict(err) || attempt >= a.MaxRetries {
+			return nil, fmt.Errorf("failed to create VLAN %d: %w", number, err)
+		}
+		reserved = append(reserved, VLANRange{Min: number, Max: number})
+	}
+}
+
+// Utilization reports the VLAN number usage of a domain within Range
+func (a *VLANAllocator) Utilization(domainID string) (*VLANUtilization, error) {
+	used, err := a.usedNumbers(domainID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VLANUtilization{DomainID: domainID, Range: a.Range}
+	seen := make(map[int]bool, len(used))
+	for _, number := range used {
+		if seen[number] {
+			continue
+		}
+		seen[number] = true
+		if a.Range.Contains(number) {
+			report.Used++
+		} else {
+			report.OutOfRange = append(report.OutOfRange, number)
+		}
+	}
+	sort.Ints(report.OutOfRange)
+
+	report.FreeRanges, err = FreeVLANs(a.Range, used, a.Reserved)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range report.FreeRanges {
+		report.Free += r.Size()
+	}
+	report.Reserved = a.Range.Size() - report.Used - report.Free
+	return report, nil
+}
+
+// UtilizationReport reports the VLAN number usage of every L2 domain
+func (a *VLANAllocator) UtilizationReport() ([]VLANUtilization, error) {
+	domains, err := a.domains.List()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]VLANUtilization, 0, len(domains))
+	for _, domain := range domains {
+		report, err := a.Utilization(domain.ID)
+		if err != nil {
+			return nil, fmt.Errorf("L2 domain %s: %w", domain.Name, err)
+		}
+		report.DomainName = domain.Name
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
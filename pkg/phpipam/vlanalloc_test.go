@@ -0,0 +1,158 @@
+package phpipam
+
+import (
+	"strings"
+	"testing"
+)
+
+// vlanRanges formats VLAN ranges as a comma-separated list
+func vlanRanges(ranges []VLANRange) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestFreeVLANs(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        VLANRange
+		used     []int
+		reserved []VLANRange
+		want     string
+		wantErr  string
+	}{
+		{
+			name: "empty domain",
+			r:    VLANRange{Min: 100, Max: 110},
+			want: "100-110",
+		},
+		{
+			name:     "used and reserved numbers split the range",
+			r:        VLANRange{Min: 100, Max: 110},
+			used:     []int{100, 103, 103, 4000},
+			reserved: []VLANRange{{Min: 105, Max: 107}, {Min: 1, Max: 1}},
+			want:     "101-102,104,108-110",
+		},
+		{
+			name:     "everything taken",
+			r:        VLANRange{Min: 10, Max: 12},
+			used:     []int{10, 12},
+			reserved: []VLANRange{{Min: 11, Max: 11}},
+		},
+		{
+			name:     "reserved range beyond the bounds",
+			r:        VLANRange{Min: 4090, Max: MaxVLAN},
+			reserved: []VLANRange{{Min: 4093, Max: 5000}},
+			want:     "4090-4092",
+		},
+		{
+			name: "single number",
+			r:    VLANRange{Min: MaxVLAN, Max: MaxVLAN},
+			want: "4094",
+		},
+		{
+			name: "full range",
+			r:    VLANRange{Min: MinVLAN, Max: MaxVLAN},
+			used: []int{1},
+			want: "2-4094",
+		},
+		{
+			name:    "reversed",
+			r:       VLANRange{Min: 200, Max: 100},
+			wantErr: "VLAN range 200-100 ends before it starts",
+		},
+		{
+			name:    "below the first VLAN",
+			r:       VLANRange{Min: 0, Max: 10},
+			wantErr: "VLAN range 0-10 is outside 1-4094",
+		},
+		{
+			name:    "negative",
+			r:       VLANRange{Min: -5, Max: -1},
+			wantErr: "is outside 1-4094",
+		},
+		{
+			name:    "above the last VLAN",
+			r:       VLANRange{Min: 4000, Max: 4095},
+			wantErr: "VLAN range 4000-4095 is outside 1-4094",
+		},
+		{
+			name:    "zero value",
+			wantErr: "VLAN range 0 is outside 1-4094",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			free, err := FreeVLANs(tt.r, tt.used, tt.reserved)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := vlanRanges(free); got != tt.want {
+				t.Errorf("FreeVLANs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVLANRangeSize(t *testing.T) {
+	tests := []struct {
+		r    VLANRange
+		want int
+	}{
+		{VLANRange{Min: 1, Max: 4094}, 4094},
+		{VLANRange{Min: 7, Max: 7}, 1},
+		{VLANRange{Min: 8, Max: 7}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Size(); got != tt.want {
+			t.Errorf("%v.Size() = %d, want %d", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestVLANUtilization(t *testing.T) {
+	api := newTestServer(t, map[string]string{
+		"l2domains/1/vlans": `{"code":200,"success":true,"data":[` +
+			`{"id":"1","domainId":"1","number":"100"},{"id":"2","domainId":"1","number":"102"},` +
+			`{"id":"3","domainId":"1","number":"102"},{"id":"4","domainId":"1","number":"3000"}]}`,
+		"l2domains/2/vlans": `{"code":200,"success":true,"data":[{"id":"5","domainId":"2","number":"ten"}]}`,
+	})
+	allocator := api.NewVLANAllocator()
+	allocator.Range = VLANRange{Min: 100, Max: 109}
+	allocator.Reserved = []VLANRange{{Min: 108, Max: 120}}
+
+	report, err := allocator.Utilization("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Used != 2 || report.Reserved != 2 || report.Free != 6 {
+		t.Errorf("used %d, reserved %d, free %d, want 2, 2, 6", report.Used, report.Reserved, report.Free)
+	}
+	if got := vlanRanges(report.FreeRanges); got != "101,103-107" {
+		t.Errorf("FreeRanges = %s, want 101,103-107", got)
+	}
+	if len(report.OutOfRange) != 1 || report.OutOfRange[0] != 3000 {
+		t.Errorf("OutOfRange = %v, want [3000]", report.OutOfRange)
+	}
+	if got := report.UsedPercent(); got != 25 {
+		t.Errorf("UsedPercent = %v, want 25", got)
+	}
+
+	if _, err := allocator.Utilization("2"); err == nil || !strings.Contains(err.Error(), `invalid VLAN number "ten"`) {
+		t.Errorf("err = %v, want an invalid VLAN number error", err)
+	}
+
+	allocator.Range = VLANRange{Min: 0, Max: 4094}
+	if _, err := allocator.NextFree("1"); err == nil {
+		t.Error("NextFree accepted an allocator range starting at 0")
+	}
+}
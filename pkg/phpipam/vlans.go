@@ -81,6 +81,9 @@ func (v *VLANsService) Create(vlan *VLAN) (*VLAN, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the VLAN data, retrieve the full VLAN
 	if resp.ID != 0 && createdVLAN.ID == "" {
@@ -101,8 +104,27 @@ func (v *VLANsService) Update(vlan *VLAN) (*VLAN, error) {
 	return &updatedVLAN, err
 }
 
+// Patch sends a partial update for a VLAN, including fields set to zero or empty
+func (v *VLANsService) Patch(id string, patch *Patch) error {
+	if id == "" {
+		return fmt.Errorf("VLAN ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("VLAN patch contains no fields")
+	}
+
+	resp, err := v.client.Request("PATCH", fmt.Sprintf("vlan/%s", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
 // Delete deletes a VLAN
 func (v *VLANsService) Delete(id string) error {
-	_, err := v.client.Request("DELETE", fmt.Sprintf("vlan/%s", id), nil, nil)
-	return err
+	resp, err := v.client.Request("DELETE", fmt.Sprintf("vlan/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }
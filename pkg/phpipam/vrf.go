@@ -67,6 +67,9 @@ func (v *VRFsService) Create(vrf *VRF) (*VRF, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
 
 	// If we got an ID in the response but not in the VRF data, retrieve the full VRF
 	if resp.ID != 0 && createdVRF.ID == "" {
@@ -87,8 +90,27 @@ func (v *VRFsService) Update(vrf *VRF) (*VRF, error) {
 	return &updatedVRF, err
 }
 
+// Patch sends a partial update for a VRF, including fields set to zero or empty
+func (v *VRFsService) Patch(id string, patch *Patch) error {
+	if id == "" {
+		return fmt.Errorf("VRF ID is required for patch")
+	}
+	if patch == nil || patch.Len() == 0 {
+		return fmt.Errorf("VRF patch contains no fields")
+	}
+
+	resp, err := v.client.Request("PATCH", fmt.Sprintf("vrf/%s", id), patch, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
 // Delete deletes a VRF
 func (v *VRFsService) Delete(id string) error {
-	_, err := v.client.Request("DELETE", fmt.Sprintf("vrf/%s", id), nil, nil)
-	return err
+	resp, err := v.client.Request("DELETE", fmt.Sprintf("vrf/%s", id), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Err()
 }